@@ -64,6 +64,18 @@ type backendExtra struct {
 	cloudDisks          []*cloudDisk // gcp/aws only
 	gcpMinCpuPlatform   *string      // gcp only - min cpu platform string
 	spotFallback        bool         // aws only - if spot capacity request fails, try on-demand
+	snapshot            string       // all: deploy from a cluster snapshot in place of the template; docker: image, aws: AMI, gcp: comma-separated disk snapshots, boot disk first
+}
+
+// backendSnapshot is a single node snapshot; a cluster snapshot is the set of node snapshots sharing a name
+type backendSnapshot struct {
+	Name         string
+	ClusterName  string
+	Node         int
+	Version      backendVersion
+	ImageId      string // docker: image, aws: AMI, gcp: comma-separated disk snapshots, boot disk first
+	Zone         string // gcp only
+	CreationTime time.Time
 }
 
 type backendVersion struct {
@@ -113,6 +125,8 @@ type backend interface {
 	ExpiriesSystemRemove(region string) error
 	ExpiriesSystemFrequency(intervalMinutes int) error
 	ClusterExpiry(zone string, clusterName string, expiry time.Duration, nodes []int) error
+	// records the aerospike version installed on the given cluster nodes in their tags/labels, as shown by inventory
+	SetNodeVersion(clusterName string, nodes []int, aerospikeVersion string) error
 	// returns whether the given system is arm (using instanceType)
 	IsSystemArm(systemType string) (bool, error)
 	// check if given node is ARM or not
@@ -195,6 +209,10 @@ type backend interface {
 	ExpiriesUpdateZoneID(zoneId string) error
 	GetInstanceTags(name string) (map[string]map[string]string, error)
 	Tag(name string, key string, value string) error
+	// snapshots; docker: commit of node containers, aws: AMI with EBS snapshots, gcp: disk snapshots
+	SnapshotCreate(snapshotName string, clusterName string, nodes []int, versions map[int]backendVersion) error
+	SnapshotList() ([]backendSnapshot, error)
+	SnapshotDestroy(snapshotName string) error
 }
 
 type inventoryJson struct {
@@ -491,6 +509,9 @@ type instanceType struct {
 // check return code from exec function
 func checkExecRetcode(err error) int {
 	if err != nil {
+		if cerr, ok := err.(*containerExitError); ok {
+			return cerr.ExitCode
+		}
 		exiterr, ok := err.(*exec.ExitError)
 		if !ok {
 			return 666
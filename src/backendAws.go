@@ -666,6 +666,34 @@ func (d *backendAws) ClusterExpiry(zone string, clusterName string, expiry time.
 	return err
 }
 
+func (d *backendAws) SetNodeVersion(clusterName string, nodes []int, aerospikeVersion string) error {
+	j, err := d.Inventory("", []int{InventoryItemClusters})
+	d.WorkOnServers()
+	if err != nil {
+		return err
+	}
+	var instances []string
+	for _, jj := range j.Clusters {
+		nodeNo, _ := strconv.Atoi(jj.NodeNo)
+		if jj.ClusterName == clusterName && (len(nodes) == 0 || inslice.HasInt(nodes, nodeNo)) {
+			instances = append(instances, jj.InstanceId)
+		}
+	}
+	if len(instances) == 0 {
+		return errors.New("not found any instances for the given name")
+	}
+	_, err = d.ec2svc.CreateTags(&ec2.CreateTagsInput{
+		Resources: aws.StringSlice(instances),
+		Tags: []*ec2.Tag{
+			{
+				Key:   aws.String(awsServerTagAerospikeVersion),
+				Value: aws.String(aerospikeVersion),
+			},
+		},
+	})
+	return err
+}
+
 var awsExpiryPolicies = []string{"arn:aws:iam::aws:policy/AmazonEC2FullAccess", "arn:aws:iam::aws:policy/AmazonElasticFileSystemFullAccess", "arn:aws:iam::aws:policy/AWSCloudFormationFullAccess"}
 
 func (d *backendAws) ExpiriesSystemRemove(region string) error {
@@ -3117,13 +3145,13 @@ func (d *backendAws) DeployCluster(v backendVersion, name string, nodeCount int,
 		})
 	}
 	hostType := extra.instanceType
-	if len(strings.Trim(extra.ebs, "\t\r\n ")) == 0 {
+	if len(strings.Trim(extra.ebs, "\t\r\n ")) == 0 && extra.snapshot == "" {
 		return errors.New("root disk size must be specified")
 	}
 	disks := strings.Split(extra.ebs, ",")
 	disksInt := extra.cloudDisks
-	// below IF block is for parsing old format of disk definitions aand defaults
-	if len(disksInt) == 0 {
+	// below IF block is for parsing old format of disk definitions aand defaults; snapshots bring their own disk mappings
+	if len(disksInt) == 0 && extra.snapshot == "" {
 		for _, disk := range disks {
 			dint, err := strconv.Atoi(disk)
 			if err != nil {
@@ -3138,7 +3166,7 @@ func (d *backendAws) DeployCluster(v backendVersion, name string, nodeCount int,
 
 	templateId := ""
 	var myImage *ec2.Image
-	if !d.client {
+	if !d.client && extra.snapshot == "" {
 		filterA := ec2.DescribeImagesInput{
 			Filters: []*ec2.Filter{
 				{
@@ -3183,7 +3211,9 @@ func (d *backendAws) DeployCluster(v backendVersion, name string, nodeCount int,
 		}
 	} else {
 		var err error
-		if extra.ami != "" {
+		if extra.snapshot != "" {
+			templateId = extra.snapshot
+		} else if extra.ami != "" {
 			templateId = extra.ami
 		} else {
 			templateId, err = d.getAmi(a.opts.Config.Backend.Region, v)
@@ -3352,7 +3382,11 @@ func (d *backendAws) DeployCluster(v backendVersion, name string, nodeCount int,
 		// number of EBS volumes of the right size
 		// resolve EBS mapping first, to correctly handle splitting of disks
 		bdms := []*ec2.BlockDeviceMapping{}
-		if myImage.RootDeviceType != nil && myImage.RootDeviceName != nil && *myImage.RootDeviceType == ec2.RootDeviceTypeEbs {
+		mappings := ""
+		if extra.snapshot != "" {
+			// use the disk mappings of the snapshot AMI as-is
+			disksInt = nil
+		} else if myImage.RootDeviceType != nil && myImage.RootDeviceName != nil && *myImage.RootDeviceType == ec2.RootDeviceTypeEbs {
 			var iops *int64
 			var throughput *int64
 			if disksInt[0].ProvisionedIOPS > 0 {
@@ -3375,7 +3409,9 @@ func (d *backendAws) DeployCluster(v backendVersion, name string, nodeCount int,
 				},
 			}
 		}
-		mappings := fmt.Sprintf("Devices: type=%s size=%d iops=%d throughput=%d device=%s\n", disksInt[0].Type, disksInt[0].Size, disksInt[0].ProvisionedIOPS, disksInt[0].ProvisionedThroughput, aws.StringValue(myImage.RootDeviceName))
+		if len(disksInt) > 0 {
+			mappings = fmt.Sprintf("Devices: type=%s size=%d iops=%d throughput=%d device=%s\n", disksInt[0].Type, disksInt[0].Size, disksInt[0].ProvisionedIOPS, disksInt[0].ProvisionedThroughput, aws.StringValue(myImage.RootDeviceName))
+		}
 		avnames := "bcdefghijklmnopqrstuvwxyz"
 		for i, av := range disksInt {
 			if i == 0 {
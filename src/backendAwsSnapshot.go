@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	awsTagSnapshotName    = "Aerolab4SnapshotName"
+	awsTagSnapshotCluster = "Aerolab4SnapshotClusterName"
+	awsTagSnapshotNode    = "Aerolab4SnapshotNodeNumber"
+)
+
+// SnapshotCreate creates an AMI of each node; the AMI holds EBS snapshots of all attached volumes
+func (d *backendAws) SnapshotCreate(snapshotName string, clusterName string, nodes []int, versions map[int]backendVersion) error {
+	if d.client {
+		return errors.New("snapshots are only supported for server clusters")
+	}
+	instances, err := d.ec2svc.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag:" + awsTagClusterName),
+				Values: []*string{aws.String(clusterName)},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not run DescribeInstances\n%s", err)
+	}
+	instanceIds := make(map[int]*string)
+	for _, reservation := range instances.Reservations {
+		for _, instance := range reservation.Instances {
+			if *instance.State.Code == int64(48) {
+				continue
+			}
+			for _, tag := range instance.Tags {
+				if *tag.Key != awsTagNodeNumber {
+					continue
+				}
+				node, err := strconv.Atoi(*tag.Value)
+				if err != nil {
+					return errors.New("problem with node numbers in the given cluster. Investigate manually")
+				}
+				instanceIds[node] = instance.InstanceId
+			}
+		}
+	}
+	imageIds := []*string{}
+	for _, node := range nodes {
+		instanceId, ok := instanceIds[node]
+		if !ok {
+			return fmt.Errorf("node %d not found in cluster %s", node, clusterName)
+		}
+		v, ok := versions[node]
+		if !ok {
+			return fmt.Errorf("version of node %d is not known", node)
+		}
+		arch := "amd"
+		if v.isArm {
+			arch = "arm"
+		}
+		tags := []*ec2.Tag{
+			{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("aerolab4-snapshot-%s_%s_%d", snapshotName, clusterName, node))},
+			{Key: aws.String(awsTagSnapshotName), Value: aws.String(snapshotName)},
+			{Key: aws.String(awsTagSnapshotCluster), Value: aws.String(clusterName)},
+			{Key: aws.String(awsTagSnapshotNode), Value: aws.String(strconv.Itoa(node))},
+			{Key: aws.String(awsServerTagOperatingSystem), Value: aws.String(v.distroName)},
+			{Key: aws.String(awsServerTagOSVersion), Value: aws.String(v.distroVersion)},
+			{Key: aws.String(awsServerTagAerospikeVersion), Value: aws.String(v.aerospikeVersion)},
+			{Key: aws.String("Arch"), Value: aws.String(arch)},
+		}
+		log.Printf("Creating AMI of node %d", node)
+		out, err := d.ec2svc.CreateImage(&ec2.CreateImageInput{
+			InstanceId: instanceId,
+			Name:       aws.String(fmt.Sprintf("aerolab4-snapshot-%s_%s_%d", snapshotName, clusterName, node)),
+			NoReboot:   aws.Bool(true),
+			TagSpecifications: []*ec2.TagSpecification{
+				{ResourceType: aws.String(ec2.ResourceTypeImage), Tags: tags},
+				{ResourceType: aws.String(ec2.ResourceTypeSnapshot), Tags: tags},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("node %d: error creating AMI: %s", node, err)
+		}
+		imageIds = append(imageIds, out.ImageId)
+	}
+	log.Print("Waiting for AMIs to become available")
+	return d.ec2svc.WaitUntilImageAvailable(&ec2.DescribeImagesInput{
+		ImageIds: imageIds,
+	})
+}
+
+func (d *backendAws) snapshotImages() ([]*ec2.Image, error) {
+	images, err := d.ec2svc.DescribeImages(&ec2.DescribeImagesInput{
+		Owners: []*string{aws.String("self")},
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag-key"),
+				Values: []*string{aws.String(awsTagSnapshotName)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not run DescribeImages\n%s", err)
+	}
+	return images.Images, nil
+}
+
+func (d *backendAws) SnapshotList() ([]backendSnapshot, error) {
+	images, err := d.snapshotImages()
+	if err != nil {
+		return nil, err
+	}
+	list := []backendSnapshot{}
+	for _, image := range images {
+		snap := backendSnapshot{
+			ImageId: aws.StringValue(image.ImageId),
+		}
+		for _, tag := range image.Tags {
+			switch aws.StringValue(tag.Key) {
+			case awsTagSnapshotName:
+				snap.Name = aws.StringValue(tag.Value)
+			case awsTagSnapshotCluster:
+				snap.ClusterName = aws.StringValue(tag.Value)
+			case awsTagSnapshotNode:
+				snap.Node, _ = strconv.Atoi(aws.StringValue(tag.Value))
+			case awsServerTagOperatingSystem:
+				snap.Version.distroName = aws.StringValue(tag.Value)
+			case awsServerTagOSVersion:
+				snap.Version.distroVersion = aws.StringValue(tag.Value)
+			case awsServerTagAerospikeVersion:
+				snap.Version.aerospikeVersion = aws.StringValue(tag.Value)
+			}
+		}
+		snap.Version.isArm = strings.Contains(aws.StringValue(image.Architecture), "arm")
+		snap.CreationTime, _ = time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+		list = append(list, snap)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Node < list[j].Node
+	})
+	return list, nil
+}
+
+// SnapshotDestroy deregisters the AMIs and removes their backing EBS snapshots
+func (d *backendAws) SnapshotDestroy(snapshotName string) error {
+	images, err := d.snapshotImages()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, image := range images {
+		isSnap := false
+		for _, tag := range image.Tags {
+			if aws.StringValue(tag.Key) == awsTagSnapshotName && aws.StringValue(tag.Value) == snapshotName {
+				isSnap = true
+			}
+		}
+		if !isSnap {
+			continue
+		}
+		found = true
+		_, err = d.ec2svc.DeregisterImage(&ec2.DeregisterImageInput{
+			ImageId: image.ImageId,
+		})
+		if err != nil {
+			return fmt.Errorf("could not deregister image %s: %s", aws.StringValue(image.ImageId), err)
+		}
+		for _, bdm := range image.BlockDeviceMappings {
+			if bdm.Ebs == nil || bdm.Ebs.SnapshotId == nil {
+				continue
+			}
+			_, err = d.ec2svc.DeleteSnapshot(&ec2.DeleteSnapshotInput{
+				SnapshotId: bdm.Ebs.SnapshotId,
+			})
+			if err != nil {
+				log.Printf("Failed to delete snapshot ID=%s %s", *bdm.Ebs.SnapshotId, err)
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("snapshot %s not found", snapshotName)
+	}
+	return nil
+}
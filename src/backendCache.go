@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backendCache wraps a backend, serving the read-only listing calls from a local state file in the aerolab root directory;
+// calls which change the lab invalidate the cache, entries older than the configured TTL are refreshed from the backend
+type backendCache struct {
+	backend
+	ttl      time.Duration
+	refresh  bool
+	fileName string
+	scope    string
+	servers  bool
+	lock     sync.Mutex
+}
+
+type backendCacheState struct {
+	InvalidatedAt map[string]time.Time // per backend scope
+	Entries       map[string]*backendCacheEntry
+}
+
+type backendCacheEntry struct {
+	Fetched time.Time
+	Data    json.RawMessage
+}
+
+// inventory with the unexported fields which do not survive json
+type backendCacheInventory struct {
+	Inventory inventoryJson
+	Clusters  []backendCacheAws
+	Clients   []backendCacheAws
+}
+
+type backendCacheAws struct {
+	Subnet    string
+	SecGroups []string
+}
+
+func newBackendCache(back backend) (*backendCache, error) {
+	rootDir, err := a.aerolabRootDir()
+	if err != nil {
+		return nil, err
+	}
+	c := &backendCache{
+		backend:  back,
+		ttl:      a.opts.Config.Backend.CacheTTL,
+		refresh:  a.opts.Refresh,
+		fileName: path.Join(rootDir, "state-cache.json"),
+		servers:  true,
+	}
+	// the same root directory may be used with different backends, regions and projects
+	switch a.opts.Config.Backend.Type {
+	case "aws":
+		c.scope = "aws:" + a.opts.Config.Backend.AWSProfile + ":" + a.opts.Config.Backend.Region
+	case "gcp":
+		c.scope = "gcp:" + a.opts.Config.Backend.Project
+	case "docker":
+		c.scope = "docker:" + a.opts.Config.Backend.ContainerRuntime + ":" + string(a.opts.Config.Backend.ContainerSocket) + ":" + os.Getenv("DOCKER_HOST") + ":" + dockerContextName()
+	default:
+		c.scope = a.opts.Config.Backend.Type
+	}
+	return c, nil
+}
+
+func (c *backendCache) load() *backendCacheState {
+	state := &backendCacheState{}
+	data, err := os.ReadFile(c.fileName)
+	if err == nil {
+		json.Unmarshal(data, state)
+	}
+	if state.Entries == nil {
+		state.Entries = make(map[string]*backendCacheEntry)
+	}
+	if state.InvalidatedAt == nil {
+		state.InvalidatedAt = make(map[string]time.Time)
+	}
+	return state
+}
+
+// save writes the state atomically, so that parallel aerolab processes never see a partial file
+func (c *backendCache) save(state *backendCacheState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	tmp := c.fileName + "." + strconv.Itoa(os.Getpid())
+	if os.WriteFile(tmp, data, 0600) != nil {
+		os.Remove(tmp)
+		return
+	}
+	if os.Rename(tmp, c.fileName) != nil {
+		os.Remove(tmp)
+	}
+}
+
+// lockFile serializes the read-modify-write of the state file between goroutines and between parallel aerolab processes
+func (c *backendCache) lockFile() (unlock func(), err error) {
+	c.lock.Lock()
+	unlockFile, err := lockFile(c.fileName + ".lock")
+	if err != nil {
+		c.lock.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile()
+		c.lock.Unlock()
+	}, nil
+}
+
+func (c *backendCache) key(item string) string {
+	mode := "servers"
+	if !c.servers {
+		mode = "clients"
+	}
+	return c.scope + "|" + mode + "|" + item
+}
+
+// get returns true if a valid cached entry was decoded into out
+func (c *backendCache) get(item string, out interface{}) bool {
+	if c.refresh {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	state := c.load()
+	entry, ok := state.Entries[c.key(item)]
+	if !ok || time.Since(entry.Fetched) > c.ttl || entry.Fetched.Before(state.InvalidatedAt[c.scope]) {
+		return false
+	}
+	return json.Unmarshal(entry.Data, out) == nil
+}
+
+// put stores an entry fetched at the given time; entries fetched before a concurrent invalidation are dropped
+func (c *backendCache) put(item string, fetched time.Time, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	unlock, err := c.lockFile()
+	if err != nil {
+		return
+	}
+	defer unlock()
+	state := c.load()
+	if fetched.Before(state.InvalidatedAt[c.scope]) {
+		return
+	}
+	for k, entry := range state.Entries {
+		if time.Since(entry.Fetched) > c.ttl {
+			delete(state.Entries, k)
+		}
+	}
+	state.Entries[c.key(item)] = &backendCacheEntry{
+		Fetched: fetched,
+		Data:    raw,
+	}
+	c.save(state)
+}
+
+// invalidate drops all entries of this backend scope; called after any call which changes the lab
+func (c *backendCache) invalidate() {
+	unlock, err := c.lockFile()
+	if err != nil {
+		// the cache must not serve stale entries, so invalidate without the lock rather than not at all
+		c.lock.Lock()
+		defer c.lock.Unlock()
+	} else {
+		defer unlock()
+	}
+	state := c.load()
+	for k := range state.Entries {
+		if strings.HasPrefix(k, c.scope+"|") {
+			delete(state.Entries, k)
+		}
+	}
+	state.InvalidatedAt[c.scope] = time.Now()
+	c.save(state)
+}
+
+func (c *backendCache) WorkOnClients() {
+	c.servers = false
+	c.backend.WorkOnClients()
+}
+
+func (c *backendCache) WorkOnServers() {
+	c.servers = true
+	c.backend.WorkOnServers()
+}
+
+func (c *backendCache) ClusterList() ([]string, error) {
+	ret := []string{}
+	if c.get("clusterList", &ret) {
+		return ret, nil
+	}
+	fetched := time.Now()
+	ret, err := c.backend.ClusterList()
+	if err == nil {
+		c.put("clusterList", fetched, ret)
+	}
+	return ret, err
+}
+
+func (c *backendCache) NodeListInCluster(name string) ([]int, error) {
+	ret := []int{}
+	if c.get("nodeList:"+name, &ret) {
+		return ret, nil
+	}
+	fetched := time.Now()
+	ret, err := c.backend.NodeListInCluster(name)
+	if err == nil {
+		c.put("nodeList:"+name, fetched, ret)
+	}
+	return ret, err
+}
+
+func (c *backendCache) GetNodeIpMap(name string, internalIPs bool) (map[int]string, error) {
+	item := fmt.Sprintf("nodeIpMap:%s:%t", name, internalIPs)
+	ret := make(map[int]string)
+	if c.get(item, &ret) {
+		return ret, nil
+	}
+	fetched := time.Now()
+	ret, err := c.backend.GetNodeIpMap(name, internalIPs)
+	if err == nil {
+		c.put(item, fetched, ret)
+	}
+	return ret, err
+}
+
+func (c *backendCache) GetClusterNodeIps(name string) ([]string, error) {
+	ret := []string{}
+	if c.get("clusterNodeIps:"+name, &ret) {
+		return ret, nil
+	}
+	fetched := time.Now()
+	ret, err := c.backend.GetClusterNodeIps(name)
+	if err == nil {
+		c.put("clusterNodeIps:"+name, fetched, ret)
+	}
+	return ret, err
+}
+
+func (c *backendCache) GetInstanceTags(name string) (map[string]map[string]string, error) {
+	ret := make(map[string]map[string]string)
+	if c.get("instanceTags:"+name, &ret) {
+		return ret, nil
+	}
+	fetched := time.Now()
+	ret, err := c.backend.GetInstanceTags(name)
+	if err == nil {
+		c.put("instanceTags:"+name, fetched, ret)
+	}
+	return ret, err
+}
+
+func (c *backendCache) Inventory(owner string, inventoryItems []int) (inventoryJson, error) {
+	item := "inventory:" + owner + ":" + intSliceToString(inventoryItems, ",")
+	cached := &backendCacheInventory{}
+	if c.get(item, cached) {
+		for i := range cached.Inventory.Clusters {
+			if i < len(cached.Clusters) {
+				cached.Inventory.Clusters[i].awsSubnet = cached.Clusters[i].Subnet
+				cached.Inventory.Clusters[i].awsSecGroups = cached.Clusters[i].SecGroups
+			}
+		}
+		for i := range cached.Inventory.Clients {
+			if i < len(cached.Clients) {
+				cached.Inventory.Clients[i].awsSubnet = cached.Clients[i].Subnet
+				cached.Inventory.Clients[i].awsSecGroups = cached.Clients[i].SecGroups
+			}
+		}
+		return cached.Inventory, nil
+	}
+	// the docker backend switches between servers and clients while listing
+	servers := c.servers
+	fetched := time.Now()
+	inv, err := c.backend.Inventory(owner, inventoryItems)
+	if servers {
+		c.WorkOnServers()
+	} else {
+		c.WorkOnClients()
+	}
+	if err != nil {
+		return inv, err
+	}
+	cached = &backendCacheInventory{Inventory: inv}
+	for _, cl := range inv.Clusters {
+		cached.Clusters = append(cached.Clusters, backendCacheAws{Subnet: cl.awsSubnet, SecGroups: cl.awsSecGroups})
+	}
+	for _, cl := range inv.Clients {
+		cached.Clients = append(cached.Clients, backendCacheAws{Subnet: cl.awsSubnet, SecGroups: cl.awsSecGroups})
+	}
+	c.put(item, fetched, cached)
+	return inv, nil
+}
+
+// calls which change the lab
+
+func (c *backendCache) DeployTemplate(v backendVersion, script string, files []fileListReader, extra *backendExtra) error {
+	defer c.invalidate()
+	return c.backend.DeployTemplate(v, script, files, extra)
+}
+
+func (c *backendCache) TemplateDestroy(v backendVersion) error {
+	defer c.invalidate()
+	return c.backend.TemplateDestroy(v)
+}
+
+func (c *backendCache) VacuumTemplates() error {
+	defer c.invalidate()
+	return c.backend.VacuumTemplates()
+}
+
+func (c *backendCache) VacuumTemplate(v backendVersion) error {
+	defer c.invalidate()
+	return c.backend.VacuumTemplate(v)
+}
+
+func (c *backendCache) DeployCluster(v backendVersion, name string, nodeCount int, extra *backendExtra) error {
+	defer c.invalidate()
+	return c.backend.DeployCluster(v, name, nodeCount, extra)
+}
+
+func (c *backendCache) ClusterStart(name string, nodes []int) error {
+	defer c.invalidate()
+	return c.backend.ClusterStart(name, nodes)
+}
+
+func (c *backendCache) ClusterStop(name string, nodes []int) error {
+	defer c.invalidate()
+	return c.backend.ClusterStop(name, nodes)
+}
+
+func (c *backendCache) ClusterDestroy(name string, nodes []int) error {
+	defer c.invalidate()
+	return c.backend.ClusterDestroy(name, nodes)
+}
+
+func (c *backendCache) ClusterExpiry(zone string, clusterName string, expiry time.Duration, nodes []int) error {
+	defer c.invalidate()
+	return c.backend.ClusterExpiry(zone, clusterName, expiry, nodes)
+}
+
+func (c *backendCache) SetNodeVersion(clusterName string, nodes []int, aerospikeVersion string) error {
+	defer c.invalidate()
+	return c.backend.SetNodeVersion(clusterName, nodes, aerospikeVersion)
+}
+
+func (c *backendCache) SetLabel(clusterName string, key string, value string, gcpZone string) error {
+	defer c.invalidate()
+	return c.backend.SetLabel(clusterName, key, value, gcpZone)
+}
+
+func (c *backendCache) Tag(name string, key string, value string) error {
+	defer c.invalidate()
+	return c.backend.Tag(name, key, value)
+}
+
+func (c *backendCache) CreateVolume(name string, zone string, tags []string, expires time.Duration, size int64, desc string) error {
+	defer c.invalidate()
+	return c.backend.CreateVolume(name, zone, tags, expires, size, desc)
+}
+
+func (c *backendCache) TagVolume(fsId string, tagName string, tagValue string, zone string) error {
+	defer c.invalidate()
+	return c.backend.TagVolume(fsId, tagName, tagValue, zone)
+}
+
+func (c *backendCache) DeleteVolume(name string, zone string) error {
+	defer c.invalidate()
+	return c.backend.DeleteVolume(name, zone)
+}
+
+func (c *backendCache) CreateMountTarget(volume *inventoryVolume, subnet string, secGroups []string) (inventoryMountTarget, error) {
+	defer c.invalidate()
+	return c.backend.CreateMountTarget(volume, subnet, secGroups)
+}
+
+func (c *backendCache) MountTargetAddSecurityGroup(mountTarget *inventoryMountTarget, volume *inventoryVolume, addGroups []string) error {
+	defer c.invalidate()
+	return c.backend.MountTargetAddSecurityGroup(mountTarget, volume, addGroups)
+}
+
+func (c *backendCache) AttachVolume(name string, zone string, clusterName string, node int) error {
+	defer c.invalidate()
+	return c.backend.AttachVolume(name, zone, clusterName, node)
+}
+
+func (c *backendCache) ResizeVolume(name string, zone string, newSize int64) error {
+	defer c.invalidate()
+	return c.backend.ResizeVolume(name, zone, newSize)
+}
+
+func (c *backendCache) DetachVolume(name string, clusterName string, node int, zone string) error {
+	defer c.invalidate()
+	return c.backend.DetachVolume(name, clusterName, node, zone)
+}
+
+func (c *backendCache) ExpiriesSystemInstall(intervalMinutes int, deployRegion string, awsDnsZoneId string, withEks bool) error {
+	defer c.invalidate()
+	return c.backend.ExpiriesSystemInstall(intervalMinutes, deployRegion, awsDnsZoneId, withEks)
+}
+
+func (c *backendCache) ExpiriesSystemRemove(region string) error {
+	defer c.invalidate()
+	return c.backend.ExpiriesSystemRemove(region)
+}
+
+func (c *backendCache) ExpiriesSystemFrequency(intervalMinutes int) error {
+	defer c.invalidate()
+	return c.backend.ExpiriesSystemFrequency(intervalMinutes)
+}
+
+func (c *backendCache) DeleteSecurityGroups(vpc string, namePrefix string, internal bool) error {
+	defer c.invalidate()
+	return c.backend.DeleteSecurityGroups(vpc, namePrefix, internal)
+}
+
+func (c *backendCache) CreateSecurityGroups(vpc string, namePrefix string, isAgi bool, extraPorts []string, noDefaults bool) error {
+	defer c.invalidate()
+	return c.backend.CreateSecurityGroups(vpc, namePrefix, isAgi, extraPorts, noDefaults)
+}
+
+func (c *backendCache) LockSecurityGroups(ip string, lockSSH bool, vpc string, namePrefix string, isAgi bool, extraPorts []string, noDefaults bool) error {
+	defer c.invalidate()
+	return c.backend.LockSecurityGroups(ip, lockSSH, vpc, namePrefix, isAgi, extraPorts, noDefaults)
+}
+
+func (c *backendCache) AssignSecurityGroups(clusterName string, names []string, vpcOrZone string, remove bool, performLocking bool, extraPorts []string, noDefaults bool) error {
+	defer c.invalidate()
+	return c.backend.AssignSecurityGroups(clusterName, names, vpcOrZone, remove, performLocking, extraPorts, noDefaults)
+}
+
+func (c *backendCache) CreateNetwork(name string, driver string, subnet string, mtu string) error {
+	defer c.invalidate()
+	return c.backend.CreateNetwork(name, driver, subnet, mtu)
+}
+
+func (c *backendCache) DeleteNetwork(name string) error {
+	defer c.invalidate()
+	return c.backend.DeleteNetwork(name)
+}
+
+func (c *backendCache) PruneNetworks() error {
+	defer c.invalidate()
+	return c.backend.PruneNetworks()
+}
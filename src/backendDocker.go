@@ -3,16 +3,12 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/bestmethod/inslice"
@@ -20,10 +16,10 @@ import (
 )
 
 type backendDocker struct {
-	server    bool
-	client    bool
-	isArm     bool
-	dockerCmd string
+	server bool
+	client bool
+	isArm  bool
+	rt     containerRuntime
 }
 
 func init() {
@@ -156,6 +152,11 @@ func (d *backendDocker) Inventory(owner string, inventoryItems []int) (inventory
 		}
 	}
 
+	// versions recorded by aerospike upgrade override the version of the image the container was created from
+	nodeVersions, err := dockerNodeVersionsLoad()
+	if err != nil {
+		return ij, err
+	}
 	nCheckList := []int{}
 	if inslice.HasInt(inventoryItems, InventoryItemClusters) {
 		nCheckList = []int{1}
@@ -169,229 +170,118 @@ func (d *backendDocker) Inventory(owner string, inventoryItems []int) (inventory
 		} else {
 			d.WorkOnClients()
 		}
-		out, err := exec.Command("docker", "container", "list", "-a", "--format", "{{.ID}}\t{{.Names}}\t{{.Status}}\t{{.Image}}\t{{.Label \"aerolab.client.type\"}}\t{{.Ports}}").CombinedOutput()
+		listOpts := &containerListOpts{All: true, NamePrefix: dockerNameHeader, Detail: true}
+		if owner != "" {
+			listOpts.Labels = map[string]string{"owner": owner}
+		}
+		containers, err := d.rt.ContainerList(listOpts)
 		if err != nil {
 			return ij, err
 		}
-		scanner := bufio.NewScanner(strings.NewReader(string(out)))
-		lineWait := new(sync.WaitGroup)
-		var lineError error
-		lineErrorLock := new(sync.Mutex)
-		invLock := new(sync.Mutex)
-		rateLimit := make(chan struct{}, 5)
-		for scanner.Scan() {
-			t := scanner.Text()
-			lineWait.Add(1)
-			rateLimit <- struct{}{}
-			go func(t string) {
-				defer func() {
-					<-rateLimit
-					lineWait.Done()
-				}()
-				t = strings.Trim(t, "'\" \t\r\n")
-				tt := strings.Split(t, "\t")
-				if len(tt) < 4 || len(tt) > 6 {
-					return
-				}
-				if !strings.HasPrefix(tt[1], dockerNameHeader) {
-					return
-				}
-				nameNo := strings.Split(strings.TrimPrefix(tt[1], dockerNameHeader+""), "_")
-				if len(nameNo) < 2 {
-					return
-				}
-				nno := nameNo[len(nameNo)-1]
-				nname := strings.Join(nameNo[0:len(nameNo)-1], "_")
-				nameNo = []string{nname, nno}
-				outl, err := exec.Command("docker", "container", "inspect", "--format", "{{json .Config.Labels}}", tt[1]).CombinedOutput()
-				if err != nil {
-					lineErrorLock.Lock()
-					lineError = fmt.Errorf("%s: %s", err, string(outl))
-					lineErrorLock.Unlock()
-					return
-				}
-				allLabels := make(map[string]string)
-				err = json.Unmarshal(outl, &allLabels)
-				if err != nil {
-					lineErrorLock.Lock()
-					lineError = err
-					lineErrorLock.Unlock()
-					return
-				}
-				ip := ""
-				exposePort := ""
-				intPort := ""
-				if d.dockerCmd == "docker" {
-					out2, err := exec.Command("docker", "container", "inspect", "--format", "{{json .Config.ExposedPorts}} {{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", tt[1]).CombinedOutput()
-					if err != nil {
-						lineErrorLock.Lock()
-						lineError = fmt.Errorf("%s: %s", err, string(out2))
-						lineErrorLock.Unlock()
-						return
-					}
-					ipport := strings.Split(strings.Trim(string(out2), "'\" \n\r"), " ")
-					for _, it := range ipport {
-						if strings.HasPrefix(it, "{") {
-							nports := make(map[string]interface{})
-							err = json.Unmarshal([]byte(it), &nports)
-							if err == nil {
-								for k := range nports {
-									k = strings.Split(k, "/")[0]
-									exposePort = k
-									intPort = k
-								}
-							}
-						} else if it != "null" && it != "" {
-							ip = it
-							break
-						}
-					}
-				} else {
-					out2, err := exec.Command("podman", "container", "inspect", "--format", `{{json .NetworkSettings.Ports}} {{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}`, tt[1]).CombinedOutput()
-					if err != nil {
-						lineErrorLock.Lock()
-						lineError = fmt.Errorf("%s: %s", err, string(out2))
-						lineErrorLock.Unlock()
-						return
-					}
-					ipport := strings.Split(strings.Trim(string(out2), "'\" \n\r"), " ")
-					for _, it := range ipport {
-						if strings.HasPrefix(it, "{") {
-							nports := make(map[string]interface{})
-							err = json.Unmarshal([]byte(it), &nports)
-							if err == nil {
-								for k, v := range nports {
-									intPort = strings.Split(k, "/")[0]
-									switch vv := v.(type) {
-									case []interface{}:
-										if len(vv) == 0 {
-											continue
-										}
-										vv0 := vv[0]
-										switch vv0m := vv0.(type) {
-										case map[string]interface{}:
-											k, ok := vv0m["HostPort"]
-											if ok {
-												exposePort = k.(string)
-											}
-										}
-									}
-									if exposePort != "" {
-										break
-									}
-								}
-							}
-						} else if it != "null" && it != "" {
-							ip = it
-							break
-						}
-					}
-				}
-				arch := "amd64"
-				if d.isArm {
-					arch = "arm64"
-				}
-				var i1, asdVer string
-				var i2 []string
-				i3 := []string{""}
-				tt[3] = strings.TrimPrefix(tt[3], "localhost/")
-				if i == 1 {
-					i1 = strings.TrimPrefix(tt[3], "aerolab-")
-					i2 = strings.Split(i1, "_")
-					if len(i2) > 1 {
-						i3 = strings.Split(i2[1], ":")
-					}
-					i4 := strings.Split(tt[3], ":")
-					if len(i4) > 1 {
-						asdVer = i4[1]
-					}
-				} else {
-					i2 = strings.Split(tt[3], ":")
-					if len(i2) > 1 {
-						i3[0] = i2[1]
-					}
-					ix := strings.Split(i2[0], "/")
-					if len(ix) > 1 {
-						i2[0] = ix[1]
-						arch = ix[0]
-					}
-				}
-				clientType := ""
-				if len(tt) > 4 {
-					clientType = tt[4]
+		for _, container := range containers {
+			nameNo := strings.Split(strings.TrimPrefix(container.Name, dockerNameHeader), "_")
+			if len(nameNo) < 2 {
+				continue
+			}
+			nno := nameNo[len(nameNo)-1]
+			nname := strings.Join(nameNo[0:len(nameNo)-1], "_")
+			nameNo = []string{nname, nno}
+			allLabels := container.Labels
+			ip := strings.Join(container.IPs, " ")
+			exposePorts := ""
+			intPorts := ""
+			// published ports are sorted first
+			if len(container.Ports) > 0 {
+				intPorts = container.Ports[0].ContainerPort
+				exposePorts = container.Ports[0].HostPort
+				if exposePorts == "" {
+					exposePorts = intPorts
 				}
-				exposePorts := ""
-				intPorts := ""
-				if len(tt) > 5 {
-					ep1 := strings.Split(tt[5], "->")
-					if len(ep1) > 1 {
-						ep2 := strings.Split(ep1[0], ":")
-						if len(ep2) > 1 {
-							exposePorts = ep2[1]
-						}
-						ep2 = strings.Split(ep1[1], "/")
-						intPorts = ep2[0]
-					}
+			}
+			arch := "amd64"
+			if d.isArm {
+				arch = "arm64"
+			}
+			var i1, asdVer string
+			var i2 []string
+			i3 := []string{""}
+			image := strings.TrimPrefix(container.Image, "localhost/")
+			if snap, ok := dockerSnapshotParse(image); ok && i == 1 {
+				// restored from a snapshot
+				i2 = []string{snap.Version.distroName}
+				i3 = []string{snap.Version.distroVersion}
+				asdVer = snap.Version.aerospikeVersion
+			} else if i == 1 {
+				i1 = strings.TrimPrefix(image, "aerolab-")
+				i2 = strings.Split(i1, "_")
+				if len(i2) > 1 {
+					i3 = strings.Split(i2[1], ":")
 				}
-				if exposePorts == "" {
-					exposePorts = exposePort
+				i4 := strings.Split(image, ":")
+				if len(i4) > 1 {
+					asdVer = i4[1]
 				}
-				if intPorts == "" {
-					intPorts = intPort
+			} else {
+				i2 = strings.Split(image, ":")
+				if len(i2) > 1 {
+					i3[0] = i2[1]
 				}
-				if strings.Contains(tt[3], "_amd64") {
-					arch = "amd64"
-				} else if strings.Contains(tt[3], "_arm64") {
-					arch = "arm64"
+				ix := strings.Split(i2[0], "/")
+				if len(ix) > 1 {
+					i2[0] = ix[1]
+					arch = ix[0]
 				}
-				invLock.Lock()
-				defer invLock.Unlock()
-				if i == 1 {
-					features, _ := strconv.Atoi(clientType)
-					ij.Clusters = append(ij.Clusters, inventoryCluster{
-						ClusterName:        nameNo[0],
-						NodeNo:             nameNo[1],
-						PublicIp:           "",
-						PrivateIp:          strings.ReplaceAll(ip, " ", ","),
-						InstanceId:         tt[0],
-						ImageId:            tt[3],
-						State:              strings.ReplaceAll(tt[2], " ", "_"),
-						Arch:               arch,
-						Distribution:       i2[0],
-						OSVersion:          i3[0],
-						AerospikeVersion:   asdVer,
-						DockerExposePorts:  exposePorts,
-						DockerInternalPort: intPorts,
-						Features:           FeatureSystem(features),
-						AGILabel:           allLabels["agiLabel"],
-						DockerLabels:       allLabels,
-						Owner:              allLabels["owner"],
-					})
-				} else {
-					ij.Clients = append(ij.Clients, inventoryClient{
-						ClientName:         nameNo[0],
-						NodeNo:             nameNo[1],
-						PublicIp:           "",
-						PrivateIp:          strings.ReplaceAll(ip, " ", ","),
-						InstanceId:         tt[0],
-						ImageId:            tt[3],
-						State:              strings.ReplaceAll(tt[2], " ", "_"),
-						Arch:               arch,
-						Distribution:       i2[0],
-						OSVersion:          i3[0],
-						AerospikeVersion:   asdVer,
-						ClientType:         clientType,
-						DockerExposePorts:  exposePorts,
-						DockerInternalPort: intPorts,
-						DockerLabels:       allLabels,
-						Owner:              allLabels["owner"],
-					})
+			}
+			clientType := allLabels["aerolab.client.type"]
+			if strings.Contains(image, "_amd64") {
+				arch = "amd64"
+			} else if strings.Contains(image, "_arm64") {
+				arch = "arm64"
+			}
+			if i == 1 {
+				if v, ok := nodeVersions[container.Name]; ok && v.ID == container.ID {
+					asdVer = v.Version
 				}
-			}(t)
-		}
-		lineWait.Wait()
-		if lineError != nil {
-			return ij, lineError
+				features, _ := strconv.Atoi(clientType)
+				ij.Clusters = append(ij.Clusters, inventoryCluster{
+					ClusterName:        nameNo[0],
+					NodeNo:             nameNo[1],
+					PublicIp:           "",
+					PrivateIp:          strings.ReplaceAll(ip, " ", ","),
+					InstanceId:         container.ID,
+					ImageId:            image,
+					State:              strings.ReplaceAll(container.Status, " ", "_"),
+					Arch:               arch,
+					Distribution:       i2[0],
+					OSVersion:          i3[0],
+					AerospikeVersion:   asdVer,
+					DockerExposePorts:  exposePorts,
+					DockerInternalPort: intPorts,
+					Features:           FeatureSystem(features),
+					AGILabel:           allLabels["agiLabel"],
+					DockerLabels:       allLabels,
+					Owner:              allLabels["owner"],
+				})
+			} else {
+				ij.Clients = append(ij.Clients, inventoryClient{
+					ClientName:         nameNo[0],
+					NodeNo:             nameNo[1],
+					PublicIp:           "",
+					PrivateIp:          strings.ReplaceAll(ip, " ", ","),
+					InstanceId:         container.ID,
+					ImageId:            image,
+					State:              strings.ReplaceAll(container.Status, " ", "_"),
+					Arch:               arch,
+					Distribution:       i2[0],
+					OSVersion:          i3[0],
+					AerospikeVersion:   asdVer,
+					ClientType:         clientType,
+					DockerExposePorts:  exposePorts,
+					DockerInternalPort: intPorts,
+					DockerLabels:       allLabels,
+					Owner:              allLabels["owner"],
+				})
+			}
 		}
 	}
 	return ij, nil
@@ -437,24 +327,16 @@ func (d *backendDocker) LockSecurityGroups(ip string, lockSSH bool, vpc string,
 }
 
 func (d *backendDocker) ClusterList() ([]string, error) {
-	out, err := exec.Command("docker", "container", "list", "-a", "--format", "{{json .Names}}").CombinedOutput()
+	containers, err := d.rt.ContainerList(&containerListOpts{All: true, NamePrefix: dockerNameHeader})
 	if err != nil {
 		return nil, err
 	}
-	var clusterList []string
-	clusterList = []string{}
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
-	for scanner.Scan() {
-		t := scanner.Text()
-		t = strings.Trim(t, "'\"")
-		if strings.Contains(t, dockerNameHeader+"") {
-			t = t[len(dockerNameHeader):]
-			cnametmp := strings.Split(t, "_")
-			cname := strings.Join(cnametmp[:len(cnametmp)-1], "_")
-			//nodename = cnametmp[len(cnametmp)-1]
-			if !inslice.HasString(clusterList, cname) {
-				clusterList = append(clusterList, cname)
-			}
+	clusterList := []string{}
+	for _, container := range containers {
+		cnametmp := strings.Split(container.Name[len(dockerNameHeader):], "_")
+		cname := strings.Join(cnametmp[:len(cnametmp)-1], "_")
+		if !inslice.HasString(clusterList, cname) {
+			clusterList = append(clusterList, cname)
 		}
 	}
 	return clusterList, nil
@@ -473,49 +355,34 @@ func (d *backendDocker) GetInstanceIpMap(name string, internalIPs bool) (map[str
 }
 
 func (d *backendDocker) nodeListInClusterDo(name string, onlyRunning bool) ([]int, error) {
-	var out []byte
-	var err error
-	if onlyRunning {
-		out, err = exec.Command("docker", "container", "list", "--format", "{{json .Names}}").CombinedOutput()
-	} else {
-		out, err = exec.Command("docker", "container", "list", "-a", "--format", "{{json .Names}}").CombinedOutput()
-	}
+	containers, err := d.rt.ContainerList(&containerListOpts{All: !onlyRunning, NamePrefix: dockerNameHeader + name + "_"})
 	if err != nil {
 		return nil, err
 	}
 	var nodeList []int
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
-	for scanner.Scan() {
-		t := scanner.Text()
-		t = strings.Trim(t, "'\"")
-		if strings.Contains(t, dockerNameHeader+"") {
-			t = t[len(dockerNameHeader):]
-			cnametmp := strings.Split(t, "_")
-			clusterNode0 := strings.Join(cnametmp[:len(cnametmp)-1], "_")
-			clusterNode1 := cnametmp[len(cnametmp)-1]
-			if clusterNode0 == name {
-				node, err := strconv.Atoi(clusterNode1)
-				if err != nil {
-					return nil, err
-				}
-				nodeList = append(nodeList, node)
+	for _, container := range containers {
+		cnametmp := strings.Split(container.Name[len(dockerNameHeader):], "_")
+		clusterNode0 := strings.Join(cnametmp[:len(cnametmp)-1], "_")
+		clusterNode1 := cnametmp[len(cnametmp)-1]
+		if clusterNode0 == name {
+			node, err := strconv.Atoi(clusterNode1)
+			if err != nil {
+				return nil, err
 			}
+			nodeList = append(nodeList, node)
 		}
 	}
 	return nodeList, nil
 }
 
 func (d *backendDocker) ListTemplates() ([]backendVersion, error) {
-	out, err := exec.Command("docker", "image", "list", "-a", "--format", "{{json .Repository}};{{.Tag}}").CombinedOutput()
+	images, err := d.rt.ImageList()
 	if err != nil {
 		return nil, err
 	}
 	var templateList []backendVersion
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
-	for scanner.Scan() {
-		t := scanner.Text()
-		repo := strings.Trim(strings.Split(t, ";")[0], "'\"")
-		repo = strings.TrimPrefix(repo, "localhost/")
+	for _, image := range images {
+		repo := image.Repository
 		if strings.Contains(repo, dockerNameHeader+"") {
 			if len(repo) > len(dockerNameHeader)+2 {
 				repo = repo[len(dockerNameHeader):]
@@ -529,11 +396,7 @@ func (d *backendDocker) ListTemplates() ([]backendVersion, error) {
 							isArm = false
 						}
 					}
-					tagList := strings.Split(t, ";")
-					if len(tagList) > 1 {
-						tag := strings.Trim(tagList[1], "'\"")
-						templateList = append(templateList, backendVersion{distVer[0], distVer[1], tag, isArm})
-					}
+					templateList = append(templateList, backendVersion{distVer[0], distVer[1], image.Tag, isArm})
 				}
 			}
 		}
@@ -553,26 +416,15 @@ func (d *backendDocker) WorkOnServers() {
 	dockerNameHeader = "aerolab-"
 }
 
-func (d *backendDocker) initIsPodman() string {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer ctxCancel()
-	ret := "docker"
-	out, err := exec.CommandContext(ctx, "docker", "version").CombinedOutput()
+func (d *backendDocker) Init() error {
+	var err error
+	d.rt, err = newContainerRuntime()
 	if err != nil {
-		return ret
-	}
-	if strings.Contains(string(out), "Podman Engine") {
-		ret = "podman"
+		return err
 	}
-	return ret
-}
-
-func (d *backendDocker) Init() error {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer ctxCancel()
-	out, err := exec.CommandContext(ctx, "docker", "info").CombinedOutput()
+	arch, err := d.rt.Arch()
 	if err != nil {
-		return fmt.Errorf("docker command not found, or docker appears to be unreachable or down: %s", string(out))
+		return err
 	}
 	switch a.opts.Config.Backend.Arch {
 	case "amd64":
@@ -580,19 +432,10 @@ func (d *backendDocker) Init() error {
 	case "arm64":
 		d.isArm = true
 	default:
-		for _, line := range strings.Split(string(out), "\n") {
-			line = strings.Trim(line, "\r\n\t ")
-			if !strings.HasPrefix(line, "Architecture: ") {
-				continue
-			}
-			arch := strings.Split(line, ": ")[1]
-			if strings.Contains(arch, "arm") || strings.Contains(arch, "aarch") {
-				d.isArm = true
-			}
-			break
+		if strings.Contains(arch, "arm") || strings.Contains(arch, "aarch") {
+			d.isArm = true
 		}
 	}
-	d.dockerCmd = d.initIsPodman()
 	d.WorkOnServers()
 	return nil
 }
@@ -610,26 +453,16 @@ func (d *backendDocker) versionToReal(v *backendVersion) error {
 }
 
 func (d *backendDocker) VacuumTemplates() error {
-	out, err := exec.Command("docker", "container", "list", "-a").CombinedOutput()
+	containers, err := d.rt.ContainerList(&containerListOpts{All: true, NamePrefix: "aerotmpl-"})
 	if err != nil {
-		return fmt.Errorf("docker command failed: %s", err)
-	}
-	s := bufio.NewScanner(strings.NewReader(string(out)))
-	ids := []string{}
-	for s.Scan() {
-		line := s.Text()
-		if strings.Contains(line, " aerotmpl-") || strings.Contains(line, "\taerotmpl-") {
-			id := strings.Split(strings.Trim(line, "\t\r\n "), " ")[0]
-			id = strings.Split(id, "\t")[0]
-			ids = append(ids, id)
-		}
+		return fmt.Errorf("%s command failed: %s", d.rt.Name(), err)
 	}
 	errs := ""
-	for _, id := range ids {
-		exec.Command("docker", "stop", "-t", "1", id).CombinedOutput()
-		out, err := exec.Command("docker", "rm", "-f", id).CombinedOutput()
+	for _, container := range containers {
+		d.rt.ContainerStop(container.ID, 1)
+		err := d.rt.ContainerRemove(container.ID, true)
 		if err != nil {
-			errs = errs + err.Error() + "\n" + string(out) + "\n"
+			errs = errs + err.Error() + "\n"
 		}
 	}
 	if errs == "" {
@@ -647,13 +480,13 @@ func (d *backendDocker) VacuumTemplate(v backendVersion) error {
 		arch = "arm64"
 	}
 	templName := fmt.Sprintf("aerotmpl-%s-%s-%s-%s", v.distroName, v.distroVersion, v.aerospikeVersion, arch)
-	out, err := exec.Command("docker", "stop", "-t", "1", templName).CombinedOutput()
+	err := d.rt.ContainerStop(templName, 1)
 	if err != nil {
-		return fmt.Errorf("could not stop temporary template container: %s;%s", out, err)
+		return fmt.Errorf("could not stop temporary template container: %s", err)
 	}
-	out, err = exec.Command("docker", "rm", "-f", templName).CombinedOutput()
+	err = d.rt.ContainerRemove(templName, true)
 	if err != nil {
-		return fmt.Errorf("could not destroy temporary template container: %s;%s", out, err)
+		return fmt.Errorf("could not destroy temporary template container: %s", err)
 	}
 	return nil
 }
@@ -673,15 +506,15 @@ func (d *backendDocker) DeployTemplate(v backendVersion, script string, files []
 		for len(deployTemplateShutdownMaking) > 0 {
 			time.Sleep(time.Second)
 		}
-		exec.Command("docker", "rm", "-f", templName).CombinedOutput()
+		d.rt.ContainerRemove(templName, true)
 	})
 	defer delShutdownHandler("deployTemplate")
 	// deploy container with os
 	deployTemplateShutdownMaking <- 1
-	out, err := exec.Command("docker", "run", "-td", "--name", templName, d.imageNaming(v)).CombinedOutput()
+	err := d.rt.ContainerRun(&containerRunSpec{Name: templName, Image: d.imageNaming(v)})
 	<-deployTemplateShutdownMaking
 	if err != nil {
-		return fmt.Errorf("could not start vanilla container: %s;%s", out, err)
+		return fmt.Errorf("could not start vanilla container: %s", err)
 	}
 	// copy add script to files list
 	files = append(files, fileListReader{"/root/install.sh", strings.NewReader(script), len(script)})
@@ -691,29 +524,29 @@ func (d *backendDocker) DeployTemplate(v backendVersion, script string, files []
 		return fmt.Errorf("could not copy files to container: %s", err)
 	}
 	// run script
-	out, err = exec.Command("docker", "exec", "-t", templName, "chmod", "755", "/root/install.sh").CombinedOutput()
+	out, err := d.execOutput(templName, "", nil, true, []string{"chmod", "755", "/root/install.sh"})
 	if err != nil {
 		return fmt.Errorf("could not chmod 755 /root/install.sh: %s;%s", out, err)
 	}
-	out, err = exec.Command("docker", "exec", "-t", templName, "/bin/bash", "-c", "/root/install.sh").CombinedOutput()
+	out, err = d.execOutput(templName, "", nil, true, []string{"/bin/bash", "-c", "/root/install.sh"})
 	if err != nil {
 		return fmt.Errorf("script /root/install.sh failed with: %s;%s", out, err)
 	}
 	// stop container
-	out, err = exec.Command("docker", "stop", templName).CombinedOutput()
+	err = d.rt.ContainerStop(templName, 10)
 	if err != nil {
-		return fmt.Errorf("failed stopping container: %s;%s", out, err)
+		return fmt.Errorf("failed stopping container: %s", err)
 	}
 	// docker container commit container_name dist_ver:aeroVer
 	templImg := fmt.Sprintf(dockerNameHeader+"%s_%s_%s:%s", v.distroName, v.distroVersion, arch, v.aerospikeVersion)
-	out, err = exec.Command(d.dockerCmd, "container", "commit", templName, templImg).CombinedOutput()
+	err = d.rt.ContainerCommit(templName, templImg)
 	if err != nil {
-		return fmt.Errorf("failed to commit container to image: %s;%s", out, err)
+		return fmt.Errorf("failed to commit container to image: %s", err)
 	}
 	// docker rm container_name
-	out, err = exec.Command("docker", "rm", templName).CombinedOutput()
+	err = d.rt.ContainerRemove(templName, false)
 	if err != nil {
-		return fmt.Errorf("failed to remove temporary container: %s;%s", out, err)
+		return fmt.Errorf("failed to remove temporary container: %s", err)
 	}
 	return nil
 }
@@ -726,19 +559,33 @@ func (d *backendDocker) TemplateDestroy(v backendVersion) error {
 	if v.isArm {
 		arch = "arm64"
 	}
-	name := fmt.Sprintf(dockerNameHeader+"%s_%s_%s:%s", v.distroName, v.distroVersion, arch, v.aerospikeVersion)
-	out, err := exec.Command("docker", "image", "list", "--format", "{{json .ID}}", fmt.Sprintf("--filter=reference=%s", name)).CombinedOutput()
-	if err != nil || len(strings.Trim(string(out), "\t\r\n ")) == 0 {
-		name = fmt.Sprintf(dockerNameHeader+"%s_%s:%s", v.distroName, v.distroVersion, v.aerospikeVersion)
-		out, err = exec.Command("docker", "image", "list", "--format", "{{json .ID}}", fmt.Sprintf("--filter=reference=%s", name)).CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("failed to get image list: %s;%s", string(out), err)
+	images, err := d.rt.ImageList()
+	if err != nil {
+		return fmt.Errorf("failed to get image list: %s", err)
+	}
+	// NOTE: the second name is the old image naming format, without the architecture
+	names := []string{
+		fmt.Sprintf(dockerNameHeader+"%s_%s_%s:%s", v.distroName, v.distroVersion, arch, v.aerospikeVersion),
+		fmt.Sprintf(dockerNameHeader+"%s_%s:%s", v.distroName, v.distroVersion, v.aerospikeVersion),
+	}
+	imageId := ""
+	for _, name := range names {
+		for _, image := range images {
+			if image.Repository+":"+image.Tag == name {
+				imageId = image.ID
+				break
+			}
+		}
+		if imageId != "" {
+			break
 		}
 	}
-	imageId := strings.Trim(string(out), "\"' \n\r")
-	out, err = exec.Command("docker", "rmi", imageId).CombinedOutput()
+	if imageId == "" {
+		return fmt.Errorf("image %s not found", names[0])
+	}
+	err = d.rt.ImageRemove(imageId)
 	if err != nil {
-		return fmt.Errorf("failed to rmi '%s': %s;%s", imageId, string(out), err)
+		return fmt.Errorf("failed to rmi '%s': %s", imageId, err)
 	}
 	return nil
 }
@@ -820,7 +667,7 @@ func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount i
 	if err := d.versionToReal(&v); err != nil {
 		return err
 	}
-	if !d.client {
+	if !d.client && extra.snapshot == "" {
 		templ, err := d.ListTemplates()
 		if err != nil {
 			return err
@@ -892,14 +739,13 @@ func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount i
 	}
 	tmplName := fmt.Sprintf(dockerNameHeader+"%s_%s_%s:%s", v.distroName, v.distroVersion, arch, v.aerospikeVersion)
 	// NOTE: eventually remve this code block up to the for loop - it is used in transition between old and new image naming formats
-	repoCheck, err := exec.Command("docker", "image", "list", "--format", "{{json .Repository}},{{json .Tag}}").CombinedOutput()
+	repoCheck, err := d.rt.ImageList()
 	if err != nil {
 		return err
 	}
 	newTmpl := false
-	ss := bufio.NewScanner(bytes.NewReader(repoCheck))
-	for ss.Scan() {
-		if strings.TrimPrefix(strings.Trim(strings.ReplaceAll(ss.Text(), "\",\"", ":"), "\r\n\t \""), "localhost/") == tmplName {
+	for _, image := range repoCheck {
+		if image.Repository+":"+image.Tag == tmplName {
 			newTmpl = true
 			break
 		}
@@ -908,15 +754,29 @@ func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount i
 		tmplName = fmt.Sprintf(dockerNameHeader+"%s_%s:%s", v.distroName, v.distroVersion, v.aerospikeVersion)
 	}
 	//END remove NOTE
+	if extra.snapshot != "" {
+		tmplName = extra.snapshot
+	}
 	for node := highestNode; node < nodeCount+highestNode; node = node + 1 {
 		exposeFreeListNext++
-		var out []byte
-		exposeList := []string{"run"}
+		spec := &containerRunSpec{
+			Name:       fmt.Sprintf(dockerNameHeader+"%s_%d", name, node),
+			Labels:     make(map[string]string),
+			Cpus:       extra.cpuLimit,
+			Memory:     extra.ramLimit,
+			MemorySwap: extra.swapLimit,
+			Network:    extra.network,
+			NoFile:     extra.limitNoFile,
+			CapAdd:     []string{"NET_ADMIN", "NET_RAW"},
+			Switches:   extra.switches,
+			Command:    []string{"/bin/bash", "-c", "while true; do [ -f /tmp/poweroff.now ] && rm -f /tmp/poweroff.now && exit; sleep 1; done"},
+		}
 		if extra.clientType != "" {
-			exposeList = append(exposeList, "--label", "aerolab.client.type="+extra.clientType)
+			spec.Labels["aerolab.client.type"] = extra.clientType
 		}
 		for _, newlabel := range extra.labels {
-			exposeList = append(exposeList, "--label", newlabel)
+			k, v, _ := strings.Cut(newlabel, "=")
+			spec.Labels[k] = v
 		}
 		if d.client {
 			if extra.customDockerImage != "" {
@@ -925,43 +785,23 @@ func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount i
 				tmplName = d.imageNaming(v)
 			}
 		}
+		spec.Image = tmplName
 		if extra.dockerHostname {
-			exposeList = append(exposeList, "--hostname", name+"-"+strconv.Itoa(node))
-		}
-		if len(extra.switches) > 0 {
-			exposeList = append(exposeList, extra.switches...)
+			spec.Hostname = name + "-" + strconv.Itoa(node)
 		}
 		if extra.autoExpose {
 			nPort := strconv.Itoa(exposeFreeList[exposeFreeListNext])
-			exposeList = append(exposeList, "-p", nPort+":"+nPort)
-		}
-		for _, ep := range extra.exposePorts {
-			exposeList = append(exposeList, "-p", ep)
-		}
-		if extra.cpuLimit != "" {
-			exposeList = append(exposeList, fmt.Sprintf("--cpus=%s", extra.cpuLimit))
-		}
-		if extra.ramLimit != "" {
-			exposeList = append(exposeList, "-m", extra.ramLimit)
-		}
-		if extra.swapLimit != "" {
-			exposeList = append(exposeList, "--memory-swap", extra.swapLimit)
-		}
-		if extra.network != "" {
-			exposeList = append(exposeList, "--network", extra.network)
-		}
-		if extra.limitNoFile > 0 {
-			exposeList = append(exposeList, "--ulimit", fmt.Sprintf("nofile=%d:%d", extra.limitNoFile, extra.limitNoFile))
+			spec.Ports = append(spec.Ports, nPort+":"+nPort)
 		}
+		spec.Ports = append(spec.Ports, extra.exposePorts...)
 		if extra.privileged {
 			fmt.Println("WARNING: privileged container")
-			exposeList = append(exposeList, "--device-cgroup-rule=b 7:* rmw", "--privileged=true", "--cap-add=NET_ADMIN", "--cap-add=NET_RAW", "-td", "--name", fmt.Sprintf(dockerNameHeader+"%s_%d", name, node), tmplName, "/bin/bash", "-c", "while true; do [ -f /tmp/poweroff.now ] && rm -f /tmp/poweroff.now && exit; sleep 1; done")
-		} else {
-			exposeList = append(exposeList, "--cap-add=NET_ADMIN", "--cap-add=NET_RAW", "-td", "--name", fmt.Sprintf(dockerNameHeader+"%s_%d", name, node), tmplName, "/bin/bash", "-c", "while true; do [ -f /tmp/poweroff.now ] && rm -f /tmp/poweroff.now && exit; sleep 1; done")
+			spec.DeviceCgroupRules = []string{"b 7:* rmw"}
+			spec.Privileged = true
 		}
-		out, err = exec.Command("docker", exposeList...).CombinedOutput()
+		err = d.rt.ContainerRun(spec)
 		if err != nil {
-			return fmt.Errorf("error running container: %s;%s", out, err)
+			return fmt.Errorf("error running container: %s", err)
 		}
 	}
 	return nil
@@ -1054,10 +894,9 @@ func (d *backendDocker) CopyFilesToClusterReader(name string, files []fileListRe
 		}
 		for _, node := range nodes {
 			nodeName := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-			var out []byte
-			out, err = exec.Command("docker", "cp", tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath)).CombinedOutput()
+			err = d.rt.CopyTo(nodeName, tmpfileName, file.filePath)
 			if err != nil {
-				return fmt.Errorf("error with docker cp: %s;%s\ntmpfileName: %s\nfilePath: %s", string(out), err, tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath))
+				return fmt.Errorf("error with docker cp: %s\ntmpfileName: %s\nfilePath: %s", err, tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath))
 			}
 		}
 		err = os.Remove(tmpfileName)
@@ -1082,9 +921,7 @@ func (d *backendDocker) RunCommands(clusterName string, commands [][]string, nod
 		var out []byte
 		var err error
 		for _, command := range commands {
-			head := []string{"exec", "-u", "0", "-e", fmt.Sprintf("NODE=%d", node), name}
-			command = append(head, command...)
-			out, err = exec.Command("docker", command...).CombinedOutput()
+			out, err = d.execOutput(name, "0", []string{fmt.Sprintf("NODE=%d", node)}, false, command)
 			fout = append(fout, out)
 			if checkExecRetcode(err) != 0 {
 				return fout, fmt.Errorf("error running %s: %s", command, err)
@@ -1106,17 +943,14 @@ func (d *backendDocker) GetClusterNodeIps(name string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	nodeIps, err := d.nodeIps(name, false)
+	if err != nil {
+		return nil, err
+	}
 	ips := []string{}
-	var out []byte
 	for _, node := range nodes {
-		containerName := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "container", "inspect", "--format", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", containerName).CombinedOutput()
-		if err != nil {
-			return nil, err
-		}
-		ip := strings.Trim(string(out), "'\" \n\r")
-		if ip != "" {
-			ips = append(ips, strings.Split(ip, " ")[0])
+		if ip, ok := nodeIps[node]; ok {
+			ips = append(ips, ip)
 		}
 	}
 	return ips, nil
@@ -1133,21 +967,23 @@ func (d *backendDocker) GetNodeIpMap(name string, internalIPs bool) (map[int]str
 	if !inslice.HasString(clusters, name) {
 		return nil, errors.New("cluster not found")
 	}
-	nodes, err := d.nodeListInClusterDo(name, true)
+	return d.nodeIps(name, true)
+}
+
+// nodeIps returns the first IP of each node of a cluster, using a single container listing
+func (d *backendDocker) nodeIps(name string, onlyRunning bool) (map[int]string, error) {
+	containers, err := d.rt.ContainerList(&containerListOpts{All: !onlyRunning, NamePrefix: dockerNameHeader + name + "_", Detail: true})
 	if err != nil {
 		return nil, err
 	}
 	ips := make(map[int]string)
-	var out []byte
-	for _, node := range nodes {
-		containerName := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "container", "inspect", "--format", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", containerName).CombinedOutput()
+	for _, container := range containers {
+		node, err := strconv.Atoi(strings.TrimPrefix(container.Name, dockerNameHeader+name+"_"))
 		if err != nil {
-			return nil, err
+			continue
 		}
-		ip := strings.Trim(string(out), "'\" \n\r")
-		if ip != "" {
-			ips[node] = strings.Split(ip, " ")[0]
+		if len(container.IPs) > 0 {
+			ips[node] = container.IPs[0]
 		}
 	}
 	return ips, nil
@@ -1162,11 +998,10 @@ func (d *backendDocker) ClusterStart(name string, nodes []int) error {
 		}
 	}
 	for _, node := range nodes {
-		var out []byte
 		name := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "start", name).CombinedOutput()
+		err = d.rt.ContainerStart(name)
 		if err != nil {
-			return fmt.Errorf("%s;%s", string(out), err)
+			return err
 		}
 	}
 	return nil
@@ -1181,11 +1016,10 @@ func (d *backendDocker) ClusterStop(name string, nodes []int) error {
 		}
 	}
 	for _, node := range nodes {
-		var out []byte
 		name := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "stop", "-t", "1", name).CombinedOutput()
+		err = d.rt.ContainerStop(name, 1)
 		if err != nil {
-			return fmt.Errorf("%s;%s", string(out), err)
+			return err
 		}
 	}
 	return nil
@@ -1200,11 +1034,10 @@ func (d *backendDocker) ClusterDestroy(name string, nodes []int) error {
 		}
 	}
 	for _, node := range nodes {
-		var out []byte
 		name := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "rm", name).CombinedOutput()
+		err = d.rt.ContainerRemove(name, false)
 		if err != nil {
-			return fmt.Errorf("%s;%s", string(out), err)
+			return err
 		}
 	}
 	return nil
@@ -1212,20 +1045,18 @@ func (d *backendDocker) ClusterDestroy(name string, nodes []int) error {
 
 func (d *backendDocker) Upload(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
 	name := fmt.Sprintf(dockerNameHeader+"%s_%d", clusterName, node)
-	cmd := []string{"cp", source, name + ":" + destination}
-	out, err := exec.Command("docker", cmd...).CombinedOutput()
+	err := d.rt.CopyTo(name, source, destination)
 	if err != nil {
-		return fmt.Errorf("%s: %s", err, strings.ReplaceAll(string(out), "\n", "; "))
+		return errors.New(strings.ReplaceAll(err.Error(), "\n", "; "))
 	}
 	return nil
 }
 
 func (d *backendDocker) Download(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
 	name := fmt.Sprintf(dockerNameHeader+"%s_%d", clusterName, node)
-	cmd := []string{"cp", name + ":" + source, destination}
-	out, err := exec.Command("docker", cmd...).CombinedOutput()
+	err := d.rt.CopyFrom(name, source, destination)
 	if err != nil {
-		return fmt.Errorf("%s: %s", err, strings.ReplaceAll(string(out), "\n", "; "))
+		return errors.New(strings.ReplaceAll(err.Error(), "\n", "; "))
 	}
 	return nil
 }
@@ -1236,30 +1067,42 @@ func (d *backendDocker) AttachAndRun(clusterName string, node int, command []str
 
 func (d *backendDocker) RunCustomOut(clusterName string, node int, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, isInteractive bool, dockerForceUser *string) (err error) {
 	name := fmt.Sprintf(dockerNameHeader+"%s_%d", clusterName, node)
-	var cmd *exec.Cmd
-	termMode := "-t"
-	if isInteractive {
-		termMode = "-ti"
-	}
-	if !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
-		termMode = "-t"
-	}
-	head := []string{"exec"}
+	user := ""
 	if dockerForceUser != nil {
-		head = append(head, "-u", *dockerForceUser)
+		user = *dockerForceUser
 	}
-	head = append(head, "-e", fmt.Sprintf("NODE=%d", node), termMode, name)
 	if len(command) == 0 {
-		command = append(head, "/bin/bash")
-	} else {
-		command = append(head, command...)
+		command = []string{"/bin/bash"}
 	}
-	cmd = exec.Command("docker", command...)
-	cmd.Stdin = stdin
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-	err = cmd.Run()
-	return err
+	if !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		isInteractive = false
+	}
+	return d.rt.Exec(&containerExecSpec{
+		Name:        name,
+		User:        user,
+		Env:         []string{fmt.Sprintf("NODE=%d", node)},
+		Command:     command,
+		Tty:         true,
+		Interactive: isInteractive,
+		Stdin:       stdin,
+		Stdout:      stdout,
+		Stderr:      stderr,
+	})
+}
+
+// execOutput runs a command in a container and returns the combined output
+func (d *backendDocker) execOutput(name string, user string, env []string, tty bool, command []string) ([]byte, error) {
+	out := new(bytes.Buffer)
+	err := d.rt.Exec(&containerExecSpec{
+		Name:    name,
+		User:    user,
+		Env:     env,
+		Command: command,
+		Tty:     tty,
+		Stdout:  out,
+		Stderr:  out,
+	})
+	return out.Bytes(), err
 }
 
 func (d *backendDocker) copyFilesToContainer(name string, files []fileListReader) error {
@@ -1283,11 +1126,9 @@ func (d *backendDocker) copyFilesToContainer(name string, files []fileListReader
 		if err != nil {
 			return fmt.Errorf("error closing tmpfile: %s", err)
 		}
-		nodeName := name
-		var out []byte
-		out, err = exec.Command("docker", "cp", tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath)).CombinedOutput()
+		err = d.rt.CopyTo(name, tmpfileName, file.filePath)
 		if err != nil {
-			return fmt.Errorf("error with docker cp: %s;%s", string(out), err)
+			return fmt.Errorf("error with docker cp: %s", err)
 		}
 		err = os.Remove(tmpfileName)
 		if err != nil {
@@ -1,11 +1,9 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strings"
 	"text/tabwriter"
 )
@@ -26,57 +24,28 @@ func (d *backendDocker) CreateNetwork(name string, driver string, subnet string,
 	if driver == "" {
 		driver = "bridge"
 	}
-	opts := []string{"network", "create", "--attachable", "-d", driver}
-	if subnet != "" {
-		opts = append(opts, "--subnet", subnet)
+	opts := map[string]string{
+		"com.docker.network.bridge.enable_icc":           "true",
+		"com.docker.network.bridge.enable_ip_masquerade": "true",
+		"com.docker.network.bridge.host_binding_ipv4":    "0.0.0.0",
+		"com.docker.network.bridge.name":                 name,
 	}
-	opts = append(opts, "--opt", "com.docker.network.bridge.enable_icc=true", "--opt", "com.docker.network.bridge.enable_ip_masquerade=true", "--opt", "com.docker.network.bridge.host_binding_ipv4=0.0.0.0", "--opt", "com.docker.network.bridge.name="+name)
 	if mtu != "" {
-		opts = append(opts, "--opt", "com.docker.network.driver.mtu="+mtu)
+		opts["com.docker.network.driver.mtu"] = mtu
 	}
-	opts = append(opts, name)
-	out, err := exec.Command("docker", opts...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %s", err, string(out))
-	}
-	return nil
+	return d.rt.NetworkCreate(name, driver, subnet, opts)
 }
 
 func (d *backendDocker) DeleteNetwork(name string) error {
-	out, err := exec.Command("docker", "network", "rm", name).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %s", err, string(out))
-	}
-	return nil
+	return d.rt.NetworkRemove(name)
 }
 
 func (d *backendDocker) PruneNetworks() error {
-	out, err := exec.Command("docker", "network", "prune", "-f").CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %s", err, string(out))
-	}
-	return nil
+	return d.rt.NetworkPrune()
 }
-func (d *backendDocker) ListNetworks(csv bool, writer io.Writer) error {
-	out, err := exec.Command("docker", "network", "list", "--format", "{{.Name}}").CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %s", err, string(out))
-	}
-	networks := []string{"network", "inspect"}
-	for _, line := range strings.Split(string(out), "\n") {
-		line = strings.Trim(line, "\r\t\n ")
-		if line == "" {
-			continue
-		}
-		networks = append(networks, line)
-	}
-	out, err = exec.Command("docker", networks...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %s", err, string(out))
-	}
 
-	netlist := []dockerListNetwork{}
-	err = json.Unmarshal(out, &netlist)
+func (d *backendDocker) ListNetworks(csv bool, writer io.Writer) error {
+	netlist, err := d.rt.NetworkList()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+)
+
+// containerRuntime is the container engine driven by the docker backend; the backend only talks to the engine through this interface
+type containerRuntime interface {
+	// Name returns the runtime name, as set in config backend
+	Name() string
+	// Arch returns the engine architecture, as reported by the engine (ex: x86_64, amd64, aarch64)
+	Arch() (string, error)
+	ContainerList(opts *containerListOpts) ([]*containerItem, error)
+	ContainerRun(spec *containerRunSpec) error
+	ContainerStart(name string) error
+	ContainerStop(name string, timeoutSeconds int) error
+	ContainerRemove(name string, force bool) error
+	ContainerCommit(name string, image string) error
+	// Exec runs a command in a container; a non-zero exit code is returned as an *exec.ExitError or *containerExitError
+	Exec(spec *containerExecSpec) error
+	// CopyTo and CopyFrom follow 'docker cp' semantics
+	CopyTo(name string, source string, destination string) error
+	CopyFrom(name string, source string, destination string) error
+	ImageList() ([]*containerImage, error)
+	ImageRemove(id string) error
+	NetworkCreate(name string, driver string, subnet string, options map[string]string) error
+	NetworkRemove(name string) error
+	NetworkPrune() error
+	NetworkList() ([]dockerListNetwork, error)
+}
+
+type containerListOpts struct {
+	All        bool              // include stopped containers
+	NamePrefix string            // only list containers with names starting with the prefix
+	Labels     map[string]string // only list containers with matching labels
+	Detail     bool              // fill labels, ports and IPs; the CLI runtimes need an extra inspect call for those
+}
+
+type containerItem struct {
+	ID     string
+	Name   string
+	Image  string
+	State  string // ex: running, exited
+	Status string // human readable, ex: Up 2 minutes
+	Labels map[string]string
+	Ports  []containerPort
+	IPs    []string
+}
+
+type containerPort struct {
+	HostPort      string // empty if the port is exposed but not published
+	ContainerPort string
+}
+
+type containerRunSpec struct {
+	Name              string
+	Image             string
+	Command           []string
+	Hostname          string
+	Labels            map[string]string
+	Ports             []string // docker -p format: [hostIp:]hostPort:containerPort[/proto]
+	Cpus              string
+	Memory            string
+	MemorySwap        string
+	Network           string
+	NoFile            int
+	Privileged        bool
+	CapAdd            []string
+	DeviceCgroupRules []string
+	Switches          []string // extra docker run command line switches, passed as-is
+}
+
+type containerExecSpec struct {
+	Name        string
+	User        string
+	Env         []string
+	Command     []string
+	Tty         bool
+	Interactive bool // attach stdin
+	Stdin       io.Reader
+	Stdout      io.Writer
+	Stderr      io.Writer
+}
+
+type containerImage struct {
+	ID         string
+	Repository string
+	Tag        string
+}
+
+// containerExitError is returned by runtimes which do not fork a process, when an executed command returns a non-zero exit code
+type containerExitError struct {
+	ExitCode int
+}
+
+func (e *containerExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.ExitCode)
+}
+
+const (
+	containerRuntimeDocker  = "docker"
+	containerRuntimePodman  = "podman"
+	containerRuntimeNerdctl = "nerdctl"
+)
+
+// newContainerRuntime returns the runtime configured in config backend
+func newContainerRuntime() (containerRuntime, error) {
+	switch a.opts.Config.Backend.ContainerRuntime {
+	case "", containerRuntimeDocker:
+		network, address, tlsConfig, err := dockerEngineAddress()
+		if err != nil {
+			return nil, err
+		}
+		if network == "" {
+			// no reachable API socket (ex: windows named pipes), use the command line tool
+			return &containerRuntimeCli{name: containerRuntimeDocker, bin: "docker"}, nil
+		}
+		return newContainerRuntimeApi(containerRuntimeDocker, network, address, tlsConfig, "docker"), nil
+	case containerRuntimeNerdctl:
+		return &containerRuntimeCli{name: containerRuntimeNerdctl, bin: "nerdctl"}, nil
+	case containerRuntimePodman:
+		socket, err := podmanSocketPath()
+		if err != nil {
+			return nil, err
+		}
+		return newContainerRuntimeApi(containerRuntimePodman, "unix", socket, nil, "podman"), nil
+	}
+	return nil, fmt.Errorf("unsupported container runtime %s, supported: docker, podman, nerdctl", a.opts.Config.Backend.ContainerRuntime)
+}
+
+// podmanSocketPath finds the podman API socket: configured path, CONTAINER_HOST, rootless user socket, rootful system socket
+func podmanSocketPath() (string, error) {
+	if a.opts.Config.Backend.ContainerSocket != "" {
+		return strings.TrimPrefix(string(a.opts.Config.Backend.ContainerSocket), "unix://"), nil
+	}
+	if h := os.Getenv("CONTAINER_HOST"); h != "" {
+		if !strings.HasPrefix(h, "unix://") {
+			return "", fmt.Errorf("CONTAINER_HOST %s is not supported, only unix:// sockets can be used", h)
+		}
+		return strings.TrimPrefix(h, "unix://"), nil
+	}
+	candidates := []string{}
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		candidates = append(candidates, path.Join(d, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, fmt.Sprintf("/run/user/%d/podman/podman.sock", os.Getuid()), "/run/podman/podman.sock")
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("podman socket not found in %s; start it with 'systemctl --user enable --now podman.socket' or set the path with 'aerolab config backend -t docker --container-runtime podman --container-socket /path/to/podman.sock'", strings.Join(candidates, ", "))
+}
+
+// dockerEngineAddress finds the docker Engine API: configured socket, DOCKER_HOST, the current docker context, the system socket, the docker desktop user socket;
+// returns an empty network if the API is not reachable over a unix socket or tcp
+func dockerEngineAddress() (network string, address string, tlsConfig *tls.Config, err error) {
+	host := os.Getenv("DOCKER_HOST")
+	tlsVerify := os.Getenv("DOCKER_TLS_VERIFY") != ""
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	skipVerify := false
+	if a.opts.Config.Backend.ContainerSocket != "" {
+		host = string(a.opts.Config.Backend.ContainerSocket)
+		if !strings.Contains(host, "://") {
+			host = "unix://" + host
+		}
+	} else if host == "" {
+		ctx, err := dockerContextEndpoint()
+		if err != nil {
+			return "", "", nil, err
+		}
+		if ctx != nil {
+			host = ctx.Host
+			if ctx.certPath != "" {
+				tlsVerify = true
+				certPath = ctx.certPath
+				skipVerify = ctx.SkipTLSVerify
+			}
+		}
+	}
+	if host != "" {
+		switch {
+		case strings.HasPrefix(host, "unix://"):
+			return "unix", strings.TrimPrefix(host, "unix://"), nil, nil
+		case strings.HasPrefix(host, "tcp://"):
+			address = strings.TrimSuffix(strings.TrimPrefix(host, "tcp://"), "/")
+			if !tlsVerify {
+				return "tcp", address, nil, nil
+			}
+			tlsConfig, err = dockerEngineTls(address, certPath)
+			if tlsConfig != nil {
+				tlsConfig.InsecureSkipVerify = skipVerify
+			}
+			return "tcp", address, tlsConfig, err
+		}
+		return "", "", nil, nil
+	}
+	candidates := []string{"/var/run/docker.sock"}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, path.Join(home, ".docker", "run", "docker.sock"))
+	}
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return "unix", c, nil, nil
+		}
+	}
+	return "", "", nil, nil
+}
+
+// dockerEndpoint is the docker endpoint of a docker context, as stored by 'docker context create'
+type dockerEndpoint struct {
+	Host          string
+	SkipTLSVerify bool
+	certPath      string // directory with ca.pem, cert.pem and key.pem, empty if the context has no TLS material
+}
+
+// dockerConfigDir returns the docker command line tool configuration directory: DOCKER_CONFIG or ~/.docker
+func dockerConfigDir() (string, error) {
+	if d := os.Getenv("DOCKER_CONFIG"); d != "" {
+		return d, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(home, ".docker"), nil
+}
+
+// dockerContextName returns the docker context in use: DOCKER_CONTEXT, or the one selected with 'docker context use'; empty or "default" means the default context
+func dockerContextName() string {
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name
+	}
+	dir, err := dockerConfigDir()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(path.Join(dir, "config.json"))
+	if err != nil {
+		return ""
+	}
+	conf := struct {
+		CurrentContext string `json:"currentContext"`
+	}{}
+	if json.Unmarshal(data, &conf) != nil {
+		return ""
+	}
+	return conf.CurrentContext
+}
+
+// dockerContextEndpoint resolves the docker endpoint of the current docker context from the context store; returns nil for the default context
+func dockerContextEndpoint() (*dockerEndpoint, error) {
+	name := dockerContextName()
+	if name == "" || name == "default" {
+		return nil, nil
+	}
+	dir, err := dockerConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	// the context store is keyed by the sha256 of the context name
+	id := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+	data, err := os.ReadFile(path.Join(dir, "contexts", "meta", id, "meta.json"))
+	if err != nil {
+		return nil, fmt.Errorf("docker context %s: %s", name, err)
+	}
+	meta := struct {
+		Endpoints map[string]*dockerEndpoint
+	}{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("docker context %s: %s", name, err)
+	}
+	ep := meta.Endpoints["docker"]
+	if ep == nil || ep.Host == "" {
+		return nil, fmt.Errorf("docker context %s does not have a docker endpoint", name)
+	}
+	tlsDir := path.Join(dir, "contexts", "tls", id, "docker")
+	if _, err := os.Stat(path.Join(tlsDir, "cert.pem")); err == nil {
+		ep.certPath = tlsDir
+	}
+	return ep, nil
+}
+
+// dockerEngineTls loads the client certificates from certPath, DOCKER_CERT_PATH or ~/.docker, as the docker command line tool does
+func dockerEngineTls(address string, certPath string) (*tls.Config, error) {
+	if certPath == "" {
+		dir, err := dockerConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		certPath = dir
+	}
+	cert, err := tls.LoadX509KeyPair(path.Join(certPath, "cert.pem"), path.Join(certPath, "key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("docker TLS is enabled, could not load client certificate from %s: %s", certPath, err)
+	}
+	ca, err := os.ReadFile(path.Join(certPath, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("docker TLS is enabled, could not load CA from %s: %s", certPath, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("could not parse %s", path.Join(certPath, "ca.pem"))
+	}
+	serverName, _, err := net.SplitHostPort(address)
+	if err != nil {
+		serverName = address
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   serverName,
+	}, nil
+}
+
+// containerRuntimeBinary returns the command line tool for the configured runtime, for the few operations which are not part of the backend
+func containerRuntimeBinary() string {
+	switch a.opts.Config.Backend.ContainerRuntime {
+	case containerRuntimePodman, containerRuntimeNerdctl:
+		return a.opts.Config.Backend.ContainerRuntime
+	}
+	return "docker"
+}
+
+// containerPortSplit splits a docker -p port specification into host ip, host port and container port with protocol
+func containerPortSplit(spec string) (hostIp string, hostPort string, containerPort string) {
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		containerPort = parts[0]
+	case 2:
+		hostPort, containerPort = parts[0], parts[1]
+	default:
+		hostIp, hostPort, containerPort = strings.Join(parts[0:len(parts)-2], ":"), parts[len(parts)-2], parts[len(parts)-1]
+	}
+	if !strings.Contains(containerPort, "/") {
+		containerPort += "/tcp"
+	}
+	return
+}
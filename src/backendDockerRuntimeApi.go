@@ -0,0 +1,784 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"golang.org/x/term"
+)
+
+// containerRuntimeApi drives a docker compatible Engine REST API (docker, podman) without forking any processes
+type containerRuntimeApi struct {
+	name        string
+	network     string // unix or tcp
+	address     string
+	baseUrl     string
+	client      *http.Client
+	cliFallback string // command line tool used for 'docker run' switches which cannot be expressed through the API
+	tlsConfig   *tls.Config
+}
+
+func newContainerRuntimeApi(name string, network string, address string, tlsConfig *tls.Config, cliFallback string) *containerRuntimeApi {
+	r := &containerRuntimeApi{
+		name:        name,
+		network:     network,
+		address:     address,
+		baseUrl:     "http://localhost",
+		cliFallback: cliFallback,
+		tlsConfig:   tlsConfig,
+	}
+	// TLS is done in dial, so the url scheme stays http
+	if network == "tcp" {
+		r.baseUrl = "http://" + address
+	}
+	r.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return r.dial(ctx)
+			},
+		},
+	}
+	return r
+}
+
+// dial connects to the engine; the http transport and hijacked exec streams share it, so TLS is handled here
+func (r *containerRuntimeApi) dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, r.network, r.address)
+	if err != nil || r.tlsConfig == nil {
+		return conn, err
+	}
+	tconn := tls.Client(conn, r.tlsConfig)
+	err = tconn.HandshakeContext(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tconn, nil
+}
+
+type containerApiError struct {
+	Message string `json:"message"`
+}
+
+// request sends an API call; body is sent as json unless it is an io.Reader; out is decoded from json if not nil
+func (r *containerRuntimeApi) request(method string, p string, query url.Values, body interface{}, out interface{}) (*http.Response, error) {
+	var reqBody io.Reader
+	contentType := "application/json"
+	switch bb := body.(type) {
+	case nil:
+	case io.Reader:
+		reqBody = bb
+		contentType = "application/x-tar"
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	u := r.baseUrl + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s API at %s://%s unreachable: %s", r.name, r.network, r.address, err)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		apiErr := &containerApiError{}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.Trim(string(data), "\r\n\t ")
+		}
+		return resp, fmt.Errorf("%s %s: %d: %s", method, p, resp.StatusCode, apiErr.Message)
+	}
+	if out == nil {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return resp, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// call is request for calls which do not stream a response body
+func (r *containerRuntimeApi) call(method string, p string, query url.Values, body interface{}, out interface{}) error {
+	resp, err := r.request(method, p, query, body, out)
+	if err == nil && out == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return err
+}
+
+func (r *containerRuntimeApi) Name() string {
+	return r.name
+}
+
+func (r *containerRuntimeApi) Arch() (string, error) {
+	info := struct {
+		Architecture string
+	}{}
+	err := r.call("GET", "/info", nil, nil, &info)
+	if err != nil {
+		return "", err
+	}
+	return info.Architecture, nil
+}
+
+type containerApiListItem struct {
+	Id     string
+	Names  []string
+	Image  string
+	State  string
+	Status string
+	Labels map[string]string
+	Ports  []struct {
+		PrivatePort int
+		PublicPort  int
+	}
+	NetworkSettings struct {
+		Networks map[string]struct{ IPAddress string }
+	}
+}
+
+// the list call returns labels, ports and networks, so Detail does not require extra calls
+func (r *containerRuntimeApi) ContainerList(opts *containerListOpts) ([]*containerItem, error) {
+	filters := make(map[string][]string)
+	if opts.NamePrefix != "" {
+		filters["name"] = []string{regexp.QuoteMeta(opts.NamePrefix)}
+	}
+	for k, v := range opts.Labels {
+		filters["label"] = append(filters["label"], k+"="+v)
+	}
+	query := url.Values{}
+	if opts.All {
+		query.Set("all", "true")
+	}
+	if len(filters) > 0 {
+		f, _ := json.Marshal(filters)
+		query.Set("filters", string(f))
+	}
+	items := []containerApiListItem{}
+	err := r.call("GET", "/containers/json", query, nil, &items)
+	if err != nil {
+		return nil, err
+	}
+	list := []*containerItem{}
+	for _, i := range items {
+		if len(i.Names) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(i.Names[0], "/")
+		// the name filter is an unanchored regex match
+		if !strings.HasPrefix(name, opts.NamePrefix) {
+			continue
+		}
+		item := &containerItem{
+			ID:     i.Id,
+			Name:   name,
+			Image:  strings.TrimPrefix(i.Image, "localhost/"),
+			State:  i.State,
+			Status: i.Status,
+			Labels: i.Labels,
+		}
+		if len(item.ID) > 12 {
+			item.ID = item.ID[0:12]
+		}
+		if item.Labels == nil {
+			item.Labels = make(map[string]string)
+		}
+		// ipv4 and ipv6 bindings are listed separately
+		seen := make(map[string]bool)
+		for _, p := range i.Ports {
+			port := containerPort{ContainerPort: strconv.Itoa(p.PrivatePort)}
+			if p.PublicPort > 0 {
+				port.HostPort = strconv.Itoa(p.PublicPort)
+			}
+			if seen[port.HostPort+":"+port.ContainerPort] {
+				continue
+			}
+			seen[port.HostPort+":"+port.ContainerPort] = true
+			item.Ports = append(item.Ports, port)
+		}
+		sort.Slice(item.Ports, func(x, y int) bool {
+			return item.Ports[x].HostPort > item.Ports[y].HostPort
+		})
+		for _, n := range i.NetworkSettings.Networks {
+			if n.IPAddress != "" {
+				item.IPs = append(item.IPs, n.IPAddress)
+			}
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+type containerApiPortBinding struct {
+	HostIp   string
+	HostPort string
+}
+
+type containerApiUlimit struct {
+	Name string
+	Soft int64
+	Hard int64
+}
+
+type containerApiCreate struct {
+	Image        string
+	Cmd          []string            `json:",omitempty"`
+	Hostname     string              `json:",omitempty"`
+	Labels       map[string]string   `json:",omitempty"`
+	Tty          bool                `json:",omitempty"`
+	ExposedPorts map[string]struct{} `json:",omitempty"`
+	HostConfig   struct {
+		PortBindings      map[string][]containerApiPortBinding `json:",omitempty"`
+		NanoCpus          int64                                `json:",omitempty"`
+		Memory            int64                                `json:",omitempty"`
+		MemorySwap        int64                                `json:",omitempty"`
+		NetworkMode       string                               `json:",omitempty"`
+		Ulimits           []containerApiUlimit                 `json:",omitempty"`
+		Privileged        bool                                 `json:",omitempty"`
+		CapAdd            []string                             `json:",omitempty"`
+		DeviceCgroupRules []string                             `json:",omitempty"`
+	}
+}
+
+func (r *containerRuntimeApi) ContainerRun(spec *containerRunSpec) error {
+	if len(spec.Switches) > 0 {
+		// raw command line switches cannot be translated to API calls
+		if _, err := exec.LookPath(r.cliFallback); err != nil {
+			return fmt.Errorf("extra container switches require the %s command line tool, which was not found: %s", r.cliFallback, err)
+		}
+		cli := &containerRuntimeCli{name: r.name, bin: r.cliFallback}
+		return cli.ContainerRun(spec)
+	}
+	create := &containerApiCreate{
+		Image:    spec.Image,
+		Cmd:      spec.Command,
+		Hostname: spec.Hostname,
+		Labels:   spec.Labels,
+		Tty:      true,
+	}
+	for _, p := range spec.Ports {
+		hostIp, hostPort, containerPort := containerPortSplit(p)
+		if create.ExposedPorts == nil {
+			create.ExposedPorts = make(map[string]struct{})
+			create.HostConfig.PortBindings = make(map[string][]containerApiPortBinding)
+		}
+		create.ExposedPorts[containerPort] = struct{}{}
+		create.HostConfig.PortBindings[containerPort] = append(create.HostConfig.PortBindings[containerPort], containerApiPortBinding{HostIp: hostIp, HostPort: hostPort})
+	}
+	if spec.Cpus != "" {
+		cpus, err := strconv.ParseFloat(spec.Cpus, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cpu limit %s: %s", spec.Cpus, err)
+		}
+		create.HostConfig.NanoCpus = int64(cpus * 1e9)
+	}
+	var err error
+	if spec.Memory != "" {
+		create.HostConfig.Memory, err = containerParseBytes(spec.Memory)
+		if err != nil {
+			return fmt.Errorf("invalid memory limit %s: %s", spec.Memory, err)
+		}
+	}
+	if spec.MemorySwap != "" {
+		create.HostConfig.MemorySwap, err = containerParseBytes(spec.MemorySwap)
+		if err != nil {
+			return fmt.Errorf("invalid swap limit %s: %s", spec.MemorySwap, err)
+		}
+	}
+	create.HostConfig.NetworkMode = spec.Network
+	if spec.NoFile > 0 {
+		create.HostConfig.Ulimits = []containerApiUlimit{{Name: "nofile", Soft: int64(spec.NoFile), Hard: int64(spec.NoFile)}}
+	}
+	create.HostConfig.Privileged = spec.Privileged
+	create.HostConfig.CapAdd = spec.CapAdd
+	create.HostConfig.DeviceCgroupRules = spec.DeviceCgroupRules
+	query := url.Values{}
+	query.Set("name", spec.Name)
+	resp, err := r.request("POST", "/containers/create", query, create, nil)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		err = r.imagePull(spec.Image)
+		if err != nil {
+			return err
+		}
+		resp, err = r.request("POST", "/containers/create", query, create, nil)
+	}
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return r.ContainerStart(spec.Name)
+}
+
+// imagePull pulls an image, the same way 'docker run' does when the image is not found locally
+func (r *containerRuntimeApi) imagePull(image string) error {
+	repo, tag := containerImageSplit(image)
+	query := url.Values{}
+	query.Set("fromImage", repo)
+	query.Set("tag", tag)
+	resp, err := r.request("POST", "/images/create", query, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		msg := struct {
+			Error string `json:"error"`
+		}{}
+		err = dec.Decode(&msg)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("pulling %s: %s", image, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("pulling %s: %s", image, msg.Error)
+		}
+	}
+}
+
+func (r *containerRuntimeApi) ContainerStart(name string) error {
+	return r.call("POST", "/containers/"+url.PathEscape(name)+"/start", nil, nil, nil)
+}
+
+func (r *containerRuntimeApi) ContainerStop(name string, timeoutSeconds int) error {
+	query := url.Values{}
+	query.Set("t", strconv.Itoa(timeoutSeconds))
+	return r.call("POST", "/containers/"+url.PathEscape(name)+"/stop", query, nil, nil)
+}
+
+func (r *containerRuntimeApi) ContainerRemove(name string, force bool) error {
+	query := url.Values{}
+	if force {
+		query.Set("force", "true")
+	}
+	return r.call("DELETE", "/containers/"+url.PathEscape(name), query, nil, nil)
+}
+
+func (r *containerRuntimeApi) ContainerCommit(name string, image string) error {
+	repo, tag := containerImageSplit(image)
+	query := url.Values{}
+	query.Set("container", name)
+	query.Set("repo", repo)
+	query.Set("tag", tag)
+	return r.call("POST", "/commit", query, struct{}{}, nil)
+}
+
+func (r *containerRuntimeApi) Exec(spec *containerExecSpec) error {
+	create := map[string]interface{}{
+		"AttachStdin":  spec.Interactive && spec.Stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          spec.Tty,
+		"Env":          spec.Env,
+		"Cmd":          spec.Command,
+		"User":         spec.User,
+	}
+	execId := struct {
+		Id string
+	}{}
+	err := r.call("POST", "/containers/"+url.PathEscape(spec.Name)+"/exec", nil, create, &execId)
+	if err != nil {
+		return err
+	}
+	conn, reader, err := r.hijack("/exec/"+execId.Id+"/start", map[string]interface{}{"Detach": false, "Tty": spec.Tty})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if spec.Interactive && spec.Stdin != nil {
+		if f, ok := spec.Stdin.(*os.File); ok && spec.Tty && isatty.IsTerminal(f.Fd()) {
+			if state, err := term.MakeRaw(int(f.Fd())); err == nil {
+				defer term.Restore(int(f.Fd()), state)
+			}
+			if w, h, err := term.GetSize(int(f.Fd())); err == nil {
+				query := url.Values{}
+				query.Set("h", strconv.Itoa(h))
+				query.Set("w", strconv.Itoa(w))
+				r.call("POST", "/exec/"+execId.Id+"/resize", query, nil, nil)
+			}
+		}
+		go func() {
+			io.Copy(conn, spec.Stdin)
+			if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+				cw.CloseWrite()
+			}
+		}()
+	}
+	stdout := spec.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	stderr := spec.Stderr
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if spec.Tty {
+		_, err = io.Copy(stdout, reader)
+	} else {
+		err = containerDemux(reader, stdout, stderr)
+	}
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	inspect := struct {
+		ExitCode int
+	}{}
+	err = r.call("GET", "/exec/"+execId.Id+"/json", nil, nil, &inspect)
+	if err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return &containerExitError{ExitCode: inspect.ExitCode}
+	}
+	return nil
+}
+
+// hijack sends a request and takes over the connection for a raw bidirectional stream, as used by exec attach
+func (r *containerRuntimeApi) hijack(p string, body interface{}) (net.Conn, *bufio.Reader, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := r.dial(context.Background())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s API at %s://%s unreachable: %s", r.name, r.network, r.address, err)
+	}
+	req, err := http.NewRequest("POST", r.baseUrl+p, bytes.NewReader(data))
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer conn.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("POST %s: %d: %s", p, resp.StatusCode, strings.Trim(string(msg), "\r\n\t "))
+	}
+	return conn, reader, nil
+}
+
+// containerDemux splits the multiplexed stdout/stderr stream of a non-tty attach
+func containerDemux(reader io.Reader, stdout io.Writer, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:8]))
+		out := stdout
+		if header[0] == 2 {
+			out = stderr
+		}
+		_, err = io.CopyN(out, reader, size)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// containerPathStat is the X-Docker-Container-Path-Stat header of the archive endpoint
+type containerPathStat struct {
+	Name string
+	Mode os.FileMode
+}
+
+func (r *containerRuntimeApi) statPath(name string, p string) (*containerPathStat, error) {
+	query := url.Values{}
+	query.Set("path", p)
+	resp, err := r.request("HEAD", "/containers/"+url.PathEscape(name)+"/archive", query, nil, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	resp.Body.Close()
+	stat := &containerPathStat{}
+	data, err := base64.StdEncoding.DecodeString(resp.Header.Get("X-Docker-Container-Path-Stat"))
+	if err != nil {
+		return nil, err
+	}
+	return stat, json.Unmarshal(data, stat)
+}
+
+func (r *containerRuntimeApi) CopyTo(name string, source string, destination string) error {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	// like docker cp: copy into an existing directory, otherwise copy as the destination name
+	dstDir := path.Dir(destination)
+	rootName := path.Base(destination)
+	stat, err := r.statPath(name, destination)
+	if err != nil {
+		return err
+	}
+	if stat != nil && stat.Mode.IsDir() {
+		dstDir = destination
+		rootName = filepath.Base(source)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(containerTarWrite(pw, source, fi, rootName))
+	}()
+	query := url.Values{}
+	query.Set("path", dstDir)
+	err = r.call("PUT", "/containers/"+url.PathEscape(name)+"/archive", query, io.Reader(pr), nil)
+	pr.Close()
+	return err
+}
+
+func containerTarWrite(w io.Writer, source string, fi os.FileInfo, rootName string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(source, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, fpath)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(fpath)
+			if err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(rootName, filepath.ToSlash(rel))
+		if !fi.IsDir() {
+			hdr.Name = rootName
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func (r *containerRuntimeApi) CopyFrom(name string, source string, destination string) error {
+	query := url.Values{}
+	query.Set("path", source)
+	resp, err := r.request("GET", "/containers/"+url.PathEscape(name)+"/archive", query, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// like docker cp: extract into an existing directory, otherwise extract as the destination name
+	target := destination
+	if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		target = filepath.Join(destination, path.Base(source))
+	}
+	tr := tar.NewReader(resp.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel := ""
+		if _, after, found := strings.Cut(path.Clean(hdr.Name), "/"); found {
+			rel = after
+		}
+		if strings.HasPrefix(rel, "../") || rel == ".." {
+			return fmt.Errorf("invalid path in archive: %s", hdr.Name)
+		}
+		fpath := filepath.Join(target, filepath.FromSlash(rel))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(fpath, os.FileMode(hdr.Mode)|0700)
+		case tar.TypeSymlink:
+			os.Remove(fpath)
+			err = os.Symlink(hdr.Linkname, fpath)
+		case tar.TypeReg:
+			var f *os.File
+			f, err = os.OpenFile(fpath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *containerRuntimeApi) ImageList() ([]*containerImage, error) {
+	images := []struct {
+		Id       string
+		RepoTags []string
+	}{}
+	query := url.Values{}
+	query.Set("all", "true")
+	err := r.call("GET", "/images/json", query, nil, &images)
+	if err != nil {
+		return nil, err
+	}
+	list := []*containerImage{}
+	for _, i := range images {
+		id := strings.TrimPrefix(i.Id, "sha256:")
+		if len(id) > 12 {
+			id = id[0:12]
+		}
+		for _, rt := range i.RepoTags {
+			if rt == "<none>:<none>" {
+				continue
+			}
+			repo, tag := containerImageSplit(rt)
+			list = append(list, &containerImage{
+				ID:         id,
+				Repository: strings.TrimPrefix(repo, "localhost/"),
+				Tag:        tag,
+			})
+		}
+	}
+	return list, nil
+}
+
+func (r *containerRuntimeApi) ImageRemove(id string) error {
+	return r.call("DELETE", "/images/"+url.PathEscape(id), nil, nil, nil)
+}
+
+func (r *containerRuntimeApi) NetworkCreate(name string, driver string, subnet string, options map[string]string) error {
+	create := map[string]interface{}{
+		"Name":       name,
+		"Driver":     driver,
+		"Attachable": true,
+		"Options":    options,
+	}
+	if subnet != "" {
+		create["IPAM"] = map[string]interface{}{
+			"Config": []map[string]string{{"Subnet": subnet}},
+		}
+	}
+	return r.call("POST", "/networks/create", nil, create, nil)
+}
+
+func (r *containerRuntimeApi) NetworkRemove(name string) error {
+	return r.call("DELETE", "/networks/"+url.PathEscape(name), nil, nil, nil)
+}
+
+func (r *containerRuntimeApi) NetworkPrune() error {
+	return r.call("POST", "/networks/prune", nil, nil, nil)
+}
+
+func (r *containerRuntimeApi) NetworkList() ([]dockerListNetwork, error) {
+	netlist := []dockerListNetwork{}
+	err := r.call("GET", "/networks", nil, nil, &netlist)
+	return netlist, err
+}
+
+// containerImageSplit splits an image reference into repository and tag; the tag defaults to latest
+func containerImageSplit(image string) (repo string, tag string) {
+	slash := strings.LastIndex(image, "/")
+	colon := strings.LastIndex(image, ":")
+	if colon > slash {
+		return image[0:colon], image[colon+1:]
+	}
+	return image, "latest"
+}
+
+// containerParseBytes parses docker memory limits, ex: 512m, 4g, -1
+func containerParseBytes(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "-1" {
+		return -1, nil
+	}
+	s = strings.TrimSuffix(s, "b")
+	mult := int64(1)
+	if len(s) > 0 {
+		switch s[len(s)-1] {
+		case 'k':
+			mult = 1024
+		case 'm':
+			mult = 1024 * 1024
+		case 'g':
+			mult = 1024 * 1024 * 1024
+		case 't':
+			mult = 1024 * 1024 * 1024 * 1024
+		}
+		if mult > 1 {
+			s = s[0 : len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(n * float64(mult)), nil
+}
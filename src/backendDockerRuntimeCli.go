@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// containerRuntimeCli drives docker compatible command line tools (docker, nerdctl)
+type containerRuntimeCli struct {
+	name string
+	bin  string
+}
+
+// the inspect fields used by the backend, shared by docker, podman and nerdctl inspect output
+type containerCliInspect struct {
+	Id     string
+	Name   string
+	Config struct {
+		Image        string
+		Labels       map[string]string
+		ExposedPorts map[string]interface{}
+	}
+	State struct {
+		Status string
+	}
+	NetworkSettings struct {
+		Ports    map[string][]struct{ HostIp, HostPort string }
+		Networks map[string]struct{ IPAddress string }
+	}
+}
+
+func (r *containerRuntimeCli) Name() string {
+	return r.name
+}
+
+func (r *containerRuntimeCli) run(args ...string) ([]byte, error) {
+	out, err := exec.Command(r.bin, args...).CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("%s %s: %s;%s", r.bin, args[0], strings.Trim(string(out), "\r\n\t "), err)
+	}
+	return out, nil
+}
+
+func (r *containerRuntimeCli) Arch() (string, error) {
+	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer ctxCancel()
+	out, err := exec.CommandContext(ctx, r.bin, "info").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s command not found, or %s appears to be unreachable or down: %s", r.bin, r.name, string(out))
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.Trim(line, "\r\n\t ")
+		// docker and nerdctl print 'Architecture: x86_64', podman-docker prints 'arch: amd64'
+		if !strings.HasPrefix(line, "Architecture: ") && !strings.HasPrefix(line, "arch: ") {
+			continue
+		}
+		return strings.Split(line, ": ")[1], nil
+	}
+	return "", nil
+}
+
+func (r *containerRuntimeCli) ContainerList(opts *containerListOpts) ([]*containerItem, error) {
+	params := []string{"container", "list", "--format", "{{.ID}}\t{{.Names}}\t{{.Status}}\t{{.Image}}"}
+	if opts.All {
+		params = append(params, "-a")
+	}
+	for k, v := range opts.Labels {
+		params = append(params, "--filter", "label="+k+"="+v)
+	}
+	out, err := r.run(params...)
+	if err != nil {
+		return nil, err
+	}
+	list := []*containerItem{}
+	byId := make(map[string]*containerItem)
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		tt := strings.Split(strings.Trim(scanner.Text(), "'\" \t\r\n"), "\t")
+		if len(tt) < 4 {
+			continue
+		}
+		if !strings.HasPrefix(tt[1], opts.NamePrefix) {
+			continue
+		}
+		item := &containerItem{
+			ID:     tt[0],
+			Name:   tt[1],
+			Status: tt[2],
+			Image:  tt[3],
+		}
+		if strings.HasPrefix(item.Status, "Up") {
+			item.State = "running"
+		} else {
+			item.State = "exited"
+		}
+		list = append(list, item)
+		byId[item.ID] = item
+	}
+	if !opts.Detail || len(list) == 0 {
+		return list, nil
+	}
+	// single inspect call for all listed containers
+	params = []string{"container", "inspect"}
+	for _, item := range list {
+		params = append(params, item.ID)
+	}
+	out, err = r.run(params...)
+	if err != nil {
+		return nil, err
+	}
+	inspect := []containerCliInspect{}
+	err = json.Unmarshal(out, &inspect)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s inspect output: %s", r.bin, err)
+	}
+	for _, ins := range inspect {
+		var item *containerItem
+		for id, i := range byId {
+			if strings.HasPrefix(ins.Id, id) {
+				item = i
+				break
+			}
+		}
+		if item == nil {
+			continue
+		}
+		if ins.State.Status != "" {
+			item.State = ins.State.Status
+		}
+		item.Labels = ins.Config.Labels
+		if item.Labels == nil {
+			item.Labels = make(map[string]string)
+		}
+		published := make(map[string]bool)
+		for k, bindings := range ins.NetworkSettings.Ports {
+			for _, b := range bindings {
+				if b.HostPort == "" {
+					continue
+				}
+				item.Ports = append(item.Ports, containerPort{HostPort: b.HostPort, ContainerPort: strings.Split(k, "/")[0]})
+				published[k] = true
+				break
+			}
+		}
+		for k := range ins.Config.ExposedPorts {
+			if !published[k] {
+				item.Ports = append(item.Ports, containerPort{ContainerPort: strings.Split(k, "/")[0]})
+			}
+		}
+		sort.Slice(item.Ports, func(i, j int) bool {
+			return item.Ports[i].HostPort > item.Ports[j].HostPort
+		})
+		for _, n := range ins.NetworkSettings.Networks {
+			if n.IPAddress != "" {
+				item.IPs = append(item.IPs, n.IPAddress)
+			}
+		}
+	}
+	return list, nil
+}
+
+func (r *containerRuntimeCli) ContainerRun(spec *containerRunSpec) error {
+	params := []string{"run"}
+	for k, v := range spec.Labels {
+		params = append(params, "--label", k+"="+v)
+	}
+	if spec.Hostname != "" {
+		params = append(params, "--hostname", spec.Hostname)
+	}
+	params = append(params, spec.Switches...)
+	for _, p := range spec.Ports {
+		params = append(params, "-p", p)
+	}
+	if spec.Cpus != "" {
+		params = append(params, "--cpus="+spec.Cpus)
+	}
+	if spec.Memory != "" {
+		params = append(params, "-m", spec.Memory)
+	}
+	if spec.MemorySwap != "" {
+		params = append(params, "--memory-swap", spec.MemorySwap)
+	}
+	if spec.Network != "" {
+		params = append(params, "--network", spec.Network)
+	}
+	if spec.NoFile > 0 {
+		params = append(params, "--ulimit", fmt.Sprintf("nofile=%d:%d", spec.NoFile, spec.NoFile))
+	}
+	for _, rule := range spec.DeviceCgroupRules {
+		params = append(params, "--device-cgroup-rule="+rule)
+	}
+	if spec.Privileged {
+		params = append(params, "--privileged=true")
+	}
+	for _, c := range spec.CapAdd {
+		params = append(params, "--cap-add="+c)
+	}
+	params = append(params, "-td", "--name", spec.Name, spec.Image)
+	params = append(params, spec.Command...)
+	_, err := r.run(params...)
+	return err
+}
+
+func (r *containerRuntimeCli) ContainerStart(name string) error {
+	_, err := r.run("start", name)
+	return err
+}
+
+func (r *containerRuntimeCli) ContainerStop(name string, timeoutSeconds int) error {
+	_, err := r.run("stop", "-t", fmt.Sprintf("%d", timeoutSeconds), name)
+	return err
+}
+
+func (r *containerRuntimeCli) ContainerRemove(name string, force bool) error {
+	if force {
+		_, err := r.run("rm", "-f", name)
+		return err
+	}
+	_, err := r.run("rm", name)
+	return err
+}
+
+func (r *containerRuntimeCli) ContainerCommit(name string, image string) error {
+	_, err := r.run("container", "commit", name, image)
+	return err
+}
+
+func (r *containerRuntimeCli) Exec(spec *containerExecSpec) error {
+	params := []string{"exec"}
+	if spec.User != "" {
+		params = append(params, "-u", spec.User)
+	}
+	for _, e := range spec.Env {
+		params = append(params, "-e", e)
+	}
+	if spec.Tty && spec.Interactive {
+		params = append(params, "-ti")
+	} else if spec.Tty {
+		params = append(params, "-t")
+	} else if spec.Interactive {
+		params = append(params, "-i")
+	}
+	params = append(params, spec.Name)
+	params = append(params, spec.Command...)
+	cmd := exec.Command(r.bin, params...)
+	cmd.Stdin = spec.Stdin
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = spec.Stderr
+	return cmd.Run()
+}
+
+func (r *containerRuntimeCli) CopyTo(name string, source string, destination string) error {
+	_, err := r.run("cp", source, name+":"+destination)
+	return err
+}
+
+func (r *containerRuntimeCli) CopyFrom(name string, source string, destination string) error {
+	_, err := r.run("cp", name+":"+source, destination)
+	return err
+}
+
+func (r *containerRuntimeCli) ImageList() ([]*containerImage, error) {
+	out, err := r.run("image", "list", "-a", "--format", "{{.ID}}\t{{.Repository}}\t{{.Tag}}")
+	if err != nil {
+		return nil, err
+	}
+	list := []*containerImage{}
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		tt := strings.Split(strings.Trim(scanner.Text(), "'\" \t\r\n"), "\t")
+		if len(tt) < 3 {
+			continue
+		}
+		list = append(list, &containerImage{
+			ID:         tt[0],
+			Repository: strings.TrimPrefix(tt[1], "localhost/"),
+			Tag:        tt[2],
+		})
+	}
+	return list, nil
+}
+
+func (r *containerRuntimeCli) ImageRemove(id string) error {
+	_, err := r.run("rmi", id)
+	return err
+}
+
+func (r *containerRuntimeCli) NetworkCreate(name string, driver string, subnet string, options map[string]string) error {
+	params := []string{"network", "create", "-d", driver}
+	// nerdctl networks are always attachable and it does not accept the switch
+	if r.name != containerRuntimeNerdctl {
+		params = append(params, "--attachable")
+	}
+	if subnet != "" {
+		params = append(params, "--subnet", subnet)
+	}
+	keys := []string{}
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		params = append(params, "--opt", k+"="+options[k])
+	}
+	params = append(params, name)
+	_, err := r.run(params...)
+	return err
+}
+
+func (r *containerRuntimeCli) NetworkRemove(name string) error {
+	_, err := r.run("network", "rm", name)
+	return err
+}
+
+func (r *containerRuntimeCli) NetworkPrune() error {
+	_, err := r.run("network", "prune", "-f")
+	return err
+}
+
+func (r *containerRuntimeCli) NetworkList() ([]dockerListNetwork, error) {
+	out, err := r.run("network", "list", "--format", "{{.Name}}")
+	if err != nil {
+		return nil, err
+	}
+	params := []string{"network", "inspect"}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.Trim(line, "\r\t\n ")
+		if line == "" {
+			continue
+		}
+		params = append(params, line)
+	}
+	netlist := []dockerListNetwork{}
+	if len(params) == 2 {
+		return netlist, nil
+	}
+	out, err = r.run(params...)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(out, &netlist)
+	return netlist, err
+}
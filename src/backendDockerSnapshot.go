@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// snapshot images are named aerolab_snap-SNAPSHOT:CLUSTER_NODE_DISTRO_DISTROVERSION_ARCH_AEROSPIKEVERSION
+// the prefix must not contain dockerNameHeader, otherwise the images would be listed as templates
+const dockerSnapshotHeader = "aerolab_snap-"
+
+func dockerSnapshotImage(snapshotName string, clusterName string, node int, v backendVersion) string {
+	arch := "amd64"
+	if v.isArm {
+		arch = "arm64"
+	}
+	return fmt.Sprintf("%s%s:%s_%d_%s_%s_%s_%s", dockerSnapshotHeader, snapshotName, clusterName, node, v.distroName, v.distroVersion, arch, v.aerospikeVersion)
+}
+
+// dockerSnapshotParse parses a snapshot image name; the cluster name may contain underscores, so the tag is parsed from the right
+func dockerSnapshotParse(image string) (*backendSnapshot, bool) {
+	image = strings.TrimPrefix(image, "localhost/")
+	if !strings.HasPrefix(image, dockerSnapshotHeader) {
+		return nil, false
+	}
+	repo, tag, ok := strings.Cut(strings.TrimPrefix(image, dockerSnapshotHeader), ":")
+	if !ok {
+		return nil, false
+	}
+	parts := strings.Split(tag, "_")
+	if len(parts) < 6 {
+		return nil, false
+	}
+	l := len(parts)
+	node, err := strconv.Atoi(parts[l-5])
+	if err != nil {
+		return nil, false
+	}
+	return &backendSnapshot{
+		Name:        repo,
+		ClusterName: strings.Join(parts[0:l-5], "_"),
+		Node:        node,
+		Version: backendVersion{
+			distroName:       parts[l-4],
+			distroVersion:    parts[l-3],
+			aerospikeVersion: parts[l-1],
+			isArm:            parts[l-2] == "arm64",
+		},
+		ImageId: image,
+	}, true
+}
+
+func (d *backendDocker) SnapshotCreate(snapshotName string, clusterName string, nodes []int, versions map[int]backendVersion) error {
+	if d.client {
+		return errors.New("snapshots are only supported for server clusters")
+	}
+	for _, node := range nodes {
+		v, ok := versions[node]
+		if !ok {
+			return fmt.Errorf("version of node %d is not known", node)
+		}
+		image := dockerSnapshotImage(snapshotName, clusterName, node, v)
+		log.Printf("Committing node %d to %s", node, image)
+		err := d.rt.ContainerCommit(fmt.Sprintf(dockerNameHeader+"%s_%d", clusterName, node), image)
+		if err != nil {
+			return fmt.Errorf("node %d: %s", node, err)
+		}
+	}
+	return nil
+}
+
+func (d *backendDocker) SnapshotList() ([]backendSnapshot, error) {
+	images, err := d.rt.ImageList()
+	if err != nil {
+		return nil, err
+	}
+	list := []backendSnapshot{}
+	for _, image := range images {
+		snap, ok := dockerSnapshotParse(image.Repository + ":" + image.Tag)
+		if !ok {
+			continue
+		}
+		list = append(list, *snap)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Node < list[j].Node
+	})
+	return list, nil
+}
+
+func (d *backendDocker) SnapshotDestroy(snapshotName string) error {
+	list, err := d.SnapshotList()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, snap := range list {
+		if snap.Name != snapshotName {
+			continue
+		}
+		found = true
+		err = d.rt.ImageRemove(snap.ImageId)
+		if err != nil {
+			return fmt.Errorf("node %d: %s", snap.Node, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("snapshot %s not found", snapshotName)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// dockerNodeVersion is the aerospike version recorded for a container by aerospike upgrade; the container ID is kept so that a recreated container with the same name does not inherit it
+type dockerNodeVersion struct {
+	ID      string
+	Version string
+}
+
+// dockerNodeVersionsLock serializes updates of the versions file between nodes upgraded in parallel; the file lock covers parallel aerolab processes
+var dockerNodeVersionsLock sync.Mutex
+
+// container labels cannot be changed, so upgraded versions are kept in a state file, keyed by container name
+func dockerNodeVersionsFile() (string, error) {
+	rootDir, err := a.aerolabRootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rootDir, "docker-versions.json"), nil
+}
+
+func dockerNodeVersionsLoad() (map[string]dockerNodeVersion, error) {
+	versions := make(map[string]dockerNodeVersion)
+	fn, err := dockerNodeVersionsFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return versions, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, &versions)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fn, err)
+	}
+	return versions, nil
+}
+
+func (d *backendDocker) SetNodeVersion(clusterName string, nodes []int, aerospikeVersion string) error {
+	containers, err := d.rt.ContainerList(&containerListOpts{All: true, NamePrefix: dockerNameHeader, Detail: true})
+	if err != nil {
+		return err
+	}
+	fn, err := dockerNodeVersionsFile()
+	if err != nil {
+		return err
+	}
+	dockerNodeVersionsLock.Lock()
+	defer dockerNodeVersionsLock.Unlock()
+	unlock, err := lockFile(fn + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	versions, err := dockerNodeVersionsLoad()
+	if err != nil {
+		return err
+	}
+	// drop the versions of containers which no longer exist
+	existing := make(map[string]string)
+	for _, container := range containers {
+		existing[container.Name] = container.ID
+	}
+	for name, v := range versions {
+		if existing[name] != v.ID {
+			delete(versions, name)
+		}
+	}
+	for _, node := range nodes {
+		name := dockerNameHeader + clusterName + "_" + strconv.Itoa(node)
+		if id, ok := existing[name]; ok {
+			versions[name] = dockerNodeVersion{ID: id, Version: aerospikeVersion}
+		}
+	}
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(fn+".tmp", data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(fn+".tmp", fn)
+}
@@ -548,6 +548,47 @@ func (d *backendGcp) ClusterExpiry(zone string, clusterName string, expiry time.
 	return nil
 }
 
+func (d *backendGcp) SetNodeVersion(clusterName string, nodes []int, aerospikeVersion string) error {
+	j, err := d.Inventory("", []int{InventoryItemClusters})
+	d.WorkOnServers()
+	if err != nil {
+		return err
+	}
+	instances := make(map[string]gcpClusterExpiryInstances)
+	for _, jj := range j.Clusters {
+		nodeNo, _ := strconv.Atoi(jj.NodeNo)
+		if jj.ClusterName == clusterName && (len(nodes) == 0 || inslice.HasInt(nodes, nodeNo)) {
+			instances[jj.InstanceId] = gcpClusterExpiryInstances{jj.GcpLabelFingerprint, jj.GcpLabels, jj.Zone}
+		}
+	}
+	if len(instances) == 0 {
+		return errors.New("not found any instances for the given name")
+	}
+	ctx := context.Background()
+	instancesClient, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		return fmt.Errorf("NewInstancesRESTClient: %w", err)
+	}
+	defer instancesClient.Close()
+	for instanceName, labelData := range instances {
+		newLabels := labelData.labels
+		newLabels[gcpServerTagAerospikeVersion] = gcpResourceName(aerospikeVersion)
+		_, err = instancesClient.SetLabels(ctx, &computepb.SetLabelsInstanceRequest{
+			Project:  a.opts.Config.Backend.Project,
+			Zone:     labelData.zone,
+			Instance: instanceName,
+			InstancesSetLabelsRequestResource: &computepb.InstancesSetLabelsRequest{
+				LabelFingerprint: proto.String(labelData.labelFingerprint),
+				Labels:           newLabels,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *backendGcp) getInstancePricePerHourEnableService(zone string) (map[string]*gcpInstancePricing, error) {
 	log.Println("WARN: pricing API is not enabled, attempting to enable")
 	out, err := exec.Command("gcloud", "services", "enable", "--project", a.opts.Config.Backend.Project, "cloudbilling.googleapis.com").CombinedOutput()
@@ -3498,10 +3539,20 @@ func (d *backendGcp) DeployCluster(v backendVersion, name string, nodeCount int,
 			})
 		}
 	}
+	var snapshotLinks []string
+	if extra.snapshot != "" {
+		// disks are recreated from the snapshots, with the original types and sizes
+		disksInt, snapshotLinks, err = d.snapshotDisks(extra.snapshot)
+		if err != nil {
+			return err
+		}
+	}
 	disksInt = gcpAdjustDisksForInstanceType(disksInt, extra.instanceType)
 
 	var imageName string
-	if d.client {
+	if extra.snapshot != "" {
+		// the boot disk is restored from its snapshot
+	} else if d.client {
 		if extra.ami != "" {
 			imageName = extra.ami
 		} else {
@@ -3634,6 +3685,11 @@ func (d *backendGcp) DeployCluster(v backendVersion, name string, nodeCount int,
 				simage = proto.String(imageName)
 				boot = true
 			}
+			var ssnapshot *string
+			if nI < len(snapshotLinks) {
+				simage = nil
+				ssnapshot = proto.String(snapshotLinks[nI])
+			}
 			diskType := fmt.Sprintf("zones/%s/diskTypes/%s", extra.zone, nDisk.Type)
 			var diskSize *int64
 			var piops *int64
@@ -3659,6 +3715,7 @@ func (d *backendGcp) DeployCluster(v backendVersion, name string, nodeCount int,
 				InitializeParams: &computepb.AttachedDiskInitializeParams{
 					DiskSizeGb:            diskSize,
 					SourceImage:           simage,
+					SourceSnapshot:        ssnapshot,
 					DiskType:              proto.String(diskType),
 					ProvisionedIops:       piops,
 					ProvisionedThroughput: pput,
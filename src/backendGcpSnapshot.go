@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	gcpTagSnapshotName     = "aerolab4snapshot_name"
+	gcpTagSnapshotCluster  = "aerolab4snapshot_cluster"
+	gcpTagSnapshotNode     = "aerolab4snapshot_node"
+	gcpTagSnapshotDisk     = "aerolab4snapshot_disk"
+	gcpTagSnapshotDiskType = "aerolab4snapshot_disk_type"
+	gcpTagSnapshotZone     = "aerolab4snapshot_zone"
+)
+
+// SnapshotCreate takes a snapshot of each persistent disk of each node; local SSDs are not snapshotted
+func (d *backendGcp) SnapshotCreate(snapshotName string, clusterName string, nodes []int, versions map[int]backendVersion) error {
+	if d.client {
+		return errors.New("snapshots are only supported for server clusters")
+	}
+	details, err := d.getInstanceDetails(clusterName, nodes)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	instancesClient, err := compute.NewInstancesRESTClient(ctx)
+	if err != nil {
+		return fmt.Errorf("NewInstancesRESTClient: %w", err)
+	}
+	defer instancesClient.Close()
+	disksClient, err := compute.NewDisksRESTClient(ctx)
+	if err != nil {
+		return fmt.Errorf("NewDisksRESTClient: %w", err)
+	}
+	defer disksClient.Close()
+	snapshotsClient, err := compute.NewSnapshotsRESTClient(ctx)
+	if err != nil {
+		return fmt.Errorf("NewSnapshotsRESTClient: %w", err)
+	}
+	defer snapshotsClient.Close()
+	ops := []*compute.Operation{}
+	for _, node := range nodes {
+		detail, ok := details[node]
+		if !ok {
+			return fmt.Errorf("node %d not found in cluster %s", node, clusterName)
+		}
+		v, ok := versions[node]
+		if !ok {
+			return fmt.Errorf("version of node %d is not known", node)
+		}
+		instance, err := instancesClient.Get(ctx, &computepb.GetInstanceRequest{
+			Project:  a.opts.Config.Backend.Project,
+			Zone:     detail.instanceZone,
+			Instance: detail.instanceName,
+		})
+		if err != nil {
+			return fmt.Errorf("node %d: %s", node, err)
+		}
+		disks := []*computepb.AttachedDisk{}
+		for _, disk := range instance.Disks {
+			if disk.GetType() == computepb.AttachedDisk_PERSISTENT.String() {
+				disks = append(disks, disk)
+			}
+		}
+		// boot disk first, then in attachment order
+		sort.SliceStable(disks, func(i, j int) bool {
+			if disks[i].GetBoot() != disks[j].GetBoot() {
+				return disks[i].GetBoot()
+			}
+			return disks[i].GetIndex() < disks[j].GetIndex()
+		})
+		for diskNo, disk := range disks {
+			diskInfo, err := disksClient.Get(ctx, &computepb.GetDiskRequest{
+				Project: a.opts.Config.Backend.Project,
+				Zone:    detail.instanceZone,
+				Disk:    path.Base(disk.GetSource()),
+			})
+			if err != nil {
+				return fmt.Errorf("node %d: %s", node, err)
+			}
+			arch := "amd"
+			if v.isArm {
+				arch = "arm"
+			}
+			log.Printf("Snapshotting node %d disk %d", node, diskNo)
+			op, err := snapshotsClient.Insert(ctx, &computepb.InsertSnapshotRequest{
+				Project: a.opts.Config.Backend.Project,
+				SnapshotResource: &computepb.Snapshot{
+					Name:       proto.String(fmt.Sprintf("aerolab4-snap-%s-%d-%d", snapshotName, node, diskNo)),
+					SourceDisk: disk.Source,
+					Labels: map[string]string{
+						gcpTagSnapshotName:           snapshotName,
+						gcpTagSnapshotCluster:        clusterName,
+						gcpTagSnapshotNode:           strconv.Itoa(node),
+						gcpTagSnapshotDisk:           strconv.Itoa(diskNo),
+						gcpTagSnapshotDiskType:       path.Base(diskInfo.GetType()),
+						gcpTagSnapshotZone:           detail.instanceZone,
+						gcpServerTagOperatingSystem:  v.distroName,
+						gcpServerTagOSVersion:        gcpResourceName(v.distroVersion),
+						gcpServerTagAerospikeVersion: gcpResourceName(v.aerospikeVersion),
+						"arch":                       arch,
+					},
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("node %d: %s", node, err)
+			}
+			ops = append(ops, op)
+		}
+	}
+	log.Print("Waiting for snapshots to complete")
+	wg := new(sync.WaitGroup)
+	errs := make(chan error, len(ops))
+	for _, op := range ops {
+		wg.Add(1)
+		go func(op *compute.Operation) {
+			defer wg.Done()
+			if err := op.Wait(ctx); err != nil {
+				errs <- err
+			}
+		}(op)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return <-errs
+	}
+	return nil
+}
+
+func (d *backendGcp) snapshots() ([]*computepb.Snapshot, error) {
+	ctx := context.Background()
+	snapshotsClient, err := compute.NewSnapshotsRESTClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("NewSnapshotsRESTClient: %w", err)
+	}
+	defer snapshotsClient.Close()
+	list := []*computepb.Snapshot{}
+	it := snapshotsClient.List(ctx, &computepb.ListSnapshotsRequest{
+		Project: a.opts.Config.Backend.Project,
+		Filter:  proto.String("labels." + gcpTagSnapshotName + ":*"),
+	})
+	for {
+		snap, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, snap)
+	}
+	return list, nil
+}
+
+func (d *backendGcp) SnapshotList() ([]backendSnapshot, error) {
+	snaps, err := d.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	type diskSnap struct {
+		disk int
+		link string
+	}
+	nodes := make(map[string]*backendSnapshot)
+	disks := make(map[string][]diskSnap)
+	for _, snap := range snaps {
+		labels := snap.GetLabels()
+		key := labels[gcpTagSnapshotName] + "/" + labels[gcpTagSnapshotNode]
+		if _, ok := nodes[key]; !ok {
+			node, _ := strconv.Atoi(labels[gcpTagSnapshotNode])
+			created, _ := time.Parse(time.RFC3339, snap.GetCreationTimestamp())
+			nodes[key] = &backendSnapshot{
+				Name:        labels[gcpTagSnapshotName],
+				ClusterName: labels[gcpTagSnapshotCluster],
+				Node:        node,
+				Version: backendVersion{
+					distroName:       labels[gcpServerTagOperatingSystem],
+					distroVersion:    gcpResourceNameBack(labels[gcpServerTagOSVersion]),
+					aerospikeVersion: gcpAerospikeVersionDecode(labels[gcpServerTagAerospikeVersion]),
+					isArm:            labels["arch"] == "arm",
+				},
+				Zone:         labels[gcpTagSnapshotZone],
+				CreationTime: created,
+			}
+		}
+		diskNo, _ := strconv.Atoi(labels[gcpTagSnapshotDisk])
+		disks[key] = append(disks[key], diskSnap{diskNo, snap.GetSelfLink()})
+	}
+	list := []backendSnapshot{}
+	for key, snap := range nodes {
+		sort.Slice(disks[key], func(i, j int) bool {
+			return disks[key][i].disk < disks[key][j].disk
+		})
+		links := []string{}
+		for _, disk := range disks[key] {
+			links = append(links, disk.link)
+		}
+		snap.ImageId = strings.Join(links, ",")
+		list = append(list, *snap)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Node < list[j].Node
+	})
+	return list, nil
+}
+
+// snapshotDisks returns the disk definitions and snapshot links for restoring a node from a comma-separated list of disk snapshots
+func (d *backendGcp) snapshotDisks(links string) ([]*cloudDisk, []string, error) {
+	ctx := context.Background()
+	snapshotsClient, err := compute.NewSnapshotsRESTClient(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("NewSnapshotsRESTClient: %w", err)
+	}
+	defer snapshotsClient.Close()
+	disks := []*cloudDisk{}
+	linkList := strings.Split(links, ",")
+	for _, link := range linkList {
+		snap, err := snapshotsClient.Get(ctx, &computepb.GetSnapshotRequest{
+			Project:  a.opts.Config.Backend.Project,
+			Snapshot: path.Base(link),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("snapshot %s: %s", path.Base(link), err)
+		}
+		diskType := snap.GetLabels()[gcpTagSnapshotDiskType]
+		if diskType == "" {
+			diskType = "pd-balanced"
+		}
+		disks = append(disks, &cloudDisk{
+			Type: diskType,
+			Size: snap.GetDiskSizeGb(),
+		})
+	}
+	return disks, linkList, nil
+}
+
+func (d *backendGcp) SnapshotDestroy(snapshotName string) error {
+	snaps, err := d.snapshots()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	snapshotsClient, err := compute.NewSnapshotsRESTClient(ctx)
+	if err != nil {
+		return fmt.Errorf("NewSnapshotsRESTClient: %w", err)
+	}
+	defer snapshotsClient.Close()
+	ops := []*compute.Operation{}
+	for _, snap := range snaps {
+		if snap.GetLabels()[gcpTagSnapshotName] != snapshotName {
+			continue
+		}
+		op, err := snapshotsClient.Delete(ctx, &computepb.DeleteSnapshotRequest{
+			Project:  a.opts.Config.Backend.Project,
+			Snapshot: snap.GetName(),
+		})
+		if err != nil {
+			return fmt.Errorf("could not delete %s: %s", snap.GetName(), err)
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return fmt.Errorf("snapshot %s not found", snapshotName)
+	}
+	for _, op := range ops {
+		err = op.Wait(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
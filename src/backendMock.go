@@ -0,0 +1,1205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bestmethod/inslice"
+)
+
+// backendMock keeps clusters, clients, templates, volumes and networks in a local state file;
+// commands run on nodes are answered by the handlers registered in backendMockHandlers.go
+type backendMock struct {
+	server    bool
+	client    bool
+	stateFile string
+	lock      mockLock
+	state     *mockState
+}
+
+// mockLock serializes access to the mock state between goroutines and, using a lock file, between parallel aerolab processes;
+// the state is reloaded from the state file each time the lock is taken, so that changes made by other processes are not overwritten
+type mockLock struct {
+	mu         sync.Mutex
+	d          *backendMock
+	unlockFile func()
+}
+
+// lock takes the lock and reloads the state; the lock is held even if an error is returned
+func (l *mockLock) lock() error {
+	l.mu.Lock()
+	l.unlockFile = func() {}
+	err := os.MkdirAll(filepath.Dir(l.d.stateFile), 0700)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(l.d.stateFile + ".lock")
+	if err != nil {
+		return fmt.Errorf("could not lock mock state file: %s", err)
+	}
+	l.unlockFile = unlock
+	return l.d.load()
+}
+
+func (l *mockLock) Lock() {
+	err := l.lock()
+	if err != nil {
+		log.Printf("WARNING: %s", err)
+	}
+}
+
+func (l *mockLock) Unlock() {
+	l.unlockFile()
+	l.mu.Unlock()
+}
+
+type mockState struct {
+	Clusters  map[string]*mockCluster
+	Clients   map[string]*mockCluster
+	Templates []*mockTemplate
+	Volumes   map[string]*mockVolume
+	Networks  map[string]*mockNetwork
+	Snapshots []*mockSnapshot
+	LastIp    int
+}
+
+type mockTemplate struct {
+	DistroName       string
+	DistroVersion    string
+	AerospikeVersion string
+	IsArm            bool
+}
+
+type mockCluster struct {
+	Nodes  map[int]*mockNode
+	Roster map[string]*mockRoster
+}
+
+type mockRoster struct {
+	Roster        string
+	PendingRoster string
+}
+
+type mockNode struct {
+	Running    bool
+	Ip         string
+	Template   mockTemplate
+	ClientType string
+	Owner      string
+	Network    string
+	Expires    time.Time
+	Labels     map[string]string
+	Files      map[string]string
+	Volumes    []string
+	Quiesced   bool
+	Runtime    map[string]map[string]string // get-config context => key => value, for values changed at runtime
+	Version    string                       // aerospike version recorded after an upgrade; empty=template version
+}
+
+func (n *mockNode) aerospikeVersion() string {
+	if n.Version != "" {
+		return n.Version
+	}
+	return n.Template.AerospikeVersion
+}
+
+type mockSnapshot struct {
+	Name        string
+	ClusterName string
+	Node        int
+	Template    mockTemplate
+	Files       map[string]string
+	Created     time.Time
+}
+
+func (s *mockSnapshot) imageId() string {
+	return fmt.Sprintf("mock-snap-%s-%d", s.Name, s.Node)
+}
+
+type mockVolume struct {
+	Name     string
+	Zone     string
+	Size     int64
+	Desc     string
+	Owner    string
+	Created  time.Time
+	Expires  time.Duration
+	Tags     map[string]string
+	Attached []string
+}
+
+type mockNetwork struct {
+	Driver string
+	Subnet string
+	MTU    string
+}
+
+func init() {
+	addBackend("mock", &backendMock{})
+}
+
+func (d *backendMock) Init() error {
+	d.stateFile = string(a.opts.Config.Backend.MockStateFile)
+	if d.stateFile == "" {
+		rootDir, err := a.aerolabRootDir()
+		if err != nil {
+			return err
+		}
+		d.stateFile = path.Join(rootDir, "mock-state.json")
+	}
+	d.lock.d = d
+	err := d.lock.lock()
+	d.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return mockLoadRules(string(a.opts.Config.Backend.MockHandlersFile))
+}
+
+// load reads the state file; must be called with the lock held. On error, the state is left as it was
+func (d *backendMock) load() error {
+	state := &mockState{}
+	f, err := os.ReadFile(d.stateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read mock state file: %s", err)
+	}
+	if err == nil {
+		err = json.Unmarshal(f, state)
+		if err != nil {
+			return fmt.Errorf("could not parse mock state file %s: %s", d.stateFile, err)
+		}
+	}
+	if state.Clusters == nil {
+		state.Clusters = make(map[string]*mockCluster)
+	}
+	if state.Clients == nil {
+		state.Clients = make(map[string]*mockCluster)
+	}
+	if state.Volumes == nil {
+		state.Volumes = make(map[string]*mockVolume)
+	}
+	if state.Networks == nil {
+		state.Networks = make(map[string]*mockNetwork)
+	}
+	d.state = state
+	return nil
+}
+
+// save must be called with the lock held
+func (d *backendMock) save() error {
+	data, err := json.MarshalIndent(d.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(d.stateFile), 0700)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(d.stateFile+".tmp", data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(d.stateFile+".tmp", d.stateFile)
+}
+
+// clusters returns the servers or clients map, depending on WorkOnServers/WorkOnClients
+func (d *backendMock) clusters() map[string]*mockCluster {
+	if d.client {
+		return d.state.Clients
+	}
+	return d.state.Clusters
+}
+
+func (d *backendMock) getNodes(name string, nodes []int) ([]int, error) {
+	cluster, ok := d.clusters()[name]
+	if !ok {
+		return nil, errors.New("cluster not found")
+	}
+	if len(nodes) == 0 {
+		for node := range cluster.Nodes {
+			nodes = append(nodes, node)
+		}
+		sort.Ints(nodes)
+		return nodes, nil
+	}
+	for _, node := range nodes {
+		if _, ok := cluster.Nodes[node]; !ok {
+			return nil, fmt.Errorf("node %d not found in cluster %s", node, name)
+		}
+	}
+	return nodes, nil
+}
+
+func (d *backendMock) DisablePricingAPI() {}
+
+func (d *backendMock) DisableExpiryInstall() {}
+
+func (d *backendMock) CreateVolume(name string, zone string, tags []string, expires time.Duration, size int64, desc string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.state.Volumes[name]; ok {
+		return errors.New("volume already exists")
+	}
+	vol := &mockVolume{
+		Name:    name,
+		Zone:    zone,
+		Size:    size,
+		Desc:    desc,
+		Created: time.Now(),
+		Expires: expires,
+		Tags:    make(map[string]string),
+	}
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("tag %s is not in format key=value", tag)
+		}
+		vol.Tags[kv[0]] = kv[1]
+	}
+	vol.Owner = vol.Tags["owner"]
+	d.state.Volumes[name] = vol
+	return d.save()
+}
+
+func (d *backendMock) TagVolume(fsId string, tagName string, tagValue string, zone string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	vol, ok := d.state.Volumes[fsId]
+	if !ok {
+		return errors.New("volume not found")
+	}
+	vol.Tags[tagName] = tagValue
+	return d.save()
+}
+
+func (d *backendMock) DeleteVolume(name string, zone string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.state.Volumes[name]; !ok {
+		return errors.New("volume not found")
+	}
+	delete(d.state.Volumes, name)
+	return d.save()
+}
+
+func (d *backendMock) CreateMountTarget(volume *inventoryVolume, subnet string, secGroups []string) (inventoryMountTarget, error) {
+	return inventoryMountTarget{}, nil
+}
+
+func (d *backendMock) MountTargetAddSecurityGroup(mountTarget *inventoryMountTarget, volume *inventoryVolume, addGroups []string) error {
+	return nil
+}
+
+func (d *backendMock) GetAZName(subnetId string) (string, error) {
+	return "mock-zone", nil
+}
+
+func (d *backendMock) AttachVolume(name string, zone string, clusterName string, node int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	vol, ok := d.state.Volumes[name]
+	if !ok {
+		return errors.New("volume not found")
+	}
+	if _, err := d.getNodes(clusterName, []int{node}); err != nil {
+		return err
+	}
+	attachment := clusterName + "_" + strconv.Itoa(node)
+	if !inslice.HasString(vol.Attached, attachment) {
+		vol.Attached = append(vol.Attached, attachment)
+	}
+	n := d.clusters()[clusterName].Nodes[node]
+	if !inslice.HasString(n.Volumes, name) {
+		n.Volumes = append(n.Volumes, name)
+	}
+	return d.save()
+}
+
+func (d *backendMock) ResizeVolume(name string, zone string, newSize int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	vol, ok := d.state.Volumes[name]
+	if !ok {
+		return errors.New("volume not found")
+	}
+	vol.Size = newSize
+	return d.save()
+}
+
+func (d *backendMock) DetachVolume(name string, clusterName string, node int, zone string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	vol, ok := d.state.Volumes[name]
+	if !ok {
+		return errors.New("volume not found")
+	}
+	attachment := clusterName + "_" + strconv.Itoa(node)
+	for i, v := range vol.Attached {
+		if v == attachment {
+			vol.Attached = append(vol.Attached[:i], vol.Attached[i+1:]...)
+			break
+		}
+	}
+	if cluster, ok := d.clusters()[clusterName]; ok {
+		if n, ok := cluster.Nodes[node]; ok {
+			for i, v := range n.Volumes {
+				if v == name {
+					n.Volumes = append(n.Volumes[:i], n.Volumes[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return d.save()
+}
+
+func (d *backendMock) EnableServices() error {
+	return nil
+}
+
+func (d *backendMock) ExpiriesSystemInstall(intervalMinutes int, deployRegion string, awsDnsZoneId string, withEks bool) error {
+	return nil
+}
+
+func (d *backendMock) ExpiriesSystemRemove(region string) error {
+	return nil
+}
+
+func (d *backendMock) ExpiriesSystemFrequency(intervalMinutes int) error {
+	return nil
+}
+
+func (d *backendMock) ClusterExpiry(zone string, clusterName string, expiry time.Duration, nodes []int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(clusterName, nodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if expiry == 0 {
+			d.clusters()[clusterName].Nodes[node].Expires = time.Time{}
+		} else {
+			d.clusters()[clusterName].Nodes[node].Expires = time.Now().Add(expiry)
+		}
+	}
+	return d.save()
+}
+
+func (d *backendMock) SetNodeVersion(clusterName string, nodes []int, aerospikeVersion string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(clusterName, nodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		d.clusters()[clusterName].Nodes[node].Version = aerospikeVersion
+	}
+	return d.save()
+}
+
+func (d *backendMock) IsSystemArm(systemType string) (bool, error) {
+	return false, nil
+}
+
+func (d *backendMock) IsNodeArm(clusterName string, nodeNumber int) (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, err := d.getNodes(clusterName, []int{nodeNumber}); err != nil {
+		return false, err
+	}
+	return d.clusters()[clusterName].Nodes[nodeNumber].Template.IsArm, nil
+}
+
+func (d *backendMock) Arch() TypeArch {
+	return TypeArchUndef
+}
+
+func (d *backendMock) WorkOnClients() {
+	d.server = false
+	d.client = true
+}
+
+func (d *backendMock) WorkOnServers() {
+	d.server = true
+	d.client = false
+}
+
+func (d *backendMock) ClusterList() ([]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	list := []string{}
+	for name := range d.clusters() {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (d *backendMock) NodeListInCluster(name string) ([]int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.clusters()[name]; !ok {
+		return nil, nil
+	}
+	return d.getNodes(name, nil)
+}
+
+func (d *backendMock) ListTemplates() ([]backendVersion, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	list := []backendVersion{}
+	for _, t := range d.state.Templates {
+		list = append(list, backendVersion{t.DistroName, t.DistroVersion, t.AerospikeVersion, t.IsArm})
+	}
+	return list, nil
+}
+
+func (d *backendMock) DeployTemplate(v backendVersion, script string, files []fileListReader, extra *backendExtra) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, t := range d.state.Templates {
+		if t.DistroName == v.distroName && t.DistroVersion == v.distroVersion && t.AerospikeVersion == v.aerospikeVersion && t.IsArm == v.isArm {
+			return nil
+		}
+	}
+	d.state.Templates = append(d.state.Templates, &mockTemplate{v.distroName, v.distroVersion, v.aerospikeVersion, v.isArm})
+	return d.save()
+}
+
+func (d *backendMock) TemplateDestroy(v backendVersion) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i, t := range d.state.Templates {
+		if t.DistroName == v.distroName && t.DistroVersion == v.distroVersion && t.AerospikeVersion == v.aerospikeVersion && t.IsArm == v.isArm {
+			d.state.Templates = append(d.state.Templates[:i], d.state.Templates[i+1:]...)
+			return d.save()
+		}
+	}
+	return errors.New("template not found")
+}
+
+func (d *backendMock) VacuumTemplates() error {
+	return nil
+}
+
+func (d *backendMock) VacuumTemplate(v backendVersion) error {
+	return nil
+}
+
+func (d *backendMock) DeployCluster(v backendVersion, name string, nodeCount int, extra *backendExtra) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var snapshot *mockSnapshot
+	if extra.snapshot != "" {
+		for _, snap := range d.state.Snapshots {
+			if snap.imageId() == extra.snapshot {
+				snapshot = snap
+			}
+		}
+		if snapshot == nil {
+			return fmt.Errorf("snapshot %s not found", extra.snapshot)
+		}
+	} else if !d.client {
+		found := false
+		for _, t := range d.state.Templates {
+			if t.DistroName == v.distroName && t.DistroVersion == v.distroVersion && t.AerospikeVersion == v.aerospikeVersion && t.IsArm == v.isArm {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("template not found")
+		}
+	}
+	cluster, ok := d.clusters()[name]
+	if !ok {
+		cluster = &mockCluster{
+			Nodes:  make(map[int]*mockNode),
+			Roster: make(map[string]*mockRoster),
+		}
+		d.clusters()[name] = cluster
+	}
+	start := 1
+	for node := range cluster.Nodes {
+		if node >= start {
+			start = node + 1
+		}
+	}
+	labels := make(map[string]string)
+	owner := ""
+	for _, label := range append(extra.labels, extra.tags...) {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if kv[0] == "owner" {
+			owner = kv[1]
+			continue
+		}
+		labels[kv[0]] = kv[1]
+	}
+	for node := start; node < start+nodeCount; node++ {
+		d.state.LastIp++
+		n := &mockNode{
+			Running:    true,
+			Ip:         fmt.Sprintf("10.128.%d.%d", d.state.LastIp/250, d.state.LastIp%250+2),
+			Template:   mockTemplate{v.distroName, v.distroVersion, v.aerospikeVersion, v.isArm},
+			ClientType: extra.clientType,
+			Owner:      owner,
+			Network:    extra.network,
+			Expires:    extra.expiresTime,
+			Labels:     make(map[string]string),
+			Files:      make(map[string]string),
+		}
+		for k, v := range labels {
+			n.Labels[k] = v
+		}
+		if snapshot != nil {
+			n.Template = snapshot.Template
+			for fn, contents := range snapshot.Files {
+				n.Files[fn] = contents
+			}
+		} else if !d.client {
+			conf := mockDefaultAerospikeConf(v.aerospikeVersion)
+			n.Files["/etc/aerospike/aerospike.conf"] = conf
+		}
+		cluster.Nodes[node] = n
+	}
+	return d.save()
+}
+
+func (d *backendMock) CopyFilesToCluster(name string, files []fileList, nodes []int) error {
+	fr := []fileListReader{}
+	for _, file := range files {
+		fr = append(fr, fileListReader{filePath: file.filePath, fileContents: strings.NewReader(file.fileContents), fileSize: file.fileSize})
+	}
+	return d.CopyFilesToClusterReader(name, fr, nodes)
+}
+
+func (d *backendMock) CopyFilesToClusterReader(name string, files []fileListReader, nodes []int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(name, nodes)
+	if err != nil {
+		return err
+	}
+	contents := []string{}
+	for _, file := range files {
+		c, err := io.ReadAll(file.fileContents)
+		if err != nil {
+			return err
+		}
+		contents = append(contents, string(c))
+	}
+	for _, node := range nodes {
+		for i, file := range files {
+			d.clusters()[name].Nodes[node].Files[file.filePath] = contents[i]
+		}
+	}
+	return d.save()
+}
+
+func (d *backendMock) RunCommands(clusterName string, commands [][]string, nodes []int) ([][]byte, error) {
+	d.lock.Lock()
+	nodes, err := d.getNodes(clusterName, nodes)
+	d.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var fout [][]byte
+	for _, node := range nodes {
+		for _, command := range commands {
+			out, exitCode, err := d.runHandlers(clusterName, node, command, nil)
+			fout = append(fout, out)
+			if err != nil {
+				return fout, err
+			}
+			if exitCode != 0 {
+				return fout, fmt.Errorf("error running %s: exit status %d", command, exitCode)
+			}
+		}
+	}
+	return fout, nil
+}
+
+func (d *backendMock) GetClusterNodeIps(name string) ([]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	ips := []string{}
+	for _, node := range nodes {
+		ips = append(ips, d.clusters()[name].Nodes[node].Ip)
+	}
+	return ips, nil
+}
+
+func (d *backendMock) setRunning(name string, nodes []int, running bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(name, nodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		d.clusters()[name].Nodes[node].Running = running
+	}
+	return d.save()
+}
+
+func (d *backendMock) ClusterStart(name string, nodes []int) error {
+	return d.setRunning(name, nodes, true)
+}
+
+func (d *backendMock) ClusterStop(name string, nodes []int) error {
+	return d.setRunning(name, nodes, false)
+}
+
+func (d *backendMock) ClusterDestroy(name string, nodes []int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(name, nodes)
+	if err != nil {
+		return err
+	}
+	cluster := d.clusters()[name]
+	for _, node := range nodes {
+		delete(cluster.Nodes, node)
+	}
+	if len(cluster.Nodes) == 0 {
+		delete(d.clusters(), name)
+	}
+	return d.save()
+}
+
+func (d *backendMock) AttachAndRun(clusterName string, node int, command []string, isInteractive bool) (err error) {
+	return d.RunCustomOut(clusterName, node, command, os.Stdin, os.Stdout, os.Stderr, isInteractive, nil)
+}
+
+func (d *backendMock) RunCustomOut(clusterName string, node int, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, isInteractive bool, dockerForceUser *string) (err error) {
+	d.lock.Lock()
+	_, err = d.getNodes(clusterName, []int{node})
+	d.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(command) == 0 {
+		command = []string{"/bin/bash"}
+	}
+	out, exitCode, err := d.runHandlers(clusterName, node, command, stdin)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		if stderr != nil {
+			stderr.Write(out)
+		}
+		return fmt.Errorf("exit status %d", exitCode)
+	}
+	if stdout != nil {
+		_, err = stdout.Write(out)
+	}
+	return err
+}
+
+func (d *backendMock) GetNodeIpMap(name string, internalIPs bool) (map[int]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	ips := make(map[int]string)
+	for _, node := range nodes {
+		n := d.clusters()[name].Nodes[node]
+		if !n.Running {
+			continue
+		}
+		ips[node] = n.Ip
+	}
+	return ips, nil
+}
+
+func (d *backendMock) ClusterListFull(isJson bool, owner string, pager bool, isPretty bool, sort []string, renderer string, theme string, noNotes bool) (string, error) {
+	a.opts.Inventory.List.Json = isJson
+	a.opts.Inventory.List.Pager = pager
+	a.opts.Inventory.List.JsonPretty = isPretty
+	a.opts.Inventory.List.SortBy = sort
+	a.opts.Inventory.List.RenderType = renderer
+	a.opts.Inventory.List.Theme = theme
+	a.opts.Inventory.List.NoNotes = noNotes
+	return "", a.opts.Inventory.List.run(d.server, d.client, false, false, false)
+}
+
+func (d *backendMock) TemplateListFull(isJson bool, pager bool, isPretty bool, sort []string, renderer string, theme string, noNotes bool) (string, error) {
+	a.opts.Inventory.List.Json = isJson
+	a.opts.Inventory.List.Pager = pager
+	a.opts.Inventory.List.JsonPretty = isPretty
+	a.opts.Inventory.List.SortBy = sort
+	a.opts.Inventory.List.RenderType = renderer
+	a.opts.Inventory.List.Theme = theme
+	a.opts.Inventory.List.NoNotes = noNotes
+	return "", a.opts.Inventory.List.run(false, false, true, false, false)
+}
+
+func (d *backendMock) Upload(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
+	files := []fileListReader{}
+	err := filepath.Walk(source, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		dst := destination
+		if fpath != source {
+			rel, err := filepath.Rel(source, fpath)
+			if err != nil {
+				return err
+			}
+			dst = path.Join(destination, filepath.ToSlash(rel))
+		}
+		contents, err := os.ReadFile(fpath)
+		if err != nil {
+			return err
+		}
+		files = append(files, fileListReader{filePath: dst, fileContents: strings.NewReader(string(contents)), fileSize: len(contents)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return d.CopyFilesToClusterReader(clusterName, files, []int{node})
+}
+
+func (d *backendMock) Download(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, err := d.getNodes(clusterName, []int{node}); err != nil {
+		return err
+	}
+	files := d.clusters()[clusterName].Nodes[node].Files
+	if contents, ok := files[source]; ok {
+		if st, err := os.Stat(destination); err == nil && st.IsDir() {
+			destination = filepath.Join(destination, path.Base(source))
+		}
+		return os.WriteFile(destination, []byte(contents), 0644)
+	}
+	found := false
+	prefix := strings.TrimSuffix(source, "/") + "/"
+	for fpath, contents := range files {
+		if !strings.HasPrefix(fpath, prefix) {
+			continue
+		}
+		found = true
+		dst := filepath.Join(destination, filepath.FromSlash(strings.TrimPrefix(fpath, prefix)))
+		err := os.MkdirAll(filepath.Dir(dst), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(dst, []byte(contents), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s: no such file or directory", source)
+	}
+	return nil
+}
+
+func (d *backendMock) DeleteSecurityGroups(vpc string, namePrefix string, internal bool) error {
+	return nil
+}
+
+func (d *backendMock) CreateSecurityGroups(vpc string, namePrefix string, isAgi bool, extraPorts []string, noDefaults bool) error {
+	return nil
+}
+
+func (d *backendMock) LockSecurityGroups(ip string, lockSSH bool, vpc string, namePrefix string, isAgi bool, extraPorts []string, noDefaults bool) error {
+	return nil
+}
+
+func (d *backendMock) AssignSecurityGroups(clusterName string, names []string, vpcOrZone string, remove bool, performLocking bool, extraPorts []string, noDefaults bool) error {
+	return nil
+}
+
+func (d *backendMock) ListSecurityGroups() error {
+	return nil
+}
+
+func (d *backendMock) ListSubnets() error {
+	return nil
+}
+
+func (d *backendMock) CreateNetwork(name string, driver string, subnet string, mtu string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.state.Networks[name]; ok {
+		return errors.New("network already exists")
+	}
+	d.state.Networks[name] = &mockNetwork{
+		Driver: driver,
+		Subnet: subnet,
+		MTU:    mtu,
+	}
+	return d.save()
+}
+
+func (d *backendMock) DeleteNetwork(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.state.Networks[name]; !ok {
+		return errors.New("network not found")
+	}
+	delete(d.state.Networks, name)
+	return d.save()
+}
+
+func (d *backendMock) PruneNetworks() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	used := make(map[string]bool)
+	for _, clusters := range []map[string]*mockCluster{d.state.Clusters, d.state.Clients} {
+		for _, cluster := range clusters {
+			for _, node := range cluster.Nodes {
+				used[node.Network] = true
+			}
+		}
+	}
+	for name := range d.state.Networks {
+		if !used[name] {
+			delete(d.state.Networks, name)
+		}
+	}
+	return d.save()
+}
+
+func (d *backendMock) ListNetworks(csv bool, writer io.Writer) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	names := []string{}
+	for name := range d.state.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if csv {
+		fmt.Fprintln(writer, "Name,Driver,Subnets,MTU")
+	} else {
+		fmt.Fprintln(writer, "NAME\tDRIVER\tSUBNETS\tMTU")
+	}
+	for _, name := range names {
+		n := d.state.Networks[name]
+		if csv {
+			fmt.Fprintf(writer, "%s,%s,%s,%s\n", name, n.Driver, n.Subnet, n.MTU)
+		} else {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", name, n.Driver, n.Subnet, n.MTU)
+		}
+	}
+	return nil
+}
+
+func (d *backendMock) Inventory(owner string, inventoryItems []int) (inventoryJson, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ij := inventoryJson{}
+	if inslice.HasInt(inventoryItems, InventoryItemTemplates) {
+		for _, t := range d.state.Templates {
+			arch := "amd64"
+			if t.IsArm {
+				arch = "arm64"
+			}
+			ij.Templates = append(ij.Templates, inventoryTemplate{
+				AerospikeVersion: t.AerospikeVersion,
+				Distribution:     t.DistroName,
+				OSVersion:        t.DistroVersion,
+				Arch:             arch,
+			})
+		}
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemFirewalls) {
+		for name, n := range d.state.Networks {
+			ij.FirewallRules = append(ij.FirewallRules, inventoryFirewallRule{
+				Docker: &inventoryFirewallRuleDocker{
+					NetworkName:   name,
+					NetworkDriver: n.Driver,
+					Subnets:       n.Subnet,
+					MTU:           n.MTU,
+				},
+			})
+		}
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemVolumes) {
+		for _, vol := range d.state.Volumes {
+			ij.Volumes = append(ij.Volumes, inventoryVolume{
+				Name:                 vol.Name,
+				FileSystemId:         vol.Name,
+				AvailabilityZoneName: vol.Zone,
+				CreationTime:         vol.Created,
+				SizeBytes:            int(vol.Size),
+				SizeString:           convSize(vol.Size),
+				Owner:                vol.Owner,
+				Tags:                 vol.Tags,
+			})
+		}
+	}
+	nodeInfo := func(n *mockNode) (state string, arch string, expires string, labels map[string]string) {
+		state = "stopped"
+		if n.Running {
+			state = "running"
+		}
+		arch = "amd64"
+		if n.Template.IsArm {
+			arch = "arm64"
+		}
+		if !n.Expires.IsZero() {
+			expires = n.Expires.Format(time.RFC3339)
+		}
+		labels = make(map[string]string)
+		for k, v := range n.Labels {
+			labels[k] = v
+		}
+		return
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemClusters) {
+		for name, cluster := range d.state.Clusters {
+			for nodeNo, n := range cluster.Nodes {
+				if owner != "" && n.Owner != owner {
+					continue
+				}
+				state, arch, expires, labels := nodeInfo(n)
+				ij.Clusters = append(ij.Clusters, inventoryCluster{
+					ClusterName:      name,
+					NodeNo:           strconv.Itoa(nodeNo),
+					Expires:          expires,
+					State:            state,
+					PrivateIp:        n.Ip,
+					Owner:            n.Owner,
+					AerospikeVersion: n.aerospikeVersion(),
+					Arch:             arch,
+					Distribution:     n.Template.DistroName,
+					OSVersion:        n.Template.DistroVersion,
+					InstanceId:       fmt.Sprintf("mock-%s-%d", name, nodeNo),
+					IsRunning:        n.Running,
+					DockerLabels:     labels,
+				})
+			}
+		}
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemClients) {
+		for name, cluster := range d.state.Clients {
+			for nodeNo, n := range cluster.Nodes {
+				if owner != "" && n.Owner != owner {
+					continue
+				}
+				state, arch, expires, labels := nodeInfo(n)
+				ij.Clients = append(ij.Clients, inventoryClient{
+					ClientName:       name,
+					NodeNo:           strconv.Itoa(nodeNo),
+					Expires:          expires,
+					State:            state,
+					PrivateIp:        n.Ip,
+					ClientType:       n.ClientType,
+					Owner:            n.Owner,
+					AerospikeVersion: n.aerospikeVersion(),
+					Arch:             arch,
+					Distribution:     n.Template.DistroName,
+					OSVersion:        n.Template.DistroVersion,
+					InstanceId:       fmt.Sprintf("mock-c-%s-%d", name, nodeNo),
+					IsRunning:        n.Running,
+					DockerLabels:     labels,
+				})
+			}
+		}
+	}
+	return ij, nil
+}
+
+func (d *backendMock) GetInstanceTypes(minCpu int, maxCpu int, minRam float64, maxRam float64, minDisks int, maxDisks int, findArm bool, gcpZone string) ([]instanceType, error) {
+	return nil, nil
+}
+
+func (d *backendMock) SetLabel(clusterName string, key string, value string, gcpZone string) error {
+	return d.Tag(clusterName, key, value)
+}
+
+func (d *backendMock) GetKeyPath(clusterName string) (keyPath string, err error) {
+	return "", nil
+}
+
+func (d *backendMock) DomainCreate(zoneId string, host string, IP string, wait bool) (err error) {
+	return nil
+}
+
+func (d *backendMock) GetInstanceIpMap(name string, internalIPs bool) (map[string]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	ips := make(map[string]string)
+	for _, node := range nodes {
+		ips[fmt.Sprintf("mock-%s-%d", name, node)] = d.clusters()[name].Nodes[node].Ip
+	}
+	return ips, nil
+}
+
+func (d *backendMock) ExpiriesUpdateZoneID(zoneId string) error {
+	return nil
+}
+
+// map[instanceId]map[key]value
+func (d *backendMock) GetInstanceTags(name string) (map[string]map[string]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]map[string]string)
+	for _, node := range nodes {
+		t := make(map[string]string)
+		for k, v := range d.clusters()[name].Nodes[node].Labels {
+			t[k] = v
+		}
+		tags[fmt.Sprintf("mock-%s-%d", name, node)] = t
+	}
+	return tags, nil
+}
+
+func (d *backendMock) Tag(name string, key string, value string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	nodes, err := d.getNodes(name, nil)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		d.clusters()[name].Nodes[node].Labels[key] = value
+	}
+	return d.save()
+}
+
+func (d *backendMock) SnapshotCreate(snapshotName string, clusterName string, nodes []int, versions map[int]backendVersion) error {
+	if d.client {
+		return errors.New("snapshots are only supported for server clusters")
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	cluster, ok := d.clusters()[clusterName]
+	if !ok {
+		return fmt.Errorf("cluster %s not found", clusterName)
+	}
+	for _, node := range nodes {
+		n, ok := cluster.Nodes[node]
+		if !ok {
+			return fmt.Errorf("node %d not found in cluster %s", node, clusterName)
+		}
+		snap := &mockSnapshot{
+			Name:        snapshotName,
+			ClusterName: clusterName,
+			Node:        node,
+			Template:    n.Template,
+			Files:       make(map[string]string),
+			Created:     time.Now(),
+		}
+		for fn, contents := range n.Files {
+			snap.Files[fn] = contents
+		}
+		d.state.Snapshots = append(d.state.Snapshots, snap)
+	}
+	return d.save()
+}
+
+func (d *backendMock) SnapshotList() ([]backendSnapshot, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	list := []backendSnapshot{}
+	for _, snap := range d.state.Snapshots {
+		list = append(list, backendSnapshot{
+			Name:         snap.Name,
+			ClusterName:  snap.ClusterName,
+			Node:         snap.Node,
+			Version:      backendVersion{snap.Template.DistroName, snap.Template.DistroVersion, snap.Template.AerospikeVersion, snap.Template.IsArm},
+			ImageId:      snap.imageId(),
+			CreationTime: snap.Created,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Node < list[j].Node
+	})
+	return list, nil
+}
+
+func (d *backendMock) SnapshotDestroy(snapshotName string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	snaps := []*mockSnapshot{}
+	for _, snap := range d.state.Snapshots {
+		if snap.Name != snapshotName {
+			snaps = append(snaps, snap)
+		}
+	}
+	if len(snaps) == len(d.state.Snapshots) {
+		return fmt.Errorf("snapshot %s not found", snapshotName)
+	}
+	d.state.Snapshots = snaps
+	return d.save()
+}
+
+// default configuration file, as installed by the template on a real backend
+func mockDefaultAerospikeConf(version string) string {
+	storage := "    memory-size 1G\n    storage-engine memory\n"
+	if major, err := strconv.Atoi(strings.Split(version, ".")[0]); err == nil && major >= 7 {
+		storage = "    storage-engine memory {\n        data-size 1G\n    }\n"
+	}
+	return `service {
+    proto-fd-max 15000
+}
+logging {
+    console {
+        context any info
+    }
+}
+network {
+    service {
+        address any
+        port 3000
+    }
+    heartbeat {
+        mode mesh
+        port 3002
+        interval 150
+        timeout 10
+    }
+    fabric {
+        port 3001
+    }
+    info {
+        port 3003
+    }
+}
+namespace test {
+    replication-factor 2
+` + storage + `}
+`
+}
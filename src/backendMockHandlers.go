@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bestmethod/inslice"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+	"gopkg.in/yaml.v3"
+)
+
+// mockCommand describes a command executed on a mock backend node
+type mockCommand struct {
+	ClusterName string
+	IsClient    bool
+	Node        int
+	Command     []string
+	Stdin       io.Reader
+	node        *mockNode
+	cluster     *mockCluster
+	changed     bool // set by handlers which modify node state, so it gets saved
+}
+
+// mockHandler answers a command executed on a mock node; if handled is false, the next handler is tried
+type mockHandler func(cmd *mockCommand) (out []byte, exitCode int, handled bool)
+
+var mockHandlers []mockHandler
+
+// addMockHandler registers a fake command handler for the mock backend; handlers are tried in order of registration
+func addMockHandler(h mockHandler) {
+	mockHandlers = append(mockHandlers, h)
+}
+
+func init() {
+	addMockHandler(mockHandleRules)
+	addMockHandler(mockHandleFiles)
+	addMockHandler(mockHandleAsinfo)
+	addMockHandler(mockHandleIsStable)
+	addMockHandler(mockHandleService)
+}
+
+// canned responses from the mock handlers file, matched against the command joined with spaces
+type mockRule struct {
+	Match    string `yaml:"match"`
+	Cluster  string `yaml:"cluster"`
+	Nodes    []int  `yaml:"nodes"`
+	Client   bool   `yaml:"client"`
+	Stdout   string `yaml:"stdout"`
+	ExitCode int    `yaml:"exitCode"`
+	re       *regexp.Regexp
+}
+
+var mockRules []*mockRule
+
+func mockLoadRules(fileName string) error {
+	mockRules = nil
+	if fileName == "" {
+		rootDir, err := a.aerolabRootDir()
+		if err != nil {
+			return err
+		}
+		fileName = path.Join(rootDir, "mock-handlers.yaml")
+		if _, err := os.Stat(fileName); err != nil {
+			return nil
+		}
+	}
+	f, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("could not read mock handlers file: %s", err)
+	}
+	err = yaml.Unmarshal(f, &mockRules)
+	if err != nil {
+		return fmt.Errorf("could not parse mock handlers file %s: %s", fileName, err)
+	}
+	for _, rule := range mockRules {
+		rule.re, err = regexp.Compile(rule.Match)
+		if err != nil {
+			return fmt.Errorf("mock handlers file %s: invalid match %q: %s", fileName, rule.Match, err)
+		}
+	}
+	return nil
+}
+
+// runHandlers executes a command on a mock node using the registered handlers; unhandled commands are logged and succeed with no output
+func (d *backendMock) runHandlers(clusterName string, node int, command []string, stdin io.Reader) (out []byte, exitCode int, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	cluster, ok := d.clusters()[clusterName]
+	if !ok {
+		return nil, 0, fmt.Errorf("cluster %s not found", clusterName)
+	}
+	n, ok := cluster.Nodes[node]
+	if !ok {
+		return nil, 0, fmt.Errorf("node %d not found in cluster %s", node, clusterName)
+	}
+	if !n.Running {
+		return []byte("node is not running"), 1, nil
+	}
+	cmd := &mockCommand{
+		ClusterName: clusterName,
+		IsClient:    d.client,
+		Node:        node,
+		Command:     command,
+		Stdin:       stdin,
+		node:        n,
+		cluster:     cluster,
+	}
+	handled := false
+	for _, h := range mockHandlers {
+		out, exitCode, handled = h(cmd)
+		if handled {
+			break
+		}
+	}
+	if !handled {
+		log.Printf("MOCK: no handler for command on %s node %d, returning no output: %s", clusterName, node, strings.Join(command, " "))
+	}
+	if cmd.changed {
+		err = d.save()
+	}
+	return out, exitCode, err
+}
+
+func mockHandleRules(cmd *mockCommand) ([]byte, int, bool) {
+	line := strings.Join(cmd.Command, " ")
+	for _, rule := range mockRules {
+		if rule.Client != cmd.IsClient {
+			continue
+		}
+		if rule.Cluster != "" && rule.Cluster != cmd.ClusterName {
+			continue
+		}
+		if len(rule.Nodes) > 0 {
+			found := false
+			for _, n := range rule.Nodes {
+				if n == cmd.Node {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		if rule.re.MatchString(line) {
+			return []byte(rule.Stdout), rule.ExitCode, true
+		}
+	}
+	return nil, 0, false
+}
+
+// basic file operations on the node's stored files
+func mockHandleFiles(cmd *mockCommand) ([]byte, int, bool) {
+	if len(cmd.Command) == 1 && cmd.Command[0] == "hostname" {
+		return []byte(cmd.node.Files["/etc/hostname"]), 0, true
+	}
+	if len(cmd.Command) < 2 {
+		return nil, 0, false
+	}
+	files := cmd.node.Files
+	switch cmd.Command[0] {
+	case "cat":
+		out := []byte{}
+		for _, f := range cmd.Command[1:] {
+			contents, ok := files[f]
+			if !ok {
+				return []byte("cat: " + f + ": No such file or directory\n"), 1, true
+			}
+			out = append(out, []byte(contents)...)
+		}
+		return out, 0, true
+	case "ls":
+		out := []byte{}
+		for _, f := range cmd.Command[1:] {
+			if strings.HasPrefix(f, "-") {
+				continue
+			}
+			if _, ok := files[f]; ok {
+				out = append(out, []byte(f+"\n")...)
+				continue
+			}
+			prefix := strings.TrimSuffix(f, "/") + "/"
+			list := []string{}
+			for fn := range files {
+				if strings.HasPrefix(fn, prefix) {
+					list = append(list, strings.Split(strings.TrimPrefix(fn, prefix), "/")[0])
+				}
+			}
+			if len(list) == 0 {
+				return []byte("ls: cannot access '" + f + "': No such file or directory\n"), 2, true
+			}
+			sort.Strings(list)
+			prev := ""
+			for _, item := range list {
+				if item != prev {
+					out = append(out, []byte(item+"\n")...)
+				}
+				prev = item
+			}
+		}
+		return out, 0, true
+	case "rm":
+		for _, f := range cmd.Command[1:] {
+			if strings.HasPrefix(f, "-") {
+				continue
+			}
+			prefix := strings.TrimSuffix(f, "/") + "/"
+			for fn := range files {
+				if fn == f || strings.HasPrefix(fn, prefix) {
+					delete(files, fn)
+					cmd.changed = true
+				}
+			}
+		}
+		return nil, 0, true
+	case "mkdir":
+		return nil, 0, true
+	}
+	return nil, 0, false
+}
+
+// canned asinfo responses, based on the node and cluster state
+func mockHandleAsinfo(cmd *mockCommand) ([]byte, int, bool) {
+	if len(cmd.Command) == 0 || path.Base(cmd.Command[0]) != "asinfo" {
+		return nil, 0, false
+	}
+	req := ""
+	for i, arg := range cmd.Command {
+		if arg == "-v" && i+1 < len(cmd.Command) {
+			req = cmd.Command[i+1]
+		}
+	}
+	out, exitCode := mockAsinfo(cmd, req)
+	return []byte(out + "\n"), exitCode, true
+}
+
+// the is-stable wait script runs asinfo in a loop; answer it as if asinfo returned on first try
+func mockHandleIsStable(cmd *mockCommand) ([]byte, int, bool) {
+	if len(cmd.Command) != 2 || path.Base(cmd.Command[0]) != "bash" {
+		return nil, 0, false
+	}
+	script := cmd.node.Files[cmd.Command[1]]
+	ind := strings.Index(script, "asinfo -v 'cluster-stable:")
+	if ind < 0 {
+		return nil, 0, false
+	}
+	req := strings.Split(script[ind+len("asinfo -v '"):], "'")[0]
+	out, exitCode := mockAsinfo(cmd, req)
+	if exitCode != 0 || strings.HasPrefix(out, "ERROR") {
+		return []byte(out + "\n"), 1, true
+	}
+	return []byte("AEROLAB-SUCCESS-CLUSTER-KEY:" + out + "\n"), 0, true
+}
+
+func mockNodeId(node int) string {
+	return fmt.Sprintf("BB9%013X", node)
+}
+
+func mockAsinfo(cmd *mockCommand, req string) (string, int) {
+	req = strings.ReplaceAll(req, "\\;", ";")
+	name, paramString, _ := strings.Cut(req, ":")
+	params := make(map[string]string)
+	for _, p := range strings.Split(paramString, ";") {
+		k, v, _ := strings.Cut(p, "=")
+		params[k] = v
+	}
+	running := []int{}
+	for n, node := range cmd.cluster.Nodes {
+		if node.Running {
+			running = append(running, n)
+		}
+	}
+	sort.Ints(running)
+	observed := []string{}
+	for _, n := range running {
+		observed = append(observed, mockNodeId(n))
+	}
+	conf, _ := aeroconf.Parse(bytes.NewReader([]byte(cmd.node.Files["/etc/aerospike/aerospike.conf"])))
+	if ns, ok := strings.CutPrefix(name, "namespace/"); ok {
+		if conf == nil || conf.Type("namespace "+ns) != aeroconf.ValueStanza {
+			return "type=unknown", 0
+		}
+		stats := []string{}
+		nsConf := conf.Stanza("namespace " + ns)
+		for _, key := range nsConf.ListKeys() {
+			if nsConf.Type(key) != aeroconf.ValueString {
+				continue
+			}
+			if vals, err := nsConf.GetValues(key); err == nil && len(vals) > 0 && vals[0] != nil {
+				stats = append(stats, key+"="+*vals[0])
+			}
+		}
+		if nsConf.Type("rack-id") != aeroconf.ValueString {
+			stats = append(stats, "rack-id=0")
+		}
+		stats = append(stats, "effective_is_quiesced="+strconv.FormatBool(cmd.node.Quiesced))
+		return strings.Join(stats, ";"), 0
+	}
+	switch name {
+	case "":
+		return "", 0
+	case "status":
+		return "ok", 0
+	case "build":
+		if v, ok := cmd.node.Files["/opt/aerolab.aerospike.version"]; ok {
+			return strings.TrimRight(strings.TrimSpace(v), "cf"), 0
+		}
+		return strings.TrimRight(cmd.node.Template.AerospikeVersion, "cf"), 0
+	case "node":
+		return mockNodeId(cmd.Node), 0
+	case "cluster-name":
+		if conf != nil && conf.Stanza("service") != nil {
+			if vals, err := conf.Stanza("service").GetValues("cluster-name"); err == nil && len(vals) > 0 && vals[0] != nil {
+				return *vals[0], 0
+			}
+		}
+		return "null", 0
+	case "namespaces":
+		namespaces := []string{}
+		if conf != nil {
+			for _, key := range conf.ListKeys() {
+				if strings.HasPrefix(key, "namespace ") {
+					namespaces = append(namespaces, strings.TrimPrefix(key, "namespace "))
+				}
+			}
+		}
+		sort.Strings(namespaces)
+		return strings.Join(namespaces, ";"), 0
+	case "cluster-stable":
+		if size, ok := params["size"]; ok && size != strconv.Itoa(len(running)) {
+			return "ERROR::cluster-size-mismatch", 0
+		}
+		return fmt.Sprintf("%X", crc32.ChecksumIEEE([]byte(cmd.ClusterName+":"+strings.Join(observed, ",")))), 0
+	case "roster":
+		r, ok := cmd.cluster.Roster[params["namespace"]]
+		if !ok {
+			r = &mockRoster{}
+		}
+		roster := r.Roster
+		if roster == "" {
+			roster = "null"
+		}
+		pending := r.PendingRoster
+		if pending == "" {
+			pending = "null"
+		}
+		return fmt.Sprintf("roster=%s:pending_roster=%s:observed_nodes=%s", roster, pending, strings.Join(observed, ",")), 0
+	case "roster-set":
+		if cmd.cluster.Roster == nil {
+			cmd.cluster.Roster = make(map[string]*mockRoster)
+		}
+		r, ok := cmd.cluster.Roster[params["namespace"]]
+		if !ok {
+			r = &mockRoster{}
+			cmd.cluster.Roster[params["namespace"]] = r
+		}
+		r.PendingRoster = params["nodes"]
+		cmd.changed = true
+		return "ok", 0
+	case "get-config":
+		if conf == nil {
+			return "", 0
+		}
+		context := "context=" + params["context"]
+		for _, param := range []string{"id", "dc", "namespace"} {
+			if v, ok := params[param]; ok {
+				context += ";" + param + "=" + v
+			}
+		}
+		values := make(map[string]string)
+		for key, value := range confRuntimeContexts(conf)[context] {
+			values[key] = value.Value
+		}
+		for key, value := range cmd.node.Runtime[context] {
+			values[key] = value
+		}
+		keys := []string{}
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		pairs := []string{}
+		for _, key := range keys {
+			pairs = append(pairs, key+"="+values[key])
+		}
+		return strings.Join(pairs, ";"), 0
+	case "set-config":
+		if conf == nil {
+			return "ERROR::no configuration", 0
+		}
+		context := "context=" + params["context"]
+		setting := ""
+		for _, param := range []string{"id", "dc", "namespace", "set"} {
+			if v, ok := params[param]; ok {
+				context += ";" + param + "=" + v
+			}
+		}
+		for param := range params {
+			if !inslice.HasString([]string{"context", "id", "dc", "namespace", "set"}, param) {
+				setting = param
+			}
+		}
+		if setting == "" {
+			return "ERROR::invalid parameter", 0
+		}
+		// namespace storage and index parameters are set without the get-config stanza prefix
+		runtime := confRuntimeContexts(conf)[context]
+		key := setting
+		for _, prefix := range []string{"", "storage-engine.", "index-type.", "sindex-type."} {
+			if _, ok := runtime[prefix+setting]; ok {
+				key = prefix + setting
+				break
+			}
+		}
+		if cmd.node.Runtime == nil {
+			cmd.node.Runtime = make(map[string]map[string]string)
+		}
+		if cmd.node.Runtime[context] == nil {
+			cmd.node.Runtime[context] = make(map[string]string)
+		}
+		cmd.node.Runtime[context][key] = params[setting]
+		cmd.changed = true
+		return "ok", 0
+	case "quiesce":
+		cmd.node.Quiesced = true
+		cmd.changed = true
+		return "ok", 0
+	case "quiesce-undo":
+		cmd.node.Quiesced = false
+		cmd.changed = true
+		return "ok", 0
+	case "recluster":
+		for ns, r := range cmd.cluster.Roster {
+			if params["namespace"] != "" && params["namespace"] != ns {
+				continue
+			}
+			if r.PendingRoster != "" {
+				r.Roster = r.PendingRoster
+				cmd.changed = true
+			}
+		}
+		return "ok", 0
+	}
+	return "", 0
+}
+
+// aerospike service start/stop/restart clears the quiesce state and runtime configuration changes of the node
+func mockHandleService(cmd *mockCommand) ([]byte, int, bool) {
+	if len(cmd.Command) < 3 || path.Base(cmd.Command[0]) != "service" || cmd.Command[1] != "aerospike" {
+		return nil, 0, false
+	}
+	switch cmd.Command[2] {
+	case "start", "stop", "restart":
+		if cmd.node.Quiesced {
+			cmd.node.Quiesced = false
+			cmd.changed = true
+		}
+		if cmd.node.Runtime != nil {
+			cmd.node.Runtime = nil
+			cmd.changed = true
+		}
+	}
+	return nil, 0, true
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMockClusterCreateListDestroy(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("AEROLAB_HOME", home)
+	t.Setenv("AEROLAB_CONFIG_FILE", filepath.Join(home, "conf"))
+
+	// commands inspect os.Args, so run them as if called from the command line
+	osArgs := os.Args
+	t.Cleanup(func() { os.Args = osArgs })
+	run := func(args ...string) {
+		t.Helper()
+		os.Args = append([]string{"aerolab"}, args...)
+		if err := a.main(args); err != nil {
+			t.Fatalf("aerolab %v: %s", args, err)
+		}
+	}
+	clusters := func() []string {
+		t.Helper()
+		list, err := b.ClusterList()
+		if err != nil {
+			t.Fatalf("cluster list: %s", err)
+		}
+		return list
+	}
+
+	run("config", "backend", "-t", "mock")
+	run("cluster", "create", "-n", "mocktest", "-c", "2", "-v", "7.1.0.0", "-d", "ubuntu", "-i", "24.04")
+	if _, ok := b.(*backendMock); !ok {
+		t.Fatalf("expected the mock backend, got %T", b)
+	}
+	if list := clusters(); !slices.Contains(list, "mocktest") {
+		t.Fatalf("cluster mocktest not found after create: %v", list)
+	}
+	nodes, err := b.NodeListInCluster("mocktest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %v", nodes)
+	}
+
+	run("cluster", "list")
+	run("cluster", "destroy", "-n", "mocktest", "-f")
+	if list := clusters(); slices.Contains(list, "mocktest") {
+		t.Fatalf("cluster mocktest still exists after destroy: %v", list)
+	}
+}
@@ -23,15 +23,19 @@ type commands struct {
 	Inventory    inventoryCmd    `command:"inventory" subcommands-optional:"true" description:"List or operate on all clusters, clients and templates" webicon:"fas fa-warehouse"`
 	Attach       attachCmd       `command:"attach" subcommands-optional:"true" description:"Attach to a node and run a command" webicon:"fas fa-plug" simplemode:"false"`
 	Net          netCmd          `command:"net" subcommands-optional:"true" description:"Firewall and latency simulation" webicon:"fas fa-network-wired"`
+	Chaos        chaosCmd        `command:"chaos" subcommands-optional:"true" description:"Run fault injection scenarios against clusters" webicon:"fas fa-bolt"`
 	Conf         confCmd         `command:"conf" subcommands-optional:"true" description:"Manage Aerospike configuration on running nodes" webicon:"fas fa-wrench"`
 	Tls          tlsCmd          `command:"tls" subcommands-optional:"true" description:"Create or copy TLS certificates" webicon:"fas fa-lock"`
-	Data         dataCmd         `command:"data" subcommands-optional:"true" description:"Insert/delete Aerospike data" webicon:"fas fa-folder-open"`
+	Security     securityCmd     `command:"security" subcommands-optional:"true" description:"Enable security and manage users and roles" webicon:"fas fa-user-shield"`
+	Data         dataCmd         `command:"data" subcommands-optional:"true" description:"Insert/delete, export/import, copy and verify Aerospike data, manage indexes and UDFs" webicon:"fas fa-folder-open"`
 	Template     templateCmd     `command:"template" subcommands-optional:"true" description:"Manage or delete template images" webicon:"fas fa-file-image"`
 	Installer    installerCmd    `command:"installer" subcommands-optional:"true" description:"List or download Aerospike installer versions" webicon:"fas fa-plus"`
 	Logs         logsCmd         `command:"logs" subcommands-optional:"true" description:"show or download logs" webicon:"fas fa-bars-progress"`
 	Files        filesCmd        `command:"files" subcommands-optional:"true" description:"Upload/Download files to/from clients or clusters" webicon:"fas fa-file"`
 	XDR          xdrCmd          `command:"xdr" subcommands-optional:"true" description:"Mange clusters' xdr configuration" webicon:"fas fa-object-group"`
 	Roster       rosterCmd       `command:"roster" subcommands-optional:"true" description:"Show or apply strong-consistency rosters" webicon:"fas fa-sliders"`
+	Plan         planCmd         `command:"plan" subcommands-optional:"true" description:"Show changes required to converge the lab to a topology file" webicon:"fas fa-list-check"`
+	Apply        applyCmd        `command:"apply" subcommands-optional:"true" description:"Create or update the lab to match a topology file" webicon:"fas fa-diagram-project"`
 	Completion   completionCmd   `command:"completion" subcommands-optional:"true" description:"Install shell completion scripts" webicon:"fas fa-arrows-turn-to-dots" webhidden:"true"`
 	AGI          agiCmd          `command:"agi" subcommands-optional:"true" description:"Launch or manage AGI troubleshooting instances" webicon:"fas fa-chart-line"`
 	Volume       volumeCmd       `command:"volume" subcommands-optional:"true" description:"Volume management (AWS EFS/GCP Volume only)" webicon:"fas fa-hard-drive" simplemode:"false"`
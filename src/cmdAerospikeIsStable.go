@@ -143,7 +143,9 @@ func (c *aerospikeIsStableCmd) Execute(args []string) error {
 			if err != nil {
 				return err
 			}
+			readerDone := make(chan struct{})
 			go func() {
+				defer close(readerDone)
 				reader := bufio.NewReader(r)
 				for {
 					line, err := reader.ReadString('\n')
@@ -163,13 +165,16 @@ func (c *aerospikeIsStableCmd) Execute(args []string) error {
 						keysLock.Unlock()
 					}
 					if !c.Wait {
+						keysLock.Lock()
 						clusterKeys = append(clusterKeys, strings.TrimRight(line, "\r\n "))
+						keysLock.Unlock()
 					}
 				}
 				r.Close()
 			}()
 			err = b.RunCustomOut(c.ClusterName.String(), node, cmd, nil, w, w, false, nil)
 			w.Close()
+			<-readerDone
 			if err != nil {
 				return fmt.Errorf("node:%d %s", node, err)
 			}
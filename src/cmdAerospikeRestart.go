@@ -1,6 +1,13 @@
 package main
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
 
 type aerospikeRestartCmd struct {
 	aerospikeStartCmd
@@ -9,3 +16,40 @@ type aerospikeRestartCmd struct {
 func (c *aerospikeRestartCmd) Execute(args []string) error {
 	return c.run(args, "restart", os.Stdout)
 }
+
+// aerospikeRollingRestart restarts aerospike one node at a time, waiting for the cluster to be stable, with migrations finished, in between
+func aerospikeRollingRestart(clusterName TypeClusterName, nodes []int, namespace string, timeout int, threads int) error {
+	for i, node := range nodes {
+		log.Printf("Restarting node %d (%d/%d)", node, i+1, len(nodes))
+		a.opts.Aerospike.Restart.ClusterName = clusterName
+		a.opts.Aerospike.Restart.Nodes = TypeNodes(strconv.Itoa(node))
+		a.opts.Aerospike.Restart.ParallelThreads = 1
+		err := a.opts.Aerospike.Restart.Execute(nil)
+		if err != nil {
+			return fmt.Errorf("restart of node %d: %s", node, err)
+		}
+		err = aerospikeWaitStable(clusterName, namespace, time.Now().Add(time.Duration(timeout)*time.Second), false, threads)
+		if err != nil {
+			return fmt.Errorf("cluster did not stabilize after restarting node %d within %d seconds: %s", node, timeout, err)
+		}
+	}
+	return nil
+}
+
+// aerospikeWaitStable waits for the whole cluster to be stable, using aerospike is-stable, until the deadline
+func aerospikeWaitStable(clusterName TypeClusterName, namespace string, deadline time.Time, ignoreMigrations bool, threads int) error {
+	timeout := int(time.Until(deadline).Seconds())
+	if timeout < 1 {
+		return errors.New("timeout reached")
+	}
+	a.opts.Aerospike.IsStable.ClusterName = clusterName
+	a.opts.Aerospike.IsStable.Nodes = nil
+	a.opts.Aerospike.IsStable.Namespace = namespace
+	a.opts.Aerospike.IsStable.Wait = true
+	a.opts.Aerospike.IsStable.WaitTimeout = timeout
+	a.opts.Aerospike.IsStable.IgnoreMigrations = ignoreMigrations
+	a.opts.Aerospike.IsStable.IgnoreClusterKey = false
+	a.opts.Aerospike.IsStable.NotClusterKey = ""
+	a.opts.Aerospike.IsStable.ParallelThreads = threads
+	return a.opts.Aerospike.IsStable.Execute(nil)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aerospike/aerolab/parallelize"
@@ -20,10 +21,16 @@ type aerospikeUpgradeCmd struct {
 	CustomSourceFile *flags.Filename `short:"f" long:"custom-source-file" description:"custom source file for upgrade; must be .deb, .rpm, .tgz, or the asd binary itself"`
 	RestartAerospike TypeYesNo       `short:"s" long:"restart" description:"Restart aerospike after upgrade (y/n)" default:"y" webchoice:"y,n"`
 	parallelThreadsCmd
-	IsArm bool `long:"arm" description:"indicate installing on an arm instance"`
+	IsArm       bool `long:"arm" description:"indicate installing on an arm instance"`
+	MigrateConf bool `long:"migrate-conf" description:"If the upgrade crosses a major version, migrate aerospike.conf to the new version, as 'conf migrate' does"`
+	NoValidate  bool `long:"no-validate" description:"With --migrate-conf, do not validate the migrated configuration against the schema of the new version"`
+	aerospikeUpgradeRollingCmd
 }
 
 func (c *aerospikeUpgradeCmd) customUpgrade() error {
+	if c.MigrateConf {
+		return errors.New("--migrate-conf cannot be used with a custom source file, as the new version is not known; run 'conf migrate' after the upgrade instead")
+	}
 	// check cluster exists already
 	clusterList, err := b.ClusterList()
 	if err != nil {
@@ -87,19 +94,9 @@ func (c *aerospikeUpgradeCmd) customUpgrade() error {
 		return err
 	}
 
-	// stop aerospike
-	a.opts.Aerospike.Stop.ClusterName = c.ClusterName
-	a.opts.Aerospike.Stop.Nodes = c.Nodes
-	a.opts.Aerospike.Stop.ParallelThreads = c.ParallelThreads
-	err = a.opts.Aerospike.Stop.Execute(nil)
-	if err != nil {
-		return err
-	}
-
-	log.Print("Upgrading Aerospike")
 	// upgrade
 	ntime := strconv.Itoa(int(time.Now().Unix()))
-	returns := parallelize.MapLimit(nodeList, c.ParallelThreads, func(i int) error {
+	upgradeNode := func(i int) error {
 		// backup aerospike.conf
 		nret, err := b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/etc/aerospike/aerospike.conf"}, {"mkdir", "-p", "/tmp/" + ntime}}, []int{i})
 		if err != nil {
@@ -139,8 +136,34 @@ func (c *aerospikeUpgradeCmd) customUpgrade() error {
 		if err != nil {
 			return err
 		}
+		version, err := c.installedVersion(i)
+		if err != nil {
+			log.Printf("WARNING: node %d: could not get the installed aerospike version, it will not be recorded: %s", i, err)
+			return nil
+		}
+		return c.recordVersion(i, version)
+	}
+
+	if c.Rolling {
+		err = c.rollingUpgrade(nodeList, upgradeNode)
+		if err != nil {
+			return err
+		}
+		log.Print("Done")
 		return nil
-	})
+	}
+
+	// stop aerospike
+	a.opts.Aerospike.Stop.ClusterName = c.ClusterName
+	a.opts.Aerospike.Stop.Nodes = c.Nodes
+	a.opts.Aerospike.Stop.ParallelThreads = c.ParallelThreads
+	err = a.opts.Aerospike.Stop.Execute(nil)
+	if err != nil {
+		return err
+	}
+
+	log.Print("Upgrading Aerospike")
+	returns := parallelize.MapLimit(nodeList, c.ParallelThreads, upgradeNode)
 	isError := false
 	for i, ret := range returns {
 		if ret != nil {
@@ -269,6 +292,15 @@ func (c *aerospikeUpgradeCmd) Execute(args []string) error {
 		}
 	}
 
+	// migrate the configuration before anything is installed, so that a failure leaves the cluster untouched
+	migrated := make(map[int][]byte)
+	if c.MigrateConf {
+		migrated, err = c.migrateConfs(nodeList, verNoSuffix)
+		if err != nil {
+			return err
+		}
+	}
+
 	// copy installer to destination nodes
 	stat, err := os.Stat(fn)
 	pfilelen := 0
@@ -287,25 +319,18 @@ func (c *aerospikeUpgradeCmd) Execute(args []string) error {
 		return err
 	}
 
-	// stop aerospike
-	a.opts.Aerospike.Stop.ClusterName = c.ClusterName
-	a.opts.Aerospike.Stop.Nodes = c.Nodes
-	a.opts.Aerospike.Stop.ParallelThreads = c.ParallelThreads
-	err = a.opts.Aerospike.Stop.Execute(nil)
-	if err != nil {
-		return err
-	}
-
-	log.Print("Upgrading Aerospike")
 	// upgrade
 	ntime := strconv.Itoa(int(time.Now().Unix()))
-	returns := parallelize.MapLimit(nodeList, c.ParallelThreads, func(i int) error {
+	upgradeNode := func(i int) error {
 		// backup aerospike.conf
 		nret, err := b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/etc/aerospike/aerospike.conf"}, {"mkdir", "-p", "/tmp/" + ntime}}, []int{i})
 		if err != nil {
 			return err
 		}
 		nfile := nret[0]
+		if newconf, ok := migrated[i]; ok {
+			nfile = newconf
+		}
 		out, err := b.RunCommands(string(c.ClusterName), [][]string{{"tar", "-zxvf", "/root/upgrade.tgz", "-C", "/tmp/" + ntime}}, []int{i})
 		if err != nil {
 			return fmt.Errorf("%s : %s", string(out[0]), err)
@@ -315,13 +340,34 @@ func (c *aerospikeUpgradeCmd) Execute(args []string) error {
 		if err != nil {
 			return fmt.Errorf("%s : %s", string(out[0]), err)
 		}
-		// recover aerospike.conf backup
+		// recover aerospike.conf backup, or the migrated configuration
 		err = b.CopyFilesToCluster(string(c.ClusterName), []fileList{{"/etc/aerospike/aerospike.conf", string(nfile), len(nfile)}}, []int{i})
 		if err != nil {
 			return err
 		}
+		return c.recordVersion(i, c.AerospikeVersion.String())
+	}
+
+	if c.Rolling {
+		err = c.rollingUpgrade(nodeList, upgradeNode)
+		if err != nil {
+			return err
+		}
+		log.Print("Done")
 		return nil
-	})
+	}
+
+	// stop aerospike
+	a.opts.Aerospike.Stop.ClusterName = c.ClusterName
+	a.opts.Aerospike.Stop.Nodes = c.Nodes
+	a.opts.Aerospike.Stop.ParallelThreads = c.ParallelThreads
+	err = a.opts.Aerospike.Stop.Execute(nil)
+	if err != nil {
+		return err
+	}
+
+	log.Print("Upgrading Aerospike")
+	returns := parallelize.MapLimit(nodeList, c.ParallelThreads, upgradeNode)
 	isError := false
 	for i, ret := range returns {
 		if ret != nil {
@@ -347,3 +393,86 @@ func (c *aerospikeUpgradeCmd) Execute(args []string) error {
 	log.Print("Done")
 	return nil
 }
+
+// migrateConfs migrates the aerospike.conf of each node whose installed major version is lower than the version being upgraded to
+func (c *aerospikeUpgradeCmd) migrateConfs(nodeList []int, version string) (map[int][]byte, error) {
+	to, err := confMigrateMajor(version)
+	if err != nil {
+		return nil, err
+	}
+	lock := new(sync.Mutex)
+	migrated := make(map[int][]byte)
+	returns := parallelize.MapLimit(nodeList, c.ParallelThreads, func(node int) error {
+		out, err := b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/opt/aerolab.aerospike.version"}, {"cat", "/etc/aerospike/aerospike.conf"}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("could not read the installed aerospike version and configuration: %s", err)
+		}
+		from, err := confMigrateMajor(string(out[0]))
+		if err != nil {
+			return err
+		}
+		if from >= to {
+			return nil
+		}
+		newconf, changes, err := confMigrate(out[1], from, to)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			log.Printf("Node %d: migrate %d to %d: %s %s: %s", node, from, to, change.Change, change.Path, change.Detail)
+		}
+		if !c.NoValidate {
+			err = confValidateAuto(fmt.Sprintf("%s-%d:/etc/aerospike/aerospike.conf", c.ClusterName, node), newconf, version)
+			if err != nil {
+				return err
+			}
+		}
+		lock.Lock()
+		migrated[node] = newconf
+		lock.Unlock()
+		return nil
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodeList[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return nil, errors.New("configuration migration failed, no nodes were upgraded")
+	}
+	return migrated, nil
+}
+
+// installedVersion returns the aerospike version reported by the asd binary on a node, with the c/f suffix for community/federal editions
+func (c *aerospikeUpgradeCmd) installedVersion(node int) (string, error) {
+	out, err := b.RunCommands(string(c.ClusterName), [][]string{{"asd", "--version"}}, []int{node})
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(out[0]))
+	if len(fields) == 0 {
+		return "", errors.New("asd --version returned no output")
+	}
+	version := fields[len(fields)-1]
+	if strings.Contains(string(out[0]), "Community") {
+		version += "c"
+	} else if strings.Contains(string(out[0]), "Federal") {
+		version += "f"
+	}
+	return version, nil
+}
+
+// recordVersion stores the new aerospike version on the node and in its instance tags/labels, so that inventory shows the version of each node
+func (c *aerospikeUpgradeCmd) recordVersion(node int, version string) error {
+	err := b.CopyFilesToCluster(string(c.ClusterName), []fileList{{"/opt/aerolab.aerospike.version", version, len(version)}}, []int{node})
+	if err != nil {
+		return err
+	}
+	err = b.SetNodeVersion(string(c.ClusterName), []int{node}, version)
+	if err != nil {
+		log.Printf("WARNING: node %d: could not record aerospike version %s in the instance tags: %s", node, version, err)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestmethod/inslice"
+)
+
+type aerospikeUpgradeRollingCmd struct {
+	Rolling          bool   `long:"rolling" description:"Rolling upgrade: upgrade one node at a time, quiescing it first and waiting for the cluster to be stable before moving on"`
+	RollingByRack    bool   `long:"rolling-by-rack" description:"With --rolling, upgrade one rack at a time instead of one node at a time"`
+	RollingNamespace string `long:"rolling-namespace" description:"With --rolling, namespace to check rack-id and stability against" default:"test"`
+	RollingTimeout   int    `long:"rolling-timeout" description:"With --rolling, seconds to wait for nodes to quiesce, and to rejoin the cluster, before aborting" default:"600"`
+	RollingNoQuiesce bool   `long:"rolling-no-quiesce" description:"With --rolling, do not quiesce nodes before stopping them"`
+}
+
+// rollingUpgrade upgrades the given nodes one node, or one rack, at a time; installer must already be uploaded to the nodes
+func (c *aerospikeUpgradeCmd) rollingUpgrade(nodeList []int, upgradeNode func(node int) error) error {
+	if !inslice.HasString([]string{"YES", "Y"}, strings.ToUpper(c.RestartAerospike.String())) {
+		return errors.New("rolling upgrade requires --restart=y")
+	}
+	if c.RollingTimeout <= 0 {
+		return errors.New("rolling-timeout must be greater than 0")
+	}
+	allNodes, err := b.NodeListInCluster(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	groups, err := c.rollingGroups(nodeList)
+	if err != nil {
+		return err
+	}
+	scNamespaces, err := c.rollingSCNamespaces(allNodes)
+	if err != nil {
+		return err
+	}
+	if len(scNamespaces) > 0 {
+		log.Printf("Rolling upgrade: strong-consistency namespaces: %s", strings.Join(scNamespaces, ","))
+	}
+	for gi, group := range groups {
+		groupString := intSliceToString(group, ",")
+		log.Printf("Rolling upgrade: step %d/%d, nodes %s", gi+1, len(groups), groupString)
+		// quiesce
+		if c.RollingNoQuiesce {
+			log.Print("Rolling upgrade: skipping quiesce")
+		} else if len(group) == len(allNodes) {
+			log.Print("Rolling upgrade: not quiescing, as this step covers all nodes in the cluster")
+		} else {
+			err = c.rollingQuiesce(group, allNodes)
+			if err != nil {
+				return fmt.Errorf("rolling upgrade aborted at nodes %s: %s", groupString, err)
+			}
+		}
+		// stop
+		a.opts.Aerospike.Stop.ClusterName = c.ClusterName
+		a.opts.Aerospike.Stop.Nodes = TypeNodes(groupString)
+		a.opts.Aerospike.Stop.ParallelThreads = c.ParallelThreads
+		err = a.opts.Aerospike.Stop.Execute(nil)
+		if err != nil {
+			return fmt.Errorf("rolling upgrade aborted at nodes %s: stop: %s", groupString, err)
+		}
+		// upgrade
+		log.Printf("Rolling upgrade: upgrading nodes %s", groupString)
+		for _, node := range group {
+			err = upgradeNode(node)
+			if err != nil {
+				return fmt.Errorf("rolling upgrade aborted at node %d: %s", node, err)
+			}
+		}
+		// start
+		a.opts.Aerospike.Start.ClusterName = c.ClusterName
+		a.opts.Aerospike.Start.Nodes = TypeNodes(groupString)
+		a.opts.Aerospike.Start.ParallelThreads = c.ParallelThreads
+		err = a.opts.Aerospike.Start.Execute(nil)
+		if err != nil {
+			return fmt.Errorf("rolling upgrade aborted at nodes %s: start: %s", groupString, err)
+		}
+		// wait for the nodes to rejoin, reapply roster if needed, and wait for migrations to finish
+		deadline := time.Now().Add(time.Duration(c.RollingTimeout) * time.Second)
+		log.Printf("Rolling upgrade: waiting for nodes %s to rejoin the cluster", groupString)
+		err = aerospikeWaitStable(c.ClusterName, c.RollingNamespace, deadline, true, c.ParallelThreads)
+		if err != nil {
+			return fmt.Errorf("rolling upgrade aborted: nodes %s failed to rejoin the cluster within %d seconds: %s", groupString, c.RollingTimeout, err)
+		}
+		for _, ns := range scNamespaces {
+			err = c.rollingRoster(ns, allNodes)
+			if err != nil {
+				return fmt.Errorf("rolling upgrade aborted at nodes %s: roster: %s", groupString, err)
+			}
+		}
+		log.Print("Rolling upgrade: waiting for migrations to finish")
+		err = aerospikeWaitStable(c.ClusterName, c.RollingNamespace, deadline, false, c.ParallelThreads)
+		if err != nil {
+			return fmt.Errorf("rolling upgrade aborted: cluster did not stabilize after upgrading nodes %s within %d seconds: %s", groupString, c.RollingTimeout, err)
+		}
+	}
+	log.Printf("Rolling upgrade: upgraded %d nodes in %d steps", len(nodeList), len(groups))
+	return nil
+}
+
+// rollingInfo runs an asinfo command on a node and returns the trimmed output
+func (c *aerospikeUpgradeCmd) rollingInfo(node int, command string) (string, error) {
+	out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"asinfo", "-v", command}}, []int{node})
+	if err != nil {
+		if len(out) == 0 {
+			out = [][]byte{{'-'}}
+		}
+		return "", fmt.Errorf("node %d: asinfo %s: %s: %s", node, command, err, string(out[0]))
+	}
+	return strings.Trim(string(out[0]), "\t\r\n "), nil
+}
+
+// rollingInfoValue finds a key in a semicolon-separated key=value info response
+func rollingInfoValue(info string, key string) string {
+	for _, kv := range strings.Split(info, ";") {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// rollingGroups returns the nodes to upgrade at each step; with RollingByRack, nodes are grouped by the rack-id of RollingNamespace
+func (c *aerospikeUpgradeCmd) rollingGroups(nodeList []int) ([][]int, error) {
+	nodes := append([]int{}, nodeList...)
+	sort.Ints(nodes)
+	groups := [][]int{}
+	if !c.RollingByRack {
+		for _, node := range nodes {
+			groups = append(groups, []int{node})
+		}
+		return groups, nil
+	}
+	racks := make(map[int][]int)
+	rackIds := []int{}
+	for _, node := range nodes {
+		info, err := c.rollingInfo(node, "namespace/"+c.RollingNamespace)
+		if err != nil {
+			return nil, err
+		}
+		rack, err := strconv.Atoi(rollingInfoValue(info, "rack-id"))
+		if err != nil {
+			return nil, fmt.Errorf("node %d: could not read rack-id of namespace %s", node, c.RollingNamespace)
+		}
+		if _, ok := racks[rack]; !ok {
+			rackIds = append(rackIds, rack)
+		}
+		racks[rack] = append(racks[rack], node)
+	}
+	sort.Ints(rackIds)
+	for _, rack := range rackIds {
+		log.Printf("Rolling upgrade: rack %d: nodes %s", rack, intSliceToString(racks[rack], ","))
+		groups = append(groups, racks[rack])
+	}
+	return groups, nil
+}
+
+// rollingSCNamespaces returns the strong-consistency namespaces, as seen by the first node
+func (c *aerospikeUpgradeCmd) rollingSCNamespaces(allNodes []int) ([]string, error) {
+	if len(allNodes) == 0 {
+		return nil, errors.New("found 0 nodes in cluster")
+	}
+	info, err := c.rollingInfo(allNodes[0], "namespaces")
+	if err != nil {
+		return nil, err
+	}
+	sc := []string{}
+	for _, ns := range strings.Split(info, ";") {
+		if ns == "" {
+			continue
+		}
+		nsInfo, err := c.rollingInfo(allNodes[0], "namespace/"+ns)
+		if err != nil {
+			return nil, err
+		}
+		if rollingInfoValue(nsInfo, "strong-consistency") == "true" {
+			sc = append(sc, ns)
+		}
+	}
+	return sc, nil
+}
+
+// rollingQuiesce quiesces the given nodes, reclusters and waits for the nodes to report being quiesced and for migrations to finish
+func (c *aerospikeUpgradeCmd) rollingQuiesce(group []int, allNodes []int) error {
+	log.Printf("Rolling upgrade: quiescing nodes %s", intSliceToString(group, ","))
+	for _, node := range group {
+		out, err := c.rollingInfo(node, "quiesce:")
+		if err != nil {
+			return err
+		}
+		if out != "ok" {
+			return fmt.Errorf("node %d: quiesce returned: %s", node, out)
+		}
+	}
+	// recluster is ignored by non-principal nodes, so send it to all of them
+	for _, node := range allNodes {
+		_, err := c.rollingInfo(node, "recluster:")
+		if err != nil {
+			return err
+		}
+	}
+	deadline := time.Now().Add(time.Duration(c.RollingTimeout) * time.Second)
+	for _, node := range group {
+		for {
+			info, err := c.rollingInfo(node, "namespace/"+c.RollingNamespace)
+			if err != nil {
+				return err
+			}
+			if rollingInfoValue(info, "effective_is_quiesced") == "true" {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("node %d did not quiesce within %d seconds", node, c.RollingTimeout)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+	log.Print("Rolling upgrade: waiting for migrations to finish")
+	return aerospikeWaitStable(c.ClusterName, c.RollingNamespace, deadline, false, c.ParallelThreads)
+}
+
+// rollingRoster reapplies the roster of a strong-consistency namespace if it does not match the observed nodes
+func (c *aerospikeUpgradeCmd) rollingRoster(namespace string, allNodes []int) error {
+	info, err := c.rollingInfo(allNodes[0], "roster:namespace="+namespace)
+	if err != nil {
+		return err
+	}
+	roster := ""
+	observed := ""
+	for _, kv := range strings.Split(info, ":") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "roster":
+			roster = v
+		case "observed_nodes":
+			observed = v
+		}
+	}
+	rosterNodes := strings.Split(roster, ",")
+	observedNodes := strings.Split(observed, ",")
+	sort.Strings(rosterNodes)
+	sort.Strings(observedNodes)
+	if strings.Join(rosterNodes, ",") == strings.Join(observedNodes, ",") {
+		return nil
+	}
+	log.Printf("Rolling upgrade: namespace %s roster (%s) does not match observed nodes (%s), reapplying roster", namespace, roster, observed)
+	a.opts.Roster.Apply.ClusterName = c.ClusterName
+	a.opts.Roster.Apply.Nodes = ""
+	a.opts.Roster.Apply.Namespace = namespace
+	a.opts.Roster.Apply.Roster = ""
+	a.opts.Roster.Apply.NoRecluster = false
+	a.opts.Roster.Apply.ParallelThreads = c.ParallelThreads
+	a.opts.Roster.Apply.Quiet = true
+	return a.opts.Roster.Apply.Execute(nil)
+}
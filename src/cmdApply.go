@@ -0,0 +1,598 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aerospike/aerolab/parallelize"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+	flags "github.com/rglonek/jeddevdk-goflags"
+	"gopkg.in/yaml.v3"
+)
+
+type applyCmd struct {
+	TopologyFile flags.Filename `short:"f" long:"file" description:"Topology yaml file describing the lab" default:"topology.yaml"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type topology struct {
+	Clusters []*topologyCluster `yaml:"clusters"`
+	Clients  []*topologyClient  `yaml:"clients"`
+	Tls      []*topologyTls     `yaml:"tls"`
+	Conf     []*topologyConf    `yaml:"conf"`
+	Xdr      []*topologyXdr     `yaml:"xdr"`
+	baseDir  string
+}
+
+type topologyCluster struct {
+	Name          string                `yaml:"name"`
+	Nodes         int                   `yaml:"nodes"`
+	Version       string                `yaml:"version"`
+	Distro        string                `yaml:"distro"`
+	DistroVersion string                `yaml:"distroVersion"`
+	CustomConf    string                `yaml:"customConf"`
+	FeatureFile   string                `yaml:"featureFile"`
+	HeartbeatMode string                `yaml:"heartbeatMode"`
+	Start         *bool                 `yaml:"start"`
+	Docker        topologyBackendDocker `yaml:"docker"`
+	Aws           topologyBackendAws    `yaml:"aws"`
+	Gcp           topologyBackendGcp    `yaml:"gcp"`
+}
+
+type topologyClient struct {
+	Name          string                `yaml:"name"`
+	Type          string                `yaml:"type"`
+	Count         int                   `yaml:"count"`
+	Distro        string                `yaml:"distro"`
+	DistroVersion string                `yaml:"distroVersion"`
+	Clusters      []string              `yaml:"clusters"`
+	Docker        topologyBackendDocker `yaml:"docker"`
+	Aws           topologyBackendAws    `yaml:"aws"`
+	Gcp           topologyBackendGcp    `yaml:"gcp"`
+}
+
+type topologyBackendDocker struct {
+	ExposePorts string   `yaml:"exposePorts"`
+	Network     string   `yaml:"network"`
+	CpuLimit    string   `yaml:"cpuLimit"`
+	RamLimit    string   `yaml:"ramLimit"`
+	Privileged  bool     `yaml:"privileged"`
+	Labels      []string `yaml:"labels"`
+}
+
+type topologyBackendAws struct {
+	InstanceType  string        `yaml:"instanceType"`
+	Disks         []string      `yaml:"disks"`
+	SubnetID      string        `yaml:"subnetId"`
+	SecurityGroup string        `yaml:"securityGroupId"`
+	Spot          bool          `yaml:"spot"`
+	Expire        time.Duration `yaml:"expire"`
+	Tags          []string      `yaml:"tags"`
+}
+
+type topologyBackendGcp struct {
+	InstanceType string        `yaml:"instanceType"`
+	Zone         string        `yaml:"zone"`
+	Disks        []string      `yaml:"disks"`
+	Spot         bool          `yaml:"spot"`
+	Expire       time.Duration `yaml:"expire"`
+	Labels       []string      `yaml:"labels"`
+}
+
+type topologyTls struct {
+	Cluster string `yaml:"cluster"`
+	TlsName string `yaml:"tlsName"`
+	CaName  string `yaml:"caName"`
+}
+
+type topologyConf struct {
+	Cluster string   `yaml:"cluster"`
+	Key     string   `yaml:"key"`
+	Values  []string `yaml:"values"`
+	Delete  bool     `yaml:"delete"`
+}
+
+type topologyXdr struct {
+	Source       string   `yaml:"source"`
+	Destinations []string `yaml:"destinations"`
+	Namespaces   []string `yaml:"namespaces"`
+}
+
+func loadTopology(fileName string) (*topology, error) {
+	f, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read topology file: %s", err)
+	}
+	t := &topology{}
+	dec := yaml.NewDecoder(bytes.NewReader(f))
+	dec.KnownFields(true)
+	err = dec.Decode(t)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse topology file: %s", err)
+	}
+	t.baseDir, err = filepath.Abs(filepath.Dir(fileName))
+	if err != nil {
+		return nil, err
+	}
+	return t, t.validate()
+}
+
+func (t *topology) validate() error {
+	clusters := make(map[string]*topologyCluster)
+	for _, c := range t.Clusters {
+		if c.Name == "" {
+			return errors.New("cluster definition is missing a name")
+		}
+		if _, ok := clusters[c.Name]; ok {
+			return fmt.Errorf("cluster %s is defined more than once", c.Name)
+		}
+		clusters[c.Name] = c
+		if c.Nodes == 0 {
+			c.Nodes = 1
+		}
+		if c.Nodes < 0 {
+			return fmt.Errorf("cluster %s: node count must be a positive number", c.Name)
+		}
+		if c.Start == nil {
+			start := true
+			c.Start = &start
+		}
+	}
+	clients := make(map[string]bool)
+	for _, c := range t.Clients {
+		if c.Name == "" {
+			return errors.New("client definition is missing a name")
+		}
+		if clients[c.Name] {
+			return fmt.Errorf("client group %s is defined more than once", c.Name)
+		}
+		clients[c.Name] = true
+		if c.Count == 0 {
+			c.Count = 1
+		}
+		if c.Type == "" {
+			c.Type = "base"
+		}
+		switch c.Type {
+		case "none", "base", "tools", "ams":
+		default:
+			return fmt.Errorf("client group %s: type must be one of: none|base|tools|ams", c.Name)
+		}
+		if len(c.Clusters) > 0 && c.Type != "ams" {
+			return fmt.Errorf("client group %s: clusters can only be specified for ams clients", c.Name)
+		}
+	}
+	for _, c := range t.Tls {
+		if c.Cluster == "" {
+			return errors.New("tls definition is missing a cluster")
+		}
+		if c.TlsName == "" {
+			c.TlsName = "tls1"
+		}
+		if c.CaName == "" {
+			c.CaName = "cacert"
+		}
+	}
+	for _, c := range t.Conf {
+		if c.Cluster == "" || c.Key == "" {
+			return errors.New("conf definition requires both cluster and key")
+		}
+		if c.Delete && len(c.Values) > 0 {
+			return fmt.Errorf("conf %s on %s: values cannot be specified with delete", c.Key, c.Cluster)
+		}
+	}
+	for _, c := range t.Xdr {
+		if c.Source == "" || len(c.Destinations) == 0 {
+			return errors.New("xdr definition requires source and destinations")
+		}
+		if len(c.Namespaces) == 0 {
+			c.Namespaces = []string{"test"}
+		}
+	}
+	return nil
+}
+
+// relative paths in the topology file are relative to the topology file itself
+func (t *topology) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(t.baseDir, p)
+}
+
+func (c *applyCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running apply")
+	t, err := loadTopology(string(c.TopologyFile))
+	if err != nil {
+		return err
+	}
+	plan, err := t.plan(c.ParallelThreads)
+	if err != nil {
+		return err
+	}
+	plan.print(os.Stdout)
+	if !plan.hasChanges() {
+		log.Print("Lab matches topology, nothing to do")
+		log.Print("Done")
+		return nil
+	}
+	restart := []string{}
+	for _, item := range plan.Items {
+		if item.Action == topologyActionNone || item.Action == topologyActionWarn {
+			continue
+		}
+		log.Printf("Applying: %s %s: %s", item.Kind, item.Name, item.Detail)
+		switch item.Kind {
+		case "cluster":
+			err = t.applyCluster(item)
+		case "version":
+			err = t.applyVersion(item, c.ParallelThreads)
+		case "client":
+			err = t.applyClient(item)
+		case "tls":
+			err = t.applyTls(item, c.ParallelThreads)
+			restart = append(restart, item.cluster)
+		case "conf":
+			err = t.applyConf(item, c.ParallelThreads)
+			restart = append(restart, item.cluster)
+		case "xdr":
+			err = t.applyXdr(item, c.ParallelThreads)
+			restart = append(restart, item.cluster)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %s", item.Kind, item.Name, err)
+		}
+	}
+	sort.Strings(restart)
+	for i, cluster := range restart {
+		if i > 0 && restart[i-1] == cluster {
+			continue
+		}
+		if tc := t.cluster(cluster); tc != nil && !*tc.Start {
+			continue
+		}
+		log.Printf("Restarting aerospike on cluster %s to apply configuration changes", cluster)
+		a.opts.Aerospike.Restart.ClusterName = TypeClusterName(cluster)
+		a.opts.Aerospike.Restart.Nodes = ""
+		a.opts.Aerospike.Restart.ParallelThreads = c.ParallelThreads
+		err = a.opts.Aerospike.Restart.Execute(nil)
+		if err != nil {
+			return fmt.Errorf("restart of cluster %s: %s", cluster, err)
+		}
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (t *topology) cluster(name string) *topologyCluster {
+	for _, c := range t.Clusters {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (t *topology) client(name string) *topologyClient {
+	for _, c := range t.Clients {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (t *topology) fillBackendOpts(aws *clusterCreateCmdAws, gcp *clusterCreateCmdGcp, docker *clusterCreateCmdDocker, tAws topologyBackendAws, tGcp topologyBackendGcp, tDocker topologyBackendDocker) {
+	if tDocker.ExposePorts != "" {
+		docker.ExposePortsToHost = tDocker.ExposePorts
+	}
+	if tDocker.Network != "" {
+		docker.NetworkName = tDocker.Network
+	}
+	if tDocker.CpuLimit != "" {
+		docker.CpuLimit = tDocker.CpuLimit
+	}
+	if tDocker.RamLimit != "" {
+		docker.RamLimit = tDocker.RamLimit
+	}
+	if tDocker.Privileged {
+		docker.Privileged = true
+	}
+	if len(tDocker.Labels) > 0 {
+		docker.Labels = tDocker.Labels
+	}
+	if tAws.InstanceType != "" {
+		aws.InstanceType = guiInstanceType(tAws.InstanceType)
+	}
+	if len(tAws.Disks) > 0 {
+		aws.Disk = tAws.Disks
+	}
+	if tAws.SubnetID != "" {
+		aws.SubnetID = tAws.SubnetID
+	}
+	if tAws.SecurityGroup != "" {
+		aws.SecurityGroupID = tAws.SecurityGroup
+	}
+	if tAws.Spot {
+		aws.SpotInstance = true
+	}
+	if tAws.Expire != 0 {
+		aws.Expires = tAws.Expire
+	}
+	if len(tAws.Tags) > 0 {
+		aws.Tags = tAws.Tags
+	}
+	if tGcp.InstanceType != "" {
+		gcp.InstanceType = guiInstanceType(tGcp.InstanceType)
+	}
+	if tGcp.Zone != "" {
+		gcp.Zone = guiZone(tGcp.Zone)
+	}
+	if len(tGcp.Disks) > 0 {
+		gcp.Disk = tGcp.Disks
+	}
+	if tGcp.Spot {
+		gcp.SpotInstance = true
+	}
+	if tGcp.Expire != 0 {
+		gcp.Expires = tGcp.Expire
+	}
+	if len(tGcp.Labels) > 0 {
+		gcp.Labels = tGcp.Labels
+	}
+}
+
+func (t *topology) applyCluster(item *topologyPlanItem) error {
+	tc := t.cluster(item.Name)
+	isGrow := item.Action == topologyActionGrow
+	cmd := a.opts.Cluster.Create
+	if isGrow {
+		cmd = a.opts.Cluster.Grow.clusterCreateCmd
+	}
+	cmd.ClusterName = TypeClusterName(tc.Name)
+	cmd.NodeCount = item.count
+	if tc.Version != "" {
+		cmd.AerospikeVersion = TypeAerospikeVersion(tc.Version)
+	}
+	if tc.Distro != "" {
+		cmd.DistroName = TypeDistro(tc.Distro)
+	}
+	if tc.DistroVersion != "" {
+		cmd.DistroVersion = TypeDistroVersion(tc.DistroVersion)
+	}
+	if tc.CustomConf != "" {
+		cmd.CustomConfigFilePath = flags.Filename(t.path(tc.CustomConf))
+	}
+	if tc.FeatureFile != "" {
+		cmd.FeaturesFilePath = flags.Filename(t.path(tc.FeatureFile))
+	}
+	if tc.HeartbeatMode != "" {
+		cmd.HeartbeatMode = TypeHBMode(tc.HeartbeatMode)
+	}
+	cmd.AutoStartAerospike = "y"
+	if !*tc.Start {
+		cmd.AutoStartAerospike = "n"
+	}
+	t.fillBackendOpts(&cmd.Aws, &cmd.Gcp, &cmd.Docker, tc.Aws, tc.Gcp, tc.Docker)
+	return cmd.realExecute(nil, isGrow)
+}
+
+func (t *topology) applyClient(item *topologyPlanItem) error {
+	tc := t.client(item.Name)
+	var base *clientCreateBaseCmd
+	var run func() error
+	switch tc.Type {
+	case "none":
+		cmd := a.opts.Client.Create.None
+		cmd.ClientName = TypeClientName(tc.Name)
+		cmd.ClientCount = tc.Count
+		if tc.Distro != "" {
+			cmd.DistroName = TypeDistro(tc.Distro)
+		}
+		if tc.DistroVersion != "" {
+			cmd.DistroVersion = TypeDistroVersion(tc.DistroVersion)
+		}
+		t.fillBackendOpts(&cmd.Aws, &cmd.Gcp, &cmd.Docker, tc.Aws, tc.Gcp, tc.Docker)
+		return cmd.Execute(nil)
+	case "base":
+		cmd := a.opts.Client.Create.Base
+		base = &cmd
+		run = func() error { return cmd.Execute(nil) }
+	case "tools":
+		cmd := a.opts.Client.Create.Tools
+		base = &cmd.clientCreateBaseCmd
+		run = func() error { return cmd.Execute(nil) }
+	case "ams":
+		cmd := a.opts.Client.Create.AMS
+		cmd.ConnectClusters = TypeClusterName(strings.Join(tc.Clusters, ","))
+		cmd.JustDoIt = true
+		base = &cmd.clientCreateBaseCmd
+		run = func() error { return cmd.Execute(nil) }
+	}
+	base.ClientName = TypeClientName(tc.Name)
+	base.ClientCount = tc.Count
+	if tc.Distro != "" {
+		base.DistroName = TypeDistro(tc.Distro)
+	}
+	if tc.DistroVersion != "" {
+		base.DistroVersion = TypeDistroVersion(tc.DistroVersion)
+	}
+	t.fillBackendOpts(&base.Aws, &base.Gcp, &base.Docker, tc.Aws, tc.Gcp, tc.Docker)
+	return run()
+}
+
+func (t *topology) applyVersion(item *topologyPlanItem, threads int) error {
+	tc := t.cluster(item.Name)
+	cmd := a.opts.Aerospike.Upgrade
+	cmd.ClusterName = TypeClusterName(tc.Name)
+	cmd.Nodes = TypeNodes(intSliceToString(item.nodes, ","))
+	cmd.AerospikeVersion = TypeAerospikeVersion(tc.Version)
+	cmd.DistroName = TypeDistro(item.distro)
+	cmd.DistroVersion = TypeDistroVersion(item.distroVersion)
+	if tc.Distro != "" {
+		cmd.DistroName = TypeDistro(tc.Distro)
+	}
+	if tc.DistroVersion != "" {
+		cmd.DistroVersion = TypeDistroVersion(tc.DistroVersion)
+	}
+	cmd.IsArm = item.isArm
+	cmd.RestartAerospike = "y"
+	if !*tc.Start {
+		cmd.RestartAerospike = "n"
+	}
+	cmd.ParallelThreads = threads
+	return cmd.Execute(nil)
+}
+
+// applyTls generates certificates on the nodes the plan found missing them, or on all nodes of a new cluster
+func (t *topology) applyTls(item *topologyPlanItem, threads int) error {
+	tt := item.tls
+	nodes := item.nodes
+	if item.recheck {
+		var err error
+		nodes, err = t.planTlsMissing(tt, threads)
+		if err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			return nil
+		}
+	}
+	cmd := a.opts.Tls.Generate
+	cmd.ClusterName = TypeClusterName(tt.Cluster)
+	cmd.Nodes = TypeNodes(intSliceToString(nodes, ","))
+	cmd.TlsName = tt.TlsName
+	cmd.CaName = tt.CaName
+	cmd.ParallelThreads = threads
+	return cmd.Execute(nil)
+}
+
+func (t *topology) applyConf(item *topologyPlanItem, threads int) error {
+	tc := item.conf
+	nodes, err := b.NodeListInCluster(tc.Cluster)
+	if err != nil {
+		return err
+	}
+	returns := parallelize.MapLimit(nodes, threads, func(node int) error {
+		s, err := topologyReadConf(tc.Cluster, node)
+		if err != nil {
+			return err
+		}
+		changed, err := tc.patch(s)
+		if err != nil || !changed {
+			return err
+		}
+		var buf bytes.Buffer
+		err = s.Write(&buf, "", "    ", true)
+		if err != nil {
+			return err
+		}
+		contents := buf.Bytes()
+		return b.CopyFilesToClusterReader(tc.Cluster, []fileListReader{{filePath: "/etc/aerospike/aerospike.conf", fileContents: bytes.NewReader(contents), fileSize: len(contents)}}, []int{node})
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	return nil
+}
+
+func (t *topology) applyXdr(item *topologyPlanItem, threads int) error {
+	tx := item.xdr
+	cmd := a.opts.XDR.Connect
+	cmd.sourceClusterName = TypeClusterName(tx.Source)
+	cmd.destinationClusterNames = TypeClusterName(strings.Join(item.xdrMissing, ","))
+	cmd.aws = cmd.Aws
+	cmd.isConnector = false
+	cmd.parallelLimit = threads
+	cmd.Namespaces = strings.Join(tx.Namespaces, ",")
+	cmd.Restart = "n"
+	return cmd.runXdrConnect(nil)
+}
+
+func topologyReadConf(cluster string, node int) (aeroconf.Stanza, error) {
+	out, err := b.RunCommands(cluster, [][]string{{"cat", "/etc/aerospike/aerospike.conf"}}, []int{node})
+	if err != nil {
+		nout := ""
+		for _, n := range out {
+			nout = nout + "\n" + string(n)
+		}
+		return nil, fmt.Errorf("%s: %s", nout, err)
+	}
+	return aeroconf.Parse(bytes.NewReader(out[0]))
+}
+
+// patch the configuration stanza, creating parent stanzas as needed; returns true if anything changed
+func (tc *topologyConf) patch(s aeroconf.Stanza) (changed bool, err error) {
+	pathn := confAdjustSplitPath(tc.Key)
+	sa := s
+	for _, i := range pathn[0 : len(pathn)-1] {
+		if sa.Stanza(i) == nil {
+			if tc.Delete {
+				return false, nil
+			}
+			if sa.Type(i) == aeroconf.ValueString {
+				return false, fmt.Errorf("key item '%s' is a string not a stanza", i)
+			}
+			err = sa.NewStanza(i)
+			if err != nil {
+				return false, err
+			}
+			changed = true
+		}
+		sa = sa.Stanza(i)
+	}
+	key := pathn[len(pathn)-1]
+	if tc.Delete {
+		if _, ok := sa[key]; !ok {
+			return changed, nil
+		}
+		return true, sa.Delete(key)
+	}
+	if sa.Type(key) == aeroconf.ValueStanza {
+		return changed, fmt.Errorf("key item '%s' is a stanza not a string", key)
+	}
+	if _, ok := sa[key]; ok {
+		vals, err := sa.GetValues(key)
+		if err != nil {
+			return changed, err
+		}
+		current := []string{}
+		for _, v := range vals {
+			if v == nil {
+				current = append(current, "")
+			} else {
+				current = append(current, *v)
+			}
+		}
+		expected := tc.Values
+		if len(expected) == 0 {
+			expected = []string{""}
+		}
+		if strings.Join(current, "\n") == strings.Join(expected, "\n") {
+			return changed, nil
+		}
+	}
+	values := tc.Values
+	if len(values) == 0 {
+		values = []string{""}
+	}
+	return true, sa.SetValues(key, aeroconf.SliceToValues(values))
+}
@@ -5,6 +5,7 @@ type attachAqlCmd struct {
 }
 
 func (c *attachAqlCmd) Execute(args []string) error {
+	c.storedAuth = true
 	command := append([]string{"aql"}, args...)
 	return c.run(command)
 }
@@ -5,6 +5,7 @@ type attachAsadmCmd struct {
 }
 
 func (c *attachAsadmCmd) Execute(args []string) error {
+	c.storedAuth = true
 	command := append([]string{"asadm"}, args...)
 	return c.run(command)
 }
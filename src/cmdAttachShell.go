@@ -20,6 +20,7 @@ type attachShellCmd struct {
 	Parallel    bool                   `long:"parallel" description:"enable parallel execution across all machines"`
 	Tail        []string               `description:"List containing command parameters to execute, ex: [\"ls\",\"/opt\"]" webrequired:"true"`
 	Help        attachCmdHelp          `command:"help" subcommands-optional:"true" description:"Print help"`
+	storedAuth  bool                   // pass the stored credentials of the cluster to the tool in args[0]
 }
 
 type attachCmdHelp struct{}
@@ -81,6 +82,9 @@ func (c *attachShellCmd) run(args []string) (err error) { // method "run"
 			nodes = append(nodes, nodeInt)
 		}
 	}
+	if c.storedAuth {
+		args = append(append([]string{args[0]}, securityAuthArgs(c.ClusterName.String(), nodes, args[1:])...), args[1:]...)
+	}
 	if len(nodes) > 1 && (len(args) == 0 || (len(args) == 1 && (args[0] == "asadm" || args[0] == "aql" || args[0] == "asinfo"))) {
 		return fmt.Errorf("%s", "When using more than 1 node in node-attach, you must specify the command to run. For example: 'node-attach -l 1,2,3 -- /command/to/run'")
 	}
@@ -0,0 +1,14 @@
+package main
+
+import "os"
+
+type chaosCmd struct {
+	Run  chaosRunCmd `command:"run" subcommands-optional:"true" description:"Run a chaos scenario timeline from a yaml file" webicon:"fas fa-play"`
+	Help helpCmd     `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *chaosCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
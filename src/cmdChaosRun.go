@@ -0,0 +1,659 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	flags "github.com/rglonek/jeddevdk-goflags"
+	"gopkg.in/yaml.v3"
+)
+
+type chaosRunCmd struct {
+	ScenarioFile    flags.Filename `short:"f" long:"file" description:"Scenario yaml file; may also be given as the first argument" default:"scenario.yaml"`
+	EventLog        string         `short:"e" long:"event-log" description:"File to append the JSON event log to, one event per line" default:"chaos-events.json"`
+	Timeline        bool           `short:"T" long:"timeline" description:"Print the timeline of the scenario and exit without applying anything"`
+	ContinueOnError bool           `short:"c" long:"continue-on-error" description:"If a step fails to apply, log the error and carry on with the timeline instead of aborting"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+const (
+	chaosActionBlock     = "block"
+	chaosActionPartition = "partition"
+	chaosActionLatency   = "latency"
+	chaosActionKill      = "kill"
+	chaosActionStop      = "stop"
+	chaosActionFillDisk  = "fill-disk"
+	chaosActionCommand   = "command"
+)
+
+type chaosScenario struct {
+	Name     string        `yaml:"name"`
+	Duration time.Duration `yaml:"duration"`
+	Steps    []*chaosStep  `yaml:"steps"`
+}
+
+type chaosStep struct {
+	Name     string        `yaml:"name"`
+	Action   string        `yaml:"action"`
+	At       time.Duration `yaml:"at"`
+	Duration time.Duration `yaml:"duration"`
+	Revert   *bool         `yaml:"revert"`
+	// kill, stop, fill-disk, command, partition
+	Cluster string `yaml:"cluster"`
+	Nodes   string `yaml:"nodes"`
+	Client  bool   `yaml:"client"`
+	// block, latency
+	Source            string `yaml:"source"`
+	SourceNodes       string `yaml:"sourceNodes"`
+	SourceClient      bool   `yaml:"sourceClient"`
+	Destination       string `yaml:"destination"`
+	DestinationNodes  string `yaml:"destinationNodes"`
+	DestinationClient bool   `yaml:"destinationClient"`
+	// block, partition
+	Ports   string `yaml:"ports"`
+	Type    string `yaml:"type"`
+	BlockOn string `yaml:"blockOn"`
+	// partition
+	Groups string `yaml:"groups"`
+	// latency
+	LatencyMs     string `yaml:"latencyMs"`
+	LossPct       string `yaml:"lossPct"`
+	RateBytes     string `yaml:"rateBytes"`
+	CorruptPct    string `yaml:"corruptPct"`
+	OnDestination bool   `yaml:"onDestination"`
+	// kill
+	Signal string `yaml:"signal"`
+	// fill-disk
+	Path string `yaml:"path"`
+	Size string `yaml:"size"`
+	// command
+	Command       string `yaml:"command"`
+	RevertCommand string `yaml:"revertCommand"`
+	index         int
+}
+
+type chaosEvent struct {
+	Time     time.Time `json:"time"`
+	TimeMs   int64     `json:"timeMs"`
+	Offset   string    `json:"offset"`
+	Run      string    `json:"run"`
+	Scenario string    `json:"scenario"`
+	Step     string    `json:"step,omitempty"`
+	Action   string    `json:"action,omitempty"`
+	Event    string    `json:"event"`
+	Detail   string    `json:"detail,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type chaosTimelineItem struct {
+	At     time.Duration
+	Step   *chaosStep
+	Revert bool
+}
+
+func (c *chaosRunCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	if len(args) > 0 {
+		c.ScenarioFile = flags.Filename(args[0])
+	}
+	log.Print("Running chaos.run")
+	s, err := loadChaosScenario(string(c.ScenarioFile))
+	if err != nil {
+		return err
+	}
+	timeline := s.timeline()
+	if c.Timeline {
+		s.printTimeline(timeline)
+		return nil
+	}
+	err = c.run(s, timeline)
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func loadChaosScenario(fileName string) (*chaosScenario, error) {
+	f, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read scenario file: %s", err)
+	}
+	s := &chaosScenario{}
+	dec := yaml.NewDecoder(bytes.NewReader(f))
+	dec.KnownFields(true)
+	err = dec.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse scenario file: %s", err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	}
+	return s, s.validate()
+}
+
+func (s *chaosScenario) validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("scenario has no steps")
+	}
+	names := make(map[string]bool)
+	for i, step := range s.Steps {
+		step.index = i
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step-%d-%s", i+1, step.Action)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("duplicate step name: %s", step.Name)
+		}
+		names[step.Name] = true
+		if step.At < 0 || step.Duration < 0 {
+			return fmt.Errorf("step %s: at and duration cannot be negative", step.Name)
+		}
+		if step.Revert == nil {
+			revert := true
+			step.Revert = &revert
+		}
+		err := step.validate()
+		if err != nil {
+			return fmt.Errorf("step %s: %s", step.Name, err)
+		}
+	}
+	return nil
+}
+
+func (step *chaosStep) validate() error {
+	switch step.Action {
+	case chaosActionBlock:
+		if step.Source == "" || step.Destination == "" {
+			return errors.New("source and destination are required")
+		}
+		if step.Ports == "" {
+			step.Ports = "3000"
+		}
+		if step.Type == "" {
+			step.Type = "reject"
+		}
+		if step.BlockOn == "" {
+			step.BlockOn = "input"
+		}
+	case chaosActionPartition:
+		if step.Cluster == "" {
+			return errors.New("cluster is required")
+		}
+		_, err := netParsePartitionGroups(step.Groups)
+		if err != nil {
+			return err
+		}
+		if step.Ports == "" {
+			step.Ports = "3001,3002,3003"
+		}
+		if step.Type == "" {
+			step.Type = "drop"
+		}
+	case chaosActionLatency:
+		if step.Source == "" || step.Destination == "" {
+			return errors.New("source and destination are required")
+		}
+		if step.LatencyMs == "" && step.LossPct == "" && step.RateBytes == "" && step.CorruptPct == "" {
+			return errors.New("at least one of latencyMs, lossPct, rateBytes or corruptPct is required")
+		}
+	case chaosActionKill:
+		if step.Cluster == "" {
+			return errors.New("cluster is required")
+		}
+		if step.Signal == "" {
+			step.Signal = "KILL"
+		}
+		step.Signal = strings.TrimPrefix(strings.ToUpper(step.Signal), "SIG")
+	case chaosActionStop:
+		if step.Cluster == "" {
+			return errors.New("cluster is required")
+		}
+	case chaosActionFillDisk:
+		if step.Cluster == "" || step.Path == "" || step.Size == "" {
+			return errors.New("cluster, path and size are required")
+		}
+		if strings.HasSuffix(step.Size, "%") {
+			pct, err := strconv.Atoi(strings.TrimSuffix(step.Size, "%"))
+			if err != nil || pct < 1 || pct > 100 {
+				return errors.New("size percentage must be between 1% and 100%")
+			}
+		}
+	case chaosActionCommand:
+		if step.Cluster == "" || step.Command == "" {
+			return errors.New("cluster and command are required")
+		}
+	default:
+		return fmt.Errorf("unknown action '%s', supported: %s", step.Action, strings.Join([]string{chaosActionBlock, chaosActionPartition, chaosActionLatency, chaosActionKill, chaosActionStop, chaosActionFillDisk, chaosActionCommand}, ", "))
+	}
+	if step.Client && (step.Action == chaosActionKill || step.Action == chaosActionStop || step.Action == chaosActionPartition) {
+		return fmt.Errorf("action %s is only supported against clusters", step.Action)
+	}
+	return nil
+}
+
+// timeline returns the apply and revert events in order of execution; steps without a duration are reverted at the end of the scenario
+func (s *chaosScenario) timeline() []*chaosTimelineItem {
+	end := s.Duration
+	for _, step := range s.Steps {
+		if step.At+step.Duration > end {
+			end = step.At + step.Duration
+		}
+	}
+	items := []*chaosTimelineItem{}
+	for _, step := range s.Steps {
+		items = append(items, &chaosTimelineItem{At: step.At, Step: step})
+		if !*step.Revert {
+			continue
+		}
+		at := end
+		if step.Duration > 0 {
+			at = step.At + step.Duration
+		}
+		items = append(items, &chaosTimelineItem{At: at, Step: step, Revert: true})
+	}
+	// at the same time, reverts go first, in reverse order of the steps
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].At != items[j].At {
+			return items[i].At < items[j].At
+		}
+		if items[i].Revert != items[j].Revert {
+			return items[i].Revert
+		}
+		if items[i].Revert {
+			return items[i].Step.index > items[j].Step.index
+		}
+		return items[i].Step.index < items[j].Step.index
+	})
+	return items
+}
+
+func (s *chaosScenario) printTimeline(timeline []*chaosTimelineItem) {
+	fmt.Printf("Scenario: %s\n", s.Name)
+	for _, item := range timeline {
+		event := "apply "
+		if item.Revert {
+			event = "revert"
+		}
+		fmt.Printf("%10s  %s  %s (%s)\n", item.At.String(), event, item.Step.Name, item.Step.describe())
+	}
+}
+
+func (step *chaosStep) describe() string {
+	target := step.Cluster
+	if step.Nodes != "" {
+		target = target + " nodes " + step.Nodes
+	}
+	switch step.Action {
+	case chaosActionBlock:
+		return fmt.Sprintf("block %s -> %s ports %s (%s on %s)", chaosEndpoint(step.Source, step.SourceNodes), chaosEndpoint(step.Destination, step.DestinationNodes), step.Ports, step.Type, step.BlockOn)
+	case chaosActionPartition:
+		return fmt.Sprintf("partition %s into groups %s on ports %s", step.Cluster, step.Groups, step.Ports)
+	case chaosActionLatency:
+		params := []string{}
+		if step.LatencyMs != "" {
+			params = append(params, "latency="+step.LatencyMs+"ms")
+		}
+		if step.LossPct != "" {
+			params = append(params, "loss="+step.LossPct+"%")
+		}
+		if step.RateBytes != "" {
+			params = append(params, "rate="+step.RateBytes)
+		}
+		if step.CorruptPct != "" {
+			params = append(params, "corrupt="+step.CorruptPct+"%")
+		}
+		return fmt.Sprintf("latency %s -> %s %s", chaosEndpoint(step.Source, step.SourceNodes), chaosEndpoint(step.Destination, step.DestinationNodes), strings.Join(params, " "))
+	case chaosActionKill:
+		return fmt.Sprintf("kill -%s asd on %s", step.Signal, target)
+	case chaosActionStop:
+		return fmt.Sprintf("stop aerospike on %s", target)
+	case chaosActionFillDisk:
+		return fmt.Sprintf("fill %s to %s on %s", step.Path, step.Size, target)
+	case chaosActionCommand:
+		return fmt.Sprintf("run '%s' on %s", step.Command, target)
+	}
+	return step.Action
+}
+
+func chaosEndpoint(name string, nodes string) string {
+	if nodes == "" {
+		return name
+	}
+	return name + ":" + nodes
+}
+
+type chaosRunner struct {
+	c        *chaosRunCmd
+	scenario *chaosScenario
+	runId    string
+	start    time.Time
+	enc      *json.Encoder
+	applied  []*chaosStep
+}
+
+func (r *chaosRunner) event(step *chaosStep, event string, detail string, err error) {
+	now := time.Now()
+	e := &chaosEvent{
+		Time:     now,
+		TimeMs:   now.UnixMilli(),
+		Offset:   now.Sub(r.start).Round(time.Millisecond).String(),
+		Run:      r.runId,
+		Scenario: r.scenario.Name,
+		Event:    event,
+		Detail:   detail,
+	}
+	if step != nil {
+		e.Step = step.Name
+		e.Action = step.Action
+	}
+	msg := "chaos: " + event
+	if e.Step != "" {
+		msg = msg + " " + e.Step
+	}
+	if detail != "" {
+		msg = msg + ": " + detail
+	}
+	if err != nil {
+		e.Error = err.Error()
+		msg = msg + ": " + e.Error
+	}
+	log.Print(msg)
+	if encErr := r.enc.Encode(e); encErr != nil {
+		log.Printf("WARNING: could not write to event log: %s", encErr)
+	}
+}
+
+func (c *chaosRunCmd) run(s *chaosScenario, timeline []*chaosTimelineItem) error {
+	f, err := os.OpenFile(c.EventLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open event log: %s", err)
+	}
+	defer f.Close()
+	r := &chaosRunner{
+		c:        c,
+		scenario: s,
+		start:    time.Now(),
+		enc:      json.NewEncoder(f),
+	}
+	r.runId = strconv.FormatInt(r.start.Unix(), 10)
+
+	// on interrupt, revert whatever was applied before exiting
+	abort := make(chan struct{})
+	done := make(chan struct{})
+	abortOnce := new(sync.Once)
+	addShutdownHandler("chaos", func(os.Signal) {
+		abortOnce.Do(func() { close(abort) })
+		<-done
+	})
+	defer func() {
+		delShutdownHandler("chaos")
+		close(done)
+	}()
+
+	r.event(nil, "scenario-start", fmt.Sprintf("%d steps from %s", len(s.Steps), c.ScenarioFile), nil)
+	for _, item := range timeline {
+		wait := time.Until(r.start.Add(item.At))
+		if wait > 0 {
+			select {
+			case <-abort:
+				r.event(nil, "scenario-abort", "interrupted", nil)
+				return errors.Join(errors.New("scenario interrupted"), r.revertAll())
+			case <-time.After(wait):
+			}
+		}
+		select {
+		case <-abort:
+			r.event(nil, "scenario-abort", "interrupted", nil)
+			return errors.Join(errors.New("scenario interrupted"), r.revertAll())
+		default:
+		}
+		if item.Revert {
+			if !r.isApplied(item.Step) {
+				continue
+			}
+			err = r.revert(item.Step)
+			if err != nil && !c.ContinueOnError {
+				r.event(nil, "scenario-abort", "revert failed", err)
+				return errors.Join(err, r.revertAll())
+			}
+			continue
+		}
+		err = r.apply(item.Step)
+		if err != nil && !c.ContinueOnError {
+			r.event(nil, "scenario-abort", "apply failed", err)
+			return errors.Join(err, r.revertAll())
+		}
+	}
+	r.event(nil, "scenario-end", fmt.Sprintf("%d steps still applied", len(r.applied)), nil)
+	return nil
+}
+
+func (r *chaosRunner) isApplied(step *chaosStep) bool {
+	for _, s := range r.applied {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *chaosRunner) apply(step *chaosStep) error {
+	r.event(step, "apply", step.describe(), nil)
+	err := step.run(r.c, r.scenario, false)
+	if err != nil {
+		r.event(step, "error", "apply failed", err)
+		return err
+	}
+	r.applied = append(r.applied, step)
+	r.event(step, "applied", step.describe(), nil)
+	return nil
+}
+
+func (r *chaosRunner) revert(step *chaosStep) error {
+	for i, s := range r.applied {
+		if s == step {
+			r.applied = append(r.applied[:i], r.applied[i+1:]...)
+			break
+		}
+	}
+	r.event(step, "revert", step.describe(), nil)
+	err := step.run(r.c, r.scenario, true)
+	if err != nil {
+		r.event(step, "error", "revert failed", err)
+		return err
+	}
+	r.event(step, "reverted", step.describe(), nil)
+	return nil
+}
+
+// revertAll reverts all applied steps which are set to be reverted, in reverse order
+func (r *chaosRunner) revertAll() error {
+	var errs error
+	for len(r.applied) > 0 {
+		step := r.applied[len(r.applied)-1]
+		if !*step.Revert {
+			r.applied = r.applied[:len(r.applied)-1]
+			continue
+		}
+		errs = errors.Join(errs, r.revert(step))
+	}
+	return errs
+}
+
+// run applies, or reverts, a step using the matching aerolab command code path
+func (step *chaosStep) run(c *chaosRunCmd, s *chaosScenario, revert bool) error {
+	switch step.Action {
+	case chaosActionBlock:
+		block := &netBlockCmd{
+			SourceClusterName:      TypeClusterName(step.Source),
+			SourceNodeList:         TypeNodes(step.SourceNodes),
+			IsSourceClient:         step.SourceClient,
+			DestinationClusterName: TypeClusterName(step.Destination),
+			DestinationNodeList:    TypeNodes(step.DestinationNodes),
+			IsDestinationClient:    step.DestinationClient,
+			Type:                   TypeNetType(step.Type),
+			Ports:                  step.Ports,
+			BlockOn:                TypeNetBlockOn(step.BlockOn),
+		}
+		if revert {
+			return block.run("-D")
+		}
+		return block.run("-I")
+	case chaosActionPartition:
+		partition := chaosPartitionName(s, step)
+		if revert {
+			heal := &netHealCmd{
+				ClusterName: TypeClusterName(step.Cluster),
+				Partition:   partition,
+			}
+			heal.ParallelThreads = c.ParallelThreads
+			return heal.run()
+		}
+		p := &netPartitionCmd{
+			ClusterName: TypeClusterName(step.Cluster),
+			Groups:      step.Groups,
+			Partition:   partition,
+			Ports:       step.Ports,
+			Type:        TypeNetType(step.Type),
+		}
+		p.ParallelThreads = c.ParallelThreads
+		return p.run()
+	case chaosActionLatency:
+		ld := &netLossDelayCmd{
+			SourceClusterName:      TypeClusterName(step.Source),
+			SourceNodeList:         TypeNodes(step.SourceNodes),
+			IsSourceClient:         step.SourceClient,
+			DestinationClusterName: TypeClusterName(step.Destination),
+			DestinationNodeList:    TypeNodes(step.DestinationNodes),
+			IsDestinationClient:    step.DestinationClient,
+			Action:                 "set",
+			LatencyMs:              step.LatencyMs,
+			PacketLossPct:          step.LossPct,
+			LinkSpeedRateBytes:     step.RateBytes,
+			CorruptPct:             step.CorruptPct,
+			RunOnDestination:       step.OnDestination,
+		}
+		ld.ParallelThreads = c.ParallelThreads
+		if revert {
+			ld.Action = "del"
+		}
+		return ld.Execute(nil)
+	case chaosActionKill, chaosActionStop:
+		if revert || step.Action == chaosActionStop {
+			cmd := &a.opts.Aerospike.Start
+			command := "start"
+			if !revert {
+				cmd = &a.opts.Aerospike.Stop.aerospikeStartCmd
+				command = "stop"
+			}
+			cmd.ClusterName = TypeClusterName(step.Cluster)
+			cmd.Nodes = TypeNodes(step.Nodes)
+			cmd.ParallelThreads = c.ParallelThreads
+			return cmd.run(nil, command, os.Stdout)
+		}
+		return step.runOnNodes(c, []string{"pkill", "-" + step.Signal, "-x", "asd"})
+	case chaosActionFillDisk:
+		fileName := fmt.Sprintf("%s/.aerolab-chaos-%s-%d", strings.TrimRight(step.Path, "/"), s.Name, step.index)
+		if revert {
+			return step.runOnNodes(c, []string{"rm", "-f", fileName})
+		}
+		size := step.Size
+		if strings.HasSuffix(size, "%") {
+			// fill the filesystem up to the given percentage of its size
+			size = fmt.Sprintf("$(df -B1 --output=size,used %s | tail -1 | awk '{s=int($1*%s/100-$2); if (s<1) s=1; print s}')", step.Path, strings.TrimSuffix(size, "%"))
+		}
+		return step.runScriptOnNodes(c, fmt.Sprintf("set -e\nfallocate -l %s %s\n", size, fileName))
+	case chaosActionCommand:
+		if revert {
+			if step.RevertCommand == "" {
+				return nil
+			}
+			return step.runScriptOnNodes(c, step.RevertCommand)
+		}
+		return step.runScriptOnNodes(c, step.Command)
+	}
+	return fmt.Errorf("unknown action %s", step.Action)
+}
+
+// runScriptOnNodes uploads the script to the nodes and runs it, so that it does not need to be quoted for the remote shell
+func (step *chaosStep) runScriptOnNodes(c *chaosRunCmd, script string) error {
+	fileName := fmt.Sprintf("/tmp/aerolab-chaos-%d.sh", step.index)
+	nodes, err := step.nodeList()
+	if err != nil {
+		return err
+	}
+	if step.Client {
+		b.WorkOnClients()
+		defer b.WorkOnServers()
+	}
+	err = b.CopyFilesToCluster(step.Cluster, []fileList{{filePath: fileName, fileContents: script, fileSize: len(script)}}, nodes)
+	if err != nil {
+		return err
+	}
+	return step.runOnNodes(c, []string{"/bin/bash", fileName})
+}
+
+func (step *chaosStep) nodeList() ([]int, error) {
+	if step.Client {
+		b.WorkOnClients()
+		defer b.WorkOnServers()
+	}
+	nodes, err := expandNodeList(step.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		nodes, err = b.NodeListInCluster(step.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("%s not found", step.Cluster)
+		}
+	}
+	return nodes, nil
+}
+
+// chaosPartitionName returns the net partition name for a step, so that reverting only heals the partition of that step
+func chaosPartitionName(s *chaosScenario, step *chaosStep) string {
+	name := regexp.MustCompile(`[^a-zA-Z0-9_-]`).ReplaceAllString(s.Name, "_")
+	if len(name) > 48 {
+		name = name[:48]
+	}
+	return fmt.Sprintf("chaos-%s-%d", name, step.index)
+}
+
+func (step *chaosStep) runOnNodes(c *chaosRunCmd, command []string) error {
+	nodes, err := step.nodeList()
+	if err != nil {
+		return err
+	}
+	if step.Client {
+		b.WorkOnClients()
+		defer b.WorkOnServers()
+	}
+	out, err := b.RunCommands(step.Cluster, [][]string{command}, nodes)
+	if err != nil {
+		nout := ""
+		for _, n := range out {
+			nout = nout + "\n" + string(n)
+		}
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(nout))
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ type clientCreateCmd struct {
 	RestGateway   clientCreateRestGatewayCmd   `command:"rest-gateway" subcommands-optional:"true" description:"deploy a rest-gateway client machine" webicon:"fas fa-dungeon" invwebforce:"true"`
 	Graph         clientCreateGraphCmd         `command:"graph" subcommands-optional:"true" description:"deploy a graph client machine" webicon:"fas fa-diagram-project" invwebforce:"true"`
 	EksCtl        clientCreateEksCtlCmd        `command:"eksctl" subcommands-optional:"true" description:"deploy a client machine with preconfigured eksctl for k8s aerospike cluster deployments" webicon:"fas fa-box-open" invwebforce:"true"`
+	Ldap          clientCreateLdapCmd          `command:"ldap" subcommands-optional:"true" description:"deploy an OpenLDAP server with seeded users and groups; for cluster configuration see: conf ldap" webicon:"fas fa-address-book" invwebforce:"true"`
 	// NEW_CLIENTS_CREATE
 	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
 }
@@ -116,4 +117,11 @@ func init() {
 	addBackendSwitch("client.grow.eksctl", "aws", &a.opts.Client.Grow.EksCtl.Aws)
 	addBackendSwitch("client.grow.eksctl", "gcp", &a.opts.Client.Grow.EksCtl.Gcp)
 	addBackendSwitch("client.grow.eksctl", "docker", &a.opts.Client.Grow.EksCtl.Docker)
+
+	addBackendSwitch("client.create.ldap", "aws", &a.opts.Client.Create.Ldap.Aws)
+	addBackendSwitch("client.create.ldap", "gcp", &a.opts.Client.Create.Ldap.Gcp)
+	addBackendSwitch("client.create.ldap", "docker", &a.opts.Client.Create.Ldap.Docker)
+	addBackendSwitch("client.grow.ldap", "aws", &a.opts.Client.Grow.Ldap.Aws)
+	addBackendSwitch("client.grow.ldap", "gcp", &a.opts.Client.Grow.Ldap.Gcp)
+	addBackendSwitch("client.grow.ldap", "docker", &a.opts.Client.Grow.Ldap.Docker)
 }
@@ -96,7 +96,7 @@ func (c *clientAddElasticSearchCmd) addElasticSearch(args []string) error {
 	isDocker := false
 	if a.opts.Config.Backend.Type == "docker" {
 		isDocker = true
-		out, err := exec.Command("docker", "run", "--rm", "-i", "--privileged", "ubuntu:24.04", "sysctl", "-w", "vm.max_map_count=262144").CombinedOutput()
+		out, err := exec.Command(containerRuntimeBinary(), "run", "--rm", "-i", "--privileged", "ubuntu:24.04", "sysctl", "-w", "vm.max_map_count=262144").CombinedOutput()
 		if err != nil {
 			fmt.Println("Workaround `sysctl -w vm.max_map_count=262144` for docker failed, elasticsearch might fail to start...")
 			fmt.Println(err)
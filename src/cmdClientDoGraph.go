@@ -141,7 +141,7 @@ func (c *clientCreateGraphCmd) Execute(args []string) error {
 			if c.DockerLoginURL != "" {
 				params = append(params, c.DockerLoginURL)
 			}
-			out, err := exec.Command("docker", params...).CombinedOutput()
+			out, err := exec.Command(containerRuntimeBinary(), params...).CombinedOutput()
 			if err != nil {
 				return fmt.Errorf("%s\n%s", err, string(out))
 			}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/aerospike/aerolab/scripts"
+)
+
+type clientCreateLdapCmd struct {
+	clientCreateNoneCmd
+	BaseDN        string   `long:"base-dn" description:"base DN of the directory, must be made of dc= components" default:"dc=aerospike,dc=com"`
+	AdminPassword string   `long:"admin-password" description:"password of the LDAP admin, cn=admin,BASE_DN" default:"admin123" webtype:"password"`
+	Users         []string `short:"u" long:"user" description:"user to seed, as USER:PASSWORD:ROLE1+ROLE2; roles are created as LDAP groups and map to aerospike roles of the same name; can be specified multiple times; default: superuser:superuser123:user-admin+sys-admin+data-admin+read-write, alice:alice123:read-write, bob:bob123:read"`
+	NoTLS         bool     `long:"no-tls" description:"do not configure ldaps on port 636"`
+	CAName        string   `long:"ca-name" description:"name of the CA in the CA directory of the working directory to issue the LDAP server certificate from; created if it does not exist" default:"cacert"`
+	chDirCmd
+}
+
+// ldapSeedUser is a user to create in the LDAP directory, with the groups (aerospike roles) it is a member of
+type ldapSeedUser struct {
+	name     string
+	password string
+	roles    []string
+}
+
+var ldapNameRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+var ldapBaseDNRegex = regexp.MustCompile(`^dc=[a-zA-Z0-9-]+(,dc=[a-zA-Z0-9-]+)*$`)
+
+func (c *clientCreateLdapCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	if c.DistroName != TypeDistro("ubuntu") && c.DistroName != TypeDistro("debian") {
+		return fmt.Errorf("the LDAP server is only supported on ubuntu and debian, selected %s", c.DistroName)
+	}
+	if !ldapBaseDNRegex.MatchString(c.BaseDN) {
+		return fmt.Errorf("base DN %s must be made of dc= components only, ex: dc=aerospike,dc=com", c.BaseDN)
+	}
+	domain := strings.ReplaceAll(strings.TrimPrefix(c.BaseDN, "dc="), ",dc=", ".")
+	if c.AdminPassword == "" || strings.ContainsAny(c.AdminPassword, "'\n") {
+		return errors.New("admin password must not be empty and must not contain single quotes or newlines")
+	}
+	if len(c.Users) == 0 {
+		c.Users = []string{"superuser:superuser123:user-admin+sys-admin+data-admin+read-write", "alice:alice123:read-write", "bob:bob123:read"}
+	}
+	users, err := ldapParseUsers(c.Users)
+	if err != nil {
+		return err
+	}
+	seed, err := ldapSeedLdif(c.BaseDN, users)
+	if err != nil {
+		return err
+	}
+	var ca *tlsCA
+	keyOpts := tlsKeyOpts{keyType: "rsa", bits: 2048}
+	if !c.NoTLS {
+		err = chDir(string(c.ChDir))
+		if err != nil {
+			return err
+		}
+		ca, err = tlsLoadOrCreateCA("CA", c.CAName, "", keyOpts, 3650)
+		if err != nil {
+			return err
+		}
+	}
+	machines, err := c.createBase(args, "ldap")
+	if err != nil {
+		return err
+	}
+	if c.PriceOnly {
+		return nil
+	}
+	log.Println("Continuing LDAP server installation...")
+	b.WorkOnClients()
+	ipMaps, err := tlsNodeIpMaps(c.ClientName.String())
+	if err != nil {
+		return err
+	}
+	script := scripts.GetLdapInstallScript(domain, c.BaseDN, c.AdminPassword, !c.NoTLS)
+	returns := parallelize.MapLimit(machines, c.ParallelThreads, func(node int) error {
+		files := map[string][]byte{
+			"/tmp/install-ldap.sh": script,
+			"/tmp/ldap-seed.ldif":  seed,
+		}
+		if ca != nil {
+			dir, err := tlsIssueNodeCert(ca, keyOpts, c.ClientName.String(), true, node, ipMaps, "ldap", "", 3650)
+			if err != nil {
+				return err
+			}
+			for src, dst := range map[string]string{
+				filepath.Join(dir, "ldap.pem"):       "/etc/ldap/certs/ldap.pem",
+				filepath.Join(dir, "ldap.key"):       "/etc/ldap/certs/ldap.key",
+				filepath.Join("CA", c.CAName+".pem"): "/etc/ldap/certs/ca.pem",
+			} {
+				files[dst], err = os.ReadFile(src)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		err := tlsUploadFiles(c.ClientName.String(), node, files)
+		if err != nil {
+			return err
+		}
+		defer backendRestoreTerminal()
+		return b.AttachAndRun(c.ClientName.String(), node, []string{"/bin/bash", "/tmp/install-ldap.sh"}, false)
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", machines[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	log.Println("Done")
+	log.Printf("LDAP admin: cn=admin,%s password: %s", c.BaseDN, c.AdminPassword)
+	for _, user := range users {
+		log.Printf("LDAP user: %s password: %s roles: %s", user.name, user.password, strings.Join(user.roles, ","))
+	}
+	log.Print("Common tasks and commands:")
+	log.Printf(" * configure a cluster to use this server: aerolab conf ldap -n mydc --ldap-client %s", c.ClientName)
+	log.Printf(" * search the directory:                   aerolab attach client -n %s -- ldapsearch -x -H ldapi:/// -b %s", c.ClientName, c.BaseDN)
+	return nil
+}
+
+// ldapParseUsers parses USER:PASSWORD:ROLE1+ROLE2 user definitions
+func ldapParseUsers(defs []string) ([]ldapSeedUser, error) {
+	users := []ldapSeedUser{}
+	for _, def := range defs {
+		parts := strings.SplitN(def, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("user %s must be in the format USER:PASSWORD:ROLE1+ROLE2", def)
+		}
+		user := ldapSeedUser{name: parts[0], password: parts[1]}
+		if !ldapNameRegex.MatchString(user.name) {
+			return nil, fmt.Errorf("user name %s may only contain letters, numbers, dots, underscores and dashes", user.name)
+		}
+		if user.password == "" || strings.Contains(user.password, "\n") {
+			return nil, fmt.Errorf("user %s: password must not be empty and must not contain newlines", user.name)
+		}
+		for _, role := range strings.Split(parts[2], "+") {
+			if role == "" {
+				continue
+			}
+			if !ldapNameRegex.MatchString(role) {
+				return nil, fmt.Errorf("user %s: role name %s may only contain letters, numbers, dots, underscores and dashes", user.name, role)
+			}
+			user.roles = append(user.roles, role)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// ldapSeedLdif returns the LDIF creating ou=People and ou=Groups, the users, and one posixGroup per role with the users as memberUid
+func ldapSeedLdif(baseDN string, users []ldapSeedUser) ([]byte, error) {
+	ldif := &strings.Builder{}
+	fmt.Fprintf(ldif, "dn: ou=People,%s\nobjectClass: organizationalUnit\nou: People\n\n", baseDN)
+	fmt.Fprintf(ldif, "dn: ou=Groups,%s\nobjectClass: organizationalUnit\nou: Groups\n\n", baseDN)
+	roles := make(map[string][]string)
+	for i, user := range users {
+		password, err := ldapSshaPassword(user.password)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(ldif, "dn: uid=%s,ou=People,%s\nobjectClass: inetOrgPerson\nobjectClass: posixAccount\nobjectClass: shadowAccount\n", user.name, baseDN)
+		fmt.Fprintf(ldif, "uid: %s\ncn: %s\nsn: %s\nuidNumber: %d\ngidNumber: %d\nhomeDirectory: /home/%s\nloginShell: /bin/bash\nuserPassword: %s\n\n", user.name, user.name, user.name, 10001+i, 10001+i, user.name, password)
+		for _, role := range user.roles {
+			roles[role] = append(roles[role], user.name)
+		}
+	}
+	roleNames := []string{}
+	for role := range roles {
+		roleNames = append(roleNames, role)
+	}
+	sort.Strings(roleNames)
+	for _, role := range roleNames {
+		ldif.WriteString(ldapGroupLdif(baseDN, role, roles[role]))
+	}
+	return []byte(ldif.String()), nil
+}
+
+// ldapGroupLdif returns the LDIF of a posixGroup for a role; the gidNumber is derived from the role name, so that it is stable across runs
+func ldapGroupLdif(baseDN string, role string, members []string) string {
+	h := fnv.New32a()
+	h.Write([]byte(role))
+	ldif := fmt.Sprintf("dn: cn=%s,ou=Groups,%s\nobjectClass: posixGroup\ncn: %s\ngidNumber: %d\n", role, baseDN, role, 20000+h.Sum32()%10000)
+	for _, member := range members {
+		ldif += "memberUid: " + member + "\n"
+	}
+	return ldif + "\n"
+}
+
+// ldapSshaPassword returns a salted SHA1 userPassword value
+func ldapSshaPassword(password string) (string, error) {
+	salt := make([]byte, 8)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	h.Write([]byte(password))
+	h.Write(salt)
+	return "{SSHA}" + base64.StdEncoding.EncodeToString(append(h.Sum(nil), salt...)), nil
+}
@@ -59,7 +59,7 @@ func (c *clientStartCmd) runStart(args []string) error {
 		return err
 	}
 	if a.opts.Config.Backend.Type == "docker" {
-		out, err := exec.Command("docker", "run", "--rm", "-i", "--privileged", "ubuntu:22.04", "sysctl", "-w", "vm.max_map_count=262144").CombinedOutput()
+		out, err := exec.Command(containerRuntimeBinary(), "run", "--rm", "-i", "--privileged", "ubuntu:22.04", "sysctl", "-w", "vm.max_map_count=262144").CombinedOutput()
 		if err != nil {
 			fmt.Println("Workaround `sysctl -w vm.max_map_count=262144` for docker failed, elasticsearch clients might fail to start...")
 			fmt.Println(err)
@@ -15,6 +15,7 @@ type clusterCmd struct {
 	Partition clusterPartitionCmd `command:"partition" subcommands-optional:"true" description:"node disk partitioner" webicon:"fas fa-divide"`
 	Attach    attachShellCmd      `command:"attach" subcommands-optional:"true" description:"symlink to: attach shell" webicon:"fas fa-terminal" simplemode:"false"`
 	Share     clusterShareCmd     `command:"share" subcommands-optional:"true" description:"AWS/GCP: share the cluster by importing a provided ssh public key file" webicon:"fas fa-share"`
+	Snapshot  clusterSnapshotCmd  `command:"snapshot" subcommands-optional:"true" description:"Create, list and restore cluster snapshots" webicon:"fas fa-camera"`
 	Help      helpCmd             `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
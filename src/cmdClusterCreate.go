@@ -117,7 +117,9 @@ func (g guiInstanceType) List(zone string) ([][]string, string, error) {
 type clusterCreateCmd struct {
 	ClusterName             TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
 	NodeCount               int             `short:"c" long:"count" description:"Number of nodes" default:"1"`
+	Versions                string          `long:"versions" description:"Deploy nodes running different aerospike versions; comma-separated list of VERSION:COUNT, ex: 6.4.0.1:2,7.1.0.0:1; overrides -c and -v" simplemode:"false"`
 	CustomConfigFilePath    flags.Filename  `short:"o" long:"customconf" description:"Custom aerospike config file path to install"`
+	NoValidate              bool            `long:"no-validate" description:"Do not validate the custom aerospike config file against the configuration schema of the aerospike version" simplemode:"false"`
 	CustomToolsFilePath     flags.Filename  `short:"z" long:"toolsconf" description:"Custom astools config file path to install"`
 	FeaturesFilePath        flags.Filename  `short:"f" long:"featurefile" description:"Features file to install, or directory containing feature files"`
 	FeaturesFilePrintDetail bool            `long:"featurefile-printdetail" description:"Print details of discovered features files" hidden:"true"`
@@ -324,9 +326,63 @@ func (c *clusterCreateCmd) realExecute(args []string, isGrow bool) error {
 	if earlyProcessV2(nil, true) {
 		return nil
 	}
+	if c.Versions != "" {
+		return c.mixedExecute(args, isGrow)
+	}
 	return c.realExecute2(args, isGrow)
 }
 
+// clusterCreateVersion is a single VERSION:COUNT item of --versions
+type clusterCreateVersion struct {
+	version string
+	count   int
+}
+
+// parseVersions parses the --versions list; the count defaults to 1
+func (c *clusterCreateCmd) parseVersions() ([]clusterCreateVersion, error) {
+	versions := []clusterCreateVersion{}
+	for _, item := range strings.Split(c.Versions, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		version, countString, found := strings.Cut(item, ":")
+		count := 1
+		if found {
+			var err error
+			count, err = strconv.Atoi(countString)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid node count in %s, format is VERSION:COUNT", item)
+			}
+		}
+		versions = append(versions, clusterCreateVersion{version, count})
+	}
+	if len(versions) == 0 {
+		return nil, errors.New("--versions must list at least one VERSION:COUNT")
+	}
+	return versions, nil
+}
+
+// mixedExecute deploys the nodes of each version in turn; the first version creates the cluster, unless growing, and the others grow it
+func (c *clusterCreateCmd) mixedExecute(args []string, isGrow bool) error {
+	versions, err := c.parseVersions()
+	if err != nil {
+		return err
+	}
+	distroVersion := c.DistroVersion
+	for i, v := range versions {
+		log.Printf("Deploying %d nodes of aerospike version %s", v.count, v.version)
+		c.AerospikeVersion = TypeAerospikeVersion(v.version)
+		c.NodeCount = v.count
+		c.DistroVersion = distroVersion
+		err = c.realExecute2(args, isGrow || i > 0)
+		if err != nil {
+			return fmt.Errorf("deploying aerospike version %s: %s", v.version, err)
+		}
+	}
+	return nil
+}
+
 func (c *clusterCreateCmd) realExecute2(args []string, isGrow bool) error {
 	if inslice.HasString(args, "help") {
 		if a.opts.Config.Backend.Type == "docker" {
@@ -680,6 +736,17 @@ func (c *clusterCreateCmd) realExecute2(args []string, isGrow bool) error {
 	verNoSuffix := strings.TrimSuffix(c.AerospikeVersion.String(), "c")
 	verNoSuffix = strings.TrimSuffix(verNoSuffix, "f")
 
+	if string(c.CustomConfigFilePath) != "" && !c.NoValidate {
+		conf, err := os.ReadFile(string(c.CustomConfigFilePath))
+		if err != nil {
+			return err
+		}
+		err = confValidateAuto(string(c.CustomConfigFilePath), conf, verNoSuffix)
+		if err != nil {
+			return err
+		}
+	}
+
 	// build extra
 	var ep []string
 	if c.Docker.ExposePortsToHost != "" {
@@ -732,7 +799,13 @@ func (c *clusterCreateCmd) realExecute2(args []string, isGrow bool) error {
 	if err != nil {
 		return err
 	}
-	if len(inSlice) == 0 {
+	if len(inSlice) == 0 && a.opts.Config.Backend.Type == "mock" {
+		// mock backend does not install anything, so no installer download is needed
+		err = b.DeployTemplate(*bv, "", nil, extra)
+		if err != nil {
+			return err
+		}
+	} else if len(inSlice) == 0 {
 		// template doesn't exist, create one
 		if url == "" {
 			url, err = aerospikeGetUrl(bv, c.Username, c.Password)
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestmethod/inslice"
+	"github.com/jedib0t/go-pretty/v6/table"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+)
+
+type clusterSnapshotCmd struct {
+	Create  clusterSnapshotCreateCmd  `command:"create" subcommands-optional:"true" description:"Snapshot all nodes of a cluster" webicon:"fas fa-camera" invwebforce:"true"`
+	Restore clusterSnapshotRestoreCmd `command:"restore" subcommands-optional:"true" description:"Create a new cluster from a snapshot" webicon:"fas fa-clock-rotate-left" invwebforce:"true"`
+	List    clusterSnapshotListCmd    `command:"list" subcommands-optional:"true" description:"List snapshots" webicon:"fas fa-list"`
+	Destroy clusterSnapshotDestroyCmd `command:"destroy" subcommands-optional:"true" description:"Remove a snapshot" webicon:"fas fa-trash" invwebforce:"true"`
+	Help    helpCmd                   `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+// snapshot names end up in docker image names, AMI tags and GCP resource names and labels
+var clusterSnapshotNameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]{0,23}$`)
+
+type clusterSnapshotCreateCmd struct {
+	ClusterName  TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
+	SnapshotName string          `short:"s" long:"snapshot" description:"Snapshot name; lowercase letters, digits and dashes, up to 24 characters" webrequired:"true"`
+	Help         helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotCreateCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running cluster.snapshot.create")
+	if !clusterSnapshotNameRegex.MatchString(c.SnapshotName) {
+		return errors.New("snapshot name must start with a lowercase letter and contain only lowercase letters, digits and dashes, up to 24 characters")
+	}
+	snaps, err := b.SnapshotList()
+	if err != nil {
+		return err
+	}
+	for _, snap := range snaps {
+		if snap.Name == c.SnapshotName {
+			return fmt.Errorf("snapshot %s already exists", c.SnapshotName)
+		}
+	}
+	clusterList, err := b.ClusterList()
+	if err != nil {
+		return err
+	}
+	if !inslice.HasString(clusterList, string(c.ClusterName)) {
+		return fmt.Errorf("cluster does not exist: %s", string(c.ClusterName))
+	}
+	nodes, err := b.NodeListInCluster(string(c.ClusterName))
+	if err != nil {
+		return err
+	}
+	sort.Ints(nodes)
+	// restore deploys nodes one by one, and node numbers are always allocated after the highest existing node
+	for i, node := range nodes {
+		if node != i+1 {
+			return fmt.Errorf("node numbers must be contiguous from 1 to be restorable; cluster %s has nodes %s", string(c.ClusterName), intSliceToString(nodes, ","))
+		}
+	}
+	inv, err := b.Inventory("", []int{InventoryItemClusters})
+	if err != nil {
+		return err
+	}
+	versions := make(map[int]backendVersion)
+	for _, item := range inv.Clusters {
+		if item.ClusterName != string(c.ClusterName) {
+			continue
+		}
+		node, err := strconv.Atoi(item.NodeNo)
+		if err != nil {
+			continue
+		}
+		versions[node] = backendVersion{
+			distroName:       item.Distribution,
+			distroVersion:    item.OSVersion,
+			aerospikeVersion: item.AerospikeVersion,
+			isArm:            strings.Contains(item.Arch, "arm") || strings.Contains(item.Arch, "aarch"),
+		}
+	}
+	log.Printf("Snapshotting %d nodes; for a crash-consistent data snapshot, stop aerospike first", len(nodes))
+	err = b.SnapshotCreate(c.SnapshotName, string(c.ClusterName), nodes, versions)
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+type clusterSnapshotRestoreCmd struct {
+	SnapshotName       string                          `short:"s" long:"snapshot" description:"Snapshot name" webrequired:"true"`
+	ClusterName        TypeClusterName                 `short:"n" long:"name" description:"Cluster name to create; default: the name of the cluster the snapshot was taken from"`
+	AutoStartAerospike TypeYesNo                       `long:"start" description:"Auto-start aerospike after restoring the cluster (y/n)" default:"y" webchoice:"y,n"`
+	NoFixMesh          bool                            `long:"no-fix-mesh" description:"By default, mesh seeds and access addresses are updated to the new node IPs; set to leave aerospike.conf untouched"`
+	Owner              string                          `long:"owner" description:"AWS/GCP only: create owner tag with this value" simplemode:"false"`
+	Aws                clusterSnapshotRestoreCmdAws    `no-flag:"true"`
+	Gcp                clusterSnapshotRestoreCmdGcp    `no-flag:"true"`
+	Docker             clusterSnapshotRestoreCmdDocker `no-flag:"true"`
+	Help               helpCmd                         `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type clusterSnapshotRestoreCmdAws struct {
+	InstanceType    guiInstanceType `short:"I" long:"instance-type" description:"instance type to use" default:"" webrequired:"true" webchoice:"method::List"`
+	SecurityGroupID string          `short:"S" long:"secgroup-id" description:"security group IDs to use, comma-separated; default: empty: create and auto-manage" simplemode:"false"`
+	SubnetID        string          `short:"U" long:"subnet-id" description:"subnet-id, availability-zone name, or empty; default: empty: first found in default VPC" simplemode:"false"`
+	Tags            []string        `long:"tags" description:"apply custom tags to instances; format: key=value; this parameter can be specified multiple times"`
+	NamePrefix      []string        `long:"secgroup-name" description:"Name prefix to use for the security groups, can be specified multiple times" default:"AeroLab" simplemode:"false"`
+	SpotInstance    bool            `long:"aws-spot-instance" description:"set to request a spot instance in place of on-demand"`
+	Expires         time.Duration   `long:"aws-expire" description:"length of life of nodes prior to expiry; smh - seconds, minutes, hours, ex 20h 30m; 0: no expiry" default:"30h"`
+}
+
+type clusterSnapshotRestoreCmdGcp struct {
+	InstanceType guiInstanceType `long:"instance" description:"instance type to use" default:"" webrequired:"true" webchoice:"method::List"`
+	Zone         guiZone         `long:"zone" description:"zone name to deploy to; default: the zone the snapshot was taken in" webchoice:"method::List"`
+	Network      string          `long:"gcp-network" description:"GCP network name to use" default:"default" simplemode:"false"`
+	Subnet       string          `long:"gcp-subnet" description:"GCP subnet name; default: auto-select a subnet in the zone's region of the chosen network" simplemode:"false"`
+	Tags         []string        `long:"tag" description:"apply custom tags to instances; this parameter can be specified multiple times"`
+	Labels       []string        `long:"label" description:"apply custom labels to instances; format: key=value; this parameter can be specified multiple times"`
+	NamePrefix   []string        `long:"firewall" description:"Name to use for the firewall, can be specified multiple times" default:"aerolab-managed-external" simplemode:"false"`
+	SpotInstance bool            `long:"gcp-spot-instance" description:"set to request a spot instance in place of on-demand"`
+	Expires      time.Duration   `long:"gcp-expire" description:"length of life of nodes prior to expiry; smh - seconds, minutes, hours, ex 20h 30m; 0: no expiry" default:"30h"`
+}
+
+type clusterSnapshotRestoreCmdDocker struct {
+	CpuLimit    string   `short:"l" long:"cpu-limit" description:"Impose CPU speed limit. Values acceptable could be '1' or '2' or '0.5' etc." default:"" simplemode:"false"`
+	RamLimit    string   `short:"t" long:"ram-limit" description:"Limit RAM available to each node, e.g. 500m, or 1g." default:"" simplemode:"false"`
+	NoFILELimit int      `long:"nofile-limit" description:"nofile ulimit of the nodes; set to -1 to disable the parameter" default:"20000" simplemode:"false"`
+	Privileged  bool     `short:"B" long:"privileged" description:"Docker only: run container in privileged mode"`
+	NetworkName string   `long:"network" description:"specify a network name to use for non-default docker network; for more info see: aerolab config docker help" default:"" simplemode:"false"`
+	Labels      []string `long:"docker-label" description:"apply custom labels to instances; format: key=value; this parameter can be specified multiple times"`
+}
+
+func init() {
+	addBackendSwitch("cluster.snapshot.restore", "aws", &a.opts.Cluster.Snapshot.Restore.Aws)
+	addBackendSwitch("cluster.snapshot.restore", "docker", &a.opts.Cluster.Snapshot.Restore.Docker)
+	addBackendSwitch("cluster.snapshot.restore", "gcp", &a.opts.Cluster.Snapshot.Restore.Gcp)
+}
+
+func (c *clusterSnapshotRestoreCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running cluster.snapshot.restore")
+	snaps, err := b.SnapshotList()
+	if err != nil {
+		return err
+	}
+	nodes := []backendSnapshot{}
+	for _, snap := range snaps {
+		if snap.Name == c.SnapshotName {
+			nodes = append(nodes, snap)
+		}
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("snapshot %s not found", c.SnapshotName)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Node < nodes[j].Node
+	})
+	for i, node := range nodes {
+		if node.Node != i+1 {
+			return fmt.Errorf("snapshot %s is incomplete: node %d is missing", c.SnapshotName, i+1)
+		}
+	}
+	if c.ClusterName == "" {
+		c.ClusterName = TypeClusterName(nodes[0].ClusterName)
+	}
+	clusterList, err := b.ClusterList()
+	if err != nil {
+		return err
+	}
+	if inslice.HasString(clusterList, string(c.ClusterName)) {
+		return fmt.Errorf("cluster %s already exists", string(c.ClusterName))
+	}
+	extra := &backendExtra{
+		cpuLimit:        c.Docker.CpuLimit,
+		ramLimit:        c.Docker.RamLimit,
+		privileged:      c.Docker.Privileged,
+		network:         c.Docker.NetworkName,
+		labels:          c.Docker.Labels,
+		dockerHostname:  true,
+		instanceType:    c.Aws.InstanceType.String(),
+		securityGroupID: c.Aws.SecurityGroupID,
+		subnetID:        c.Aws.SubnetID,
+		tags:            c.Aws.Tags,
+		spotInstance:    c.Aws.SpotInstance,
+	}
+	if c.Docker.NoFILELimit > 0 {
+		extra.limitNoFile = c.Docker.NoFILELimit
+	}
+	switch a.opts.Config.Backend.Type {
+	case "aws":
+		extra.firewallNamePrefix = c.Aws.NamePrefix
+		if c.Owner != "" {
+			extra.tags = append(extra.tags, "owner="+c.Owner)
+		}
+		if c.Aws.Expires != 0 {
+			extra.expiresTime = time.Now().Add(c.Aws.Expires)
+		}
+	case "gcp":
+		extra = &backendExtra{
+			instanceType:       c.Gcp.InstanceType.String(),
+			zone:               c.Gcp.Zone.String(),
+			gcpNetwork:         c.Gcp.Network,
+			gcpSubnet:          c.Gcp.Subnet,
+			tags:               c.Gcp.Tags,
+			labels:             c.Gcp.Labels,
+			firewallNamePrefix: c.Gcp.NamePrefix,
+			spotInstance:       c.Gcp.SpotInstance,
+		}
+		if c.Owner != "" {
+			extra.labels = append(extra.labels, "owner="+c.Owner)
+		}
+		if extra.zone == "" {
+			extra.zone = nodes[0].Zone
+		}
+		if c.Gcp.Expires != 0 {
+			extra.expiresTime = time.Now().Add(c.Gcp.Expires)
+		}
+	}
+	// deploying one node at a time keeps the node numbering of the snapshot
+	for _, node := range nodes {
+		log.Printf("Restoring node %d", node.Node)
+		extra.snapshot = node.ImageId
+		err = b.DeployCluster(node.Version, string(c.ClusterName), 1, extra)
+		if err != nil {
+			return fmt.Errorf("node %d: %s", node.Node, err)
+		}
+	}
+	err = b.ClusterStart(string(c.ClusterName), nil)
+	if err != nil {
+		return err
+	}
+	if !c.NoFixMesh {
+		isMesh, err := c.isMesh()
+		if err != nil {
+			log.Printf("WARNING: could not read aerospike.conf, not fixing mesh configuration: %s", err)
+		} else if isMesh {
+			a.opts.Conf.FixMesh.ClusterName = c.ClusterName
+			a.opts.Conf.FixMesh.Nodes = ""
+			err = a.opts.Conf.FixMesh.Execute(nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if inslice.HasString([]string{"YES", "Y"}, strings.ToUpper(c.AutoStartAerospike.String())) {
+		a.opts.Aerospike.Start.ClusterName = c.ClusterName
+		a.opts.Aerospike.Start.Nodes = ""
+		err = a.opts.Aerospike.Start.Execute(nil)
+		if err != nil {
+			return err
+		}
+	}
+	log.Print("Done")
+	return nil
+}
+
+// isMesh checks the heartbeat mode in aerospike.conf of the first node
+func (c *clusterSnapshotRestoreCmd) isMesh() (bool, error) {
+	out, err := b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/etc/aerospike/aerospike.conf"}}, []int{1})
+	if err != nil {
+		return false, err
+	}
+	conf, err := aeroconf.Parse(strings.NewReader(string(out[0])))
+	if err != nil {
+		return false, err
+	}
+	if conf.Type("network") != aeroconf.ValueStanza || conf.Stanza("network").Type("heartbeat") != aeroconf.ValueStanza {
+		return false, nil
+	}
+	vals, err := conf.Stanza("network").Stanza("heartbeat").GetValues("mode")
+	if err != nil || len(vals) == 0 || vals[0] == nil {
+		return false, err
+	}
+	return *vals[0] == "mesh", nil
+}
+
+type clusterSnapshotListCmd struct {
+	Json       bool    `short:"j" long:"json" description:"Provide output in json format"`
+	JsonPretty bool    `short:"p" long:"pretty" description:"Provide json output with line-feeds and indentations"`
+	Help       helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotListCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	snaps, err := b.SnapshotList()
+	if err != nil {
+		return err
+	}
+	type snapshotJson struct {
+		Name             string
+		ClusterName      string
+		Node             int
+		AerospikeVersion string
+		Distribution     string
+		OSVersion        string
+		Arch             string
+		Zone             string `json:",omitempty"`
+		ImageId          string
+		CreationTime     time.Time
+	}
+	list := []snapshotJson{}
+	for _, snap := range snaps {
+		arch := "amd64"
+		if snap.Version.isArm {
+			arch = "arm64"
+		}
+		list = append(list, snapshotJson{
+			Name:             snap.Name,
+			ClusterName:      snap.ClusterName,
+			Node:             snap.Node,
+			AerospikeVersion: snap.Version.aerospikeVersion,
+			Distribution:     snap.Version.distroName,
+			OSVersion:        snap.Version.distroVersion,
+			Arch:             arch,
+			Zone:             snap.Zone,
+			ImageId:          snap.ImageId,
+			CreationTime:     snap.CreationTime,
+		})
+	}
+	if c.Json || c.JsonPretty {
+		enc := json.NewEncoder(os.Stdout)
+		if c.JsonPretty {
+			enc.SetIndent("", "  ")
+		}
+		return enc.Encode(list)
+	}
+	tb := table.NewWriter()
+	tb.SetStyle(table.StyleLight)
+	tb.AppendHeader(table.Row{"Snapshot", "Cluster", "Node", "AsdVer", "Distro", "DistroVer", "Arch", "Zone", "Created", "ImageId"})
+	for _, snap := range list {
+		created := ""
+		if !snap.CreationTime.IsZero() {
+			created = snap.CreationTime.Local().Format(time.RFC3339)
+		}
+		tb.AppendRow(table.Row{snap.Name, snap.ClusterName, snap.Node, snap.AerospikeVersion, snap.Distribution, snap.OSVersion, snap.Arch, snap.Zone, created, snap.ImageId})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
+
+type clusterSnapshotDestroyCmd struct {
+	SnapshotName string  `short:"s" long:"snapshot" description:"Snapshot name" webrequired:"true"`
+	Force        bool    `short:"f" long:"force" description:"do not ask for confirmation" webdisable:"true" webset:"true"`
+	Help         helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotDestroyCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running cluster.snapshot.destroy")
+	if !c.Force {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			fmt.Printf("Are you sure you want to destroy snapshot %s (y/n)? ", c.SnapshotName)
+			yesno, err := reader.ReadString('\n')
+			if err != nil {
+				logExit(err)
+			}
+			yesno = strings.ToLower(strings.TrimSpace(yesno))
+			if yesno == "y" || yesno == "yes" {
+				break
+			} else if yesno == "n" || yesno == "no" {
+				fmt.Println("Aborting")
+				return nil
+			}
+		}
+	}
+	err := b.SnapshotDestroy(c.SnapshotName)
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
@@ -10,7 +10,11 @@ type confCmd struct {
 	FixMesh         confFixMeshCmd         `command:"fix-mesh" subcommands-optional:"true" description:"Fix mesh configuration in the cluster" webicon:"fas fa-screwdriver"`
 	RackID          confRackIdCmd          `command:"rackid" subcommands-optional:"true" description:"Change/add rack-id to namespaces in the existing cluster nodes" webicon:"fas fa-id-badge"`
 	NamespaceMemory confNamespaceMemoryCmd `command:"namespace-memory" subcommands-optional:"true" description:"Adjust memory for a namespace using total percentages" webicon:"fas fa-sd-card"`
+	Migrate         confMigrateCmd         `command:"migrate" subcommands-optional:"true" description:"Migrate aerospike.conf between major aerospike versions, reporting the semantic changes" webicon:"fas fa-right-left"`
 	Adjust          confAdjustCmd          `command:"adjust" subcommands-optional:"true" description:"Adjust running Aerospike configuration parameters" webicon:"fas fa-sliders"`
+	Diff            confDiffCmd            `command:"diff" subcommands-optional:"true" description:"Compare aerospike.conf between nodes, clusters or a reference file, or against the runtime configuration" webicon:"fas fa-code-compare"`
+	Validate        confValidateCmd        `command:"validate" subcommands-optional:"true" description:"Validate aerospike.conf against the configuration schema of the aerospike version" webicon:"fas fa-list-check"`
+	Ldap            confLdapCmd            `command:"ldap" subcommands-optional:"true" description:"Configure the cluster to authenticate users against an LDAP server created with 'client create ldap'" webicon:"fas fa-address-book"`
 	Help            helpCmd                `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
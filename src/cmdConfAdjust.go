@@ -7,9 +7,12 @@ import (
 	"log"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
 	aeroconf "github.com/rglonek/aerospike-config-file-parser"
 )
 
@@ -20,6 +23,11 @@ type confAdjustCmd struct {
 	Command     string          `short:"c" long:"command" description:"command to run, get|set|create|delete" webchoice:"create,set,delete,get"`
 	Key         string          `short:"k" long:"key" description:"the key to work on; eg 'namespace bar.storage-engine device.write-block-size'" webrequired:"true"`
 	Values      []string        `short:"v" long:"value" description:"value to set a key to when using set option; can be specified multiple times"`
+	Live        bool            `long:"live" description:"also apply 'set' changes to the running nodes using set-config; changes which cannot be applied dynamically are reported as requiring a restart"`
+	Rolling     bool            `long:"rolling-restart" description:"restart the nodes on which the change was not applied live, one at a time, waiting for the cluster to be stable in between"`
+	Namespace   string          `long:"namespace" description:"with --rolling-restart, namespace to check stability against" default:"test"`
+	Timeout     int             `long:"timeout" description:"with --rolling-restart, seconds to wait for each node to rejoin the cluster and migrations to finish" default:"600"`
+	NoValidate  bool            `long:"no-validate" description:"do not validate the adjusted configuration against the configuration schema of the aerospike version before copying it to the nodes"`
 	parallelThreadsCmd
 }
 
@@ -54,6 +62,9 @@ func (c *confAdjustCmd) Execute(args []string) error {
 			c.help("Get command does not accept value parameters")
 			return nil
 		}
+		if c.Live || c.Rolling {
+			return errors.New("--live and --rolling-restart cannot be used with the get command")
+		}
 	case "delete":
 		if len(args) != 2 {
 			c.help("Invalid argument count for delete command")
@@ -80,6 +91,12 @@ func (c *confAdjustCmd) Execute(args []string) error {
 		return err
 	}
 
+	authArgs := []string{}
+	if c.Live {
+		authArgs = securityAuthArgs(c.ClusterName.String(), nodes, nil)
+	}
+	restartLock := new(sync.Mutex)
+	restartNodes := []int{}
 	returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
 		out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"cat", c.Path}}, []int{node})
 		if err != nil {
@@ -97,14 +114,7 @@ func (c *confAdjustCmd) Execute(args []string) error {
 		}
 
 		sa := s
-		path = strings.ReplaceAll(path, "..", "±§±§±")
-		pathn := strings.Split(path, ".")
-		for i := range pathn {
-			pathn[i] = strings.ReplaceAll(pathn[i], "±§±§±", ".")
-		}
-		if pathn[0] == "" && len(pathn) > 1 {
-			pathn = pathn[1:]
-		}
+		pathn := confAdjustSplitPath(path)
 		switch command {
 		case "get":
 			prefix := ""
@@ -182,12 +192,47 @@ func (c *confAdjustCmd) Execute(args []string) error {
 				return err
 			}
 			contents := buf.Bytes()
+			// without --live, there is nothing to copy or restart if the change is already in place; the original is rewritten the same way so that formatting differences do not count
+			var orig bytes.Buffer
+			if origConf, err := aeroconf.Parse(bytes.NewReader(out[0])); !c.Live && err == nil && origConf.Write(&orig, "", "    ", true) == nil && bytes.Equal(orig.Bytes(), contents) {
+				log.Printf("Node %d: configuration already up to date", node)
+				return nil
+			}
+			if !c.NoValidate {
+				version := ""
+				out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"cat", "/opt/aerolab.aerospike.version"}}, []int{node})
+				if err != nil {
+					log.Printf("WARNING: node %d: could not read the aerospike version, validating the configuration structure only: %s", node, err)
+				} else {
+					version = strings.TrimSpace(string(out[0]))
+				}
+				err = confValidateAuto(fmt.Sprintf("%s-%d:%s", c.ClusterName, node, c.Path), contents, version)
+				if err != nil {
+					return err
+				}
+			}
 			fileContents = bytes.NewReader(contents)
 			// edit end
 			err = b.CopyFilesToClusterReader(c.ClusterName.String(), []fileListReader{{filePath: c.Path, fileContents: fileContents, fileSize: len(contents)}}, []int{node})
 			if err != nil {
 				return err
 			}
+			if c.Live || c.Rolling {
+				err = errors.New("not applied live")
+				if c.Live {
+					err = c.applyLive(node, command, pathn, setValues, authArgs)
+				}
+				if err != nil {
+					if c.Live {
+						log.Printf("Node %d: restart required: %s", node, err)
+					}
+					restartLock.Lock()
+					restartNodes = append(restartNodes, node)
+					restartLock.Unlock()
+				} else {
+					log.Printf("Node %d: applied live", node)
+				}
+			}
 		}
 		return nil
 	})
@@ -201,10 +246,112 @@ func (c *confAdjustCmd) Execute(args []string) error {
 	if isError {
 		return errors.New("some nodes returned errors")
 	}
+	if len(restartNodes) > 0 {
+		sort.Ints(restartNodes)
+		if !c.Rolling {
+			log.Printf("Done, restart nodes %s for the change to take effect, or use --rolling-restart", intSliceToString(restartNodes, ","))
+			return nil
+		}
+		err = aerospikeRollingRestart(c.ClusterName, restartNodes, c.Namespace, c.Timeout, c.ParallelThreads)
+		if err != nil {
+			return fmt.Errorf("rolling restart aborted: %s", err)
+		}
+	}
 	log.Println("Done")
 	return nil
 }
 
+// applyLive sends the set-config command matching a 'set' to a node; other commands, and keys which cannot be changed dynamically, require a restart
+func (c *confAdjustCmd) applyLive(node int, command string, pathn []string, values []string, authArgs []string) error {
+	if command != "set" {
+		return fmt.Errorf("the %s command is not applied dynamically", command)
+	}
+	if len(values) != 1 || values[0] == "" {
+		return errors.New("only keys with a single value can be set dynamically")
+	}
+	req, err := confSetConfigCommand(pathn, values[0])
+	if err != nil {
+		return err
+	}
+	resp, err := confAsinfo(c.ClusterName.String(), node, req, authArgs)
+	if err != nil {
+		return err
+	}
+	if resp != "ok" {
+		return fmt.Errorf("%s returned '%s': the key is static, or the value is invalid", req, resp)
+	}
+	return nil
+}
+
+// confSetConfigCommand derives the set-config info command from a conf adjust path, ex: 'namespace bar.storage-engine device.defrag-lwm-pct' => 'set-config:context=namespace;id=bar;defrag-lwm-pct=VALUE'
+func confSetConfigCommand(pathn []string, value string) (string, error) {
+	unsupported := fmt.Errorf("no set-config equivalent for %s", confDiffJoinPath(pathn))
+	if len(pathn) < 2 {
+		return "", unsupported
+	}
+	stanza, id, named := strings.Cut(pathn[0], " ")
+	middle := pathn[1 : len(pathn)-1]
+	keyPath := []string{}
+	context := ""
+	switch stanza {
+	case "service", "network", "security":
+		if named {
+			return "", unsupported
+		}
+		context = "context=" + stanza
+		for _, item := range middle {
+			if strings.Contains(item, " ") {
+				return "", unsupported
+			}
+			keyPath = append(keyPath, item)
+		}
+	case "namespace":
+		context = "context=namespace;id=" + id
+		for _, item := range middle {
+			name, sub, named := strings.Cut(item, " ")
+			switch {
+			case named && name == "set":
+				context += ";set=" + sub
+			case named && inslice.HasString([]string{"storage-engine", "index-type", "sindex-type"}, name):
+				// storage and index parameters are set directly in the namespace context
+			case named:
+				return "", unsupported
+			default:
+				keyPath = append(keyPath, item)
+			}
+		}
+	case "xdr":
+		if named {
+			return "", unsupported
+		}
+		context = "context=xdr"
+		for _, item := range middle {
+			name, sub, named := strings.Cut(item, " ")
+			if !named || (name != "dc" && name != "namespace") {
+				return "", unsupported
+			}
+			context += ";" + name + "=" + sub
+		}
+	default:
+		return "", unsupported
+	}
+	keyPath = append(keyPath, pathn[len(pathn)-1])
+	return "set-config:" + context + ";" + strings.Join(keyPath, ".") + "=" + value, nil
+}
+
+// split a conf adjust path (path.to.item) into stanza/key names; a double-dot is a literal dot
+func confAdjustSplitPath(path string) []string {
+	path = strings.ReplaceAll(path, "..", "±§±§±")
+	pathn := strings.Split(path, ".")
+	for i := range pathn {
+		pathn[i] = strings.ReplaceAll(pathn[i], "±§±§±", ".")
+	}
+	if pathn[0] == "" && len(pathn) > 1 {
+		pathn = pathn[1:]
+	}
+	return pathn
+}
+
 func (c *confAdjustCmd) help(debugtext string) {
 	fmt.Println("ERR: " + debugtext)
 	comm := path.Base(os.Args[0]) + " conf adjust"
@@ -213,7 +360,15 @@ func (c *confAdjustCmd) help(debugtext string) {
 	-n, --name=  Cluster name (default: mydc)
 	-l, --nodes= Nodes list, comma separated. Empty=ALL
 	-p, --path=  Path to aerospike configuration file (default: /etc/aerospike/aerospike.conf)
-	 --threads=  Number of parallel threads to run on (default: 50)`)
+	 --threads=  Number of parallel threads to run on (default: 50)
+	 --live      Also apply 'set' changes to the running nodes using set-config
+	 --rolling-restart
+	             Restart the nodes on which the change was not applied live, one at a time
+	 --namespace=
+	             With --rolling-restart, namespace to check stability against (default: test)
+	 --timeout=  With --rolling-restart, seconds to wait for the cluster to be stable (default: 600)
+	 --no-validate
+	             Do not validate the adjusted configuration against the version schema`)
 	fmt.Println("\n" + `COMMANDS:
 	get    - get configuration/stanza and print to stdout
 	delete - delete configuration/stanza
@@ -230,5 +385,6 @@ func (c *confAdjustCmd) help(debugtext string) {
 	%s -n mydc set service.proto-fd-max 3000
 	%s -n mydc get
 	%s -n mydc get network.service
-	`+"\n", comm, comm, comm, comm, comm, comm, comm)
+	%s -n mydc --live set "namespace test.nsup-period" 120
+	`+"\n", comm, comm, comm, comm, comm, comm, comm, comm)
 }
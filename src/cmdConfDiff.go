@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aerospike/aerolab/diff"
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+	"github.com/jedib0t/go-pretty/v6/table"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+)
+
+type confDiffCmd struct {
+	ClusterName      TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
+	Nodes            TypeNodes       `short:"l" long:"nodes" description:"Nodes list, comma separated. Empty=ALL" default:""`
+	Path             string          `short:"p" long:"path" description:"Path to aerospike.conf on the remote nodes" default:"/etc/aerospike/aerospike.conf"`
+	ReferenceFile    string          `short:"f" long:"reference-file" description:"compare each node against this local aerospike.conf file"`
+	ReferenceCluster TypeClusterName `short:"r" long:"reference-cluster" description:"compare each node against a node of this cluster"`
+	ReferenceNode    int             `long:"reference-node" description:"node to compare against, in the reference cluster if set, otherwise in the cluster itself; 0=first node" default:"0"`
+	Runtime          bool            `short:"R" long:"runtime" description:"compare the on-disk configuration of each node against its runtime configuration from 'asinfo get-config', to find dynamic changes which were never persisted"`
+	Ignore           []string        `short:"i" long:"ignore" description:"ignore keys with a path matching this pattern, ex: 'network.*' or '*.node-id'; can be specified multiple times"`
+	Text             bool            `long:"text" description:"print a unified diff of the normalised configuration files instead of a structured report"`
+	Json             bool            `short:"j" long:"json" description:"Provide output in json format"`
+	parallelThreadsLongCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// confDiffItem is a single difference between a node's configuration and the reference
+type confDiffItem struct {
+	Node      int
+	Change    string // added, removed or changed
+	Path      string
+	Reference string
+	Value     string
+}
+
+func (c *confDiffCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running conf.diff")
+	if c.ReferenceFile != "" && c.ReferenceCluster != "" {
+		return errors.New("only one of --reference-file and --reference-cluster can be specified")
+	}
+	if c.Runtime && (c.ReferenceFile != "" || c.ReferenceCluster != "" || c.Text) {
+		return errors.New("--runtime compares each node against itself and cannot be combined with a reference or --text")
+	}
+	for _, pattern := range c.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %s: %s", pattern, err)
+		}
+	}
+	err := c.Nodes.ExpandNodes(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	nodes, err := c.Nodes.Translate(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	sort.Ints(nodes)
+	if len(nodes) == 0 {
+		return errors.New("no nodes found in cluster")
+	}
+	confs, err := confReadNodes(c.ClusterName.String(), nodes, c.Path, c.ParallelThreads)
+	if err != nil {
+		return err
+	}
+
+	var items []confDiffItem
+	if c.Runtime {
+		items, err = c.runtimeDiff(nodes, confs)
+		if err != nil {
+			return err
+		}
+		return c.print(items, "Disk", "Runtime")
+	}
+
+	// find the reference configuration
+	var refConf []byte
+	refName := ""
+	switch {
+	case c.ReferenceFile != "":
+		refConf, err = os.ReadFile(c.ReferenceFile)
+		if err != nil {
+			return err
+		}
+		refName = c.ReferenceFile
+	case c.ReferenceCluster != "":
+		clusterList, err := b.ClusterList()
+		if err != nil {
+			return err
+		}
+		if !inslice.HasString(clusterList, c.ReferenceCluster.String()) {
+			return fmt.Errorf("reference cluster does not exist: %s", c.ReferenceCluster)
+		}
+		refNode := c.ReferenceNode
+		if refNode == 0 {
+			refNodes, err := b.NodeListInCluster(c.ReferenceCluster.String())
+			if err != nil {
+				return err
+			}
+			if len(refNodes) == 0 {
+				return fmt.Errorf("reference cluster %s has no nodes", c.ReferenceCluster)
+			}
+			sort.Ints(refNodes)
+			refNode = refNodes[0]
+		}
+		refConfs, err := confReadNodes(c.ReferenceCluster.String(), []int{refNode}, c.Path, 1)
+		if err != nil {
+			return err
+		}
+		refConf = refConfs[refNode]
+		refName = fmt.Sprintf("%s:%d", c.ReferenceCluster, refNode)
+	default:
+		refNode := c.ReferenceNode
+		if refNode == 0 {
+			refNode = nodes[0]
+		}
+		if _, ok := confs[refNode]; !ok {
+			refConfs, err := confReadNodes(c.ClusterName.String(), []int{refNode}, c.Path, 1)
+			if err != nil {
+				return err
+			}
+			confs[refNode] = refConfs[refNode]
+		}
+		refConf = confs[refNode]
+		refName = fmt.Sprintf("%s:%d", c.ClusterName, refNode)
+		newNodes := []int{}
+		for _, node := range nodes {
+			if node != refNode {
+				newNodes = append(newNodes, node)
+			}
+		}
+		nodes = newNodes
+		if len(nodes) == 0 {
+			return errors.New("need at least two nodes, or a reference file or cluster, to compare")
+		}
+	}
+	log.Printf("Comparing against %s", refName)
+	ref, err := aeroconf.Parse(bytes.NewReader(refConf))
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %s", refName, err)
+	}
+
+	if c.Text {
+		refText, err := confNormalise(ref)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			cc, err := aeroconf.Parse(bytes.NewReader(confs[node]))
+			if err != nil {
+				return fmt.Errorf("could not parse configuration of node %d: %s", node, err)
+			}
+			nodeText, err := confNormalise(cc)
+			if err != nil {
+				return err
+			}
+			out := diff.Diff(refName, refText, fmt.Sprintf("%s:%d", c.ClusterName, node), nodeText)
+			if out == nil {
+				fmt.Printf("Node %d: no differences\n", node)
+				continue
+			}
+			fmt.Print(string(out))
+		}
+		log.Print("Done")
+		return nil
+	}
+
+	for _, node := range nodes {
+		cc, err := aeroconf.Parse(bytes.NewReader(confs[node]))
+		if err != nil {
+			return fmt.Errorf("could not parse configuration of node %d: %s", node, err)
+		}
+		items = append(items, c.filter(confDiffStanzas(node, nil, ref, cc))...)
+	}
+	return c.print(items, "Reference", "Value")
+}
+
+// filter removes the items matching the ignore patterns
+func (c *confDiffCmd) filter(items []confDiffItem) []confDiffItem {
+	if len(c.Ignore) == 0 {
+		return items
+	}
+	ret := []confDiffItem{}
+	for _, item := range items {
+		ignore := false
+		for _, pattern := range c.Ignore {
+			if match, _ := path.Match(pattern, item.Path); match {
+				ignore = true
+				break
+			}
+		}
+		if !ignore {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+func (c *confDiffCmd) print(items []confDiffItem, refHeader string, valueHeader string) error {
+	if c.Json {
+		if items == nil {
+			items = []confDiffItem{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(items)
+	}
+	if len(items) == 0 {
+		log.Print("Done, no differences found")
+		return nil
+	}
+	tb := table.NewWriter()
+	tb.SetStyle(table.StyleLight)
+	tb.AppendHeader(table.Row{"Node", "Change", "Path", refHeader, valueHeader})
+	for _, item := range items {
+		tb.AppendRow(table.Row{item.Node, item.Change, item.Path, item.Reference, item.Value})
+	}
+	fmt.Println(tb.Render())
+	log.Printf("Done, found %d differences", len(items))
+	return nil
+}
+
+// runtimeDiff compares the on-disk configuration of each node against the values reported by get-config
+func (c *confDiffCmd) runtimeDiff(nodes []int, confs map[int][]byte) ([]confDiffItem, error) {
+	authArgs := securityAuthArgs(c.ClusterName.String(), nodes, nil)
+	lock := new(sync.Mutex)
+	items := []confDiffItem{}
+	returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+		cc, err := aeroconf.Parse(bytes.NewReader(confs[node]))
+		if err != nil {
+			return fmt.Errorf("config parse failure: %s", err)
+		}
+		nodeItems := []confDiffItem{}
+		for context, keys := range confRuntimeContexts(cc) {
+			runtime, err := confGetConfig(c.ClusterName.String(), node, context, authArgs)
+			if err != nil {
+				log.Printf("WARN: node %d: %s", node, err)
+				continue
+			}
+			for key, disk := range keys {
+				value, ok := runtime[key]
+				if !ok || confRuntimeEqual(disk.Value, value) {
+					continue
+				}
+				nodeItems = append(nodeItems, confDiffItem{Node: node, Change: "changed", Path: disk.Path, Reference: disk.Value, Value: value})
+			}
+		}
+		lock.Lock()
+		items = append(items, c.filter(nodeItems)...)
+		lock.Unlock()
+		return nil
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return nil, errors.New("some nodes returned errors")
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Node != items[j].Node {
+			return items[i].Node < items[j].Node
+		}
+		return items[i].Path < items[j].Path
+	})
+	return items, nil
+}
+
+// confReadNodes reads the aerospike.conf file from each of the given nodes
+func confReadNodes(clusterName string, nodes []int, confPath string, threads int) (map[int][]byte, error) {
+	lock := new(sync.Mutex)
+	confs := make(map[int][]byte)
+	returns := parallelize.MapLimit(nodes, threads, func(node int) error {
+		out, err := b.RunCommands(clusterName, [][]string{{"cat", confPath}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("cluster=%s node=%v RunCommands error=%s", clusterName, node, err)
+		}
+		lock.Lock()
+		confs[node] = out[0]
+		lock.Unlock()
+		return nil
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return nil, errors.New("some nodes returned errors")
+	}
+	return confs, nil
+}
+
+// confNormalise renders a parsed configuration with sorted keys and consistent indentation
+func confNormalise(cc aeroconf.Stanza) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := cc.Write(buf, "", "    ", true)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// confDiffStanzas recursively compares two stanzas; a stanza missing on one side is reported once, not per key
+func confDiffStanzas(node int, stanzaPath []string, ref aeroconf.Stanza, cmp aeroconf.Stanza) []confDiffItem {
+	keys := ref.ListKeys()
+	for _, key := range cmp.ListKeys() {
+		if !inslice.HasString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	items := []confDiffItem{}
+	for _, key := range keys {
+		itemPath := append(append([]string{}, stanzaPath...), key)
+		refType := ref.Type(key)
+		cmpType := cmp.Type(key)
+		switch {
+		case refType == aeroconf.ValueNil:
+			items = append(items, confDiffItem{Node: node, Change: "added", Path: confDiffJoinPath(itemPath), Value: confDiffValue(cmp, key)})
+		case cmpType == aeroconf.ValueNil:
+			items = append(items, confDiffItem{Node: node, Change: "removed", Path: confDiffJoinPath(itemPath), Reference: confDiffValue(ref, key)})
+		case refType == aeroconf.ValueStanza && cmpType == aeroconf.ValueStanza:
+			items = append(items, confDiffStanzas(node, itemPath, ref.Stanza(key), cmp.Stanza(key))...)
+		default:
+			refValue := confDiffValue(ref, key)
+			cmpValue := confDiffValue(cmp, key)
+			if refValue != cmpValue {
+				items = append(items, confDiffItem{Node: node, Change: "changed", Path: confDiffJoinPath(itemPath), Reference: refValue, Value: cmpValue})
+			}
+		}
+	}
+	return items
+}
+
+// confDiffValue renders a key's values, or {...} for a stanza
+func confDiffValue(s aeroconf.Stanza, key string) string {
+	if s.Type(key) == aeroconf.ValueStanza {
+		return "{...}"
+	}
+	vals, err := s.GetValues(key)
+	if err != nil {
+		return ""
+	}
+	values := []string{}
+	for _, val := range vals {
+		if val == nil {
+			values = append(values, "")
+			continue
+		}
+		values = append(values, *val)
+	}
+	return strings.Join(values, ", ")
+}
+
+// confDiffJoinPath joins stanza/key names into a 'conf adjust' path; a literal dot becomes a double-dot
+func confDiffJoinPath(items []string) string {
+	escaped := []string{}
+	for _, item := range items {
+		escaped = append(escaped, strings.ReplaceAll(item, ".", ".."))
+	}
+	return strings.Join(escaped, ".")
+}
+
+// confRuntimeValue is a single-valued aerospike.conf key, with its path in the file
+type confRuntimeValue struct {
+	Path  string
+	Value string
+}
+
+// confRuntimeContexts maps the single-valued keys of aerospike.conf to get-config contexts (ex: 'context=namespace;id=test') and runtime key names (ex: 'storage-engine.data-size'); logging, mod-lua and multi-valued keys are not mapped
+func confRuntimeContexts(cc aeroconf.Stanza) map[string]map[string]confRuntimeValue {
+	contexts := make(map[string]map[string]confRuntimeValue)
+	add := func(context string, key string, keyPath []string, s aeroconf.Stanza, item string) {
+		vals, err := s.GetValues(item)
+		if err != nil || len(vals) != 1 {
+			return
+		}
+		value := ""
+		if vals[0] != nil {
+			value = *vals[0]
+		}
+		if _, ok := contexts[context]; !ok {
+			contexts[context] = make(map[string]confRuntimeValue)
+		}
+		contexts[context][key] = confRuntimeValue{Path: confDiffJoinPath(keyPath), Value: value}
+	}
+	// walk adds the keys of a stanza and its sub-stanzas, with sub-stanza names as dot-separated prefixes; named sub-stanzas are skipped, except for namespace storage-engine and index types
+	var walk func(context string, prefix string, keyPath []string, s aeroconf.Stanza, inNamespace bool)
+	walk = func(context string, prefix string, keyPath []string, s aeroconf.Stanza, inNamespace bool) {
+		for _, item := range s.ListKeys() {
+			itemPath := append(append([]string{}, keyPath...), item)
+			switch s.Type(item) {
+			case aeroconf.ValueString:
+				add(context, prefix+item, itemPath, s, item)
+			case aeroconf.ValueStanza:
+				name, _, named := strings.Cut(item, " ")
+				if named && !(inNamespace && inslice.HasString([]string{"storage-engine", "index-type", "sindex-type"}, name)) {
+					continue
+				}
+				walk(context, prefix+name+".", itemPath, s.Stanza(item), inNamespace)
+			}
+		}
+	}
+	for _, item := range cc.ListKeys() {
+		if cc.Type(item) != aeroconf.ValueStanza {
+			continue
+		}
+		name, id, _ := strings.Cut(item, " ")
+		s := cc.Stanza(item)
+		switch name {
+		case "service", "network", "security":
+			walk("context="+name, "", []string{item}, s, false)
+		case "namespace":
+			walk("context=namespace;id="+id, "", []string{item}, s, true)
+		case "xdr":
+			for _, key := range s.ListKeys() {
+				switch s.Type(key) {
+				case aeroconf.ValueString:
+					add("context=xdr", key, []string{item, key}, s, key)
+				case aeroconf.ValueStanza:
+					stanzaType, dc, ok := strings.Cut(key, " ")
+					if !ok || stanzaType != "dc" {
+						continue
+					}
+					dcStanza := s.Stanza(key)
+					for _, dcKey := range dcStanza.ListKeys() {
+						switch dcStanza.Type(dcKey) {
+						case aeroconf.ValueString:
+							add("context=xdr;dc="+dc, dcKey, []string{item, key, dcKey}, dcStanza, dcKey)
+						case aeroconf.ValueStanza:
+							stanzaType, ns, ok := strings.Cut(dcKey, " ")
+							if !ok || stanzaType != "namespace" {
+								continue
+							}
+							walk("context=xdr;dc="+dc+";namespace="+ns, "", []string{item, key, dcKey}, dcStanza.Stanza(dcKey), false)
+						}
+					}
+				}
+			}
+		}
+	}
+	return contexts
+}
+
+// confGetConfig runs get-config for a context on a node and returns the key=value pairs
+func confGetConfig(clusterName string, node int, context string, authArgs []string) (map[string]string, error) {
+	resp, err := confAsinfo(clusterName, node, "get-config:"+context, authArgs)
+	if err != nil {
+		return nil, err
+	}
+	if resp == "" || strings.HasPrefix(resp, "ERROR") {
+		return nil, fmt.Errorf("get-config:%s returned '%s'", context, resp)
+	}
+	values := make(map[string]string)
+	for _, pair := range strings.Split(resp, ";") {
+		key, value, ok := strings.Cut(pair, "=")
+		if ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// confAsinfo runs an info command on a node and returns the trimmed response
+func confAsinfo(clusterName string, node int, req string, authArgs []string) (string, error) {
+	if a.opts.Config.Backend.Type != "docker" {
+		req = strings.ReplaceAll(req, ";", "\\;")
+	}
+	command := append(append([]string{"asinfo"}, authArgs...), "-v", req)
+	out, err := b.RunCommands(clusterName, [][]string{command}, []int{node})
+	if err != nil {
+		nout := ""
+		if len(out) > 0 {
+			nout = strings.TrimSpace(string(out[0]))
+		}
+		return "", fmt.Errorf("asinfo %s: %s: %s", req, err, nout)
+	}
+	return strings.TrimSpace(string(out[0])), nil
+}
+
+// confRuntimeEqual compares an aerospike.conf value against a get-config value, allowing for size (K,M,G,T,P) and time (s,m,h,d) units and boolean case
+func confRuntimeEqual(disk string, runtime string) bool {
+	if strings.EqualFold(disk, runtime) {
+		return true
+	}
+	runtimeNumber, err := strconv.ParseInt(runtime, 10, 64)
+	if err != nil || len(disk) < 2 {
+		return false
+	}
+	number, err := strconv.ParseInt(disk[:len(disk)-1], 10, 64)
+	if err != nil {
+		return false
+	}
+	sizes := map[byte]int64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30, 'T': 1 << 40, 'P': 1 << 50}
+	times := map[byte]int64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400}
+	unit := disk[len(disk)-1]
+	if mul, ok := sizes[unit&^0x20]; ok && number*mul == runtimeNumber {
+		return true
+	}
+	if mul, ok := times[unit|0x20]; ok && number*mul == runtimeNumber {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+)
+
+type confLdapCmd struct {
+	aerospikeStartSelectorCmd
+	LdapClient    TypeClientName `long:"ldap-client" description:"name of the client group running the LDAP server, see: client create ldap" webrequired:"true"`
+	LdapNode      int            `long:"ldap-node" description:"machine number in the LDAP client group to use" default:"1"`
+	NoTLS         bool           `long:"no-tls" description:"connect to LDAP on port 389 without TLS, even if the LDAP server has ldaps configured"`
+	RoleMap       []string       `short:"r" long:"role-map" description:"set the LDAP group members of an aerospike role, as ROLE:USER1+USER2; the group is created if it does not exist; can be specified multiple times"`
+	PollingPeriod int            `long:"polling-period" description:"how often, in seconds, aerospike should poll LDAP for role changes" default:"10"`
+	NoRestart     bool           `short:"e" long:"no-restart" description:"by default aerolab will restart aerospike once the configuration is changed; set this to prevent said action"`
+	parallelThreadsCmd
+}
+
+func (c *confLdapCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running conf.ldap")
+	if c.LdapClient == "" {
+		return errors.New("LDAP client group name must be specified with --ldap-client")
+	}
+
+	baseDN, ldapIp, useTLS, caPem, err := c.ldapServer()
+	if err != nil {
+		return err
+	}
+
+	// check cluster exists already
+	clusterList, err := b.ClusterList()
+	if err != nil {
+		return err
+	}
+	if !inslice.HasString(clusterList, string(c.ClusterName)) {
+		return fmt.Errorf("cluster does not exist: %s", string(c.ClusterName))
+	}
+	nodeList, err := b.NodeListInCluster(string(c.ClusterName))
+	if err != nil {
+		return err
+	}
+	nodes, err := expandNodeList(c.Nodes.String())
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		nodes = nodeList
+	}
+	for _, node := range nodes {
+		if !inslice.HasInt(nodeList, node) {
+			return fmt.Errorf("node %d not found", node)
+		}
+	}
+
+	server := "ldap://" + ldapIp + ":389"
+	if useTLS {
+		server = "ldaps://" + ldapIp + ":636"
+	}
+	log.Printf("Configuring security.ldap with server %s", server)
+	returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+		out, err := b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/etc/aerospike/aerospike.conf"}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("cluster=%s node=%v RunCommands error=%s", string(c.ClusterName), node, err)
+		}
+		cc, err := aeroconf.Parse(bytes.NewReader(out[0]))
+		if err != nil {
+			return fmt.Errorf("config parse failure: %s", err)
+		}
+		switch cc.Type("security") {
+		case aeroconf.ValueNil:
+			cc.NewStanza("security")
+		case aeroconf.ValueStanza:
+		default:
+			return errors.New("security definition must be a {} stanza")
+		}
+		security := cc.Stanza("security")
+		security.Delete("ldap")
+		security.NewStanza("ldap")
+		ldap := security.Stanza("ldap")
+		ldap.SetValue("query-base-dn", baseDN)
+		ldap.SetValue("server", server)
+		if useTLS {
+			ldap.SetValue("disable-tls", "false")
+			ldap.SetValue("tls-ca-file", "/etc/aerospike/ldap/ca.pem")
+		} else {
+			ldap.SetValue("disable-tls", "true")
+		}
+		ldap.SetValue("user-dn-pattern", "uid=${un},ou=People,"+baseDN)
+		ldap.SetValue("role-query-search-ou", "false")
+		ldap.SetValue("role-query-pattern", "(&(objectClass=posixGroup)(memberUid=${un}))")
+		ldap.SetValue("polling-period", strconv.Itoa(c.PollingPeriod))
+		buf := new(bytes.Buffer)
+		err = cc.Write(buf, "", "    ", true)
+		if err != nil {
+			return err
+		}
+		files := map[string][]byte{"/etc/aerospike/aerospike.conf": buf.Bytes()}
+		if useTLS {
+			files["/etc/aerospike/ldap/ca.pem"] = caPem
+		}
+		return tlsUploadFiles(string(c.ClusterName), node, files)
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	if c.NoRestart {
+		log.Print("Done, remember to restart aerospike for the changes to take effect")
+		return nil
+	}
+	a.opts.Aerospike.Restart.ClusterName = c.ClusterName
+	a.opts.Aerospike.Restart.Nodes = TypeNodes(intSliceToString(nodes, ","))
+	a.opts.Aerospike.Restart.ParallelThreads = c.ParallelThreads
+	err = a.opts.Aerospike.Restart.run(args, "restart", os.Stdout)
+	if err != nil {
+		return fmt.Errorf("aerospike restart: %s", err)
+	}
+	log.Print("Done")
+	log.Print("LDAP users authenticate using external authentication, ex: aql -U alice -P alice123 --auth EXTERNAL_INSECURE")
+	return nil
+}
+
+// ldapServer reads the LDAP server details left by 'client create ldap' from the LDAP client group, and applies the role map
+func (c *confLdapCmd) ldapServer() (baseDN string, ldapIp string, useTLS bool, caPem []byte, err error) {
+	b.WorkOnClients()
+	defer b.WorkOnServers()
+	clients, err := b.ClusterList()
+	if err != nil {
+		return "", "", false, nil, err
+	}
+	if !inslice.HasString(clients, c.LdapClient.String()) {
+		return "", "", false, nil, fmt.Errorf("client group does not exist: %s", c.LdapClient)
+	}
+	out, err := b.RunCommands(c.LdapClient.String(), [][]string{{"cat", "/etc/ldap/aerolab.conf"}}, []int{c.LdapNode})
+	if err != nil {
+		return "", "", false, nil, fmt.Errorf("could not read /etc/ldap/aerolab.conf on %s:%d, was it created with 'client create ldap'? %s", c.LdapClient, c.LdapNode, err)
+	}
+	ldapConf := ldapParseConf(out[0])
+	baseDN = ldapConf["LDAP_BASE_DN"]
+	if baseDN == "" {
+		return "", "", false, nil, errors.New("LDAP_BASE_DN not found in /etc/ldap/aerolab.conf on the LDAP server")
+	}
+	useTLS = ldapConf["LDAP_TLS"] == "true" && !c.NoTLS
+	for _, internal := range []bool{true, false} {
+		ips, err := b.GetNodeIpMap(c.LdapClient.String(), internal)
+		if err != nil {
+			return "", "", false, nil, err
+		}
+		if ips[c.LdapNode] != "" {
+			ldapIp = ips[c.LdapNode]
+			break
+		}
+	}
+	if ldapIp == "" {
+		return "", "", false, nil, errors.New("could not find the IP of the LDAP server - is it down?")
+	}
+	if useTLS {
+		out, err = b.RunCommands(c.LdapClient.String(), [][]string{{"cat", "/etc/ldap/certs/ca.pem"}}, []int{c.LdapNode})
+		if err != nil {
+			return "", "", false, nil, fmt.Errorf("could not read the LDAP CA certificate: %s", err)
+		}
+		caPem = out[0]
+	}
+	if len(c.RoleMap) > 0 {
+		err = c.applyRoleMap(baseDN)
+		if err != nil {
+			return "", "", false, nil, err
+		}
+	}
+	return baseDN, ldapIp, useTLS, caPem, nil
+}
+
+// applyRoleMap replaces the LDAP groups of the given roles on the LDAP server; the backend must be working on clients
+func (c *confLdapCmd) applyRoleMap(baseDN string) error {
+	ldif := ""
+	script := "set -e\nsource /etc/ldap/aerolab.conf\n"
+	for _, roleMap := range c.RoleMap {
+		role, members, ok := strings.Cut(roleMap, ":")
+		if !ok || !ldapNameRegex.MatchString(role) {
+			return fmt.Errorf("role map %s must be in the format ROLE:USER1+USER2", roleMap)
+		}
+		memberList := []string{}
+		for _, member := range strings.Split(members, "+") {
+			if member == "" {
+				continue
+			}
+			if !ldapNameRegex.MatchString(member) {
+				return fmt.Errorf("role map %s: user name %s may only contain letters, numbers, dots, underscores and dashes", roleMap, member)
+			}
+			memberList = append(memberList, member)
+		}
+		log.Printf("Mapping LDAP users %s to role %s", strings.Join(memberList, ","), role)
+		ldif += ldapGroupLdif(baseDN, role, memberList)
+		// exit code 32: no such object
+		script += fmt.Sprintf("ldapdelete -x -H ldapi:/// -D \"cn=admin,${LDAP_BASE_DN}\" -w \"${LDAP_ADMIN_PASSWORD}\" \"cn=%s,ou=Groups,${LDAP_BASE_DN}\" || [ $? -eq 32 ]\n", role)
+	}
+	script += "ldapadd -x -H ldapi:/// -D \"cn=admin,${LDAP_BASE_DN}\" -w \"${LDAP_ADMIN_PASSWORD}\" -f /tmp/ldap-roles.ldif\nrm -f /tmp/ldap-roles.ldif\n"
+	err := b.CopyFilesToCluster(c.LdapClient.String(), []fileList{{"/tmp/ldap-roles.ldif", ldif, len(ldif)}, {"/tmp/ldap-roles.sh", script, len(script)}}, []int{c.LdapNode})
+	if err != nil {
+		return err
+	}
+	out, err := b.RunCommands(c.LdapClient.String(), [][]string{{"/bin/bash", "/tmp/ldap-roles.sh"}}, []int{c.LdapNode})
+	if err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("could not apply role map: %s: %s", err, strings.TrimSpace(string(out[0])))
+		}
+		return fmt.Errorf("could not apply role map: %s", err)
+	}
+	return nil
+}
+
+// ldapParseConf parses the KEY=VALUE lines of /etc/ldap/aerolab.conf
+func ldapParseConf(data []byte) map[string]string {
+	conf := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		conf[key] = strings.Trim(value, "\"'")
+	}
+	return conf
+}
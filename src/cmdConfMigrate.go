@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/jedib0t/go-pretty/v6/table"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type confMigrateCmd struct {
+	From        int             `long:"from" description:"Major aerospike version the configuration is written for" default:"6"`
+	To          int             `long:"to" description:"Major aerospike version to migrate the configuration to" default:"7"`
+	File        flags.Filename  `short:"f" long:"file" description:"Local aerospike.conf file to migrate; if not set, the file on the cluster nodes is migrated"`
+	Output      string          `short:"o" long:"output" description:"With -f, write the migrated configuration to this file; default: print to stdout"`
+	ClusterName TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
+	Nodes       TypeNodes       `short:"l" long:"nodes" description:"Nodes list, comma separated. Empty=ALL" default:""`
+	Path        string          `short:"p" long:"path" description:"Path to aerospike.conf on the remote nodes" default:"/etc/aerospike/aerospike.conf"`
+	DryRun      bool            `short:"d" long:"dry-run" description:"Only print the report of changes, do not modify the configuration files on the nodes"`
+	Json        bool            `short:"j" long:"json" description:"Provide the report in json format"`
+	parallelThreadsLongCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// confMigrateChange is a single change made while migrating a configuration file
+type confMigrateChange struct {
+	Node   int    `json:",omitempty"`
+	Path   string // path in the 'conf adjust' notation, before migration
+	Change string // moved, renamed, converted, removed or review
+	Detail string
+}
+
+// confMigrations holds the rules migrating a configuration from the keyed major version to the next one
+var confMigrations = map[int]func(cc aeroconf.Stanza) ([]confMigrateChange, error){
+	6: confMigrate6to7,
+}
+
+func (c *confMigrateCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running conf.migrate")
+	if c.File != "" {
+		data, err := os.ReadFile(string(c.File))
+		if err != nil {
+			return err
+		}
+		newconf, changes, err := confMigrate(data, c.From, c.To)
+		if err != nil {
+			return err
+		}
+		out := os.Stdout
+		if c.Output == "" {
+			out = os.Stderr
+		}
+		err = c.report(out, changes, false)
+		if err != nil {
+			return err
+		}
+		if c.Output == "" {
+			fmt.Print(string(newconf))
+		} else {
+			err = os.WriteFile(c.Output, newconf, 0644)
+			if err != nil {
+				return err
+			}
+		}
+		log.Print("Done")
+		return nil
+	}
+
+	err := c.Nodes.ExpandNodes(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	nodes, err := c.Nodes.Translate(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	sort.Ints(nodes)
+	confs, err := confReadNodes(c.ClusterName.String(), nodes, c.Path, c.ParallelThreads)
+	if err != nil {
+		return err
+	}
+	changes := []confMigrateChange{}
+	newconfs := make(map[int][]byte)
+	for _, node := range nodes {
+		newconf, nodeChanges, err := confMigrate(confs[node], c.From, c.To)
+		if err != nil {
+			return fmt.Errorf("node %d: %s", node, err)
+		}
+		for i := range nodeChanges {
+			nodeChanges[i].Node = node
+		}
+		changes = append(changes, nodeChanges...)
+		if len(nodeChanges) > 0 {
+			newconfs[node] = newconf
+		}
+	}
+	err = c.report(os.Stdout, changes, true)
+	if err != nil {
+		return err
+	}
+	if c.DryRun || len(newconfs) == 0 {
+		log.Print("Done")
+		return nil
+	}
+	changed := []int{}
+	for _, node := range nodes {
+		if _, ok := newconfs[node]; ok {
+			changed = append(changed, node)
+		}
+	}
+	returns := parallelize.MapLimit(changed, c.ParallelThreads, func(node int) error {
+		backup := confs[node]
+		newconf := newconfs[node]
+		return b.CopyFilesToCluster(c.ClusterName.String(), []fileList{{c.Path + ".pre-migrate", string(backup), len(backup)}, {c.Path, string(newconf), len(newconf)}}, []int{node})
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", changed[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	log.Printf("Done, migrated nodes %s; the original files were saved as %s.pre-migrate; restart aerospike for the changes to take effect", intSliceToString(changed, ","), c.Path)
+	return nil
+}
+
+// report prints the changes to out; when the migrated file itself is printed to stdout, the report goes to stderr
+func (c *confMigrateCmd) report(out io.Writer, changes []confMigrateChange, withNode bool) error {
+	if c.Json {
+		return json.NewEncoder(out).Encode(changes)
+	}
+	if len(changes) == 0 {
+		log.Print("No changes required")
+		return nil
+	}
+	tb := table.NewWriter()
+	tb.SetStyle(table.StyleLight)
+	tb.SetOutputMirror(out)
+	if withNode {
+		tb.AppendHeader(table.Row{"Node", "Change", "Path", "Detail"})
+	} else {
+		tb.AppendHeader(table.Row{"Change", "Path", "Detail"})
+	}
+	for _, change := range changes {
+		if withNode {
+			tb.AppendRow(table.Row{change.Node, change.Change, change.Path, change.Detail})
+		} else {
+			tb.AppendRow(table.Row{change.Change, change.Path, change.Detail})
+		}
+	}
+	tb.Render()
+	return nil
+}
+
+// confMigrate migrates a configuration file between major versions, one major version at a time; an unchanged file is returned as-is, with its comments
+func confMigrate(data []byte, from int, to int) ([]byte, []confMigrateChange, error) {
+	if from >= to {
+		return nil, nil, fmt.Errorf("cannot migrate from version %d to %d, only upgrades are supported", from, to)
+	}
+	cc, err := aeroconf.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("config parse failure: %s", err)
+	}
+	changes := []confMigrateChange{}
+	for version := from; version < to; version++ {
+		rules, ok := confMigrations[version]
+		if !ok {
+			return nil, nil, fmt.Errorf("no migration rules from version %d to %d", version, version+1)
+		}
+		stepChanges, err := rules(cc)
+		if err != nil {
+			return nil, nil, err
+		}
+		changes = append(changes, stepChanges...)
+	}
+	if len(changes) == 0 {
+		return data, changes, nil
+	}
+	newconf, err := confNormalise(cc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newconf, changes, nil
+}
+
+// confMigrateMajor returns the major version from an aerospike version string, ex: 7.1.0.5 => 7
+func confMigrateMajor(version string) (int, error) {
+	major := strings.Split(strings.TrimSpace(version), ".")[0]
+	ret, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("could not get the major version of %s", version)
+	}
+	return ret, nil
+}
+
+// confMigrate6to7 applies the storage changes of aerospike 7.0 to all namespaces
+func confMigrate6to7(cc aeroconf.Stanza) ([]confMigrateChange, error) {
+	keys := cc.ListKeys()
+	sort.Strings(keys)
+	changes := []confMigrateChange{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "namespace ") || cc.Type(key) != aeroconf.ValueStanza {
+			continue
+		}
+		nsChanges, err := confMigrateNamespace6to7(key, cc.Stanza(key))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", key, err)
+		}
+		changes = append(changes, nsChanges...)
+	}
+	return changes, nil
+}
+
+// confMigrateNamespace6to7 moves memory-size to storage-engine memory data-size, converts data-in-memory namespaces to storage-engine memory with persistence, and moves the eviction and stop-writes thresholds into the storage-engine stanza
+func confMigrateNamespace6to7(name string, ns aeroconf.Stanza) ([]confMigrateChange, error) {
+	changes := []confMigrateChange{}
+	add := func(change string, path []string, format string, args ...interface{}) {
+		changes = append(changes, confMigrateChange{
+			Path:   confDiffJoinPath(append([]string{name}, path...)),
+			Change: change,
+			Detail: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// find the storage engine; namespaces without one are in memory
+	engine := "memory"
+	engineKey := ""
+	for _, key := range ns.ListKeys() {
+		if key == "storage-engine" && ns.Type(key) == aeroconf.ValueString {
+			engine = confDiffValue(ns, key)
+			engineKey = key
+		} else if strings.HasPrefix(key, "storage-engine ") && ns.Type(key) == aeroconf.ValueStanza {
+			engine = strings.TrimPrefix(key, "storage-engine ")
+			engineKey = key
+		}
+	}
+	if engine != "memory" && ns.Type(engineKey) != aeroconf.ValueStanza {
+		return nil, fmt.Errorf("storage-engine %s must be a {} stanza", engine)
+	}
+	origEngineKey := engineKey
+	persisted := false
+	if engineKey == "storage-engine memory" {
+		se := ns.Stanza(engineKey)
+		persisted = se.Type("file") != aeroconf.ValueNil || se.Type("device") != aeroconf.ValueNil
+	}
+
+	// data-in-memory namespaces become storage-engine memory, persisted to the same devices or files
+	if engine == "device" {
+		se := ns.Stanza(engineKey)
+		if se.Type("data-in-memory") == aeroconf.ValueString {
+			dataInMemory := confDiffValue(se, "data-in-memory")
+			se.Delete("data-in-memory")
+			if dataInMemory == "true" {
+				add("converted", []string{engineKey, "data-in-memory"}, "data-in-memory was removed; the namespace is now storage-engine memory, persisted to the same devices or files")
+				for _, key := range []string{"cold-start-empty", "commit-to-device", "commit-min-size", "direct-files", "disable-odsync", "post-write-queue", "read-page-cache", "write-block-size"} {
+					if se.Type(key) == aeroconf.ValueNil {
+						continue
+					}
+					add("removed", []string{engineKey, key}, "not supported by storage-engine memory")
+					se.Delete(key)
+				}
+				ns.Delete(engineKey)
+				engine = "memory"
+				engineKey = "storage-engine memory"
+				ns[engineKey] = se
+				persisted = true
+			} else {
+				add("removed", []string{engineKey, "data-in-memory"}, "data-in-memory was removed; %s was the default", dataInMemory)
+			}
+		}
+	}
+
+	// storage-engine memory is a stanza since 7.0
+	if engine == "memory" && !persisted {
+		if engineKey == "storage-engine" {
+			ns.Delete(engineKey)
+		}
+		engineKey = "storage-engine memory"
+		if ns.Type(engineKey) == aeroconf.ValueNil {
+			ns.NewStanza(engineKey)
+		}
+	}
+	se := ns.Stanza(engineKey)
+
+	// memory-size
+	memorySize := confDiffValue(ns, "memory-size")
+	if ns.Type("memory-size") == aeroconf.ValueString {
+		ns.Delete("memory-size")
+		switch {
+		case persisted:
+			add("removed", []string{"memory-size"}, "the size of a persisted storage-engine memory namespace is the size of its devices or files")
+		case engine == "memory":
+			se.SetValue("data-size", memorySize)
+			add("moved", []string{"memory-size"}, "now storage-engine memory data-size %s; the primary and secondary indexes are no longer counted against it, review the size", memorySize)
+		default:
+			add("removed", []string{"memory-size"}, "no longer used by storage-engine %s; use indexes-memory-budget (7.1+) to limit the memory used by the primary index", engine)
+		}
+	} else if engine == "memory" && !persisted && se.Type("data-size") == aeroconf.ValueNil {
+		add("review", []string{engineKey, "data-size"}, "storage-engine memory requires data-size, set it with 'conf adjust'")
+	}
+
+	// move a key into the storage-engine stanza, unless a key it replaces is already there
+	move := func(from aeroconf.Stanza, fromPath []string, key string, newKey string) {
+		if from.Type(key) != aeroconf.ValueString {
+			return
+		}
+		value := confDiffValue(from, key)
+		from.Delete(key)
+		if se.Type(newKey) != aeroconf.ValueNil {
+			add("removed", fromPath, "superseded by storage-engine %s %s", newKey, confDiffValue(se, newKey))
+			return
+		}
+		se.SetValue(newKey, value)
+		change := "moved"
+		if len(fromPath) > 1 {
+			change = "renamed"
+		}
+		add(change, fromPath, "now storage-engine %s %s", newKey, value)
+	}
+	remove := func(key string, format string, args ...interface{}) {
+		if ns.Type(key) == aeroconf.ValueNil {
+			return
+		}
+		ns.Delete(key)
+		add("removed", []string{key}, format, args...)
+	}
+
+	move(se, []string{origEngineKey, "max-used-pct"}, "max-used-pct", "stop-writes-used-pct")
+	move(se, []string{origEngineKey, "min-avail-pct"}, "min-avail-pct", "stop-writes-avail-pct")
+	if engine == "memory" && !persisted {
+		move(ns, []string{"high-water-memory-pct"}, "high-water-memory-pct", "evict-used-pct")
+		move(ns, []string{"stop-writes-pct"}, "stop-writes-pct", "stop-writes-used-pct")
+		remove("high-water-disk-pct", "the namespace has no storage")
+	} else {
+		move(ns, []string{"high-water-disk-pct"}, "high-water-disk-pct", "evict-used-pct")
+		remove("high-water-memory-pct", "memory eviction was removed; use evict-sys-memory-pct to evict based on system memory")
+		remove("stop-writes-pct", "memory stop-writes was removed; use stop-writes-sys-memory-pct to stop writes based on system memory")
+	}
+	remove("single-bin", "single-bin namespaces are no longer supported, the namespace is now multi-bin")
+	remove("data-in-index", "data-in-index was removed with single-bin")
+	return changes, nil
+}
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type confValidateCmd struct {
+	File             flags.Filename  `short:"f" long:"file" description:"Local aerospike.conf file to validate; if not set, the file on the cluster nodes is validated"`
+	AerospikeVersion string          `short:"v" long:"aerospike-version" description:"Aerospike version to validate against; default: the version installed on each node; required to validate a local file against a schema"`
+	Schema           flags.Filename  `short:"s" long:"schema" description:"Validate against this local JSON schema file instead of the published schema for the version"`
+	ClusterName      TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
+	Nodes            TypeNodes       `short:"l" long:"nodes" description:"Nodes list, comma separated. Empty=ALL" default:""`
+	Path             string          `short:"p" long:"path" description:"Path to aerospike.conf on the remote nodes" default:"/etc/aerospike/aerospike.conf"`
+	parallelThreadsLongCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *confValidateCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running conf.validate")
+	var schema *confSchema
+	var err error
+	if c.Schema != "" {
+		schema, err = confSchemaParseFile(string(c.Schema))
+		if err != nil {
+			return err
+		}
+	}
+	if c.File != "" {
+		data, err := os.ReadFile(string(c.File))
+		if err != nil {
+			return err
+		}
+		if schema == nil && c.AerospikeVersion != "" {
+			schema, err = confSchemaLoad(c.AerospikeVersion, false)
+			if err != nil {
+				return err
+			}
+		}
+		if schema == nil {
+			log.Print("No --aerospike-version or --schema specified, checking the file structure only")
+		}
+		errs := confValidate(data, schema)
+		for _, e := range errs {
+			fmt.Println(e.Format(string(c.File)))
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("found %d errors", len(errs))
+		}
+		log.Print("Done, no errors found")
+		return nil
+	}
+
+	err = c.Nodes.ExpandNodes(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	nodes, err := c.Nodes.Translate(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	sort.Ints(nodes)
+	lock := new(sync.Mutex)
+	output := make(map[int][]string)
+	errCount := 0
+	returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+		out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"cat", c.Path}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("cluster=%s node=%v RunCommands error=%s", c.ClusterName, node, err)
+		}
+		data := out[0]
+		nodeSchema := schema
+		if nodeSchema == nil {
+			version := c.AerospikeVersion
+			if version == "" {
+				out, err = b.RunCommands(c.ClusterName.String(), [][]string{{"cat", "/opt/aerolab.aerospike.version"}}, []int{node})
+				if err != nil {
+					return fmt.Errorf("could not read the aerospike version, specify it with -v: %s", err)
+				}
+				version = strings.TrimSpace(string(out[0]))
+			}
+			nodeSchema, err = confSchemaLoad(version, false)
+			if err != nil {
+				return err
+			}
+		}
+		errs := confValidate(data, nodeSchema)
+		name := fmt.Sprintf("%s-%d:%s", c.ClusterName, node, c.Path)
+		lines := []string{}
+		for _, e := range errs {
+			lines = append(lines, e.Format(name))
+		}
+		lock.Lock()
+		output[node] = lines
+		errCount += len(errs)
+		lock.Unlock()
+		return nil
+	})
+	for _, node := range nodes {
+		for _, line := range output[node] {
+			fmt.Println(line)
+		}
+	}
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	if errCount > 0 {
+		return fmt.Errorf("found %d errors", errCount)
+	}
+	log.Print("Done, no errors found")
+	return nil
+}
+
+// confValidateError is a problem found in an aerospike.conf file
+type confValidateError struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+// Format renders the error in the file:line: path: message format
+func (e confValidateError) Format(name string) string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s:%d: %s", name, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", name, e.Line, e.Path, e.Message)
+}
+
+// confLine is a line of aerospike.conf: a key with values, or a stanza, with its type and name as values, and its contents
+type confLine struct {
+	Line     int
+	Key      string
+	Values   []string
+	Stanza   bool
+	Children []*confLine
+}
+
+func (l *confLine) name() string {
+	return strings.Join(append([]string{l.Key}, l.Values...), " ")
+}
+
+// confParseLines parses aerospike.conf the same way as aeroconf, keeping line numbers
+func confParseLines(data []byte) (*confLine, []confValidateError) {
+	root := &confLine{Stanza: true}
+	stack := []*confLine{root}
+	errs := []confValidateError{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if ind := strings.IndexRune(line, '#'); ind >= 0 {
+			line = line[0:ind]
+		}
+		line = strings.Trim(line, "\r\n\t ")
+		if len(line) == 0 {
+			continue
+		}
+		current := stack[len(stack)-1]
+		switch {
+		case strings.HasSuffix(line, "{"):
+			fields := strings.Fields(strings.TrimSuffix(line, "{"))
+			if len(fields) == 0 {
+				errs = append(errs, confValidateError{Line: lineNo, Message: "stanza has no name"})
+				fields = []string{""}
+			}
+			stanza := &confLine{Line: lineNo, Key: fields[0], Values: fields[1:], Stanza: true}
+			current.Children = append(current.Children, stanza)
+			stack = append(stack, stanza)
+		case line == "}":
+			if len(stack) == 1 {
+				errs = append(errs, confValidateError{Line: lineNo, Message: "closing brace without an open stanza"})
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			fields := strings.Fields(line)
+			if inslice.HasString(fields, "{") || inslice.HasString(fields, "}") {
+				errs = append(errs, confValidateError{Line: lineNo, Message: "'{' must end the line and '}' must be on a line of its own"})
+				continue
+			}
+			current.Children = append(current.Children, &confLine{Line: lineNo, Key: fields[0], Values: fields[1:]})
+		}
+	}
+	for _, stanza := range stack[1:] {
+		errs = append(errs, confValidateError{Line: stanza.Line, Path: stanza.name(), Message: "stanza is not closed"})
+	}
+	return root, errs
+}
+
+// confValidate checks the structure of an aerospike.conf and, if a schema is given, its stanzas, keys and values against the schema
+func confValidate(data []byte, schema *confSchema) []confValidateError {
+	root, errs := confParseLines(data)
+	v := &confValidator{errs: errs}
+	v.duplicateStanzas(root, nil)
+	if schema != nil {
+		v.object(root, schema, nil)
+	}
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		return v.errs[i].Line < v.errs[j].Line
+	})
+	return v.errs
+}
+
+type confValidator struct {
+	errs []confValidateError
+}
+
+func (v *confValidator) add(line *confLine, path []string, format string, args ...interface{}) {
+	v.errs = append(v.errs, confValidateError{Line: line.Line, Path: confDiffJoinPath(path), Message: fmt.Sprintf(format, args...)})
+}
+
+// duplicateStanzas reports stanzas defined twice, which aerospike rejects and aeroconf silently merges into one
+func (v *confValidator) duplicateStanzas(stanza *confLine, path []string) {
+	seen := make(map[string]int)
+	for _, child := range stanza.Children {
+		if !child.Stanza {
+			continue
+		}
+		childPath := append(append([]string{}, path...), child.name())
+		if line, ok := seen[child.name()]; ok {
+			v.add(child, childPath, "duplicate stanza, first defined on line %d", line)
+		} else {
+			seen[child.name()] = child.Line
+		}
+		v.duplicateStanzas(child, childPath)
+	}
+}
+
+// object validates the contents of a stanza against an object schema
+func (v *confValidator) object(stanza *confLine, schema *confSchema, path []string) {
+	seen := make(map[string]int)
+	for _, child := range stanza.Children {
+		key := child.Key
+		values := child.Values
+		// logging contexts are set as 'context NAME LEVEL'
+		if !child.Stanza && key == "context" && len(values) == 2 && schema.Properties["context"] == nil {
+			key = values[0]
+			values = values[1:]
+		}
+		childPath := append(append([]string{}, path...), child.name())
+		if !child.Stanza {
+			childPath = append(append([]string{}, path...), key)
+		}
+		_, prop := schema.property(key)
+		if prop == nil {
+			if schema.strict() {
+				if child.Stanza {
+					v.add(child, childPath, "unknown stanza")
+				} else {
+					v.add(child, childPath, "unknown key")
+				}
+			}
+			continue
+		}
+		switch {
+		case child.Stanza && prop.isArray():
+			if prop.Items == nil {
+				continue
+			}
+			if len(values) == 0 && !prop.Items.isObject() {
+				v.add(child, childPath, "expected a key, not a stanza")
+				continue
+			}
+			if len(values) == 0 {
+				// an unnamed stanza holding a list of named stanzas, ex: logging
+				for _, item := range child.Children {
+					itemPath := append(append([]string{}, childPath...), item.name())
+					if !item.Stanza {
+						v.add(item, itemPath, "expected a stanza")
+						continue
+					}
+					v.object(item, prop.Items, itemPath)
+				}
+				continue
+			}
+			v.object(child, prop.Items, childPath)
+		case child.Stanza && prop.isTyped():
+			if len(values) == 0 {
+				v.add(child, childPath, "%s requires a type, ex: %s device", key, key)
+				continue
+			}
+			variant := prop.variant(values[0])
+			if variant == nil {
+				v.add(child, childPath, "unknown %s type %s", key, values[0])
+				continue
+			}
+			v.unique(seen, child, childPath, key)
+			v.object(child, variant, childPath)
+		case child.Stanza:
+			if !prop.isObject() {
+				v.add(child, childPath, "expected a key, not a stanza")
+				continue
+			}
+			v.unique(seen, child, childPath, key)
+			v.object(child, prop, childPath)
+		case prop.isTyped():
+			// storage-engine memory
+			if len(values) == 0 || prop.variant(values[0]) == nil {
+				v.add(child, childPath, "unknown %s type %s", key, strings.Join(values, " "))
+				continue
+			}
+			v.unique(seen, child, childPath, key)
+		case prop.isObject():
+			v.add(child, childPath, "expected a stanza, not a key")
+		default:
+			valueSchema := prop
+			if prop.isArray() {
+				if prop.Items == nil {
+					continue
+				}
+				valueSchema = prop.Items
+			} else {
+				v.unique(seen, child, childPath, key)
+			}
+			value := strings.Join(values, " ")
+			if value == "" {
+				if !valueSchema.hasType("boolean") {
+					v.add(child, childPath, "missing value")
+				}
+				continue
+			}
+			if msg := valueSchema.checkValue(value); msg != "" {
+				v.add(child, childPath, "%s", msg)
+			}
+		}
+	}
+}
+
+// unique reports keys and stanzas which may only be specified once in a stanza
+func (v *confValidator) unique(seen map[string]int, line *confLine, path []string, key string) {
+	if first, ok := seen[key]; ok {
+		v.add(line, path, "%s specified more than once, first on line %d", key, first)
+		return
+	}
+	seen[key] = line.Line
+}
+
+// confSchema is the subset of JSON schema used by the aerospike configuration schemas
+type confSchema struct {
+	Type                 interface{}            `json:"type"`
+	Properties           map[string]*confSchema `json:"properties"`
+	AdditionalProperties interface{}            `json:"additionalProperties"`
+	Items                *confSchema            `json:"items"`
+	OneOf                []*confSchema          `json:"oneOf"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+}
+
+func (s *confSchema) hasType(t string) bool {
+	switch st := s.Type.(type) {
+	case string:
+		return st == t
+	case []interface{}:
+		for _, item := range st {
+			if item == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *confSchema) types() []string {
+	switch st := s.Type.(type) {
+	case string:
+		return []string{st}
+	case []interface{}:
+		types := []string{}
+		for _, item := range st {
+			if t, ok := item.(string); ok {
+				types = append(types, t)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func (s *confSchema) isArray() bool {
+	return s.hasType("array")
+}
+
+func (s *confSchema) isObject() bool {
+	return s.hasType("object") || s.Properties != nil
+}
+
+// isTyped returns true for stanzas which have a type, and a set of allowed keys per type, ex: storage-engine
+func (s *confSchema) isTyped() bool {
+	return len(s.OneOf) > 0 || (s.Properties != nil && s.Properties["type"] != nil)
+}
+
+// variant returns the schema of a typed stanza for the given type, or nil if the type is not allowed
+func (s *confSchema) variant(typeName string) *confSchema {
+	variants := s.OneOf
+	if len(variants) == 0 {
+		variants = []*confSchema{s}
+	}
+	for _, variant := range variants {
+		if variant.Properties == nil || variant.Properties["type"] == nil {
+			continue
+		}
+		if variant.Properties["type"].checkValue(typeName) == "" {
+			return variant
+		}
+	}
+	return nil
+}
+
+// strict returns true if the schema does not allow keys other than those listed
+func (s *confSchema) strict() bool {
+	allowed, ok := s.AdditionalProperties.(bool)
+	return ok && !allowed
+}
+
+// property finds the schema of a key in an object schema; keys which can be repeated are listed in plural form, ex: 'address' as 'addresses' and 'namespace' as 'namespaces'
+func (s *confSchema) property(key string) (string, *confSchema) {
+	for _, name := range []string{key, key + "s", key + "es"} {
+		if prop, ok := s.Properties[name]; ok {
+			return name, prop
+		}
+	}
+	return "", nil
+}
+
+// checkValue returns why a value does not match the schema, or empty string if it does
+func (s *confSchema) checkValue(value string) string {
+	if len(s.Enum) > 0 {
+		allowed := []string{}
+		for _, item := range s.Enum {
+			if fmt.Sprint(item) == value {
+				return ""
+			}
+			allowed = append(allowed, fmt.Sprint(item))
+		}
+		return fmt.Sprintf("%s is not one of: %s", value, strings.Join(allowed, ", "))
+	}
+	types := s.types()
+	msg := ""
+	for _, t := range types {
+		switch t {
+		case "integer", "number":
+			n, ok := confParseNumber(value)
+			if !ok {
+				msg = fmt.Sprintf("%s is not a number", value)
+				continue
+			}
+			if t == "integer" && n != float64(int64(n)) {
+				msg = fmt.Sprintf("%s is not a whole number", value)
+				continue
+			}
+			if s.Minimum != nil && n < *s.Minimum {
+				msg = fmt.Sprintf("%s is below the minimum of %s", value, strconv.FormatFloat(*s.Minimum, 'f', -1, 64))
+				continue
+			}
+			if s.Maximum != nil && n > *s.Maximum {
+				msg = fmt.Sprintf("%s is above the maximum of %s", value, strconv.FormatFloat(*s.Maximum, 'f', -1, 64))
+				continue
+			}
+			return ""
+		case "boolean":
+			if value == "true" || value == "false" {
+				return ""
+			}
+			msg = fmt.Sprintf("%s is not true or false", value)
+		default:
+			return ""
+		}
+	}
+	return msg
+}
+
+// confParseNumber parses a number with an optional size (K,M,G,T,P) or time (s,m,h,d) unit
+func confParseNumber(value string) (float64, bool) {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n, true
+	}
+	if len(value) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(value[:len(value)-1], 64)
+	if err != nil {
+		return 0, false
+	}
+	switch value[len(value)-1] {
+	case 'K', 'k':
+		return n * (1 << 10), true
+	case 'M':
+		return n * (1 << 20), true
+	case 'G', 'g':
+		return n * (1 << 30), true
+	case 'T', 't':
+		return n * (1 << 40), true
+	case 'P', 'p':
+		return n * (1 << 50), true
+	case 's':
+		return n, true
+	case 'm':
+		return n * 60, true
+	case 'h':
+		return n * 3600, true
+	case 'd':
+		return n * 86400, true
+	}
+	return 0, false
+}
+
+// confSchemaUrl is the location of the published aerospike configuration schemas, by X.Y.0 version
+const confSchemaUrl = "https://raw.githubusercontent.com/aerospike/schemas/main/json/aerospike/%s.json"
+
+// confSchemaRetry is how long automatic validation waits before trying again to download a schema which could not be downloaded
+const confSchemaRetry = 24 * time.Hour
+
+// confSchemas caches the loaded schemas, or the errors loading them, by schema version
+var confSchemas = struct {
+	sync.Mutex
+	schemas map[string]*confSchema
+	errs    map[string]error
+}{schemas: make(map[string]*confSchema), errs: make(map[string]error)}
+
+// confSchemaVersion returns the schema version of an aerospike version, ex: 7.1.0.5 => 7.1.0
+func confSchemaVersion(version string) (string, error) {
+	version = strings.TrimRight(strings.TrimSpace(version), "cf")
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("aerospike version %s is not in the X.Y.Z format", version)
+	}
+	for _, part := range parts[0:2] {
+		if _, err := strconv.Atoi(part); err != nil {
+			return "", fmt.Errorf("aerospike version %s is not in the X.Y.Z format", version)
+		}
+	}
+	return parts[0] + "." + parts[1] + ".0", nil
+}
+
+// confSchemaLoad returns the schema for an aerospike version from ${AEROLAB_HOME}/schemas, downloading it from the aerospike/schemas repository if needed;
+// with auto set, a failed download is not retried for confSchemaRetry, so that automatic validation does not keep going to the network
+func confSchemaLoad(version string, auto bool) (*confSchema, error) {
+	schemaVersion, err := confSchemaVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	confSchemas.Lock()
+	defer confSchemas.Unlock()
+	if schema, ok := confSchemas.schemas[schemaVersion]; ok {
+		return schema, nil
+	}
+	if err, ok := confSchemas.errs[schemaVersion]; ok {
+		return nil, err
+	}
+	schema, err := confSchemaFetch(schemaVersion, auto)
+	if err != nil {
+		confSchemas.errs[schemaVersion] = err
+		return nil, err
+	}
+	confSchemas.schemas[schemaVersion] = schema
+	return schema, nil
+}
+
+func confSchemaFetch(schemaVersion string, auto bool) (*confSchema, error) {
+	rootDir, err := a.aerolabRootDir()
+	if err != nil {
+		return nil, err
+	}
+	fn := filepath.Join(rootDir, "schemas", schemaVersion+".json")
+	if _, err := os.Stat(fn); err == nil {
+		return confSchemaParseFile(fn)
+	}
+	failed := fn + ".failed"
+	if st, err := os.Stat(failed); err == nil && auto && time.Since(st.ModTime()) < confSchemaRetry {
+		reason, _ := os.ReadFile(failed)
+		return nil, fmt.Errorf("%s; not retrying until %s, run 'conf validate' to retry now", strings.TrimSpace(string(reason)), st.ModTime().Add(confSchemaRetry).Format(time.RFC3339))
+	}
+	schema, data, err := confSchemaDownload(schemaVersion)
+	if err != nil {
+		if os.MkdirAll(filepath.Dir(fn), 0755) == nil {
+			os.WriteFile(failed, []byte(err.Error()), 0644)
+		}
+		return nil, err
+	}
+	os.Remove(failed)
+	err = os.MkdirAll(filepath.Dir(fn), 0755)
+	if err == nil {
+		err = os.WriteFile(fn, data, 0644)
+	}
+	if err != nil {
+		log.Printf("WARNING: could not cache schema in %s: %s", fn, err)
+	}
+	return schema, nil
+}
+
+func confSchemaDownload(schemaVersion string) (*confSchema, []byte, error) {
+	url := fmt.Sprintf(confSchemaUrl, schemaVersion)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not download schema %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("no configuration schema is published for aerospike %s", strings.TrimSuffix(schemaVersion, ".0"))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("could not download schema %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not download schema %s: %s", url, err)
+	}
+	schema := &confSchema{}
+	err = json.Unmarshal(data, schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse schema %s: %s", url, err)
+	}
+	return schema, data, nil
+}
+
+func confSchemaParseFile(fn string) (*confSchema, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	schema := &confSchema{}
+	err = json.Unmarshal(data, schema)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse schema %s: %s", fn, err)
+	}
+	return schema, nil
+}
+
+// confValidateAuto validates a configuration file before it is shipped to nodes; if the version is empty or no schema can be loaded for it, only the file structure is checked
+func confValidateAuto(name string, data []byte, version string) error {
+	var schema *confSchema
+	if version != "" {
+		var err error
+		schema, err = confSchemaLoad(version, true)
+		if err != nil {
+			log.Printf("WARNING: validating the structure of %s only: %s", name, err)
+		}
+	}
+	errs := confValidate(data, schema)
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, e := range errs {
+		log.Print(e.Format(name))
+	}
+	return fmt.Errorf("%s failed validation with %d errors; use --no-validate to skip validation", name, len(errs))
+}
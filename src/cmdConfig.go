@@ -45,7 +45,7 @@ func (c *configMyIPCmd) Execute(args []string) error {
 }
 
 type configBackendCmd struct {
-	Type                    string         `short:"t" long:"type" description:"Supported backends: aws|docker|gcp" default:"" webchoice:"aws,gcp,docker"`
+	Type                    string         `short:"t" long:"type" description:"Supported backends: aws|docker|gcp|mock" default:"" webchoice:"aws,gcp,docker,mock"`
 	SshKeyPath              flags.Filename `short:"p" long:"key-path" description:"AWS and GCP backends: specify a path to store SSH keys in, default: ${HOME}/aerolab-keys/" default:"${HOME}/aerolab-keys/" webtype:"text"`
 	Region                  string         `short:"r" long:"region" description:"AWS backend: override default aws configured region" default:""`
 	AWSProfile              string         `short:"P" long:"aws-profile" description:"AWS backend: provide a profile to use; setting this ignores the AWS_PROFILE env variable"`
@@ -53,8 +53,13 @@ type configBackendCmd struct {
 	Project                 string         `short:"o" long:"project" description:"GCP backend: override default gcp configured project" default:""`
 	GCPNoPublicIps          bool           `long:"gcp-nopublic-ip" description:"GCP backend: if set, aerolab will not request public IPs, and will operate on private IPs only"`
 	Arch                    string         `short:"a" long:"docker-arch" description:"set to either amd64 or arm64 to force a particular architecture on docker; see https://github.com/aerospike/aerolab/tree/master/docs/docker_multiarch.md"`
+	ContainerRuntime        string         `long:"container-runtime" description:"DOCKER backend: container runtime to drive: docker|podman|nerdctl" default:"docker" webchoice:"docker,podman,nerdctl"`
+	ContainerSocket         flags.Filename `long:"container-socket" description:"DOCKER backend: container engine API socket; docker default: DOCKER_HOST, /var/run/docker.sock or the docker desktop socket; podman default: CONTAINER_HOST, or the user or system podman.sock" default:"" webtype:"text"`
+	CacheTTL                time.Duration  `long:"cache-ttl" description:"cache cluster lists, node IPs, tags and inventory in a local state file for this long; 0 to disable; override per command with --refresh" default:"0s"`
 	UseAlternateIpDiscovery bool           `long:"alt-ip-discovery" description:"Use alternate IP discovery services (in case the original one fails)"`
 	TmpDir                  flags.Filename `short:"d" long:"temp-dir" description:"use a non-default temporary directory" default:"" webtype:"text"`
+	MockStateFile           flags.Filename `long:"mock-state" description:"MOCK backend: path to the state file; default: ${AEROLAB_HOME}/mock-state.json" default:"" webtype:"text"`
+	MockHandlersFile        flags.Filename `long:"mock-handlers" description:"MOCK backend: path to a yaml file with canned command responses; default: ${AEROLAB_HOME}/mock-handlers.yaml if it exists" default:"" webtype:"text"`
 	Help                    helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
 	typeSet                 string
 }
@@ -89,6 +94,9 @@ func (c *configBackendCmd) Execute(args []string) error {
 	if c.Arch == "unset" {
 		c.Arch = ""
 	}
+	if !inslice.HasString([]string{containerRuntimeDocker, containerRuntimePodman, containerRuntimeNerdctl}, c.ContainerRuntime) {
+		return errors.New("container-runtime must be one of: docker, podman, nerdctl")
+	}
 	if c.typeSet != "" {
 		err := c.ExecTypeSet(args)
 		if err != nil {
@@ -96,7 +104,7 @@ func (c *configBackendCmd) Execute(args []string) error {
 		}
 	}
 	fmt.Printf("Config.Backend.Type = %s\n", c.Type)
-	if c.Type != "docker" {
+	if c.Type != "docker" && c.Type != "mock" {
 		fmt.Printf("Config.Backend.SshKeyPath = %s\n", c.SshKeyPath)
 	}
 	if c.Type == "aws" {
@@ -111,6 +119,17 @@ func (c *configBackendCmd) Execute(args []string) error {
 	if c.Type == "docker" && c.Arch != "" {
 		fmt.Printf("Config.Backend.Arch = %s\n", c.Arch)
 	}
+	if c.Type == "docker" {
+		fmt.Printf("Config.Backend.ContainerRuntime = %s\n", c.ContainerRuntime)
+		if c.ContainerRuntime != containerRuntimeNerdctl {
+			fmt.Printf("Config.Backend.ContainerSocket = %s\n", c.ContainerSocket)
+		}
+	}
+	if c.Type == "mock" {
+		fmt.Printf("Config.Backend.MockStateFile = %s\n", c.MockStateFile)
+		fmt.Printf("Config.Backend.MockHandlersFile = %s\n", c.MockHandlersFile)
+	}
+	fmt.Printf("Config.Backend.CacheTTL = %s\n", c.CacheTTL)
 	fmt.Printf("Config.Backend.TmpDir = %s\n", c.TmpDir)
 	return nil
 }
@@ -150,8 +169,8 @@ func (c *configBackendCmd) ExecTypeSet(args []string) error {
 				return err
 			}
 		}
-	} else if c.Type != "docker" && c.Type != "none" {
-		return errors.New("backend types supported: docker, aws, gcp")
+	} else if c.Type != "docker" && c.Type != "mock" && c.Type != "none" {
+		return errors.New("backend types supported: docker, aws, gcp, mock")
 	}
 	if c.TmpDir == "" {
 		out, err := exec.Command("uname", "-r").CombinedOutput()
@@ -3,9 +3,16 @@ package main
 import "os"
 
 type dataCmd struct {
-	Insert dataInsertCmd `command:"insert" subcommands-optional:"true" description:"Insert data into an Aerospike cluster" webicon:"fas fa-circle-plus"`
-	Delete dataDeleteCmd `command:"delete" subcommands-optional:"true" description:"Delete data inserted via AeroLab" webicon:"fas fa-circle-minus"`
-	Help   helpCmd       `command:"help" subcommands-optional:"true" description:"Print help"`
+	Insert   dataInsertCmd   `command:"insert" subcommands-optional:"true" description:"Insert data into an Aerospike cluster" webicon:"fas fa-circle-plus"`
+	Delete   dataDeleteCmd   `command:"delete" subcommands-optional:"true" description:"Delete data inserted via AeroLab" webicon:"fas fa-circle-minus"`
+	Workload dataWorkloadCmd `command:"workload" subcommands-optional:"true" description:"Run a read/write workload described by a YAML spec" webicon:"fas fa-gauge-high"`
+	Export   dataExportCmd   `command:"export" subcommands-optional:"true" description:"Export a namespace or set to a compressed local file" webicon:"fas fa-file-export"`
+	Import   dataImportCmd   `command:"import" subcommands-optional:"true" description:"Import a file created by data export" webicon:"fas fa-file-import"`
+	Copy     dataCopyCmd     `command:"copy" subcommands-optional:"true" description:"Copy a namespace or set from one cluster to another" webicon:"fas fa-copy"`
+	Sindex   dataSindexCmd   `command:"sindex" subcommands-optional:"true" description:"Create, drop and list secondary indexes" webicon:"fas fa-list-ol"`
+	Udf      dataUdfCmd      `command:"udf" subcommands-optional:"true" description:"Register, remove and list UDF modules" webicon:"fas fa-code"`
+	Verify   dataVerifyCmd   `command:"verify" subcommands-optional:"true" description:"Verify that the writes recorded by data insert --manifest survived" webicon:"fas fa-clipboard-check"`
+	Help     helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
 func (c *dataCmd) Execute(args []string) error {
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dataAdapter is implemented once per supported aerospike client library version; the data commands are written against it
+type dataAdapter interface {
+	// Put writes a single bin to the record, using the TTL and exists action of the config
+	Put(key *dataKey, bin string, value interface{}) error
+	// PutGen is Put, also reading back the record generation after the write
+	PutGen(key *dataKey, bin string, value interface{}) (uint32, error)
+	// Get reads the record
+	Get(key *dataKey) error
+	// Delete deletes the record, durably if set in the config
+	Delete(key *dataKey) error
+	// Nodes returns the names of the cluster nodes
+	Nodes() []string
+	// Info runs an info command against the given node
+	Info(node string, command string) (string, error)
+	Close()
+}
+
+// dataKey is a record key; if Digest is set, the record is addressed by digest only
+type dataKey struct {
+	Namespace string
+	Set       string
+	Pk        string
+	Digest    []byte
+}
+
+// dataAdapterConfig holds the connection and policy settings for creating a dataAdapter
+type dataAdapterConfig struct {
+	Host          string
+	Port          int
+	User          string
+	Pass          string
+	AuthExternal  bool
+	TlsConfig     *tls.Config
+	Timeout       time.Duration
+	MaxRetries    int
+	TTL           int // -1=server default, 0=don't expire
+	ExistsAction  string
+	DurableDelete bool
+	WarmUp        int
+}
+
+// dataAdapters maps the --version option to the adapter of the client library version
+var dataAdapters = map[TypeClientVersion]func(cfg *dataAdapterConfig) (dataAdapter, error){
+	"8": newDataAdapterV8,
+	"7": newDataAdapterV7,
+	"5": newDataAdapterV5,
+	"4": newDataAdapterV4,
+}
+
+// connect creates the adapter of the selected library version and connects to the seed node
+func (c *dataInsertCommonCmd) connect(seedNode string, cfg *dataAdapterConfig) (dataAdapter, error) {
+	newAdapter, ok := dataAdapters[c.Version]
+	if !ok {
+		return nil, fmt.Errorf("aerospike client version %s does not exist", c.Version)
+	}
+	host, portString, err := net.SplitHostPort(seedNode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process SeedNode, must be IP:PORT: %s", seedNode)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return nil, fmt.Errorf("error processing SeedNodePort: %s: %s", portString, err)
+	}
+	cfg.Host = host
+	cfg.Port = port
+	cfg.User = c.User
+	cfg.Pass = c.Pass
+	cfg.AuthExternal = c.AuthExternal
+	cfg.TlsConfig, err = dataTlsConfig(c.TlsCaCert, c.TlsClientCert, c.TlsServerName)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	client, err := newAdapter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting: %s", err)
+	}
+	return client, nil
+}
+
+// dataTlsConfig returns the TLS config for the given certificate files, or nil if TLS is not in use
+func dataTlsConfig(caCert string, clientCert string, serverName string) (*tls.Config, error) {
+	if caCert == "" && clientCert == "" {
+		return nil, nil
+	}
+	tlsconfig := &tls.Config{ServerName: serverName}
+	if caCert != "" {
+		cacertpool := x509.NewCertPool()
+		ncertfile, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ca cert: %s", err)
+		}
+		cacertpool.AppendCertsFromPEM(ncertfile)
+		tlsconfig.RootCAs = cacertpool
+	}
+	if clientCert != "" {
+		clientcertpool := x509.NewCertPool()
+		ncertfile, err := os.ReadFile(clientCert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read client cert: %s", err)
+		}
+		clientcertpool.AppendCertsFromPEM(ncertfile)
+		tlsconfig.ClientCAs = clientcertpool
+	}
+	return tlsconfig, nil
+}
+
+// dataRetry runs do, retrying a few times on client timeouts
+func dataRetry(name string, do func() error) error {
+	err := do()
+	for retry := 0; err != nil && retry < 5; retry++ {
+		if !strings.Contains(err.Error(), "i/o timeout") && !strings.Contains(err.Error(), "command execution timed out on client") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+		err = do()
+	}
+	if err != nil {
+		log.Printf("WARN %s error, giving up: %s", name, err)
+	}
+	return err
+}
+
+// dataMasterPartitions returns the partitions of the namespace mastered by each of the given comma-separated nodes, or all nodes if none match, taking at most count partitions across all the nodes
+func dataMasterPartitions(client dataAdapter, namespace string, nodes string, count int) ([]int, error) {
+	nodeList := []string{}
+	for _, node := range strings.Split(nodes, ",") {
+		node = strings.ToLower(strings.Trim(node, "\n\t\r ,"))
+		if node == "" {
+			continue
+		}
+		for _, realNode := range client.Nodes() {
+			if strings.ToLower(realNode) == node {
+				nodeList = append(nodeList, realNode)
+			}
+		}
+	}
+	if len(nodeList) == 0 {
+		nodeList = client.Nodes()
+	}
+	if len(nodeList) == 0 {
+		return nil, fmt.Errorf("no cluster nodes found")
+	}
+	partitionsPerNode := count / len(nodeList)
+	partitions := []int{}
+	for _, node := range nodeList {
+		partitionInfo, err := client.Info(node, "partition-info")
+		if err != nil {
+			return nil, fmt.Errorf("partition-info: %s", err)
+		}
+		nodePartitions := 0
+		for _, partition := range strings.Split(partitionInfo, ";") {
+			partitionSplit := strings.Split(partition, ":")
+			if len(partitionSplit) < 7 || partitionSplit[0] != namespace || partitionSplit[4] != "0" || !strings.EqualFold(node, partitionSplit[6]) {
+				continue
+			}
+			pNo, err := strconv.Atoi(partitionSplit[1])
+			if err != nil {
+				continue
+			}
+			if nodePartitions < partitionsPerNode || partitionsPerNode == 0 {
+				partitions = append(partitions, pNo)
+				nodePartitions++
+			}
+		}
+	}
+	return partitions, nil
+}
+
+// dataRun calls do for each PK number from start to end, printing progress; with threads set, do runs on that many threads and errors are logged, otherwise the first error stops the run
+func dataRun(name string, action string, start int, end int, threads int, do func(i int) error) error {
+	total := end - start + 1
+	var processed atomic.Int64
+	var sem chan struct{}
+	if threads > 0 {
+		sem = make(chan struct{}, threads)
+	}
+	startTime := time.Now()
+	esc := "                   \r"
+	if isWebRun {
+		esc = "\n"
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			nsec := int64(time.Since(startTime).Seconds())
+			var ttkn int64
+			if nsec > 0 {
+				ttkn = processed.Load() / nsec
+			}
+			fmt.Printf("Total records: %d , %s: %d , Subthreads running: %d , Records per second: %d"+esc, total, action, processed.Load(), len(sem), ttkn)
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	wg := new(sync.WaitGroup)
+	for i := start; i <= end; i++ {
+		if threads == 0 {
+			err := do(i)
+			if err != nil {
+				close(stop)
+				<-stopped
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		} else {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				err := do(i)
+				if err != nil {
+					log.Printf("WARN %s error while multithreading: %s", name, err)
+				}
+				<-sem
+			}(i)
+		}
+		processed.Add(1)
+	}
+	wg.Wait()
+	close(stop)
+	<-stopped
+	runTime := time.Since(startTime)
+	rps := total
+	if int(runTime.Seconds()) > 0 {
+		rps = total / int(runTime.Seconds())
+	}
+	fmt.Printf("Total records: %d , %s: %d                                                                \nTime taken: %s , Records per second: %d\n", total, action, processed.Load(), runTime.String(), rps)
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aerospike/aerospike-client-go/v7"
+)
+
+type dataAdapterV7 struct {
+	client *aerospike.Client
+	wp     *aerospike.WritePolicy
+	rp     *aerospike.BasePolicy
+}
+
+func newDataAdapterV7(cfg *dataAdapterConfig) (dataAdapter, error) {
+	policy := aerospike.NewClientPolicy()
+	if cfg.User != "" {
+		policy.User = cfg.User
+		policy.Password = cfg.Pass
+		if cfg.AuthExternal {
+			policy.AuthMode = aerospike.AuthModeExternal
+		} else {
+			policy.AuthMode = aerospike.AuthModeInternal
+		}
+	}
+	policy.TlsConfig = cfg.TlsConfig
+	client, err := aerospike.NewClientWithPolicy(policy, cfg.Host, cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.WarmUp > 0 {
+		client.WarmUp(cfg.WarmUp)
+	}
+	a := &dataAdapterV7{
+		client: client,
+		wp:     aerospike.NewWritePolicy(0, aerospike.TTLServerDefault),
+		rp:     aerospike.NewPolicy(),
+	}
+	if cfg.TTL == 0 {
+		a.wp.Expiration = aerospike.TTLDontExpire
+	} else if cfg.TTL > 0 {
+		a.wp.Expiration = uint32(cfg.TTL)
+	}
+	a.wp.TotalTimeout = cfg.Timeout
+	a.wp.SocketTimeout = 0
+	a.wp.MaxRetries = cfg.MaxRetries
+	a.wp.DurableDelete = cfg.DurableDelete
+	switch cfg.ExistsAction {
+	case "UPDATE":
+		a.wp.RecordExistsAction = aerospike.UPDATE
+	case "UPDATE_ONLY":
+		a.wp.RecordExistsAction = aerospike.UPDATE_ONLY
+	case "REPLACE":
+		a.wp.RecordExistsAction = aerospike.REPLACE
+	case "REPLACE_ONLY":
+		a.wp.RecordExistsAction = aerospike.REPLACE_ONLY
+	case "CREATE_ONLY":
+		a.wp.RecordExistsAction = aerospike.CREATE_ONLY
+	}
+	a.rp.TotalTimeout = cfg.Timeout
+	a.rp.SocketTimeout = 0
+	a.rp.MaxRetries = cfg.MaxRetries
+	return a, nil
+}
+
+func (a *dataAdapterV7) key(key *dataKey) (*aerospike.Key, error) {
+	if key.Digest != nil {
+		return aerospike.NewKeyWithDigest(key.Namespace, key.Set, nil, key.Digest)
+	}
+	return aerospike.NewKey(key.Namespace, key.Set, key.Pk)
+}
+
+func (a *dataAdapterV7) Put(key *dataKey, bin string, value interface{}) error {
+	k, err := a.key(key)
+	if err != nil {
+		return err
+	}
+	return a.client.PutBins(a.wp, k, aerospike.NewBin(bin, value))
+}
+
+func (a *dataAdapterV7) PutGen(key *dataKey, bin string, value interface{}) (uint32, error) {
+	k, err := a.key(key)
+	if err != nil {
+		return 0, err
+	}
+	if a.wp.RecordExistsAction == aerospike.REPLACE || a.wp.RecordExistsAction == aerospike.REPLACE_ONLY {
+		// the server does not allow read operations with replace, so the header is read after the write
+		err = a.client.PutBins(a.wp, k, aerospike.NewBin(bin, value))
+		if err != nil {
+			return 0, err
+		}
+		rec, err := a.client.GetHeader(a.rp, k)
+		if err != nil {
+			return 0, err
+		}
+		return rec.Generation, nil
+	}
+	rec, err := a.client.Operate(a.wp, k, aerospike.PutOp(aerospike.NewBin(bin, value)), aerospike.GetHeaderOp())
+	if err != nil {
+		return 0, err
+	}
+	return rec.Generation, nil
+}
+
+func (a *dataAdapterV7) Get(key *dataKey) error {
+	k, err := a.key(key)
+	if err != nil {
+		return err
+	}
+	_, err = a.client.Get(a.rp, k)
+	return err
+}
+
+func (a *dataAdapterV7) Delete(key *dataKey) error {
+	k, err := a.key(key)
+	if err != nil {
+		return err
+	}
+	_, err = a.client.Delete(a.wp, k)
+	return err
+}
+
+func (a *dataAdapterV7) Nodes() []string {
+	nodes := []string{}
+	for _, node := range a.client.GetNodes() {
+		nodes = append(nodes, node.GetName())
+	}
+	return nodes
+}
+
+func (a *dataAdapterV7) Info(node string, command string) (string, error) {
+	for _, n := range a.client.GetNodes() {
+		if n.GetName() != node {
+			continue
+		}
+		out, err := n.RequestInfo(aerospike.NewInfoPolicy(), command)
+		if err != nil {
+			return "", err
+		}
+		return out[command], nil
+	}
+	return "", fmt.Errorf("node %s not found", node)
+}
+
+func (a *dataAdapterV7) Close() {
+	a.client.Close()
+}
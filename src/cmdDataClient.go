@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+)
+
+// dataClientCmd holds the connection options of the data commands which only use the current aerospike client library
+type dataClientCmd struct {
+	RunDirect     bool   `short:"d" long:"run-direct" description:"If set, will ignore backend, cluster name and node ID and connect to SeedNode directly from running machine" simplemode:"false"`
+	User          string `short:"U" long:"username" description:"If set, will use this user to authenticate to aerospike cluster; default: the credentials stored for the cluster by 'security enable' or 'security user create --save'" default:""`
+	Pass          string `short:"P" long:"password" description:"If set, will use this pass to authenticate to aerospike cluster" webtype:"password" default:""`
+	AuthExternal  bool   `short:"Q" long:"auth-external" description:"if set, will use external auth method"`
+	TlsCaCert     string `short:"y" long:"tls-ca-cert" description:"Tls CA certificate path" default:""`
+	TlsClientCert string `short:"w" long:"tls-client-cert" description:"Tls client certificate path" default:""`
+	TlsServerName string `short:"i" long:"tls-server-name" description:"Tls ServerName" default:""`
+	dataInsertSelectorCmd
+}
+
+// run runs the command on a node, passing it the marshalled command struct, or if run-direct is set, connects to the seed node and calls do
+func (c *dataClientCmd) run(command []string, cmdStruct interface{}, do func(client *aerospike.Client) error) error {
+	if c.RunJson != "" {
+		jf, err := os.ReadFile(c.RunJson)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(jf, cmdStruct)
+		if err != nil {
+			return err
+		}
+	}
+	if c.RunDirect {
+		client, err := c.connect()
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		return do(client)
+	}
+	err := c.prepare()
+	if err != nil {
+		return err
+	}
+	return c.runRemote(command, cmdStruct)
+}
+
+// prepare initialises the backend and resolves the credentials and seed node for running the command on a node
+func (c *dataClientCmd) prepare() error {
+	if b == nil {
+		return logFatal("Invalid backend")
+	}
+	err := b.Init()
+	if err != nil {
+		return logFatal("Could not init backend: %s", err)
+	}
+	if c.User == "" {
+		creds, err := securityLoadCreds(c.ClusterName.String())
+		if err != nil {
+			return err
+		}
+		if creds != nil {
+			c.User = creds.User
+			c.Pass = creds.Password
+			c.AuthExternal = creds.AuthExternal
+		}
+	}
+	err = c.resolveSeedNode()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// runRemote runs the command on the selected node, passing it the marshalled command struct
+func (c *dataClientCmd) runRemote(command []string, cmdStruct interface{}) error {
+	c.RunDirect = true
+	data, err := json.Marshal(cmdStruct)
+	if err != nil {
+		return err
+	}
+	return c.unpackCommand(command, data)
+}
+
+func (c *dataClientCmd) connect() (*aerospike.Client, error) {
+	host, portString, err := net.SplitHostPort(c.SeedNode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process SeedNode, must be IP:PORT: %s", c.SeedNode)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return nil, fmt.Errorf("error processing SeedNodePort: %s: %s", portString, err)
+	}
+	policy := aerospike.NewClientPolicy()
+	if c.User != "" {
+		policy.User = c.User
+		policy.Password = c.Pass
+		policy.AuthMode = aerospike.AuthModeInternal
+		if c.AuthExternal {
+			policy.AuthMode = aerospike.AuthModeExternal
+		}
+	}
+	policy.TlsConfig, err = dataTlsConfig(c.TlsCaCert, c.TlsClientCert, c.TlsServerName)
+	if err != nil {
+		return nil, err
+	}
+	client, err := aerospike.NewClientWithPolicy(policy, host, port)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting: %s", err)
+	}
+	return client, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync/atomic"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+)
+
+type dataCopyCmd struct {
+	Destination          TypeClusterName `short:"D" long:"destination" description:"Cluster to copy to" webrequired:"true"`
+	Namespace            string          `short:"m" long:"namespace" description:"Namespace name" default:"test"`
+	Set                  string          `short:"e" long:"set" description:"Set name; default: copy all sets of the namespace" default:""`
+	DestinationNamespace string          `short:"M" long:"destination-namespace" description:"Namespace to copy into; default: same as the source namespace" default:""`
+	Threads              int             `short:"u" long:"threads" description:"Number of parallel partition scans, each writing its records to the destination" default:"8"`
+	DestinationSeed      string          `long:"destination-seed" description:"Destination seed node IP:PORT; default: the first node of the destination cluster; required with --run-direct"`
+	Dst                  *dataClientCmd  `no-flag:"true"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *dataCopyCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running data.copy")
+	if c.RunJson != "" {
+		return c.run([]string{"data", "copy"}, c, c.copy)
+	}
+	if c.DestinationNamespace == "" {
+		c.DestinationNamespace = c.Namespace
+	}
+	if c.RunDirect && c.DestinationSeed == "" {
+		return errors.New("--destination-seed must be specified with --run-direct")
+	}
+	if !c.RunDirect && c.Destination == "" {
+		return errors.New("destination cluster must be specified with -D")
+	}
+	sameCluster := c.ClusterName == c.Destination
+	if c.RunDirect {
+		sameCluster = c.SeedNode == c.DestinationSeed
+	}
+	if sameCluster && c.Namespace == c.DestinationNamespace {
+		return errors.New("source and destination are the same cluster and namespace")
+	}
+	// the credentials and TLS options apply to both clusters; credentials not given are looked up separately for each cluster
+	c.Dst = &dataClientCmd{
+		User:          c.User,
+		Pass:          c.Pass,
+		AuthExternal:  c.AuthExternal,
+		TlsCaCert:     c.TlsCaCert,
+		TlsClientCert: c.TlsClientCert,
+		TlsServerName: c.TlsServerName,
+	}
+	c.Dst.ClusterName = c.Destination
+	c.Dst.Node = 1
+	c.Dst.SeedNode = c.DestinationSeed
+	if c.RunDirect {
+		err := c.run([]string{"data", "copy"}, c, c.copy)
+		if err != nil {
+			return err
+		}
+		log.Print("Done")
+		return nil
+	}
+	err := c.prepare()
+	if err != nil {
+		return err
+	}
+	if c.Dst.User == "" {
+		creds, err := securityLoadCreds(c.Destination.String())
+		if err != nil {
+			return err
+		}
+		if creds != nil {
+			c.Dst.User = creds.User
+			c.Dst.Pass = creds.Password
+			c.Dst.AuthExternal = creds.AuthExternal
+		}
+	}
+	// the copy runs on the source node, so the destination is reached on the internal IP of its first node
+	if c.DestinationSeed == "" {
+		ips, err := b.GetNodeIpMap(c.Destination.String(), true)
+		if err != nil {
+			return err
+		}
+		if len(ips) == 0 {
+			return fmt.Errorf("destination cluster %s not found", c.Destination)
+		}
+		nodes := []int{}
+		for node := range ips {
+			nodes = append(nodes, node)
+		}
+		sort.Ints(nodes)
+		c.Dst.Node = TypeNode(nodes[0])
+		c.Dst.SeedNode = "127.0.0.1:3000"
+		seed, err := c.Dst.checkSeedPort()
+		if err != nil {
+			return err
+		}
+		_, port, err := net.SplitHostPort(seed)
+		if err != nil {
+			return err
+		}
+		c.Dst.SeedNode = net.JoinHostPort(ips[nodes[0]], port)
+	}
+	b.WorkOnServers()
+	log.Printf("Copying %s:%s to %s:%s, seed %s", c.ClusterName, c.Namespace, c.Destination, c.DestinationNamespace, c.Dst.SeedNode)
+	err = c.runRemote([]string{"data", "copy"}, c)
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *dataCopyCmd) copy(src *aerospike.Client) error {
+	log.Printf("source=%s namespace=%s set=%s destination=%s destination_namespace=%s threads=%d", c.SeedNode, c.Namespace, c.Set, c.Dst.SeedNode, c.DestinationNamespace, c.Threads)
+	dst, err := c.Dst.connect()
+	if err != nil {
+		return fmt.Errorf("destination: %s", err)
+	}
+	defer dst.Close()
+	w := newDataWriter(dst, c.DestinationNamespace)
+	count := new(atomic.Int64)
+	failed := new(atomic.Int64)
+	stop := dataProgress("Copied", count)
+	err = dataScan(src, c.Namespace, c.Set, c.Threads, func(rec *aerospike.Record) error {
+		written, err := w.put(dataExportFromRecord(rec), 0)
+		if err != nil {
+			failed.Add(1)
+		} else if written {
+			count.Add(1)
+		}
+		return nil
+	})
+	stop()
+	if err != nil {
+		return err
+	}
+	if failed.Load() > 0 {
+		return fmt.Errorf("failed to write %d records", failed.Load())
+	}
+	return nil
+}
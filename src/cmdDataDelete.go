@@ -2,7 +2,7 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -41,18 +41,7 @@ func (c *dataDeleteCmd) delete(args []string) error {
 		var err error
 		log.Print("Delete start")
 		log.Printf("namespace=%s set=%s pk_start_key=%s%d pk_end_key=%s%d", c.Namespace, c.Set, c.PkPrefix, c.PkStartNumber, c.PkPrefix, c.PkEndNumber)
-		switch c.Version {
-		case "8":
-			err = c.delete7(args)
-		case "7":
-			err = c.delete6(args)
-		case "5":
-			err = c.delete5(args)
-		case "4":
-			err = c.delete4(args)
-		default:
-			err = errors.New("aerospike client version does not exist")
-		}
+		err = c.deleteData()
 		if err == nil {
 			log.Print("Delete done")
 		}
@@ -65,21 +54,13 @@ func (c *dataDeleteCmd) delete(args []string) error {
 	if err != nil {
 		return logFatal("Could not init backend: %s", err)
 	}
-	seedNode, err := c.checkSeedPort()
+	err = c.loadCredentials(c.ClusterName.String())
 	if err != nil {
 		return err
 	}
-	if a.opts.Config.Backend.Type == "docker" {
-		found := false
-		for _, arg := range os.Args[1:] {
-			if strings.HasPrefix(arg, "-g") || strings.HasPrefix(arg, "--seed-node") {
-				found = true
-				break
-			}
-		}
-		if !found {
-			c.SeedNode = seedNode
-		}
+	err = c.resolveSeedNode()
+	if err != nil {
+		return err
 	}
 	log.Print("Unpacking start")
 	c.RunDirect = true
@@ -94,6 +75,43 @@ func (c *dataDeleteCmd) delete(args []string) error {
 	return nil
 }
 
+// deleteData deletes the records from this machine, using the selected client library version
+func (c *dataDeleteCmd) deleteData() error {
+	client, err := c.connect(c.SeedNode, &dataAdapterConfig{
+		MaxRetries:    2,
+		TTL:           -1,
+		DurableDelete: c.Durable,
+		WarmUp:        100,
+	})
+	if err != nil {
+		return fmt.Errorf("delete-data: %s", err)
+	}
+	defer client.Close()
+	return dataRun("delete-data", "Deleted", c.PkStartNumber, c.PkEndNumber, c.UseMultiThreaded, func(i int) error {
+		key := &dataKey{Namespace: c.Namespace, Set: c.Set, Pk: fmt.Sprintf("%s%d", c.PkPrefix, i)}
+		dataRetry("Client.Delete", func() error { return client.Delete(key) })
+		return nil
+	})
+}
+
+// resolveSeedNode points the seed node at the exposed port of the selected node on docker, unless a seed node was given with -g
+func (c *dataInsertSelectorCmd) resolveSeedNode() error {
+	seedNode, err := c.checkSeedPort()
+	if err != nil {
+		return err
+	}
+	if a.opts.Config.Backend.Type != "docker" {
+		return nil
+	}
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "-g") || strings.HasPrefix(arg, "--seed-node") {
+			return nil
+		}
+	}
+	c.SeedNode = seedNode
+	return nil
+}
+
 func (c *dataInsertSelectorCmd) checkSeedPort() (string, error) {
 	if a.opts.Config.Backend.Type != "docker" {
 		return c.SeedNode, nil
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+	"github.com/lithammer/shortuuid"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type dataExportCmd struct {
+	Namespace string         `short:"m" long:"namespace" description:"Namespace name" default:"test"`
+	Set       string         `short:"s" long:"set" description:"Set name; default: export all sets of the namespace" default:""`
+	Output    flags.Filename `short:"o" long:"output" description:"File to export to; default: NAMESPACE[.SET].aerolab.gz"`
+	Threads   int            `short:"u" long:"threads" description:"Number of parallel partition scans" default:"8"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// dataExportFormat identifies aerolab export files; the file is a gzip-compressed gob stream of a dataExportHeader followed by dataExportRecords
+const dataExportFormat = "aerolab-data-export"
+
+const dataExportVersion = 1
+
+type dataExportHeader struct {
+	Format    string
+	Version   int
+	Namespace string
+	Set       string
+	Created   time.Time
+}
+
+type dataExportRecord struct {
+	Digest     []byte
+	Key        interface{} // the user key, if it was stored with the record
+	Set        string
+	Generation uint32
+	Expiration uint32 // remaining TTL in seconds when exported
+	Bins       map[string]interface{}
+}
+
+func init() {
+	// concrete types the client can return inside bin values
+	gob.Register([]interface{}{})
+	gob.Register(map[interface{}]interface{}{})
+	gob.Register(aerospike.GeoJSONValue(""))
+	gob.Register(aerospike.HLLValue{})
+}
+
+func (c *dataExportCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running data.export")
+	if c.Output == "" {
+		c.Output = flags.Filename(c.Namespace + ".aerolab.gz")
+		if c.Set != "" {
+			c.Output = flags.Filename(c.Namespace + "." + c.Set + ".aerolab.gz")
+		}
+	}
+	if c.RunJson != "" || c.RunDirect {
+		return c.run([]string{"data", "export"}, c, c.export)
+	}
+	// export to a file on the node, then download it
+	err := c.prepare()
+	if err != nil {
+		return err
+	}
+	local := string(c.Output)
+	remote := "/tmp/aerolab-data-export." + shortuuid.New()
+	c.Output = flags.Filename(remote)
+	defer b.RunCommands(c.ClusterName.String(), [][]string{{"rm", "-f", remote}}, []int{c.Node.Int()})
+	err = c.runRemote([]string{"data", "export"}, c)
+	if err != nil {
+		return err
+	}
+	log.Printf("Downloading export to %s", local)
+	err = b.Download(c.ClusterName.String(), c.Node.Int(), remote, local, false, false)
+	if err != nil {
+		return fmt.Errorf("could not download export file: %s", err)
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *dataExportCmd) export(client *aerospike.Client) error {
+	log.Printf("namespace=%s set=%s output=%s threads=%d", c.Namespace, c.Set, c.Output, c.Threads)
+	f, err := os.Create(string(c.Output))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	enc := gob.NewEncoder(gz)
+	err = enc.Encode(&dataExportHeader{
+		Format:    dataExportFormat,
+		Version:   dataExportVersion,
+		Namespace: c.Namespace,
+		Set:       c.Set,
+		Created:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	count := new(atomic.Int64)
+	stop := dataProgress("Exported", count)
+	lock := new(sync.Mutex)
+	err = dataScan(client, c.Namespace, c.Set, c.Threads, func(rec *aerospike.Record) error {
+		lock.Lock()
+		defer lock.Unlock()
+		err := enc.Encode(dataExportFromRecord(rec))
+		if err != nil {
+			return fmt.Errorf("could not encode record %x: %s", rec.Key.Digest(), err)
+		}
+		count.Add(1)
+		return nil
+	})
+	stop()
+	if err != nil {
+		return err
+	}
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// dataExportFromRecord converts a scanned record to its export form
+func dataExportFromRecord(rec *aerospike.Record) *dataExportRecord {
+	r := &dataExportRecord{
+		Digest:     rec.Key.Digest(),
+		Set:        rec.Key.SetName(),
+		Generation: rec.Generation,
+		Expiration: rec.Expiration,
+		Bins:       rec.Bins,
+	}
+	if rec.Key.Value() != nil {
+		r.Key = rec.Key.Value().GetObject()
+	}
+	return r
+}
+
+// dataExportReader reads the header of an export file and returns a function returning the next record, or io.EOF at the end
+func dataExportReader(r io.Reader) (*dataExportHeader, func() (*dataExportRecord, error), error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not an aerolab export file: %s", err)
+	}
+	dec := gob.NewDecoder(gz)
+	header := &dataExportHeader{}
+	err = dec.Decode(header)
+	if err != nil || header.Format != dataExportFormat {
+		return nil, nil, errors.New("not an aerolab export file")
+	}
+	if header.Version > dataExportVersion {
+		return nil, nil, fmt.Errorf("export file version %d is newer than supported by this version of aerolab", header.Version)
+	}
+	return header, func() (*dataExportRecord, error) {
+		rec := &dataExportRecord{}
+		err := dec.Decode(rec)
+		if err != nil {
+			return nil, err
+		}
+		return rec, nil
+	}, nil
+}
+
+// dataScan scans the namespace and set, splitting the partitions between the given number of parallel scans; do is called from all the scans concurrently
+func dataScan(client *aerospike.Client, namespace string, set string, threads int, do func(rec *aerospike.Record) error) error {
+	if threads < 1 {
+		threads = 1
+	}
+	if threads > 4096 {
+		threads = 4096
+	}
+	var scanErr error
+	errLock := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	begin := 0
+	for i := 0; i < threads; i++ {
+		count := 4096 / threads
+		if i < 4096%threads {
+			count++
+		}
+		wg.Add(1)
+		go func(begin int, count int) {
+			defer wg.Done()
+			err := func() error {
+				rs, err := client.ScanPartitions(aerospike.NewScanPolicy(), aerospike.NewPartitionFilterByRange(begin, count), namespace, set)
+				if err != nil {
+					return err
+				}
+				defer rs.Close()
+				for res := range rs.Results() {
+					if res.Err != nil {
+						return res.Err
+					}
+					err := do(res.Record)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}()
+			if err != nil {
+				errLock.Lock()
+				if scanErr == nil {
+					scanErr = fmt.Errorf("scan of partitions %d-%d: %s", begin, begin+count-1, err)
+				}
+				errLock.Unlock()
+			}
+		}(begin, count)
+		begin += count
+	}
+	wg.Wait()
+	return scanErr
+}
+
+// dataWriter writes exported records, keeping their digest and remaining TTL
+type dataWriter struct {
+	client    *aerospike.Client
+	namespace string
+	policy    *aerospike.WritePolicy
+}
+
+func newDataWriter(client *aerospike.Client, namespace string) *dataWriter {
+	wp := aerospike.NewWritePolicy(0, aerospike.TTLServerDefault)
+	wp.RecordExistsAction = aerospike.REPLACE
+	wp.TotalTimeout = 5 * time.Second
+	wp.MaxRetries = 2
+	return &dataWriter{
+		client:    client,
+		namespace: namespace,
+		policy:    wp,
+	}
+}
+
+// put writes the record; age is the time since the record was exported, records which expired in that time are skipped and false is returned
+func (w *dataWriter) put(rec *dataExportRecord, age time.Duration) (bool, error) {
+	wp := *w.policy
+	if rec.Expiration == 0 || rec.Expiration == aerospike.TTLDontExpire {
+		wp.Expiration = aerospike.TTLDontExpire
+	} else {
+		remaining := int64(rec.Expiration) - int64(age.Seconds())
+		if remaining <= 0 {
+			return false, nil
+		}
+		wp.Expiration = uint32(remaining)
+	}
+	wp.SendKey = rec.Key != nil
+	key, kerr := aerospike.NewKeyWithDigest(w.namespace, rec.Set, rec.Key, rec.Digest)
+	if kerr != nil {
+		return false, kerr
+	}
+	err := dataRetry("Client.Put", func() error {
+		return w.client.Put(&wp, key, rec.Bins)
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// dataProgress prints the number of records processed every second, until the returned stop function is called
+func dataProgress(action string, count *atomic.Int64) (stop func()) {
+	startTime := time.Now()
+	esc := "                   \r"
+	if isWebRun {
+		esc = "\n"
+	}
+	stopChan := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-ticker.C:
+			}
+			fmt.Printf("%s: %d , Records per second: %d"+esc, action, count.Load(), count.Load()/int64(time.Since(startTime).Seconds()))
+		}
+	}()
+	return func() {
+		close(stopChan)
+		<-stopped
+		runTime := time.Since(startTime)
+		rps := count.Load()
+		if int64(runTime.Seconds()) > 0 {
+			rps = count.Load() / int64(runTime.Seconds())
+		}
+		fmt.Printf("%s: %d                                                                \nTime taken: %s , Records per second: %d\n", action, count.Load(), runTime.String(), rps)
+	}
+}
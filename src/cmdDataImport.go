@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+	"github.com/lithammer/shortuuid"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type dataImportCmd struct {
+	Input     flags.Filename `short:"f" long:"input" description:"File created by 'data export' to import" webrequired:"true"`
+	Namespace string         `short:"m" long:"namespace" description:"Namespace to import into; default: the namespace the data was exported from" default:""`
+	Threads   int            `short:"u" long:"threads" description:"Number of parallel writers" default:"8"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *dataImportCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running data.import")
+	if c.RunJson != "" || c.RunDirect {
+		return c.run([]string{"data", "import"}, c, c.dataImport)
+	}
+	if c.Input == "" {
+		return fmt.Errorf("input file must be specified with -f")
+	}
+	if _, err := os.Stat(string(c.Input)); err != nil {
+		return err
+	}
+	// upload the file to the node and import from there
+	err := c.prepare()
+	if err != nil {
+		return err
+	}
+	remote := "/tmp/aerolab-data-import." + shortuuid.New()
+	defer b.RunCommands(c.ClusterName.String(), [][]string{{"rm", "-f", remote}}, []int{c.Node.Int()})
+	log.Printf("Uploading %s", c.Input)
+	err = b.Upload(c.ClusterName.String(), c.Node.Int(), string(c.Input), remote, false, false)
+	if err != nil {
+		return fmt.Errorf("could not upload import file: %s", err)
+	}
+	c.Input = flags.Filename(remote)
+	err = c.runRemote([]string{"data", "import"}, c)
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *dataImportCmd) dataImport(client *aerospike.Client) error {
+	f, err := os.Open(string(c.Input))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	header, next, err := dataExportReader(f)
+	if err != nil {
+		return err
+	}
+	if c.Namespace == "" {
+		c.Namespace = header.Namespace
+	}
+	log.Printf("exported=%s source_namespace=%s source_set=%s namespace=%s threads=%d", header.Created.Format(time.RFC3339), header.Namespace, header.Set, c.Namespace, c.Threads)
+	age := time.Since(header.Created)
+	w := newDataWriter(client, c.Namespace)
+	count := new(atomic.Int64)
+	expired := new(atomic.Int64)
+	failed := new(atomic.Int64)
+	threads := c.Threads
+	if threads < 1 {
+		threads = 1
+	}
+	records := make(chan *dataExportRecord, threads*2)
+	wg := new(sync.WaitGroup)
+	stop := dataProgress("Imported", count)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range records {
+				written, err := w.put(rec, age)
+				switch {
+				case err != nil:
+					failed.Add(1)
+				case written:
+					count.Add(1)
+				default:
+					expired.Add(1)
+				}
+			}
+		}()
+	}
+	var readErr error
+	for {
+		rec, err := next()
+		if err != nil {
+			if err != io.EOF {
+				readErr = fmt.Errorf("could not read export file: %s", err)
+			}
+			break
+		}
+		records <- rec
+	}
+	close(records)
+	wg.Wait()
+	stop()
+	if expired.Load() > 0 {
+		log.Printf("Skipped %d records which expired since the export", expired.Load())
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if failed.Load() > 0 {
+		return fmt.Errorf("failed to write %d records", failed.Load())
+	}
+	return nil
+}
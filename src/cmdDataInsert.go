@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,8 +12,10 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/lithammer/shortuuid"
 	flags "github.com/rglonek/jeddevdk-goflags"
@@ -62,6 +65,7 @@ type dataInsertCmd struct {
 	InsertToPartitions    int              `short:"C" long:"to-partitions" description:"insert to X number of partitions at most. to-partitions/to-nodes=partitions-per-node" default:"0" simplemode:"false"`
 	InsertToPartitionList string           `short:"L" long:"to-partition-list" description:"comma-separated list of partition numbers to insert data to. -P and -L  are ignored if this is specified" default:"" simplemode:"false"`
 	ExistsAction          TypeExistsAction `short:"E" long:"exists-action" description:"action policy: CREATE_ONLY | REPLACE_ONLY | REPLACE | UPDATE_ONLY | UPDATE" default:""`
+	Manifest              flags.Filename   `long:"manifest" description:"Record the key, generation and contents digest of each acknowledged write to this file, for 'data verify'" default:""`
 	dataInsertSelectorCmd
 	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
 }
@@ -97,18 +101,7 @@ func (c *dataInsertCmd) insert(args []string) error {
 		} else {
 			log.Printf("namespace=%s set=%s pk_start_key=%s%d pk_end_key=%s%d bin_name=%s ttl=%d read_after_write=%t exists_action=%s", c.Namespace, c.Set, c.PkPrefix, c.PkStartNumber, c.PkPrefix, c.PkEndNumber, c.Bin, c.TTL, c.ReadAfterWrite, c.ExistsAction)
 		}
-		switch c.Version {
-		case "8":
-			err = c.insert7(args)
-		case "7":
-			err = c.insert6(args)
-		case "5":
-			err = c.insert5(args)
-		case "4":
-			err = c.insert4(args)
-		default:
-			err = errors.New("aerospike client version does not exist")
-		}
+		err = c.insertData()
 		if err == nil {
 			log.Print("Insert done")
 		}
@@ -121,21 +114,20 @@ func (c *dataInsertCmd) insert(args []string) error {
 	if err != nil {
 		return logFatal("Could not init backend: %s", err)
 	}
-	seedNode, err := c.checkSeedPort()
+	err = c.loadCredentials(c.ClusterName.String())
 	if err != nil {
 		return err
 	}
-	if a.opts.Config.Backend.Type == "docker" {
-		found := false
-		for _, arg := range os.Args[1:] {
-			if strings.HasPrefix(arg, "-g") || strings.HasPrefix(arg, "--seed-node") {
-				found = true
-				break
-			}
-		}
-		if !found {
-			c.SeedNode = seedNode
-		}
+	err = c.resolveSeedNode()
+	if err != nil {
+		return err
+	}
+	// the manifest is written on the node, then downloaded
+	manifest := string(c.Manifest)
+	if manifest != "" {
+		remote := "/tmp/aerolab-data-manifest." + shortuuid.New()
+		c.Manifest = flags.Filename(remote)
+		defer b.RunCommands(c.ClusterName.String(), [][]string{{"rm", "-f", remote}}, []int{c.Node.Int()})
 	}
 	log.Print("Unpacking start")
 	c.RunDirect = true
@@ -146,11 +138,178 @@ func (c *dataInsertCmd) insert(args []string) error {
 	if err := c.unpack("insert", data); err != nil {
 		return err
 	}
+	if manifest != "" {
+		log.Printf("Downloading manifest to %s", manifest)
+		err = b.Download(c.ClusterName.String(), c.Node.Int(), string(c.Manifest), manifest, false, false)
+		if err != nil {
+			return fmt.Errorf("could not download manifest: %s", err)
+		}
+	}
 	log.Print("Complete")
 	return nil
 }
 
+// insertData inserts the records from this machine, using the selected client library version
+func (c *dataInsertCmd) insertData() error {
+	client, err := c.connect(c.SeedNode, &dataAdapterConfig{
+		MaxRetries:   2,
+		TTL:          c.TTL,
+		ExistsAction: string(c.ExistsAction),
+		WarmUp:       100,
+	})
+	if err != nil {
+		return fmt.Errorf("insert-data: %s", err)
+	}
+	defer client.Close()
+
+	var partitions []int
+	if c.InsertToPartitionList != "" {
+		for _, pTo := range strings.Split(c.InsertToPartitionList, ",") {
+			pToInt, err := strconv.Atoi(pTo)
+			if err != nil {
+				return fmt.Errorf("insert-data: partition list not numeric: %s", err)
+			}
+			partitions = append(partitions, pToInt)
+		}
+	} else if c.InsertToNodes != "" || c.InsertToPartitions != 0 {
+		partitions, err = dataMasterPartitions(client, c.Namespace, c.InsertToNodes, c.InsertToPartitions)
+		if err != nil {
+			return fmt.Errorf("insert-data: %s", err)
+		}
+	}
+
+	var manifest *dataManifestWriter
+	if c.Manifest != "" {
+		manifest, err = newDataManifestWriter(string(c.Manifest))
+		if err != nil {
+			return fmt.Errorf("insert-data: %s", err)
+		}
+	}
+	src := rand.NewSource(time.Now().UnixNano())
+	srcLock := new(sync.Mutex)
+	err = dataRun("insert-data", "Inserted", c.PkStartNumber, c.PkEndNumber, c.UseMultiThreaded, func(i int) error {
+		set, bin, binc, err := c.record(i, src, srcLock)
+		if err != nil {
+			return err
+		}
+		key := &dataKey{Namespace: c.Namespace, Set: set}
+		if len(partitions) > 0 {
+			// records are spread round-robin over the partitions, with the partition number in the first bits of the digest
+			digest := make([]byte, 20)
+			binary.LittleEndian.PutUint32(digest, uint32(partitions[(i-c.PkStartNumber)%len(partitions)]))
+			binary.LittleEndian.PutUint64(digest[2:], uint64(i))
+			key.Digest = digest
+		} else {
+			key.Pk = fmt.Sprintf("%s%d", c.PkPrefix, i)
+		}
+		if manifest == nil {
+			if dataRetry("Client.Put", func() error { return client.Put(key, bin, binc) }) != nil {
+				return nil
+			}
+		} else {
+			// the generation is only read back when it is recorded, to keep the write path of plain inserts unchanged
+			var gen uint32
+			if dataRetry("Client.Put", func() error {
+				var err error
+				gen, err = client.PutGen(key, bin, binc)
+				return err
+			}) != nil {
+				return nil
+			}
+			err = manifest.add(key, gen, map[string]interface{}{bin: binc})
+			if err != nil {
+				return fmt.Errorf("insert-data: manifest: %s", err)
+			}
+		}
+		if c.ReadAfterWrite {
+			dataRetry("Client.Get", func() error { return client.Get(key) })
+		}
+		return nil
+	})
+	if manifest != nil {
+		if cerr := manifest.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("insert-data: manifest: %s", cerr)
+		}
+	}
+	return err
+}
+
+// record returns the set, bin name and bin contents of the record with the given PK number
+func (c *dataInsertCmd) record(i int, src rand.Source, srcLock *sync.Mutex) (set string, bin string, binc string, err error) {
+	setSplit := strings.Split(c.Set, ":")
+	if len(setSplit) == 1 {
+		set = setSplit[0]
+	} else if setSplit[0] == "random" {
+		setSizeNo, err := strconv.Atoi(setSplit[1])
+		if err != nil {
+			return "", "", "", fmt.Errorf("insert-data: Error processing set random: %s", err)
+		}
+		set = RandStringRunes(setSizeNo, src, srcLock)
+	} else {
+		return "", "", "", fmt.Errorf("insert-data: Set name error: %s", setSplit)
+	}
+
+	binData := strings.Split(c.Bin, ":")
+	if len(binData) != 2 {
+		return "", "", "", fmt.Errorf("insert-data: Bin data convert error: %s", binData)
+	}
+	if binData[0] == "static" {
+		bin = binData[1]
+	} else if binData[0] == "random" {
+		binSizeNo, err := strconv.Atoi(binData[1])
+		if err != nil {
+			return "", "", "", fmt.Errorf("insert-data: Bin Size No error: %s", err)
+		}
+		bin = RandStringRunes(binSizeNo, src, srcLock)
+	} else if binData[0] == "unique" {
+		bin = fmt.Sprintf("%s%d", binData[1], i)
+	} else {
+		return "", "", "", fmt.Errorf("insert-data: Bin Name error")
+	}
+
+	bincData := strings.Split(c.BinContents, ":")
+	if len(bincData) != 2 {
+		return "", "", "", errors.New("insert-data: Bin Contents invalid")
+	}
+	if bincData[0] == "static" {
+		binc = bincData[1]
+	} else if bincData[0] == "random" {
+		bincSizeNo, err := strconv.Atoi(bincData[1])
+		if err != nil {
+			return "", "", "", fmt.Errorf("insert-data: Bin contents size error: %s", err)
+		}
+		binc = RandStringRunes(bincSizeNo, src, srcLock)
+	} else if bincData[0] == "unique" {
+		binc = fmt.Sprintf("%s%d", bincData[1], i)
+	} else {
+		return "", "", "", fmt.Errorf("insert-data: bin contents error")
+	}
+	return set, bin, binc, nil
+}
+
+// loadCredentials uses the credentials stored for the cluster by 'security enable', unless a user is specified
+func (c *dataInsertCommonCmd) loadCredentials(clusterName string) error {
+	if c.User != "" {
+		return nil
+	}
+	creds, err := securityLoadCreds(clusterName)
+	if err != nil {
+		return err
+	}
+	if creds != nil {
+		c.User = creds.User
+		c.Pass = creds.Password
+		c.AuthExternal = creds.AuthExternal
+	}
+	return nil
+}
+
 func (c *dataInsertSelectorCmd) unpack(cmd string, data []byte) error {
+	return c.unpackCommand([]string{"data", cmd}, data)
+}
+
+// unpackCommand copies self to the selected node and runs the given aerolab command there, passing it the marshalled command struct
+func (c *dataInsertSelectorCmd) unpackCommand(command []string, data []byte) error {
 	if c.IsClient {
 		b.WorkOnClients()
 	}
@@ -231,7 +390,7 @@ func (c *dataInsertSelectorCmd) unpack(cmd string, data []byte) error {
 	if err != nil {
 		return fmt.Errorf("insert-data: backend.AttachAndRun(1): %s", err)
 	}
-	runCommand := []string{"/usr/local/bin/aerolab", "data", cmd, "--run-json=" + jsonName}
+	runCommand := append(append([]string{"/usr/local/bin/aerolab"}, command...), "--run-json="+jsonName)
 	err = b.AttachAndRun(string(c.ClusterName), c.Node.Int(), runCommand, false)
 	if err != nil {
 		return fmt.Errorf("insert-data: backend.AttachAndRun(2): %s", err)
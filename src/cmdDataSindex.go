@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+type dataSindexCmd struct {
+	Create dataSindexCreateCmd `command:"create" subcommands-optional:"true" description:"Create a secondary index" webicon:"fas fa-plus"`
+	Drop   dataSindexDropCmd   `command:"drop" subcommands-optional:"true" description:"Drop a secondary index" webicon:"fas fa-trash"`
+	List   dataSindexListCmd   `command:"list" subcommands-optional:"true" description:"List secondary indexes" webicon:"fas fa-list"`
+	Help   helpCmd             `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *dataSindexCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+type dataSindexCreateCmd struct {
+	Namespace  string `short:"m" long:"namespace" description:"Namespace name" default:"test"`
+	Set        string `short:"s" long:"set" description:"Set name; default: index records of all sets" default:""`
+	IndexName  string `short:"x" long:"index" description:"Index name" webrequired:"true"`
+	Bin        string `short:"b" long:"bin" description:"Bin to index; either this or --expression must be set" default:""`
+	Type       string `short:"T" long:"type" description:"Type of the indexed values: numeric|string|blob|geo2dsphere" default:"numeric" webchoice:"numeric,string,blob,geo2dsphere"`
+	Collection string `short:"c" long:"collection" description:"Index the elements of a collection: default|list|mapkeys|mapvalues" default:"default" webchoice:"default,list,mapkeys,mapvalues"`
+	Context    string `long:"context" description:"Base64-encoded CDT context, to index a value nested in a list or map bin" default:""`
+	Expression string `short:"e" long:"expression" description:"Base64-encoded expression to index the result of, instead of a bin; requires Aerospike 8.1+" default:""`
+	NoWait     bool   `long:"no-wait" description:"Do not wait for the index to be built on all nodes"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type dataSindexDropCmd struct {
+	Namespace string `short:"m" long:"namespace" description:"Namespace name" default:"test"`
+	Set       string `short:"s" long:"set" description:"Set name; only required by Aerospike versions before 5.7" default:""`
+	IndexName string `short:"x" long:"index" description:"Index name" webrequired:"true"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type dataSindexListCmd struct {
+	Namespace string `short:"m" long:"namespace" description:"Only list indexes of this namespace" default:""`
+	Json      bool   `short:"j" long:"json" description:"Output in JSON format"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+var dataSindexTypes = map[string]aerospike.IndexType{
+	"numeric":     aerospike.NUMERIC,
+	"string":      aerospike.STRING,
+	"blob":        aerospike.BLOB,
+	"geo2dsphere": aerospike.GEO2DSPHERE,
+}
+
+var dataSindexCollections = map[string]aerospike.IndexCollectionType{
+	"default":   aerospike.ICT_DEFAULT,
+	"list":      aerospike.ICT_LIST,
+	"mapkeys":   aerospike.ICT_MAPKEYS,
+	"mapvalues": aerospike.ICT_MAPVALUES,
+}
+
+func (c *dataSindexCreateCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running data.sindex.create")
+	if c.RunJson == "" {
+		if c.IndexName == "" {
+			return errors.New("index name must be specified with -x")
+		}
+		if (c.Bin == "") == (c.Expression == "") {
+			return errors.New("exactly one of --bin or --expression must be specified")
+		}
+		if c.Expression != "" && c.Context != "" {
+			return errors.New("--context cannot be used with --expression")
+		}
+		if _, ok := dataSindexTypes[c.Type]; !ok {
+			return fmt.Errorf("index type %s not supported, must be one of: numeric, string, blob, geo2dsphere", c.Type)
+		}
+		if _, ok := dataSindexCollections[c.Collection]; !ok {
+			return fmt.Errorf("collection %s not supported, must be one of: default, list, mapkeys, mapvalues", c.Collection)
+		}
+	}
+	err := c.run([]string{"data", "sindex", "create"}, c, c.create)
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *dataSindexCreateCmd) create(client *aerospike.Client) error {
+	log.Printf("namespace=%s set=%s index=%s bin=%s type=%s collection=%s", c.Namespace, c.Set, c.IndexName, c.Bin, c.Type, c.Collection)
+	var task *aerospike.IndexTask
+	if c.Expression != "" {
+		// expression indexes are not supported by the client library yet, send the info command directly
+		cmd := "sindex-create:namespace=" + c.Namespace
+		if c.Set != "" {
+			cmd += ";set=" + c.Set
+		}
+		cmd += ";indexname=" + c.IndexName
+		if c.Collection != "default" {
+			cmd += ";indextype=" + c.Collection
+		}
+		cmd += ";type=" + c.Type + ";exp=" + c.Expression
+		nodes := client.GetNodes()
+		if len(nodes) == 0 {
+			return errors.New("no cluster nodes found")
+		}
+		out, err := nodes[0].RequestInfo(aerospike.NewInfoPolicy(), cmd)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(out[cmd], "OK") {
+			return fmt.Errorf("could not create index: %s", out[cmd])
+		}
+		task = aerospike.NewIndexTask(client.Cluster(), c.Namespace, c.IndexName)
+	} else {
+		var ctx []*aerospike.CDTContext
+		if c.Context != "" {
+			var err error
+			ctx, err = aerospike.Base64ToCDTContext(c.Context)
+			if err != nil {
+				return fmt.Errorf("could not decode context: %s", err)
+			}
+		}
+		var err error
+		task, err = client.CreateComplexIndex(nil, c.Namespace, c.Set, c.IndexName, c.Bin, dataSindexTypes[c.Type], dataSindexCollections[c.Collection], ctx...)
+		if err != nil {
+			return fmt.Errorf("could not create index: %s", err)
+		}
+	}
+	if c.NoWait {
+		return nil
+	}
+	log.Print("Waiting for index to be built")
+	err := <-task.OnComplete()
+	if err != nil {
+		return fmt.Errorf("index build: %s", err)
+	}
+	return nil
+}
+
+func (c *dataSindexDropCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running data.sindex.drop")
+	if c.RunJson == "" && c.IndexName == "" {
+		return errors.New("index name must be specified with -x")
+	}
+	err := c.run([]string{"data", "sindex", "drop"}, c, func(client *aerospike.Client) error {
+		return client.DropIndex(nil, c.Namespace, c.Set, c.IndexName)
+	})
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *dataSindexListCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	if !c.Json {
+		log.Print("Running data.sindex.list")
+	}
+	err := c.run([]string{"data", "sindex", "list"}, c, c.list)
+	if err != nil {
+		return err
+	}
+	if !c.Json {
+		log.Print("Done")
+	}
+	return nil
+}
+
+// dataSindexInfo is a secondary index as returned by sindex-list
+type dataSindexInfo struct {
+	Namespace  string
+	Set        string
+	Name       string
+	Bin        string
+	Type       string
+	Collection string
+	Context    string
+	Expression string
+	State      string
+}
+
+func (c *dataSindexListCmd) list(client *aerospike.Client) error {
+	nodes := client.GetNodes()
+	if len(nodes) == 0 {
+		return errors.New("no cluster nodes found")
+	}
+	cmd := "sindex-list:"
+	if c.Namespace != "" {
+		cmd += "ns=" + c.Namespace
+	}
+	out, err := nodes[0].RequestInfo(aerospike.NewInfoPolicy(), cmd)
+	if err != nil {
+		return err
+	}
+	indexes := dataSindexParse(out[cmd])
+	if c.Json {
+		return json.NewEncoder(os.Stdout).Encode(indexes)
+	}
+	tb := table.NewWriter()
+	tb.SetStyle(table.StyleLight)
+	tb.AppendHeader(table.Row{"Namespace", "Set", "Name", "Bin", "Type", "Collection", "Context", "Expression", "State"})
+	for _, index := range indexes {
+		tb.AppendRow(table.Row{index.Namespace, index.Set, index.Name, index.Bin, index.Type, index.Collection, index.Context, index.Expression, index.State})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
+
+// dataSindexParse parses the output of sindex-list; older servers use bins and num_bins instead of bin, and do not return context or exp
+func dataSindexParse(out string) []dataSindexInfo {
+	indexes := []dataSindexInfo{}
+	for _, line := range strings.Split(strings.TrimSpace(out), ";") {
+		if line == "" {
+			continue
+		}
+		values := make(map[string]string)
+		for _, item := range strings.Split(line, ":") {
+			k, v, _ := strings.Cut(item, "=")
+			if v == "NULL" {
+				v = ""
+			}
+			values[k] = v
+		}
+		index := dataSindexInfo{
+			Namespace:  values["ns"],
+			Set:        values["set"],
+			Name:       values["indexname"],
+			Bin:        values["bin"],
+			Type:       strings.ToLower(values["type"]),
+			Collection: strings.ToLower(values["indextype"]),
+			Context:    values["context"],
+			Expression: values["exp"],
+			State:      values["state"],
+		}
+		if index.Bin == "" {
+			index.Bin = values["bins"]
+		}
+		if index.Collection == "" || index.Collection == "none" {
+			index.Collection = "default"
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		if indexes[i].Namespace != indexes[j].Namespace {
+			return indexes[i].Namespace < indexes[j].Namespace
+		}
+		return indexes[i].Name < indexes[j].Name
+	})
+	return indexes
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+	"github.com/jedib0t/go-pretty/v6/table"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type dataUdfCmd struct {
+	Register dataUdfRegisterCmd `command:"register" subcommands-optional:"true" description:"Register a Lua UDF module" webicon:"fas fa-upload"`
+	Remove   dataUdfRemoveCmd   `command:"remove" subcommands-optional:"true" description:"Remove a UDF module" webicon:"fas fa-trash"`
+	List     dataUdfListCmd     `command:"list" subcommands-optional:"true" description:"List registered UDF modules" webicon:"fas fa-list"`
+	Help     helpCmd            `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *dataUdfCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+type dataUdfRegisterCmd struct {
+	File       flags.Filename `short:"f" long:"file" description:"Lua UDF file to register" webrequired:"true"`
+	ServerPath string         `short:"S" long:"server-path" description:"Name to register the module as; default: the file name" default:""`
+	Body       []byte         // the module contents, passed to the node registering it
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type dataUdfRemoveCmd struct {
+	Module string `short:"f" long:"module" description:"Name of the registered module, ex: mymodule.lua" webrequired:"true"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type dataUdfListCmd struct {
+	Json bool `short:"j" long:"json" description:"Output in JSON format"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *dataUdfRegisterCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running data.udf.register")
+	if c.RunJson == "" {
+		if c.File == "" {
+			return errors.New("UDF file must be specified with -f")
+		}
+		body, err := os.ReadFile(string(c.File))
+		if err != nil {
+			return err
+		}
+		c.Body = body
+		if c.ServerPath == "" {
+			c.ServerPath = filepath.Base(string(c.File))
+		}
+	}
+	err := c.run([]string{"data", "udf", "register"}, c, func(client *aerospike.Client) error {
+		log.Printf("Registering %s", c.ServerPath)
+		task, err := client.RegisterUDF(nil, c.Body, c.ServerPath, aerospike.LUA)
+		if err != nil {
+			return fmt.Errorf("could not register UDF: %s", err)
+		}
+		return <-task.OnComplete()
+	})
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *dataUdfRemoveCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running data.udf.remove")
+	if c.RunJson == "" && c.Module == "" {
+		return errors.New("module name must be specified with -f")
+	}
+	err := c.run([]string{"data", "udf", "remove"}, c, func(client *aerospike.Client) error {
+		task, err := client.RemoveUDF(nil, c.Module)
+		if err != nil {
+			return fmt.Errorf("could not remove UDF: %s", err)
+		}
+		return <-task.OnComplete()
+	})
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *dataUdfListCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	if !c.Json {
+		log.Print("Running data.udf.list")
+	}
+	err := c.run([]string{"data", "udf", "list"}, c, func(client *aerospike.Client) error {
+		udfs, err := client.ListUDF(nil)
+		if err != nil {
+			return err
+		}
+		sort.Slice(udfs, func(i, j int) bool {
+			return udfs[i].Filename < udfs[j].Filename
+		})
+		if c.Json {
+			return json.NewEncoder(os.Stdout).Encode(udfs)
+		}
+		tb := table.NewWriter()
+		tb.SetStyle(table.StyleLight)
+		tb.AppendHeader(table.Row{"Module", "Language", "Hash"})
+		for _, udf := range udfs {
+			tb.AppendRow(table.Row{udf.Filename, udf.Language, udf.Hash})
+		}
+		fmt.Println(tb.Render())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !c.Json {
+		log.Print("Done")
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/lithammer/shortuuid"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type dataVerifyCmd struct {
+	Manifest flags.Filename `short:"f" long:"manifest" description:"Manifest written by 'data insert --manifest'" webrequired:"true"`
+	Threads  int            `short:"u" long:"threads" description:"Number of parallel partition scans" default:"8"`
+	Details  bool           `short:"D" long:"details" description:"List each missing, stale, modified and unexpected record"`
+	Json     bool           `short:"j" long:"json" description:"Output the report in JSON format"`
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// dataManifestEntry is a record acknowledged by the cluster during 'data insert --manifest'
+type dataManifestEntry struct {
+	Namespace  string   `json:"ns"`
+	Set        string   `json:"set"`
+	Key        string   `json:"key,omitempty"`
+	Digest     string   `json:"digest"`
+	Generation uint32   `json:"gen"`
+	Bins       []string `json:"bins"`
+	Hash       string   `json:"hash"`
+}
+
+// dataManifestWriter writes manifest entries as JSON lines; it is safe for concurrent use
+type dataManifestWriter struct {
+	lock sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newDataManifestWriter(fn string) (*dataManifestWriter, error) {
+	f, err := os.Create(fn)
+	if err != nil {
+		return nil, fmt.Errorf("could not create manifest: %s", err)
+	}
+	w := bufio.NewWriter(f)
+	return &dataManifestWriter{
+		f:   f,
+		w:   w,
+		enc: json.NewEncoder(w),
+	}, nil
+}
+
+// add records an acknowledged write of the given bins, with the generation the record had after the write
+func (m *dataManifestWriter) add(key *dataKey, gen uint32, bins map[string]interface{}) error {
+	digest := key.Digest
+	if digest == nil {
+		k, err := aerospike.NewKey(key.Namespace, key.Set, key.Pk)
+		if err != nil {
+			return err
+		}
+		digest = k.Digest()
+	}
+	names := make([]string, 0, len(bins))
+	for name := range bins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entry := &dataManifestEntry{
+		Namespace:  key.Namespace,
+		Set:        key.Set,
+		Key:        key.Pk,
+		Digest:     hex.EncodeToString(digest),
+		Generation: gen,
+		Bins:       names,
+		Hash:       dataContentHash(bins, names),
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.enc.Encode(entry)
+}
+
+func (m *dataManifestWriter) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := m.w.Flush()
+	if err != nil {
+		m.f.Close()
+		return err
+	}
+	return m.f.Close()
+}
+
+// dataContentHash returns a digest of the type and value of the given bins of a record; other bins are ignored, as updates may leave bins which were not written
+func dataContentHash(bins map[string]interface{}, names []string) string {
+	h := fnv.New64a()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%T:%v\x00", name, bins[name], bins[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// dataPartition returns the partition ID of a record digest
+func dataPartition(digest []byte) int {
+	return int(binary.LittleEndian.Uint16(digest[0:2]) & 0x0FFF)
+}
+
+// dataVerifyCounts are the verification results of one partition
+type dataVerifyCounts struct {
+	Partition  int `json:"partition"`
+	Expected   int `json:"expected"`
+	Verified   int `json:"verified"`
+	Missing    int `json:"missing"`
+	Stale      int `json:"stale"`
+	Modified   int `json:"modified"`
+	Unexpected int `json:"unexpected"`
+}
+
+// dataVerifyRecord is a record which did not verify
+type dataVerifyRecord struct {
+	Partition          int    `json:"partition"`
+	Namespace          string `json:"ns"`
+	Set                string `json:"set"`
+	Key                string `json:"key,omitempty"`
+	Digest             string `json:"digest"`
+	Status             string `json:"status"`
+	ExpectedGeneration uint32 `json:"expectedGen,omitempty"`
+	Generation         uint32 `json:"gen,omitempty"`
+}
+
+type dataVerifyReport struct {
+	Total      dataVerifyCounts   `json:"total"`
+	Partitions []dataVerifyCounts `json:"partitions"`
+	Records    []dataVerifyRecord `json:"records,omitempty"`
+}
+
+func (c *dataVerifyCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	if !c.Json {
+		log.Print("Running data.verify")
+	}
+	// with --run-json the manifest path comes from the json file
+	if c.RunJson == "" && c.Manifest == "" {
+		return errors.New("manifest must be specified with -f")
+	}
+	if c.RunJson != "" || c.RunDirect {
+		err := c.run([]string{"data", "verify"}, c, c.verify)
+		if err != nil {
+			return err
+		}
+		if !c.Json {
+			log.Print("Done")
+		}
+		return nil
+	}
+	if _, err := os.Stat(string(c.Manifest)); err != nil {
+		return err
+	}
+	// upload the manifest to the node and verify from there
+	err := c.prepare()
+	if err != nil {
+		return err
+	}
+	remote := "/tmp/aerolab-data-manifest." + shortuuid.New()
+	defer b.RunCommands(c.ClusterName.String(), [][]string{{"rm", "-f", remote}}, []int{c.Node.Int()})
+	err = b.Upload(c.ClusterName.String(), c.Node.Int(), string(c.Manifest), remote, false, false)
+	if err != nil {
+		return fmt.Errorf("could not upload manifest: %s", err)
+	}
+	c.Manifest = flags.Filename(remote)
+	err = c.runRemote([]string{"data", "verify"}, c)
+	if err != nil {
+		return err
+	}
+	if !c.Json {
+		log.Print("Done")
+	}
+	return nil
+}
+
+func (c *dataVerifyCmd) verify(client *aerospike.Client) error {
+	entries, err := dataManifestLoad(string(c.Manifest))
+	if err != nil {
+		return err
+	}
+	// scan each namespace once; a single set is scanned on its own, otherwise the whole namespace is scanned and filtered to the manifest sets
+	sets := make(map[string]map[string]bool)
+	for _, entry := range entries {
+		if sets[entry.Namespace] == nil {
+			sets[entry.Namespace] = make(map[string]bool)
+		}
+		sets[entry.Namespace][entry.Set] = true
+	}
+	if !c.Json {
+		log.Printf("Loaded %d records from manifest, threads=%d", len(entries), c.Threads)
+	}
+	partitions := make(map[int]*dataVerifyCounts)
+	counts := func(partition int) *dataVerifyCounts {
+		if partitions[partition] == nil {
+			partitions[partition] = &dataVerifyCounts{Partition: partition}
+		}
+		return partitions[partition]
+	}
+	records := []dataVerifyRecord{}
+	for _, entry := range entries {
+		digest, _ := hex.DecodeString(entry.Digest)
+		counts(dataPartition(digest)).Expected++
+	}
+	found := make(map[string]bool)
+	lock := new(sync.Mutex)
+	scanned := new(atomic.Int64)
+	var stop func()
+	if !c.Json {
+		stop = dataProgress("Scanned", scanned)
+	}
+	namespaces := []string{}
+	for ns := range sets {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		scanSet := ""
+		if len(sets[ns]) == 1 {
+			for set := range sets[ns] {
+				scanSet = set
+			}
+		}
+		err = dataScan(client, ns, scanSet, c.Threads, func(rec *aerospike.Record) error {
+			scanned.Add(1)
+			if !sets[ns][rec.Key.SetName()] {
+				return nil
+			}
+			digest := hex.EncodeToString(rec.Key.Digest())
+			lock.Lock()
+			defer lock.Unlock()
+			partition := dataPartition(rec.Key.Digest())
+			entry, ok := entries[ns+":"+digest]
+			if !ok {
+				counts(partition).Unexpected++
+				records = append(records, dataVerifyRecord{Partition: partition, Namespace: ns, Set: rec.Key.SetName(), Digest: digest, Status: "unexpected", Generation: rec.Generation})
+				return nil
+			}
+			found[ns+":"+digest] = true
+			status := "verified"
+			hash := dataContentHash(rec.Bins, entry.Bins)
+			switch {
+			case rec.Generation < entry.Generation:
+				status = "stale"
+			case rec.Generation == entry.Generation && hash != entry.Hash:
+				status = "stale"
+			case rec.Generation > entry.Generation && hash != entry.Hash:
+				status = "modified"
+			}
+			switch status {
+			case "verified":
+				counts(partition).Verified++
+				return nil
+			case "stale":
+				counts(partition).Stale++
+			case "modified":
+				counts(partition).Modified++
+			}
+			records = append(records, dataVerifyRecord{Partition: partition, Namespace: ns, Set: entry.Set, Key: entry.Key, Digest: digest, Status: status, ExpectedGeneration: entry.Generation, Generation: rec.Generation})
+			return nil
+		})
+		if err != nil {
+			if stop != nil {
+				stop()
+			}
+			return err
+		}
+	}
+	if stop != nil {
+		stop()
+	}
+	for id, entry := range entries {
+		if found[id] {
+			continue
+		}
+		digest, _ := hex.DecodeString(entry.Digest)
+		partition := dataPartition(digest)
+		counts(partition).Missing++
+		records = append(records, dataVerifyRecord{Partition: partition, Namespace: entry.Namespace, Set: entry.Set, Key: entry.Key, Digest: entry.Digest, Status: "missing", ExpectedGeneration: entry.Generation})
+	}
+
+	report := &dataVerifyReport{Total: dataVerifyCounts{Partition: -1}}
+	for _, p := range partitions {
+		report.Total.Expected += p.Expected
+		report.Total.Verified += p.Verified
+		report.Total.Missing += p.Missing
+		report.Total.Stale += p.Stale
+		report.Total.Modified += p.Modified
+		report.Total.Unexpected += p.Unexpected
+		if p.Missing+p.Stale+p.Modified+p.Unexpected > 0 {
+			report.Partitions = append(report.Partitions, *p)
+		}
+	}
+	sort.Slice(report.Partitions, func(i, j int) bool {
+		return report.Partitions[i].Partition < report.Partitions[j].Partition
+	})
+	if c.Details {
+		sort.Slice(records, func(i, j int) bool {
+			if records[i].Partition != records[j].Partition {
+				return records[i].Partition < records[j].Partition
+			}
+			return records[i].Digest < records[j].Digest
+		})
+		report.Records = records
+	}
+	if c.Json {
+		err = json.NewEncoder(os.Stdout).Encode(report)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.print(report)
+	}
+	if report.Total.Missing+report.Total.Stale+report.Total.Unexpected > 0 {
+		return fmt.Errorf("verification failed: %d missing, %d stale, %d unexpected records", report.Total.Missing, report.Total.Stale, report.Total.Unexpected)
+	}
+	return nil
+}
+
+func (c *dataVerifyCmd) print(report *dataVerifyReport) {
+	if len(report.Partitions) > 0 {
+		tb := table.NewWriter()
+		tb.SetStyle(table.StyleLight)
+		tb.AppendHeader(table.Row{"Partition", "Expected", "Verified", "Missing", "Stale", "Modified", "Unexpected"})
+		for _, p := range report.Partitions {
+			tb.AppendRow(table.Row{p.Partition, p.Expected, p.Verified, p.Missing, p.Stale, p.Modified, p.Unexpected})
+		}
+		t := report.Total
+		tb.AppendFooter(table.Row{"TOTAL", t.Expected, t.Verified, t.Missing, t.Stale, t.Modified, t.Unexpected})
+		fmt.Println(tb.Render())
+	}
+	if len(report.Records) > 0 {
+		tb := table.NewWriter()
+		tb.SetStyle(table.StyleLight)
+		tb.AppendHeader(table.Row{"Partition", "Namespace", "Set", "Key", "Digest", "Status", "Expected Gen", "Gen"})
+		for _, r := range report.Records {
+			expected := "-"
+			if r.ExpectedGeneration > 0 {
+				expected = strconv.Itoa(int(r.ExpectedGeneration))
+			}
+			gen := "-"
+			if r.Generation > 0 {
+				gen = strconv.Itoa(int(r.Generation))
+			}
+			tb.AppendRow(table.Row{r.Partition, r.Namespace, r.Set, r.Key, r.Digest, r.Status, expected, gen})
+		}
+		fmt.Println(tb.Render())
+	}
+	t := report.Total
+	fmt.Printf("Expected: %d , Verified: %d , Missing: %d , Stale: %d , Modified: %d , Unexpected: %d\n", t.Expected, t.Verified, t.Missing, t.Stale, t.Modified, t.Unexpected)
+}
+
+// dataManifestLoad reads a manifest, keyed by namespace:digest; later entries for the same record replace earlier ones, so that manifests of several inserts can be concatenated
+func dataManifestLoad(fn string) (map[string]*dataManifestEntry, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries := make(map[string]*dataManifestEntry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &dataManifestEntry{}
+		err := json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil {
+			return nil, fmt.Errorf("manifest line %d: %s", line, err)
+		}
+		digest, err := hex.DecodeString(entry.Digest)
+		if err != nil || len(digest) != 20 {
+			return nil, fmt.Errorf("manifest line %d: invalid digest %s", line, entry.Digest)
+		}
+		entries[entry.Namespace+":"+entry.Digest] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("manifest is empty")
+	}
+	return entries, nil
+}
@@ -0,0 +1,722 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+	"github.com/aerospike/aerospike-client-go/v8/types"
+	"github.com/jedib0t/go-pretty/v6/table"
+	flags "github.com/rglonek/jeddevdk-goflags"
+	"gopkg.in/yaml.v3"
+)
+
+type dataWorkloadCmd struct {
+	Spec     flags.Filename    `short:"f" long:"spec" description:"YAML workload spec file; see --example"`
+	Example  bool              `long:"example" description:"Print an example workload spec and exit"`
+	Tps      int               `long:"tps" description:"Override the target operations per second of the spec; -1=use spec, 0=unlimited" default:"-1"`
+	Duration time.Duration     `long:"duration" description:"Override the duration of the spec, ex: 30s, 5m; 0=use spec"`
+	Threads  int               `long:"threads" description:"Override the thread count of the spec; 0=use spec" default:"0"`
+	SpecData *dataWorkloadSpec // the parsed spec, passed to the node running the workload
+	dataClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type dataWorkloadSpec struct {
+	Namespace string            `yaml:"namespace"`
+	Set       string            `yaml:"set"`
+	Keys      dataWorkloadKeys  `yaml:"keys"`
+	Ratio     dataWorkloadRatio `yaml:"ratio"`
+	Bins      []dataWorkloadBin `yaml:"bins"`
+	Tps       int               `yaml:"tps"`
+	Duration  time.Duration     `yaml:"duration"`
+	Threads   int               `yaml:"threads"`
+	TTL       int               `yaml:"ttl"`
+	Timeout   time.Duration     `yaml:"timeout"`
+}
+
+type dataWorkloadKeys struct {
+	Count        int     `yaml:"count"`
+	Start        int     `yaml:"start"`
+	Prefix       string  `yaml:"prefix"`
+	Distribution string  `yaml:"distribution"`
+	ZipfS        float64 `yaml:"zipf-s"`
+	HotKeys      float64 `yaml:"hot-keys"`
+	HotOps       float64 `yaml:"hot-ops"`
+}
+
+type dataWorkloadRatio struct {
+	Read   int `yaml:"read"`
+	Write  int `yaml:"write"`
+	Update int `yaml:"update"`
+}
+
+type dataWorkloadBin struct {
+	Name    string `yaml:"name"`
+	Type    string `yaml:"type"`
+	Min     int64  `yaml:"min"`
+	Max     int64  `yaml:"max"`
+	Size    int    `yaml:"size"`
+	Element string `yaml:"element"`
+}
+
+const dataWorkloadExample = `# namespace and set to run the workload against
+namespace: test
+set: workload
+
+# key space: keys are PREFIX+NUMBER strings, from start to start+count-1, like the keys written by 'data insert'
+keys:
+  count: 100000
+  start: 1
+  prefix: ""
+  # uniform: all keys equally likely
+  # zipf: key popularity follows a zipf distribution with exponent zipf-s (must be > 1)
+  # hotspot: hot-ops of the operations go to the first hot-keys of the key space
+  distribution: uniform
+  zipf-s: 1.1
+  hot-keys: 0.1
+  hot-ops: 0.9
+
+# relative weights of the operations: read gets the record, write replaces the whole record, update writes a single bin
+ratio:
+  read: 60
+  write: 20
+  update: 20
+
+# record shape; types: int (min, max), float (min, max), string (size), blob (size),
+# list (size elements of element type int or string), map (size entries of element type), geojson (random point)
+bins:
+  - name: count
+    type: int
+    min: 0
+    max: 1000000
+  - name: name
+    type: string
+    size: 16
+  - name: payload
+    type: blob
+    size: 1024
+  - name: tags
+    type: list
+    size: 10
+    element: string
+  - name: attrs
+    type: map
+    size: 5
+    element: int
+  - name: location
+    type: geojson
+
+# target operations per second, 0=unlimited
+tps: 1000
+# how long to run for, 0=until interrupted with ctrl+c
+duration: 60s
+threads: 16
+# record ttl in seconds, -1=namespace default, 0=never expire
+ttl: -1
+# timeout of each operation
+timeout: 5s
+`
+
+// dataWorkloadOps are the operation types, in reporting order
+var dataWorkloadOps = []string{"read", "write", "update"}
+
+func (c *dataWorkloadCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	if c.Example {
+		fmt.Print(dataWorkloadExample)
+		return nil
+	}
+	log.Print("Running data.workload")
+	if c.RunJson == "" && c.SpecData == nil {
+		if c.Spec == "" {
+			return errors.New("a workload spec file must be specified with -f; use --example to print an example spec")
+		}
+		spec, err := dataWorkloadLoadSpec(string(c.Spec))
+		if err != nil {
+			return err
+		}
+		c.SpecData = spec
+	}
+	err := c.run([]string{"data", "workload"}, c, c.workload)
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func dataWorkloadLoadSpec(fn string) (*dataWorkloadSpec, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	spec := &dataWorkloadSpec{TTL: -1}
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	err = dec.Decode(spec)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse workload spec %s: %s", fn, err)
+	}
+	err = spec.validate()
+	if err != nil {
+		return nil, fmt.Errorf("workload spec %s: %s", fn, err)
+	}
+	return spec, nil
+}
+
+// validate checks the spec and fills in the defaults
+func (s *dataWorkloadSpec) validate() error {
+	if s.Namespace == "" {
+		s.Namespace = "test"
+	}
+	if s.Set == "" {
+		s.Set = "workload"
+	}
+	if s.Keys.Count == 0 {
+		s.Keys.Count = 100000
+	}
+	if s.Keys.Count < 0 {
+		return errors.New("keys.count must be positive")
+	}
+	if s.Keys.Distribution == "" {
+		s.Keys.Distribution = "uniform"
+	}
+	switch s.Keys.Distribution {
+	case "uniform":
+	case "zipf":
+		if s.Keys.ZipfS == 0 {
+			s.Keys.ZipfS = 1.1
+		}
+		if s.Keys.ZipfS <= 1 {
+			return errors.New("keys.zipf-s must be greater than 1")
+		}
+	case "hotspot":
+		if s.Keys.HotKeys == 0 {
+			s.Keys.HotKeys = 0.1
+		}
+		if s.Keys.HotOps == 0 {
+			s.Keys.HotOps = 0.9
+		}
+		if s.Keys.HotKeys <= 0 || s.Keys.HotKeys >= 1 || s.Keys.HotOps <= 0 || s.Keys.HotOps > 1 {
+			return errors.New("keys.hot-keys must be between 0 and 1, and keys.hot-ops between 0 and 1")
+		}
+	default:
+		return fmt.Errorf("keys.distribution %s not supported, must be one of: uniform, zipf, hotspot", s.Keys.Distribution)
+	}
+	if s.Ratio.Read < 0 || s.Ratio.Write < 0 || s.Ratio.Update < 0 {
+		return errors.New("ratio values cannot be negative")
+	}
+	if s.Ratio.Read+s.Ratio.Write+s.Ratio.Update == 0 {
+		s.Ratio.Read = 50
+		s.Ratio.Write = 50
+	}
+	if len(s.Bins) == 0 {
+		s.Bins = []dataWorkloadBin{{Name: "value", Type: "string"}}
+	}
+	names := make(map[string]bool)
+	for i := range s.Bins {
+		bin := &s.Bins[i]
+		if bin.Name == "" || len(bin.Name) > 15 {
+			return fmt.Errorf("bin %d: name must be 1-15 characters long", i+1)
+		}
+		if names[bin.Name] {
+			return fmt.Errorf("bin %s specified more than once", bin.Name)
+		}
+		names[bin.Name] = true
+		switch bin.Type {
+		case "int", "float":
+			if bin.Min == 0 && bin.Max == 0 {
+				bin.Max = 1000000
+			}
+			if bin.Max < bin.Min {
+				return fmt.Errorf("bin %s: max is lower than min", bin.Name)
+			}
+		case "string":
+			if bin.Size == 0 {
+				bin.Size = 16
+			}
+		case "blob":
+			if bin.Size == 0 {
+				bin.Size = 1024
+			}
+		case "list", "map":
+			if bin.Size == 0 {
+				bin.Size = 10
+			}
+			if bin.Element == "" {
+				bin.Element = "int"
+			}
+			if bin.Element != "int" && bin.Element != "string" {
+				return fmt.Errorf("bin %s: element must be int or string", bin.Name)
+			}
+		case "geojson":
+		default:
+			return fmt.Errorf("bin %s: type %s not supported, must be one of: int, float, string, blob, list, map, geojson", bin.Name, bin.Type)
+		}
+		if bin.Size < 0 {
+			return fmt.Errorf("bin %s: size cannot be negative", bin.Name)
+		}
+	}
+	if s.Tps < 0 {
+		return errors.New("tps cannot be negative")
+	}
+	if s.Threads == 0 {
+		s.Threads = 16
+	}
+	if s.Threads < 0 {
+		return errors.New("threads must be positive")
+	}
+	if s.Duration < 0 {
+		return errors.New("duration cannot be negative")
+	}
+	if s.Timeout == 0 {
+		s.Timeout = 5 * time.Second
+	}
+	return nil
+}
+
+func (c *dataWorkloadCmd) workload(client *aerospike.Client) error {
+	spec := c.SpecData
+	if spec == nil {
+		return errors.New("workload spec missing")
+	}
+	if c.Tps >= 0 {
+		spec.Tps = c.Tps
+	}
+	if c.Duration > 0 {
+		spec.Duration = c.Duration
+	}
+	if c.Threads > 0 {
+		spec.Threads = c.Threads
+	}
+	duration := "until interrupted"
+	if spec.Duration > 0 {
+		duration = spec.Duration.String()
+	}
+	tps := "unlimited"
+	if spec.Tps > 0 {
+		tps = strconv.Itoa(spec.Tps)
+	}
+	log.Printf("namespace=%s set=%s keys=%d distribution=%s ratio=%d:%d:%d (read:write:update) tps=%s threads=%d duration=%s", spec.Namespace, spec.Set, spec.Keys.Count, spec.Keys.Distribution, spec.Ratio.Read, spec.Ratio.Write, spec.Ratio.Update, tps, spec.Threads, duration)
+
+	w := &dataWorkload{
+		spec:   spec,
+		client: client,
+		hist:   make(map[string]*dataWorkloadHistogram),
+		errs:   make(map[string]int),
+	}
+	for _, op := range dataWorkloadOps {
+		w.hist[op] = new(dataWorkloadHistogram)
+	}
+	w.readPolicy = aerospike.NewPolicy()
+	w.readPolicy.TotalTimeout = spec.Timeout
+	var expiration uint32
+	switch {
+	case spec.TTL < 0:
+		expiration = aerospike.TTLServerDefault
+	case spec.TTL > 0:
+		expiration = uint32(spec.TTL)
+	default:
+		expiration = aerospike.TTLDontExpire
+	}
+	w.writePolicy = aerospike.NewWritePolicy(0, expiration)
+	w.writePolicy.TotalTimeout = spec.Timeout
+	w.writePolicy.RecordExistsAction = aerospike.REPLACE
+	w.updatePolicy = aerospike.NewWritePolicy(0, expiration)
+	w.updatePolicy.TotalTimeout = spec.Timeout
+	w.updatePolicy.RecordExistsAction = aerospike.UPDATE
+
+	// stop on duration or interrupt
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	done := make(chan struct{})
+	go func() {
+		var timeout <-chan time.Time
+		if spec.Duration > 0 {
+			timeout = time.After(spec.Duration)
+		}
+		select {
+		case <-interrupt:
+			log.Print("Interrupted, stopping")
+		case <-timeout:
+		case <-done:
+		}
+		w.stop.Store(true)
+	}()
+
+	client.WarmUp(spec.Threads)
+	start := time.Now()
+	wg := new(sync.WaitGroup)
+	for i := 0; i < spec.Threads; i++ {
+		wg.Add(1)
+		go func(thread int) {
+			defer wg.Done()
+			w.worker(thread, start)
+		}(i)
+	}
+	reportDone := make(chan struct{})
+	go func() {
+		w.report(start)
+		close(reportDone)
+	}()
+	wg.Wait()
+	close(done)
+	<-reportDone
+	w.summary(time.Since(start))
+	return nil
+}
+
+// dataWorkload is a running workload, shared by the worker threads
+type dataWorkload struct {
+	spec         *dataWorkloadSpec
+	client       *aerospike.Client
+	readPolicy   *aerospike.BasePolicy
+	writePolicy  *aerospike.WritePolicy
+	updatePolicy *aerospike.WritePolicy
+	hist         map[string]*dataWorkloadHistogram
+	stop         atomic.Bool
+	errLock      sync.Mutex
+	errs         map[string]int
+}
+
+func (w *dataWorkload) worker(thread int, start time.Time) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(thread)))
+	var zipf *rand.Zipf
+	if w.spec.Keys.Distribution == "zipf" {
+		zipf = rand.NewZipf(r, w.spec.Keys.ZipfS, 1, uint64(w.spec.Keys.Count-1))
+	}
+	// each thread does its share of the target tps, at a fixed interval
+	var interval time.Duration
+	if w.spec.Tps > 0 {
+		interval = time.Duration(int64(time.Second) * int64(w.spec.Threads) / int64(w.spec.Tps))
+	}
+	next := start
+	total := w.spec.Ratio.Read + w.spec.Ratio.Write + w.spec.Ratio.Update
+	for !w.stop.Load() {
+		if interval > 0 {
+			next = next.Add(interval)
+			wait := time.Until(next)
+			if wait > 0 {
+				time.Sleep(wait)
+			} else if wait < -time.Second {
+				// do not burst to catch up after a stall
+				next = time.Now()
+			}
+		}
+		key, err := aerospike.NewKey(w.spec.Namespace, w.spec.Set, w.key(r, zipf))
+		if err != nil {
+			w.addError(err)
+			continue
+		}
+		op := "update"
+		n := r.Intn(total)
+		if n < w.spec.Ratio.Read {
+			op = "read"
+		} else if n < w.spec.Ratio.Read+w.spec.Ratio.Write {
+			op = "write"
+		}
+		var aerr aerospike.Error
+		opStart := time.Now()
+		switch op {
+		case "read":
+			_, aerr = w.client.Get(w.readPolicy, key)
+		case "write":
+			record := aerospike.BinMap{}
+			for _, bin := range w.spec.Bins {
+				record[bin.Name] = bin.value(r)
+			}
+			aerr = w.client.Put(w.writePolicy, key, record)
+		case "update":
+			bin := w.spec.Bins[r.Intn(len(w.spec.Bins))]
+			aerr = w.client.PutBins(w.updatePolicy, key, aerospike.NewBin(bin.Name, bin.value(r)))
+		}
+		latency := time.Since(opStart)
+		h := w.hist[op]
+		switch {
+		case aerr == nil:
+			h.add(latency)
+		case aerr.Matches(types.KEY_NOT_FOUND_ERROR):
+			h.add(latency)
+			h.notFound.Add(1)
+		default:
+			h.errors.Add(1)
+			w.addError(aerr)
+		}
+	}
+}
+
+// key returns the next key, following the spec distribution
+func (w *dataWorkload) key(r *rand.Rand, zipf *rand.Zipf) string {
+	count := w.spec.Keys.Count
+	var n int
+	switch w.spec.Keys.Distribution {
+	case "zipf":
+		n = int(zipf.Uint64())
+	case "hotspot":
+		hot := int(float64(count) * w.spec.Keys.HotKeys)
+		if hot < 1 {
+			hot = 1
+		}
+		if r.Float64() < w.spec.Keys.HotOps || hot >= count {
+			n = r.Intn(hot)
+		} else {
+			n = hot + r.Intn(count-hot)
+		}
+	default:
+		n = r.Intn(count)
+	}
+	return w.spec.Keys.Prefix + strconv.Itoa(w.spec.Keys.Start+n)
+}
+
+func (w *dataWorkload) addError(err error) {
+	w.errLock.Lock()
+	defer w.errLock.Unlock()
+	msg := err.Error()
+	if _, ok := w.errs[msg]; !ok && len(w.errs) >= 10 {
+		msg = "other errors"
+	}
+	w.errs[msg]++
+}
+
+// value generates a random bin value of the bin type
+func (b *dataWorkloadBin) value(r *rand.Rand) interface{} {
+	switch b.Type {
+	case "int":
+		return b.Min + r.Int63n(b.Max-b.Min+1)
+	case "float":
+		return float64(b.Min) + r.Float64()*float64(b.Max-b.Min)
+	case "string":
+		return dataWorkloadString(r, b.Size)
+	case "blob":
+		blob := make([]byte, b.Size)
+		r.Read(blob)
+		return blob
+	case "list":
+		list := make([]interface{}, b.Size)
+		for i := range list {
+			list[i] = dataWorkloadElement(r, b.Element)
+		}
+		return list
+	case "map":
+		m := make(map[interface{}]interface{}, b.Size)
+		for i := 0; i < b.Size; i++ {
+			m["k"+strconv.Itoa(i)] = dataWorkloadElement(r, b.Element)
+		}
+		return m
+	case "geojson":
+		return aerospike.NewGeoJSONValue(fmt.Sprintf(`{"type":"Point","coordinates":[%.6f,%.6f]}`, r.Float64()*360-180, r.Float64()*180-90))
+	}
+	return nil
+}
+
+func dataWorkloadElement(r *rand.Rand, element string) interface{} {
+	if element == "string" {
+		return dataWorkloadString(r, 8)
+	}
+	return r.Int63n(1000000)
+}
+
+func dataWorkloadString(r *rand.Rand, size int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	sb := strings.Builder{}
+	sb.Grow(size)
+	for i := 0; i < size; i++ {
+		sb.WriteByte(letters[r.Intn(len(letters))])
+	}
+	return sb.String()
+}
+
+// report prints the throughput and latency of the last second, every second, until the workload stops
+func (w *dataWorkload) report(start time.Time) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := make(map[string]dataWorkloadCounts)
+	lastTime := start
+	for !w.stop.Load() {
+		<-ticker.C
+		now := time.Now()
+		seconds := now.Sub(lastTime).Seconds()
+		lastTime = now
+		line := []string{fmt.Sprintf("%6s", time.Since(start).Round(time.Second))}
+		var totalOps, notFound, errs int64
+		parts := []string{}
+		for _, op := range w.activeOps() {
+			counts := w.hist[op].snapshot()
+			delta := counts.sub(last[op])
+			last[op] = counts
+			totalOps += delta.count + delta.errors
+			notFound += delta.notFound
+			errs += delta.errors
+			parts = append(parts, fmt.Sprintf("%s %d/s p50%s p99%s", op, int64(float64(delta.count+delta.errors)/seconds), delta.percentile(0.5), delta.percentile(0.99)))
+		}
+		line = append(line, fmt.Sprintf("total %d/s", int64(float64(totalOps)/seconds)))
+		line = append(line, parts...)
+		line = append(line, fmt.Sprintf("not-found %d", notFound), fmt.Sprintf("errors %d", errs))
+		fmt.Println(strings.Join(line, " | "))
+	}
+}
+
+func (w *dataWorkload) activeOps() []string {
+	ops := []string{}
+	for _, op := range dataWorkloadOps {
+		if (op == "read" && w.spec.Ratio.Read > 0) || (op == "write" && w.spec.Ratio.Write > 0) || (op == "update" && w.spec.Ratio.Update > 0) {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// summary prints the totals and the latency histogram of each operation type
+func (w *dataWorkload) summary(runTime time.Duration) {
+	tb := table.NewWriter()
+	tb.SetStyle(table.StyleLight)
+	tb.AppendHeader(table.Row{"Op", "Ops", "Ops/s", "Not found", "Errors", "p50", "p90", "p99", "p99.9", "Max", ">1ms", ">8ms", ">64ms"})
+	for _, op := range w.activeOps() {
+		h := w.hist[op]
+		counts := h.snapshot()
+		tb.AppendRow(table.Row{
+			op,
+			counts.count + counts.errors,
+			int64(float64(counts.count+counts.errors) / runTime.Seconds()),
+			counts.notFound,
+			counts.errors,
+			counts.percentile(0.5),
+			counts.percentile(0.9),
+			counts.percentile(0.99),
+			counts.percentile(0.999),
+			time.Duration(h.max.Load()).Round(time.Microsecond).String(),
+			counts.over(1),
+			counts.over(8),
+			counts.over(64),
+		})
+	}
+	fmt.Println(tb.Render())
+	fmt.Printf("Run time: %s\n", runTime.Round(time.Millisecond))
+	if len(w.errs) > 0 {
+		msgs := []string{}
+		for msg := range w.errs {
+			msgs = append(msgs, msg)
+		}
+		sort.Slice(msgs, func(i, j int) bool {
+			return w.errs[msgs[i]] > w.errs[msgs[j]]
+		})
+		fmt.Println("Errors:")
+		for _, msg := range msgs {
+			fmt.Printf("%8d x %s\n", w.errs[msg], msg)
+		}
+	}
+}
+
+// dataWorkloadBuckets is the number of latency buckets; bucket i holds latencies of [2^(i-1), 2^i) 1/1024ths of a millisecond, so that bucket boundaries fall on 1ms, 2ms, 4ms, ...
+const dataWorkloadBuckets = 48
+
+type dataWorkloadHistogram struct {
+	buckets  [dataWorkloadBuckets]atomic.Int64
+	count    atomic.Int64 // successful operations, including not found
+	notFound atomic.Int64
+	errors   atomic.Int64
+	max      atomic.Int64 // nanoseconds
+}
+
+func (h *dataWorkloadHistogram) add(latency time.Duration) {
+	ns := latency.Nanoseconds()
+	i := bits.Len64(uint64(ns) * 1024 / uint64(time.Millisecond))
+	if i >= dataWorkloadBuckets {
+		i = dataWorkloadBuckets - 1
+	}
+	h.buckets[i].Add(1)
+	h.count.Add(1)
+	for {
+		max := h.max.Load()
+		if ns <= max || h.max.CompareAndSwap(max, ns) {
+			break
+		}
+	}
+}
+
+// dataWorkloadCounts is a point-in-time copy of a histogram
+type dataWorkloadCounts struct {
+	buckets  [dataWorkloadBuckets]int64
+	count    int64
+	notFound int64
+	errors   int64
+}
+
+func (h *dataWorkloadHistogram) snapshot() dataWorkloadCounts {
+	c := dataWorkloadCounts{
+		count:    h.count.Load(),
+		notFound: h.notFound.Load(),
+		errors:   h.errors.Load(),
+	}
+	for i := range h.buckets {
+		c.buckets[i] = h.buckets[i].Load()
+	}
+	return c
+}
+
+func (c dataWorkloadCounts) sub(o dataWorkloadCounts) dataWorkloadCounts {
+	ret := dataWorkloadCounts{
+		count:    c.count - o.count,
+		notFound: c.notFound - o.notFound,
+		errors:   c.errors - o.errors,
+	}
+	for i := range c.buckets {
+		ret.buckets[i] = c.buckets[i] - o.buckets[i]
+	}
+	return ret
+}
+
+// percentile returns the upper bound of the bucket holding the given percentile, ex: <2ms
+func (c dataWorkloadCounts) percentile(q float64) string {
+	var total int64
+	for _, n := range c.buckets {
+		total += n
+	}
+	if total == 0 {
+		return " -"
+	}
+	target := int64(float64(total)*q + 0.5)
+	if target < 1 {
+		target = 1
+	}
+	var sum int64
+	for i, n := range c.buckets {
+		sum += n
+		if sum >= target {
+			return "<" + strconv.FormatFloat(float64(uint64(1)<<i)/1024, 'f', -1, 64) + "ms"
+		}
+	}
+	return "-"
+}
+
+// over returns the percentage of operations which took longer than the given number of milliseconds, which must be a power of 2
+func (c dataWorkloadCounts) over(ms int) string {
+	var total, over int64
+	first := 11 + bits.Len(uint(ms)) - 1 // first bucket with a lower bound of at least ms
+	for i, n := range c.buckets {
+		total += n
+		if i >= first {
+			over += n
+		}
+	}
+	if total == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(over)*100/float64(total), 'f', 2, 64) + "%"
+}
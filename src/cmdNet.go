@@ -7,6 +7,9 @@ type netCmd struct {
 	Unblock   netUnblockCmd   `command:"unblock" subcommands-optional:"true" description:"Unblock a port" webicon:"fas fa-unlock"`
 	List      netListCmd      `command:"list" subcommands-optional:"true" description:"List blocked ports" webicon:"fas fa-list"`
 	LossDelay netLossDelayCmd `command:"loss-delay" subcommands-optional:"true" description:"Simulate packet loss or latencies" webicon:"fas fa-building-shield"`
+	Partition netPartitionCmd `command:"partition" subcommands-optional:"true" description:"Split a cluster into groups of nodes which cannot talk to each other" webicon:"fas fa-scissors"`
+	Heal      netHealCmd      `command:"heal" subcommands-optional:"true" description:"Remove partitions created by net partition" webicon:"fas fa-bandage"`
+	Wan       netWanCmd       `command:"wan" subcommands-optional:"true" description:"Emulate WAN links between clusters using named profiles" webicon:"fas fa-globe"`
 	Help      helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
@@ -85,8 +85,17 @@ func (c *netListCmd) Execute(args []string) error {
 		Chain              string
 		RuleAppliedOn      string
 		Behaviour          string
+		Partition          string `json:",omitempty"`
 	}
 	jout := []*rule{}
+	type partition struct {
+		Cluster string
+		Name    string
+		Groups  string
+		Ports   []string
+		Nodes   []int
+	}
+	partitions := make(map[string]*partition)
 
 	//go through all DCs and all nodes and list iptables, display in a nice (this -> that) format
 	clusters := make(map[bool][]string) // map[isClient][]names
@@ -184,6 +193,19 @@ func (c *netListCmd) Execute(args []string) error {
 							dport = strings.Trim(dport, "\n\r")
 							suffix := cutSuffix(line, 12, " ")
 							tb.AppendRow(table.Row{fmt.Sprintf("%s-%d%s", srcC, srcN, srcclienttext), fmt.Sprintf("%s-%d%s", cluster, node, dstclienttext), dport, t, "INPUT", "DestNode", suffix})
+							pName, pGroups, isPartition := netListPartition(line)
+							if isPartition {
+								key := cluster + "/" + pName
+								if _, ok := partitions[key]; !ok {
+									partitions[key] = &partition{Cluster: cluster, Name: pName, Groups: pGroups}
+								}
+								if !inslice.HasString(partitions[key].Ports, dport) {
+									partitions[key].Ports = append(partitions[key].Ports, dport)
+								}
+								if !inslice.HasInt(partitions[key].Nodes, node) {
+									partitions[key].Nodes = append(partitions[key].Nodes, node)
+								}
+							}
 							jout = append(jout, &rule{
 								SourceCluster:      srcC,
 								SourceNode:         srcN,
@@ -196,6 +218,7 @@ func (c *netListCmd) Execute(args []string) error {
 								Chain:              "INPUT",
 								RuleAppliedOn:      "Destination",
 								Behaviour:          suffix,
+								Partition:          pName,
 							})
 						}
 					}
@@ -254,6 +277,19 @@ func (c *netListCmd) Execute(args []string) error {
 	}
 	if !c.Json && !c.PrettyJson {
 		fmt.Println(tb.Render())
+		if len(partitions) > 0 {
+			tp := table.NewWriter()
+			tp.SetStyle(*tb.Style())
+			tp.SetTitle(colorHiWhite.Sprint("PARTITIONS"))
+			tp.AppendHeader(table.Row{"Cluster", "Partition", "Groups", "Ports", "RulesOnNodes"})
+			tp.SortBy([]table.SortBy{{Name: "Cluster", Mode: table.Asc}, {Name: "Partition", Mode: table.Asc}})
+			for _, p := range partitions {
+				sort.Ints(p.Nodes)
+				sort.Strings(p.Ports)
+				tp.AppendRow(table.Row{p.Cluster, p.Name, strings.ReplaceAll(p.Groups, ":", " | "), strings.Join(p.Ports, ","), intSliceToString(p.Nodes, ",")})
+			}
+			fmt.Println(tp.Render())
+		}
 		return nil
 	}
 	//sort json: {"Source", "Destination", "Port", "Type", "Chain", "RuleOn", "Behaviour"}
@@ -353,6 +389,17 @@ func (c *netListCmd) Execute(args []string) error {
 	return nil
 }
 
+// netListPartition returns the partition name and groups from an iptables rule tagged by net partition
+func netListPartition(line string) (name string, groups string, ok bool) {
+	_, tag, found := strings.Cut(line, "/* "+netPartitionTag)
+	if !found {
+		return "", "", false
+	}
+	tag, _, _ = strings.Cut(tag, " */")
+	name, groups, _ = strings.Cut(tag, ":")
+	return name, groups, true
+}
+
 func find_node_by_ip(nodes map[bool]map[string]map[int][]string, ip string) (isClient bool, cluster string, node int) {
 	for isClient, clusters := range nodes {
 		for cluster := range clusters {
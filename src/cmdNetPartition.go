@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/aerospike/aerolab/parallelize"
+)
+
+// partition rules are tagged with an iptables comment in format: aerolab-partition:NAME:GROUPS
+const netPartitionTag = "aerolab-partition:"
+
+var netPartitionNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type netPartitionCmd struct {
+	ClusterName TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
+	Groups      string          `short:"g" long:"groups" description:"Colon-separated groups of comma-separated nodes; nodes in different groups will not be able to talk to each other, e.g. 1,2,3:4,5" webrequired:"true"`
+	Partition   string          `short:"N" long:"partition" description:"Partition name, used by 'net heal' to remove only the rules of this partition" default:"default"`
+	Ports       string          `short:"p" long:"ports" description:"Comma separated list of ports to block; default: fabric, heartbeat and info" default:"3001,3002,3003"`
+	Type        TypeNetType     `long:"type" description:"Block type (reject|drop)." default:"drop" webchoice:"drop,reject"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type netHealCmd struct {
+	ClusterName TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
+	Partition   string          `short:"N" long:"partition" description:"Only remove the rules of this partition name; empty=all partitions created by aerolab" default:""`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// netParsePartitionGroups parses groups in format 1,2,3:4,5; nodes may use ranges, e.g. 1-3:4-5
+func netParsePartitionGroups(groups string) ([][]int, error) {
+	ret := [][]int{}
+	seen := make(map[int]bool)
+	for _, group := range strings.Split(groups, ":") {
+		nodes, err := expandNodeList(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid groups '%s': %s", groups, err)
+		}
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("invalid groups '%s': empty group", groups)
+		}
+		for _, node := range nodes {
+			if seen[node] {
+				return nil, fmt.Errorf("invalid groups '%s': node %d is in more than one group", groups, node)
+			}
+			seen[node] = true
+		}
+		ret = append(ret, nodes)
+	}
+	if len(ret) < 2 {
+		return nil, fmt.Errorf("invalid groups '%s': at least 2 groups are required, e.g. 1,2,3:4,5", groups)
+	}
+	return ret, nil
+}
+
+func (c *netPartitionCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running net.partition")
+	err := c.run()
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *netPartitionCmd) run() error {
+	if !netPartitionNameRegex.MatchString(c.Partition) {
+		return errors.New("partition name must be 1-64 characters of a-z, A-Z, 0-9, _ and -")
+	}
+	if c.Type != "drop" && c.Type != "reject" {
+		return errors.New("type must be one of: drop, reject")
+	}
+	groups, err := netParsePartitionGroups(c.Groups)
+	if err != nil {
+		return err
+	}
+	nodes, err := b.NodeListInCluster(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("cluster %s not found", c.ClusterName)
+	}
+	nodeGroup := make(map[int]int)
+	for gi, group := range groups {
+		for _, node := range group {
+			found := false
+			for _, n := range nodes {
+				if n == node {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("node %d does not exist in cluster %s", node, c.ClusterName)
+			}
+			nodeGroup[node] = gi
+		}
+	}
+	ips := make(map[int][]string)
+	for _, internal := range []bool{false, true} {
+		nodeIps, err := b.GetNodeIpMap(c.ClusterName.String(), internal)
+		if err != nil {
+			return err
+		}
+		for node, ip := range nodeIps {
+			if ip == "" {
+				continue
+			}
+			if len(ips[node]) == 0 || ips[node][0] != ip {
+				ips[node] = append(ips[node], ip)
+			}
+		}
+	}
+	// every node drops input from the nodes of all other groups, which blocks both directions
+	tag := netPartitionTag + c.Partition + ":" + c.Groups
+	ports := strings.Split(c.Ports, ",")
+	commandList := make(map[int][]string)
+	affected := []int{}
+	for node, gi := range nodeGroup {
+		affected = append(affected, node)
+		for otherNode, ogi := range nodeGroup {
+			if gi == ogi {
+				continue
+			}
+			for _, ip := range ips[otherNode] {
+				for _, port := range ports {
+					commandList[node] = append(commandList[node], fmt.Sprintf("/sbin/iptables -I INPUT -p tcp --dport %s --source %s -m comment --comment %s -j %s", port, ip, tag, strings.ToUpper(c.Type.String())))
+				}
+			}
+		}
+	}
+	log.Printf("Partitioning cluster %s into groups %s", c.ClusterName, c.Groups)
+	returns := parallelize.MapLimit(affected, c.ParallelThreads, func(node int) error {
+		script := strings.Join(commandList[node], "\n") + "\n"
+		return netPartitionRunScript(c.ClusterName.String(), node, script)
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", affected[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	return nil
+}
+
+func (c *netHealCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running net.heal")
+	err := c.run()
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *netHealCmd) run() error {
+	if c.Partition != "" && !netPartitionNameRegex.MatchString(c.Partition) {
+		return errors.New("partition name must be 1-64 characters of a-z, A-Z, 0-9, _ and -")
+	}
+	nodes, err := b.NodeListInCluster(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("cluster %s not found", c.ClusterName)
+	}
+	match := netPartitionTag
+	if c.Partition != "" {
+		match = netPartitionTag + c.Partition + ":"
+	}
+	// only remove the rules which carry the aerolab partition tag, leaving any other rules in place
+	script := fmt.Sprintf(`/sbin/iptables -S INPUT | grep -F -- '%s' | sed 's/^-A /-D /' | while read -r rule; do
+	eval /sbin/iptables ${rule} || exit 1
+done
+`, match)
+	log.Printf("Healing partitions on cluster %s", c.ClusterName)
+	returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+		return netPartitionRunScript(c.ClusterName.String(), node, script)
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	return nil
+}
+
+func netPartitionRunScript(clusterName string, node int, script string) error {
+	err := b.CopyFilesToCluster(clusterName, []fileList{{filePath: "/tmp/aerolab-partition.sh", fileContents: script, fileSize: len(script)}}, []int{node})
+	if err != nil {
+		return err
+	}
+	out, err := b.RunCommands(clusterName, [][]string{{"/bin/bash", "/tmp/aerolab-partition.sh"}}, []int{node})
+	if err != nil {
+		if len(out) == 0 {
+			out = [][]byte{{'-'}}
+		}
+		return fmt.Errorf("%s: %s", err, string(out[0]))
+	}
+	return nil
+}
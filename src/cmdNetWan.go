@@ -0,0 +1,650 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/aerospike/aerolab/scripts"
+	"github.com/jedib0t/go-pretty/v6/table"
+	flags "github.com/rglonek/jeddevdk-goflags"
+	"gopkg.in/yaml.v3"
+)
+
+type netWanCmd struct {
+	Set      netWanSetCmd      `command:"set" subcommands-optional:"true" description:"Apply a named WAN profile between clusters or client groups" webicon:"fas fa-globe"`
+	Del      netWanDelCmd      `command:"del" subcommands-optional:"true" description:"Remove WAN profiles between clusters or client groups" webicon:"fas fa-trash"`
+	Show     netWanShowCmd     `command:"show" subcommands-optional:"true" description:"Show WAN profiles applied on a cluster or client group" webicon:"fas fa-list"`
+	Profiles netWanProfilesCmd `command:"profiles" subcommands-optional:"true" description:"List available WAN profiles" webicon:"fas fa-book"`
+	Help     helpCmd           `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *netWanCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+type netWanSelectCmd struct {
+	SourceClusterName      TypeClusterName `short:"s" long:"source" description:"Source Cluster name/Client group" default:"mydc"`
+	SourceNodeList         TypeNodes       `short:"l" long:"source-node-list" description:"List of source nodes. Empty=ALL." default:""`
+	IsSourceClient         bool            `short:"c" long:"source-client" description:"set to indicate the source is a client group"`
+	DestinationClusterName TypeClusterName `short:"d" long:"destination" description:"Destination Cluster name/Client group" default:"mydc-xdr"`
+	DestinationNodeList    TypeNodes       `short:"i" long:"destination-node-list" description:"List of destination nodes. Empty=ALL." default:""`
+	IsDestinationClient    bool            `short:"C" long:"destination-client" description:"set to indicate the destination is a client group"`
+	OneWay                 bool            `short:"o" long:"one-way" description:"only shape traffic from source to destination; by default traffic is shaped in both directions"`
+}
+
+type netWanSetCmd struct {
+	netWanSelectCmd
+	Profile      string         `short:"p" long:"profile" description:"WAN profile name, see: net wan profiles" default:"transatlantic"`
+	ProfilesFile flags.Filename `short:"f" long:"profiles-file" description:"yaml file with custom WAN profiles; default: ${AEROLAB_HOME}/wan-profiles.yaml, if it exists"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type netWanDelCmd struct {
+	netWanSelectCmd
+	All bool `short:"a" long:"all" description:"remove all WAN profiles from the source nodes; destination is ignored"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type netWanShowCmd struct {
+	ClusterName TypeClusterName `short:"n" long:"name" description:"Cluster name/Client group" default:"mydc"`
+	Nodes       TypeNodes       `short:"l" long:"nodes" description:"List of nodes. Empty=ALL." default:""`
+	IsClient    bool            `short:"c" long:"client" description:"set to indicate this is a client group"`
+	Json        bool            `short:"j" long:"json" description:"Provide output in json format"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type netWanProfilesCmd struct {
+	ProfilesFile flags.Filename `short:"f" long:"profiles-file" description:"yaml file with custom WAN profiles; default: ${AEROLAB_HOME}/wan-profiles.yaml, if it exists"`
+	Json         bool           `short:"j" long:"json" description:"Provide output in json format"`
+	Help         helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// netWanProfile describes one direction of a WAN link; latency is one-way, so round-trip time is roughly twice the latency when shaping both directions
+type netWanProfile struct {
+	Description  string `yaml:"description"`
+	Latency      string `yaml:"latency"`
+	Jitter       string `yaml:"jitter"`
+	Correlation  string `yaml:"correlation"`
+	Distribution string `yaml:"distribution"`
+	Loss         string `yaml:"loss"`
+	Duplicate    string `yaml:"duplicate"`
+	Reorder      string `yaml:"reorder"`
+	Corrupt      string `yaml:"corrupt"`
+	Rate         string `yaml:"rate"`
+	Limit        int    `yaml:"limit"`
+	source       string
+}
+
+var netWanBuiltinProfiles = map[string]netWanProfile{
+	"lan": {
+		Description:  "same datacenter, different racks",
+		Latency:      "0.5ms",
+		Jitter:       "0.1ms",
+		Distribution: "normal",
+	},
+	"cross-region": {
+		Description:  "regions on the same continent, ~30ms RTT",
+		Latency:      "15ms",
+		Jitter:       "2ms",
+		Distribution: "normal",
+		Loss:         "0.01%",
+	},
+	"transatlantic": {
+		Description:  "US east to Europe, ~80ms RTT",
+		Latency:      "40ms",
+		Jitter:       "5ms",
+		Distribution: "normal",
+		Loss:         "0.01%",
+	},
+	"transpacific": {
+		Description:  "US west to Asia, ~140ms RTT",
+		Latency:      "70ms",
+		Jitter:       "8ms",
+		Distribution: "normal",
+		Loss:         "0.05%",
+	},
+	"lossy-mobile": {
+		Description:  "mobile network with heavy jitter, loss, duplication and reordering, 5mbit",
+		Latency:      "60ms",
+		Jitter:       "30ms",
+		Correlation:  "25%",
+		Distribution: "pareto",
+		Loss:         "3%",
+		Duplicate:    "0.5%",
+		Reorder:      "2%",
+		Rate:         "5mbit",
+	},
+	"congested-10mbit": {
+		Description:  "congested 10mbit link with queueing jitter and loss",
+		Latency:      "20ms",
+		Jitter:       "10ms",
+		Distribution: "paretonormal",
+		Loss:         "1%",
+		Reorder:      "0.5%",
+		Rate:         "10mbit",
+	},
+	"satellite": {
+		Description:  "geostationary satellite link, ~600ms RTT, 20mbit",
+		Latency:      "300ms",
+		Jitter:       "15ms",
+		Distribution: "normal",
+		Loss:         "0.5%",
+		Rate:         "20mbit",
+	},
+}
+
+var (
+	netWanProfileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	netWanTimeRegex        = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(us|ms|s)?$`)
+	netWanPctRegex         = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?%?$`)
+	netWanRateRegex        = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(bit|kbit|mbit|gbit|tbit|bps|kbps|mbps|gbps|tbps)$`)
+)
+
+// netWanTime returns a netem time value; values without a unit are in milliseconds
+func netWanTime(name string, v string) (string, error) {
+	if !netWanTimeRegex.MatchString(v) {
+		return "", fmt.Errorf("invalid %s '%s', expected a number with optional unit (us|ms|s)", name, v)
+	}
+	if strings.HasSuffix(v, "s") {
+		return v, nil
+	}
+	return v + "ms", nil
+}
+
+// netWanPct returns a netem percentage value
+func netWanPct(name string, v string) (string, error) {
+	if !netWanPctRegex.MatchString(v) {
+		return "", fmt.Errorf("invalid %s '%s', expected a percentage", name, v)
+	}
+	return strings.TrimSuffix(v, "%") + "%", nil
+}
+
+// netemArgs converts a profile to tc netem parameters
+func (p *netWanProfile) netemArgs() ([]string, error) {
+	args := []string{}
+	if p.Latency == "" && (p.Jitter != "" || p.Distribution != "" || p.Correlation != "") {
+		return nil, errors.New("jitter, correlation and distribution require latency to be set")
+	}
+	if p.Latency == "" && p.Reorder != "" {
+		return nil, errors.New("reorder requires latency to be set")
+	}
+	if p.Jitter == "" && (p.Distribution != "" || p.Correlation != "") {
+		return nil, errors.New("correlation and distribution require jitter to be set")
+	}
+	if p.Latency != "" {
+		v, err := netWanTime("latency", p.Latency)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "delay", v)
+		if p.Jitter != "" {
+			v, err = netWanTime("jitter", p.Jitter)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+			if p.Correlation != "" {
+				v, err = netWanPct("correlation", p.Correlation)
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, v)
+			}
+			if p.Distribution != "" {
+				switch p.Distribution {
+				case "uniform":
+					// netem default for jitter
+				case "normal", "pareto", "paretonormal":
+					args = append(args, "distribution", p.Distribution)
+				default:
+					return nil, fmt.Errorf("invalid distribution '%s', expected one of: uniform, normal, pareto, paretonormal", p.Distribution)
+				}
+			}
+		}
+	}
+	for _, param := range []struct {
+		name  string
+		value string
+	}{
+		{"loss", p.Loss},
+		{"duplicate", p.Duplicate},
+		{"reorder", p.Reorder},
+		{"corrupt", p.Corrupt},
+	} {
+		if param.value == "" {
+			continue
+		}
+		v, err := netWanPct(param.name, param.value)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, param.name, v)
+	}
+	if p.Rate != "" {
+		if !netWanRateRegex.MatchString(p.Rate) {
+			return nil, fmt.Errorf("invalid rate '%s', expected a number with unit, ex: 10mbit", p.Rate)
+		}
+		args = append(args, "rate", p.Rate)
+	}
+	if len(args) == 0 {
+		return nil, errors.New("profile does not define any parameters")
+	}
+	// the default netem queue of 1000 packets is too small to hold in-flight packets on a high latency link
+	limit := p.Limit
+	if limit < 0 {
+		return nil, errors.New("limit cannot be negative")
+	}
+	if limit == 0 {
+		limit = 100000
+	}
+	args = append(args, "limit", strconv.Itoa(limit))
+	return args, nil
+}
+
+// netWanLoadProfiles returns the built-in profiles, overridden and extended by the profiles from the yaml file
+func netWanLoadProfiles(fileName string) (map[string]netWanProfile, error) {
+	profiles := make(map[string]netWanProfile)
+	for name, p := range netWanBuiltinProfiles {
+		p.source = "built-in"
+		profiles[name] = p
+	}
+	if fileName == "" {
+		rootDir, err := a.aerolabRootDir()
+		if err != nil {
+			return nil, err
+		}
+		fileName = filepath.Join(rootDir, "wan-profiles.yaml")
+		if _, err := os.Stat(fileName); err != nil {
+			return profiles, nil
+		}
+	}
+	f, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read WAN profiles file: %s", err)
+	}
+	userProfiles := make(map[string]netWanProfile)
+	dec := yaml.NewDecoder(bytes.NewReader(f))
+	dec.KnownFields(true)
+	err = dec.Decode(&userProfiles)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("could not parse WAN profiles file %s: %s", fileName, err)
+	}
+	for name, p := range userProfiles {
+		if !netWanProfileNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("WAN profiles file %s: profile name '%s' must be 1-64 characters of a-z, A-Z, 0-9, _ and -", fileName, name)
+		}
+		if _, err := p.netemArgs(); err != nil {
+			return nil, fmt.Errorf("WAN profiles file %s: profile %s: %s", fileName, name, err)
+		}
+		p.source = fileName
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// netWanNode is a cluster or client node, with all its IP addresses
+type netWanNode struct {
+	name     string
+	node     int
+	isClient bool
+	ips      []string
+}
+
+func (n *netWanNode) String() string {
+	if n.isClient {
+		return fmt.Sprintf("client:%s:%d", n.name, n.node)
+	}
+	return fmt.Sprintf("cluster:%s:%d", n.name, n.node)
+}
+
+func netWanFindNodes(inv inventoryJson, name string, nodes string, isClient bool) ([]*netWanNode, error) {
+	ret := []*netWanNode{}
+	add := func(name string, nodeNo string, privateIp string, publicIp string) error {
+		nno, err := strconv.Atoi(nodeNo)
+		if err != nil {
+			return err
+		}
+		n := &netWanNode{name: name, node: nno, isClient: isClient}
+		if privateIp != "" {
+			n.ips = append(n.ips, privateIp)
+		}
+		if publicIp != "" && publicIp != privateIp {
+			n.ips = append(n.ips, publicIp)
+		}
+		ret = append(ret, n)
+		return nil
+	}
+	if isClient {
+		list, err := (&netLossDelayCmd{}).findClient(inv, name, nodes, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		for _, i := range list {
+			if err = add(i.ClientName, i.NodeNo, i.PrivateIp, i.PublicIp); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		list, err := (&netLossDelayCmd{}).findCluster(inv, name, nodes, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		for _, i := range list {
+			if err = add(i.ClusterName, i.NodeNo, i.PrivateIp, i.PublicIp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ret, nil
+}
+
+// netWanRun runs the WAN script with the given commands appended on each node; client groups and clusters are handled separately, as the backend needs switching between them
+func netWanRun(nodes []*netWanNode, commands map[*netWanNode][]string, threads int) (map[*netWanNode]string, error) {
+	outputs := make(map[*netWanNode]string)
+	outputsLock := new(sync.Mutex)
+	isError := false
+	for _, isClient := range []bool{true, false} {
+		list := []*netWanNode{}
+		for _, n := range nodes {
+			if n.isClient == isClient && len(commands[n]) > 0 {
+				list = append(list, n)
+			}
+		}
+		if len(list) == 0 {
+			continue
+		}
+		if isClient {
+			b.WorkOnClients()
+		}
+		returns := parallelize.MapLimit(list, threads, func(n *netWanNode) error {
+			script := scripts.GetNetWan() + "\n" + strings.Join(commands[n], "\n") + "\n"
+			err := b.CopyFilesToCluster(n.name, []fileList{{filePath: "/tmp/aerolab-wan.sh", fileContents: script, fileSize: len(script)}}, []int{n.node})
+			if err != nil {
+				return err
+			}
+			out, err := b.RunCommands(n.name, [][]string{{"/bin/bash", "/tmp/aerolab-wan.sh"}}, []int{n.node})
+			if err != nil {
+				if len(out) == 0 {
+					out = [][]byte{{'-'}}
+				}
+				return fmt.Errorf("%s: %s", err, string(out[0]))
+			}
+			outputsLock.Lock()
+			outputs[n] = string(out[0])
+			outputsLock.Unlock()
+			return nil
+		})
+		b.WorkOnServers()
+		for i, ret := range returns {
+			if ret != nil {
+				log.Printf("Node %s returned %s", list[i].String(), ret)
+				isError = true
+			}
+		}
+	}
+	if isError {
+		return outputs, errors.New("some nodes returned errors")
+	}
+	return outputs, nil
+}
+
+// commands returns the source and destination nodes, and the commands to run on each of them; for each node, cmd is called with the IP of every node on the other side
+func (c *netWanSelectCmd) commands(cmd func(ip string) string) ([]*netWanNode, map[*netWanNode][]string, error) {
+	inv, err := b.Inventory("", []int{InventoryItemClusters, InventoryItemClients})
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := netWanFindNodes(inv, c.SourceClusterName.String(), c.SourceNodeList.String(), c.IsSourceClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := netWanFindNodes(inv, c.DestinationClusterName.String(), c.DestinationNodeList.String(), c.IsDestinationClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	commands := make(map[*netWanNode][]string)
+	nodes := []*netWanNode{}
+	addCommands := func(from []*netWanNode, to []*netWanNode) {
+		for _, f := range from {
+			if _, ok := commands[f]; !ok {
+				nodes = append(nodes, f)
+			}
+			for _, t := range to {
+				if f.String() == t.String() {
+					continue
+				}
+				for _, ip := range t.ips {
+					commands[f] = append(commands[f], cmd(ip))
+				}
+			}
+		}
+	}
+	addCommands(src, dst)
+	if !c.OneWay {
+		addCommands(dst, src)
+	}
+	return nodes, commands, nil
+}
+
+func (c *netWanSetCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running net.wan.set")
+	err := c.run()
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *netWanSetCmd) run() error {
+	profiles, err := netWanLoadProfiles(string(c.ProfilesFile))
+	if err != nil {
+		return err
+	}
+	profile, ok := profiles[c.Profile]
+	if !ok {
+		return fmt.Errorf("WAN profile %s not found, see: aerolab net wan profiles", c.Profile)
+	}
+	netemArgs, err := profile.netemArgs()
+	if err != nil {
+		return fmt.Errorf("profile %s: %s", c.Profile, err)
+	}
+	nodes, commands, err := c.commands(func(ip string) string {
+		return fmt.Sprintf("wan_set %s %s %s", ip, c.Profile, strings.Join(netemArgs, " "))
+	})
+	if err != nil {
+		return err
+	}
+	direction := "in both directions"
+	if c.OneWay {
+		direction = "one-way"
+	}
+	log.Printf("Applying WAN profile %s between %s and %s %s: netem %s", c.Profile, c.SourceClusterName, c.DestinationClusterName, direction, strings.Join(netemArgs, " "))
+	_, err = netWanRun(nodes, commands, c.ParallelThreads)
+	return err
+}
+
+func (c *netWanDelCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running net.wan.del")
+	err := c.run()
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *netWanDelCmd) run() error {
+	if c.All {
+		inv, err := b.Inventory("", []int{InventoryItemClusters, InventoryItemClients})
+		if err != nil {
+			return err
+		}
+		nodes, err := netWanFindNodes(inv, c.SourceClusterName.String(), c.SourceNodeList.String(), c.IsSourceClient)
+		if err != nil {
+			return err
+		}
+		commands := make(map[*netWanNode][]string)
+		for _, n := range nodes {
+			commands[n] = []string{"wan_delall"}
+		}
+		log.Printf("Removing all WAN profiles from %s", c.SourceClusterName)
+		_, err = netWanRun(nodes, commands, c.ParallelThreads)
+		return err
+	}
+	nodes, commands, err := c.commands(func(ip string) string {
+		return "wan_del " + ip
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Removing WAN profiles between %s and %s", c.SourceClusterName, c.DestinationClusterName)
+	_, err = netWanRun(nodes, commands, c.ParallelThreads)
+	return err
+}
+
+func (c *netWanShowCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	inv, err := b.Inventory("", []int{InventoryItemClusters, InventoryItemClients})
+	if err != nil {
+		return err
+	}
+	nodes, err := netWanFindNodes(inv, c.ClusterName.String(), c.Nodes.String(), c.IsClient)
+	if err != nil {
+		return err
+	}
+	ipmap := make(map[string]string)
+	for _, i := range inv.Clients {
+		for _, ip := range []string{i.PrivateIp, i.PublicIp} {
+			if ip != "" {
+				ipmap[ip] = fmt.Sprintf("client:%s:%s", i.ClientName, i.NodeNo)
+			}
+		}
+	}
+	for _, i := range inv.Clusters {
+		for _, ip := range []string{i.PrivateIp, i.PublicIp} {
+			if ip != "" {
+				ipmap[ip] = fmt.Sprintf("cluster:%s:%s", i.ClusterName, i.NodeNo)
+			}
+		}
+	}
+	commands := make(map[*netWanNode][]string)
+	for _, n := range nodes {
+		commands[n] = []string{"wan_show"}
+	}
+	outputs, err := netWanRun(nodes, commands, c.ParallelThreads)
+	if err != nil {
+		return err
+	}
+	type wanRule struct {
+		Node          string
+		DestinationIp string
+		Destination   string
+		Profile       string
+		Netem         string
+	}
+	rules := []wanRule{}
+	for _, n := range nodes {
+		for _, line := range strings.Split(outputs[n], "\n") {
+			fields := strings.SplitN(strings.Trim(line, "\r\t "), " ", 3)
+			if len(fields) < 2 || len(findIP(fields[0])) == 0 {
+				continue
+			}
+			rule := wanRule{
+				Node:          n.String(),
+				DestinationIp: fields[0],
+				Destination:   ipmap[fields[0]],
+				Profile:       fields[1],
+			}
+			if len(fields) == 3 {
+				rule.Netem = fields[2]
+			}
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Node != rules[j].Node {
+			return rules[i].Node < rules[j].Node
+		}
+		return rules[i].DestinationIp < rules[j].DestinationIp
+	})
+	if c.Json {
+		return json.NewEncoder(os.Stdout).Encode(rules)
+	}
+	tb := table.NewWriter()
+	tb.SetStyle(table.StyleLight)
+	tb.AppendHeader(table.Row{"Node", "DestinationIP", "Destination", "Profile", "Netem"})
+	for _, rule := range rules {
+		tb.AppendRow(table.Row{rule.Node, rule.DestinationIp, rule.Destination, rule.Profile, rule.Netem})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
+
+func (c *netWanProfilesCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	profiles, err := netWanLoadProfiles(string(c.ProfilesFile))
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	type profileJson struct {
+		Name        string
+		Source      string
+		Description string
+		Netem       string
+	}
+	list := []profileJson{}
+	for _, name := range names {
+		p := profiles[name]
+		netemArgs, _ := p.netemArgs()
+		list = append(list, profileJson{
+			Name:        name,
+			Source:      p.source,
+			Description: p.Description,
+			Netem:       strings.Join(netemArgs, " "),
+		})
+	}
+	if c.Json {
+		return json.NewEncoder(os.Stdout).Encode(list)
+	}
+	tb := table.NewWriter()
+	tb.SetStyle(table.StyleLight)
+	tb.AppendHeader(table.Row{"Profile", "Source", "Description", "Netem"})
+	for _, p := range list {
+		tb.AppendRow(table.Row{p.Name, p.Source, p.Description, p.Netem})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
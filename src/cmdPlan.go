@@ -0,0 +1,420 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type planCmd struct {
+	TopologyFile flags.Filename `short:"f" long:"file" description:"Topology yaml file describing the lab" default:"topology.yaml"`
+	Json         bool           `short:"j" long:"json" description:"set to display the plan in json format"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+const (
+	topologyActionNone   = "none"
+	topologyActionCreate = "create"
+	topologyActionGrow   = "grow"
+	topologyActionUpdate = "update"
+	topologyActionWarn   = "warn"
+)
+
+type topologyPlan struct {
+	Items []*topologyPlanItem
+}
+
+type topologyPlanItem struct {
+	Kind          string
+	Name          string
+	Action        string
+	Detail        string
+	count         int
+	cluster       string
+	nodes         []int
+	recheck       bool
+	distro        string
+	distroVersion string
+	isArm         bool
+	tls           *topologyTls
+	conf          *topologyConf
+	xdr           *topologyXdr
+	xdrMissing    []string
+}
+
+func (c *planCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	t, err := loadTopology(string(c.TopologyFile))
+	if err != nil {
+		return err
+	}
+	plan, err := t.plan(c.ParallelThreads)
+	if err != nil {
+		return err
+	}
+	if c.Json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+	plan.print(os.Stdout)
+	return nil
+}
+
+func (p *topologyPlan) hasChanges() bool {
+	for _, item := range p.Items {
+		if item.Action != topologyActionNone && item.Action != topologyActionWarn {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *topologyPlan) print(w io.Writer) {
+	changes := 0
+	for _, item := range p.Items {
+		sign := "="
+		switch item.Action {
+		case topologyActionCreate:
+			sign = "+"
+			changes++
+		case topologyActionGrow, topologyActionUpdate:
+			sign = "~"
+			changes++
+		case topologyActionWarn:
+			sign = "!"
+		}
+		fmt.Fprintf(w, "%s %s %s: %s\n", sign, item.Kind, item.Name, item.Detail)
+	}
+	fmt.Fprintf(w, "Plan: %d change(s)\n", changes)
+}
+
+// plan compares the topology against the backend inventory and the configuration on the running nodes
+func (t *topology) plan(threads int) (*topologyPlan, error) {
+	inv, err := b.Inventory("", []int{InventoryItemClusters, InventoryItemClients})
+	if err != nil {
+		return nil, err
+	}
+	clusterNodes := make(map[string]int)
+	clusterInv := make(map[string][]inventoryCluster)
+	for _, node := range inv.Clusters {
+		clusterNodes[node.ClusterName]++
+		clusterInv[node.ClusterName] = append(clusterInv[node.ClusterName], node)
+	}
+	clientNodes := make(map[string]int)
+	clientTypes := make(map[string]string)
+	for _, node := range inv.Clients {
+		clientNodes[node.ClientName]++
+		clientTypes[node.ClientName] = node.ClientType
+	}
+	p := &topologyPlan{}
+	// clusters which will be created by this plan cannot be inspected, so anything on them is a create
+	newClusters := []string{}
+	grownClusters := []string{}
+	for _, tc := range t.Clusters {
+		item := &topologyPlanItem{
+			Kind: "cluster",
+			Name: tc.Name,
+		}
+		existing, ok := clusterNodes[tc.Name]
+		switch {
+		case !ok:
+			item.Action = topologyActionCreate
+			item.count = tc.Nodes
+			item.Detail = fmt.Sprintf("create %d node(s)", tc.Nodes)
+			if tc.Version != "" {
+				item.Detail += " with aerospike " + tc.Version
+			}
+			newClusters = append(newClusters, tc.Name)
+		case existing < tc.Nodes:
+			item.Action = topologyActionGrow
+			item.count = tc.Nodes - existing
+			item.Detail = fmt.Sprintf("grow from %d to %d node(s)", existing, tc.Nodes)
+			grownClusters = append(grownClusters, tc.Name)
+		case existing > tc.Nodes:
+			item.Action = topologyActionWarn
+			item.Detail = fmt.Sprintf("has %d node(s), topology expects %d; clusters are not shrunk, destroy nodes manually", existing, tc.Nodes)
+		default:
+			item.Action = topologyActionNone
+			item.Detail = fmt.Sprintf("%d node(s)", existing)
+		}
+		p.Items = append(p.Items, item)
+		if ok && tc.Version != "" {
+			if vitem := t.planVersion(tc, clusterInv[tc.Name]); vitem != nil {
+				p.Items = append(p.Items, vitem)
+			}
+		}
+	}
+	for _, tc := range t.Clients {
+		item := &topologyPlanItem{
+			Kind: "client",
+			Name: tc.Name,
+		}
+		existing, ok := clientNodes[tc.Name]
+		switch {
+		case !ok:
+			item.Action = topologyActionCreate
+			item.Detail = fmt.Sprintf("create %d %s client(s)", tc.Count, tc.Type)
+			if len(tc.Clusters) > 0 {
+				item.Detail += " monitoring " + strings.Join(tc.Clusters, ",")
+			}
+		case clientTypes[tc.Name] != tc.Type:
+			item.Action = topologyActionWarn
+			item.Detail = fmt.Sprintf("exists with type %s, topology expects %s", clientTypes[tc.Name], tc.Type)
+		case existing != tc.Count:
+			item.Action = topologyActionWarn
+			item.Detail = fmt.Sprintf("has %d client(s), topology expects %d; use 'client grow' or 'client destroy' to adjust", existing, tc.Count)
+		default:
+			item.Action = topologyActionNone
+			item.Detail = fmt.Sprintf("%d %s client(s)", existing, tc.Type)
+		}
+		p.Items = append(p.Items, item)
+	}
+	for _, tt := range t.Tls {
+		item := &topologyPlanItem{
+			Kind:    "tls",
+			Name:    tt.Cluster + "/" + tt.TlsName,
+			cluster: tt.Cluster,
+			tls:     tt,
+		}
+		if inslice.HasString(newClusters, tt.Cluster) {
+			item.Action = topologyActionCreate
+			item.Detail = "generate certificates"
+		} else if _, ok := clusterNodes[tt.Cluster]; !ok {
+			return nil, fmt.Errorf("tls: cluster %s does not exist and is not defined in topology", tt.Cluster)
+		} else {
+			missing, err := t.planTlsMissing(tt, threads)
+			if err != nil {
+				return nil, err
+			}
+			item.nodes = missing
+			switch {
+			case len(missing) > 0:
+				item.Action = topologyActionCreate
+				item.Detail = "generate certificates, missing on nodes " + intSliceToString(missing, ",")
+				if inslice.HasString(grownClusters, tt.Cluster) {
+					item.Detail += " and the new nodes"
+					item.recheck = true
+				}
+			case inslice.HasString(grownClusters, tt.Cluster):
+				// the numbers of the new nodes are only known once the cluster is grown
+				item.Action = topologyActionCreate
+				item.Detail = "generate certificates on the new nodes"
+				item.recheck = true
+			default:
+				item.Action = topologyActionNone
+				item.Detail = "certificates present on all nodes"
+			}
+		}
+		p.Items = append(p.Items, item)
+	}
+	for _, tc := range t.Conf {
+		item := &topologyPlanItem{
+			Kind:    "conf",
+			Name:    tc.Cluster + "/" + tc.Key,
+			cluster: tc.Cluster,
+			conf:    tc,
+		}
+		desired := "set to '" + strings.Join(tc.Values, " ") + "'"
+		if tc.Delete {
+			desired = "delete"
+		}
+		if inslice.HasString(newClusters, tc.Cluster) {
+			item.Action = topologyActionUpdate
+			item.Detail = desired
+		} else if _, ok := clusterNodes[tc.Cluster]; !ok {
+			return nil, fmt.Errorf("conf: cluster %s does not exist and is not defined in topology", tc.Cluster)
+		} else {
+			changed, err := t.planConfChanged(tc, threads)
+			if err != nil {
+				return nil, err
+			}
+			if len(changed) == 0 {
+				item.Action = topologyActionNone
+				item.Detail = "up to date"
+			} else {
+				item.Action = topologyActionUpdate
+				item.Detail = desired + " on nodes " + intSliceToString(changed, ",")
+			}
+		}
+		p.Items = append(p.Items, item)
+	}
+	for _, tx := range t.Xdr {
+		item := &topologyPlanItem{
+			Kind:    "xdr",
+			Name:    tx.Source,
+			cluster: tx.Source,
+			xdr:     tx,
+		}
+		for _, dst := range tx.Destinations {
+			if _, ok := clusterNodes[dst]; !ok && !inslice.HasString(newClusters, dst) {
+				return nil, fmt.Errorf("xdr: destination cluster %s does not exist and is not defined in topology", dst)
+			}
+		}
+		if inslice.HasString(newClusters, tx.Source) {
+			item.xdrMissing = tx.Destinations
+		} else if _, ok := clusterNodes[tx.Source]; !ok {
+			return nil, fmt.Errorf("xdr: source cluster %s does not exist and is not defined in topology", tx.Source)
+		} else {
+			item.xdrMissing, err = t.planXdrMissing(tx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(item.xdrMissing) == 0 {
+			item.Action = topologyActionNone
+			item.Detail = "connected to " + strings.Join(tx.Destinations, ",")
+		} else {
+			item.Action = topologyActionCreate
+			item.Detail = "connect to " + strings.Join(item.xdrMissing, ",") + " for namespaces " + strings.Join(tx.Namespaces, ",")
+		}
+		p.Items = append(p.Items, item)
+	}
+	return p, nil
+}
+
+// planVersion reports the nodes of an existing cluster which do not run the aerospike version of the topology
+func (t *topology) planVersion(tc *topologyCluster, nodes []inventoryCluster) *topologyPlanItem {
+	item := &topologyPlanItem{
+		Kind:    "version",
+		Name:    tc.Name,
+		cluster: tc.Name,
+	}
+	running := []string{}
+	for _, node := range nodes {
+		if node.AerospikeVersion == "" || topologyVersionMatches(node.AerospikeVersion, tc.Version) {
+			continue
+		}
+		nodeNo, err := strconv.Atoi(node.NodeNo)
+		if err != nil {
+			continue
+		}
+		item.nodes = append(item.nodes, nodeNo)
+		if !inslice.HasString(running, node.AerospikeVersion) {
+			running = append(running, node.AerospikeVersion)
+		}
+		item.distro = node.Distribution
+		item.distroVersion = node.OSVersion
+		item.isArm = node.Arch == "arm64"
+	}
+	if len(item.nodes) == 0 {
+		return nil
+	}
+	sort.Ints(item.nodes)
+	item.Action = topologyActionUpdate
+	item.Detail = fmt.Sprintf("upgrade nodes %s from %s to aerospike %s", intSliceToString(item.nodes, ","), strings.Join(running, ","), tc.Version)
+	return item
+}
+
+// topologyVersionMatches checks an installed version against a topology version, which may be partial (7.1) or end with a wildcard (7.1.*); 'latest' cannot be resolved offline, so it always matches
+func topologyVersionMatches(installed string, want string) bool {
+	want = strings.TrimSuffix(strings.TrimSuffix(want, "*"), ".")
+	if want == "" || want == "latest" {
+		return true
+	}
+	installed = strings.TrimRight(installed, "cf")
+	want = strings.TrimRight(want, "cf")
+	return installed == want || strings.HasPrefix(installed, want+".")
+}
+
+func (t *topology) planTlsMissing(tt *topologyTls, threads int) ([]int, error) {
+	nodes, err := b.NodeListInCluster(tt.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	returns := parallelize.MapLimit(nodes, threads, func(node int) error {
+		_, err := b.RunCommands(tt.Cluster, [][]string{{"ls", "/etc/aerospike/ssl/" + tt.TlsName + "/cert.pem"}}, []int{node})
+		return err
+	})
+	missing := []int{}
+	for i, ret := range returns {
+		if ret != nil {
+			missing = append(missing, nodes[i])
+		}
+	}
+	return missing, nil
+}
+
+func (t *topology) planConfChanged(tc *topologyConf, threads int) ([]int, error) {
+	nodes, err := b.NodeListInCluster(tc.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	changed := make([]bool, len(nodes))
+	returns := parallelize.MapLimit(nodes, threads, func(node int) error {
+		s, err := topologyReadConf(tc.Cluster, node)
+		if err != nil {
+			return err
+		}
+		isChanged, err := tc.patch(s)
+		if err != nil {
+			return err
+		}
+		for i := range nodes {
+			if nodes[i] == node {
+				changed[i] = isChanged
+			}
+		}
+		return nil
+	})
+	ret := []int{}
+	for i := range returns {
+		if returns[i] != nil {
+			log.Printf("Node %d returned %s", nodes[i], returns[i])
+			return nil, fmt.Errorf("could not read configuration of cluster %s", tc.Cluster)
+		}
+		if changed[i] {
+			ret = append(ret, nodes[i])
+		}
+	}
+	return ret, nil
+}
+
+// xdr configuration is read from the first node of the source cluster; xdr connect configures all nodes the same way
+func (t *topology) planXdrMissing(tx *topologyXdr) ([]string, error) {
+	nodes, err := b.NodeListInCluster(tx.Source)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return tx.Destinations, nil
+	}
+	s, err := topologyReadConf(tx.Source, nodes[0])
+	if err != nil {
+		return nil, err
+	}
+	missing := []string{}
+	xdr := s.Stanza("xdr")
+	for _, dst := range tx.Destinations {
+		if xdr == nil {
+			missing = append(missing, dst)
+			continue
+		}
+		// aerospike 5+ uses 'dc NAME' with namespace sub-stanzas, older versions use 'datacenter NAME'
+		if dc := xdr.Stanza("dc " + dst); dc != nil {
+			for _, ns := range tx.Namespaces {
+				if dc.Stanza("namespace "+ns) == nil {
+					missing = append(missing, dst)
+					break
+				}
+			}
+			continue
+		}
+		if xdr.Stanza("datacenter "+dst) == nil {
+			missing = append(missing, dst)
+		}
+	}
+	return missing, nil
+}
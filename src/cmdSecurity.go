@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+)
+
+type securityCmd struct {
+	Enable securityEnableCmd `command:"enable" subcommands-optional:"true" description:"Enable security in aerospike.conf and restart the cluster" webicon:"fas fa-lock"`
+	User   securityUserCmd   `command:"user" subcommands-optional:"true" description:"Create, grant roles to and list users" webicon:"fas fa-user"`
+	Role   securityRoleCmd   `command:"role" subcommands-optional:"true" description:"Create roles" webicon:"fas fa-user-tag"`
+	Help   helpCmd           `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *securityCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+// securityCreds are the credentials aerolab uses to connect to a cluster which has security enabled
+type securityCreds struct {
+	User         string
+	Password     string
+	AuthExternal bool
+}
+
+// securityCredsFile returns the path of the credentials state file of a cluster: ${AEROLAB_HOME}/security/BACKEND/CLUSTER.json
+func securityCredsFile(clusterName string) (string, error) {
+	rootDir, err := a.aerolabRootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rootDir, "security", a.opts.Config.Backend.Type, clusterName+".json"), nil
+}
+
+// securityLoadCreds loads the stored credentials of a cluster; returns nil if none are stored
+func securityLoadCreds(clusterName string) (*securityCreds, error) {
+	fn, err := securityCredsFile(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	creds := &securityCreds{}
+	err = json.Unmarshal(data, creds)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %s", fn, err)
+	}
+	return creds, nil
+}
+
+func securitySaveCreds(clusterName string, creds *securityCreds) error {
+	fn, err := securityCredsFile(clusterName)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(fn), 0700)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, data, 0600)
+}
+
+// securityToolsConfFile is the astools config file through which the stored credentials are passed to the tools on the nodes, so that the password does not show in the process list
+const securityToolsConfFile = "/opt/aerolab.astools.conf"
+
+// securityAuthArgs uploads the stored credentials of a cluster to the given nodes (nil for all nodes) and returns the aql/asadm/asinfo parameters which use them, unless a user is already specified in args
+func securityAuthArgs(clusterName string, nodes []int, args []string) []string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-U") || strings.HasPrefix(arg, "--user") {
+			return nil
+		}
+	}
+	creds, err := securityLoadCreds(clusterName)
+	if err != nil {
+		log.Printf("WARN: could not load stored credentials: %s", err)
+		return nil
+	}
+	if creds == nil {
+		return nil
+	}
+	if nodes == nil {
+		nodes, err = b.NodeListInCluster(clusterName)
+		if err != nil {
+			log.Printf("WARN: could not list cluster nodes, stored credentials will not be used: %s", err)
+			return nil
+		}
+	}
+	conf := "[cluster]\nuser = " + strconv.Quote(creds.User) + "\npassword = " + strconv.Quote(creds.Password) + "\n"
+	if creds.AuthExternal {
+		conf += "auth = \"EXTERNAL_INSECURE\"\n"
+	}
+	err = b.CopyFilesToCluster(clusterName, []fileList{{securityToolsConfFile, conf, len(conf)}}, nodes)
+	if err == nil {
+		_, err = b.RunCommands(clusterName, [][]string{{"chmod", "600", securityToolsConfFile}}, nodes)
+	}
+	if err != nil {
+		log.Printf("WARN: could not upload stored credentials, they will not be used: %s", err)
+		return nil
+	}
+	// the tools read this file after the default astools.conf, so other tools settings still apply
+	return []string{"--config-file=" + securityToolsConfFile}
+}
+
+// securityClientCmd runs the security commands which use the aerospike client like the data commands: aerolab copies itself to a node and runs the command there, unless run-direct is set
+type securityClientCmd struct {
+	dataClientCmd
+}
+
+// run runs the command on a node, or if run-direct is set, connects to the seed node and calls do; managing users and roles requires credentials, so they must be given or stored for the cluster
+func (c *securityClientCmd) run(command []string, cmdStruct interface{}, do func(client *aerospike.Client, policy *aerospike.AdminPolicy) error) error {
+	if c.RunJson == "" && !c.RunDirect && c.User == "" {
+		creds, err := securityLoadCreds(c.ClusterName.String())
+		if err != nil {
+			return err
+		}
+		if creds == nil {
+			return fmt.Errorf("no credentials stored for cluster %s, run 'security enable' first or specify -U and -P", c.ClusterName)
+		}
+	}
+	return c.dataClientCmd.run(command, cmdStruct, func(client *aerospike.Client) error {
+		return do(client, aerospike.NewAdminPolicy())
+	})
+}
+
+// securitySplitList splits a comma-separated list, dropping empty items
+func securitySplitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
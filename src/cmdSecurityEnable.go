@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+)
+
+type securityEnableCmd struct {
+	aerospikeStartSelectorCmd
+	NoRestart bool `short:"e" long:"no-restart" description:"by default aerolab will restart aerospike once security is enabled; set this to prevent said action"`
+	parallelThreadsCmd
+}
+
+func (c *securityEnableCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running security.enable")
+	clusterList, err := b.ClusterList()
+	if err != nil {
+		return err
+	}
+	if !inslice.HasString(clusterList, string(c.ClusterName)) {
+		return fmt.Errorf("cluster does not exist: %s", string(c.ClusterName))
+	}
+	nodeList, err := b.NodeListInCluster(string(c.ClusterName))
+	if err != nil {
+		return err
+	}
+	nodes, err := expandNodeList(c.Nodes.String())
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		nodes = nodeList
+	}
+	for _, node := range nodes {
+		if !inslice.HasInt(nodeList, node) {
+			return fmt.Errorf("node %d not found", node)
+		}
+	}
+	returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+		out, err := b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/etc/aerospike/aerospike.conf"}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("cluster=%s node=%v RunCommands error=%s", string(c.ClusterName), node, err)
+		}
+		cc, err := aeroconf.Parse(bytes.NewReader(out[0]))
+		if err != nil {
+			return fmt.Errorf("config parse failure: %s", err)
+		}
+		switch cc.Type("security") {
+		case aeroconf.ValueNil:
+			cc.NewStanza("security")
+		case aeroconf.ValueStanza:
+		default:
+			return errors.New("security definition must be a {} stanza")
+		}
+		// before 5.7, the security stanza alone does not enable security
+		out, err = b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/opt/aerolab.aerospike.version"}}, []int{node})
+		if err == nil && securityNeedsEnableFlag(string(out[0])) {
+			cc.Stanza("security").SetValue("enable-security", "true")
+		}
+		buf := new(bytes.Buffer)
+		err = cc.Write(buf, "", "    ", true)
+		if err != nil {
+			return err
+		}
+		newconf := buf.String()
+		return b.CopyFilesToCluster(string(c.ClusterName), []fileList{{"/etc/aerospike/aerospike.conf", newconf, len(newconf)}}, []int{node})
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	creds, err := securityLoadCreds(string(c.ClusterName))
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		err = securitySaveCreds(string(c.ClusterName), &securityCreds{User: "admin", Password: "admin"})
+		if err != nil {
+			return err
+		}
+		log.Printf("Stored default credentials admin/admin for cluster %s", c.ClusterName)
+	}
+	if c.NoRestart {
+		log.Print("Done, remember to restart aerospike for the changes to take effect")
+		return nil
+	}
+	a.opts.Aerospike.Restart.ClusterName = c.ClusterName
+	a.opts.Aerospike.Restart.Nodes = TypeNodes(intSliceToString(nodes, ","))
+	a.opts.Aerospike.Restart.ParallelThreads = c.ParallelThreads
+	err = a.opts.Aerospike.Restart.run(args, "restart", os.Stdout)
+	if err != nil {
+		return fmt.Errorf("aerospike restart: %s", err)
+	}
+	log.Print("Done")
+	return nil
+}
+
+// securityNeedsEnableFlag returns true for aerospike versions before 5.7, which require 'enable-security true'
+func securityNeedsEnableFlag(version string) bool {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	if len(parts) < 2 {
+		return false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return major < 5 || (major == 5 && minor < 7)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+)
+
+type securityRoleCmd struct {
+	Create securityRoleCreateCmd `command:"create" subcommands-optional:"true" description:"Create a role" webicon:"fas fa-plus"`
+	Help   helpCmd               `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *securityRoleCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+type securityRoleCreateCmd struct {
+	Role       string `short:"r" long:"role" description:"name of the role to create" webrequired:"true"`
+	Privileges string `short:"p" long:"privileges" description:"comma-separated list of privileges, each as PRIVILEGE[:NAMESPACE[:SET]], ex: read-write:test:myset,sindex-admin" webrequired:"true"`
+	Allowlist  string `short:"a" long:"allowlist" description:"comma-separated list of IP addresses/ranges the role is allowed to connect from; empty=any" default:""`
+	ReadQuota  uint32 `long:"read-quota" description:"maximum reads per second; 0=unlimited" default:"0"`
+	WriteQuota uint32 `long:"write-quota" description:"maximum writes per second; 0=unlimited" default:"0"`
+	securityClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// securityPrivilegeCodes maps privilege names to client privileges
+var securityPrivilegeCodes = map[string]aerospike.Privilege{
+	"user-admin":     {Code: aerospike.UserAdmin},
+	"sys-admin":      {Code: aerospike.SysAdmin},
+	"data-admin":     {Code: aerospike.DataAdmin},
+	"udf-admin":      {Code: aerospike.UDFAdmin},
+	"sindex-admin":   {Code: aerospike.SIndexAdmin},
+	"read":           {Code: aerospike.Read},
+	"read-write":     {Code: aerospike.ReadWrite},
+	"read-write-udf": {Code: aerospike.ReadWriteUDF},
+	"write":          {Code: aerospike.Write},
+	"truncate":       {Code: aerospike.Truncate},
+}
+
+func (c *securityRoleCreateCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running security.role.create")
+	if c.RunJson == "" {
+		if c.Role == "" {
+			return errors.New("role name must be specified")
+		}
+		if _, err := securityParsePrivileges(c.Privileges); err != nil {
+			return err
+		}
+	}
+	err := c.run([]string{"security", "role", "create"}, c, func(client *aerospike.Client, policy *aerospike.AdminPolicy) error {
+		privileges, err := securityParsePrivileges(c.Privileges)
+		if err != nil {
+			return err
+		}
+		return client.CreateRole(policy, c.Role, privileges, securitySplitList(c.Allowlist), c.ReadQuota, c.WriteQuota)
+	})
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+// securityParsePrivileges parses a comma-separated list of PRIVILEGE[:NAMESPACE[:SET]]
+func securityParsePrivileges(list string) ([]aerospike.Privilege, error) {
+	privileges := []aerospike.Privilege{}
+	for _, item := range securitySplitList(list) {
+		parts := strings.Split(item, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("privilege %s must be in the format PRIVILEGE[:NAMESPACE[:SET]]", item)
+		}
+		privilege, ok := securityPrivilegeCodes[parts[0]]
+		if !ok {
+			names := []string{}
+			for name := range securityPrivilegeCodes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("privilege %s not recognised, must be one of: %s", parts[0], strings.Join(names, ","))
+		}
+		if len(parts) > 1 {
+			privilege.Namespace = parts[1]
+		}
+		if len(parts) > 2 {
+			privilege.SetName = parts[2]
+		}
+		privileges = append(privileges, privilege)
+	}
+	if len(privileges) == 0 {
+		return nil, errors.New("at least one privilege must be specified")
+	}
+	return privileges, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aerospike/aerospike-client-go/v8"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+type securityUserCmd struct {
+	Create securityUserCreateCmd `command:"create" subcommands-optional:"true" description:"Create a user" webicon:"fas fa-user-plus"`
+	Grant  securityUserGrantCmd  `command:"grant" subcommands-optional:"true" description:"Grant roles to a user" webicon:"fas fa-user-check"`
+	List   securityUserListCmd   `command:"list" subcommands-optional:"true" description:"List users and their roles" webicon:"fas fa-users"`
+	Help   helpCmd               `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *securityUserCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+type securityUserCreateCmd struct {
+	NewUser     string `short:"u" long:"user" description:"name of the user to create" webrequired:"true"`
+	NewPassword string `short:"p" long:"pass" description:"password of the user to create" webtype:"password" webrequired:"true"`
+	Roles       string `short:"r" long:"roles" description:"comma-separated list of roles to grant, ex: read-write,sys-admin" default:""`
+	Save        bool   `long:"save" description:"store the new user's credentials for the cluster, to be used by aerolab commands from now on"`
+	securityClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *securityUserCreateCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running security.user.create")
+	if c.RunJson == "" && (c.NewUser == "" || c.NewPassword == "") {
+		return errors.New("user name and password must be specified")
+	}
+	// run sets RunDirect before handing the command to the node, so record whether this is the local invocation first
+	direct := c.RunDirect
+	err := c.run([]string{"security", "user", "create"}, c, func(client *aerospike.Client, policy *aerospike.AdminPolicy) error {
+		return client.CreateUser(policy, c.NewUser, c.NewPassword, securitySplitList(c.Roles))
+	})
+	if err != nil {
+		return err
+	}
+	if c.Save && !direct && c.RunJson == "" {
+		err = securitySaveCreds(c.ClusterName.String(), &securityCreds{User: c.NewUser, Password: c.NewPassword})
+		if err != nil {
+			return err
+		}
+		log.Printf("Stored credentials of user %s for cluster %s", c.NewUser, c.ClusterName)
+	}
+	log.Print("Done")
+	return nil
+}
+
+type securityUserGrantCmd struct {
+	GrantUser string `short:"u" long:"user" description:"name of the user to grant roles to" webrequired:"true"`
+	Roles     string `short:"r" long:"roles" description:"comma-separated list of roles to grant, ex: read-write,sys-admin" webrequired:"true"`
+	securityClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *securityUserGrantCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running security.user.grant")
+	if c.RunJson == "" && (c.GrantUser == "" || len(securitySplitList(c.Roles)) == 0) {
+		return errors.New("user name and roles must be specified")
+	}
+	err := c.run([]string{"security", "user", "grant"}, c, func(client *aerospike.Client, policy *aerospike.AdminPolicy) error {
+		return client.GrantRoles(policy, c.GrantUser, securitySplitList(c.Roles))
+	})
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+type securityUserListCmd struct {
+	Json bool `short:"j" long:"json" description:"Provide output in json format"`
+	securityClientCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *securityUserListCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	return c.run([]string{"security", "user", "list"}, c, func(client *aerospike.Client, policy *aerospike.AdminPolicy) error {
+		users, err := client.QueryUsers(policy)
+		if err != nil {
+			return err
+		}
+		sort.Slice(users, func(i, j int) bool {
+			return users[i].User < users[j].User
+		})
+		if c.Json {
+			type userJson struct {
+				User  string
+				Roles []string
+			}
+			list := []userJson{}
+			for _, user := range users {
+				list = append(list, userJson{user.User, user.Roles})
+			}
+			return json.NewEncoder(os.Stdout).Encode(list)
+		}
+		tb := table.NewWriter()
+		tb.SetStyle(table.StyleLight)
+		tb.AppendHeader(table.Row{"User", "Roles", "Connections"})
+		for _, user := range users {
+			tb.AppendRow(table.Row{user.User, strings.Join(user.Roles, ", "), user.ConnsInUse})
+		}
+		fmt.Println(tb.Render())
+		return nil
+	})
+}
@@ -5,6 +5,9 @@ import "os"
 type tlsCmd struct {
 	Generate tlsGenerateCmd `command:"generate" subcommands-optional:"true" description:"Generate TLS certificates" webicon:"fas fa-passport"`
 	Copy     tlsCopyCmd     `command:"copy" subcommands-optional:"true" description:"Copy certificates to other nodes,clusters or clients" webicon:"fas fa-copy"`
+	Show     tlsShowCmd     `command:"show" subcommands-optional:"true" description:"Show the certificates configured on nodes" webicon:"fas fa-list"`
+	Check    tlsCheckCmd    `command:"check" subcommands-optional:"true" description:"Check node certificates for expiry, tls-name mismatches and chain errors" webicon:"fas fa-stethoscope"`
+	Rotate   tlsRotateCmd   `command:"rotate" subcommands-optional:"true" description:"Issue and distribute new certificates, optionally rolling over to a new CA" webicon:"fas fa-arrows-rotate"`
 	Help     helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
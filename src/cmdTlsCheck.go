@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+type tlsCheckCmd struct {
+	ClusterName TypeClusterName `short:"n" long:"name" description:"Cluster name/Client group" default:"mydc"`
+	Nodes       TypeNodes       `short:"l" long:"nodes" description:"Nodes list, comma separated. Empty=ALL" default:""`
+	IsClient    bool            `short:"C" long:"client" description:"set to indicate this is a client group"`
+	WarnDays    int             `short:"w" long:"warn-days" description:"Warn about certificates expiring within this many days" default:"30"`
+	ChDir       string          `short:"W" long:"work-dir" description:"Specify working directory, containing the CA directory created by tls generate, to verify certificates against"`
+	Json        bool            `short:"j" long:"json" description:"Provide output in json format"`
+	parallelThreadsLongCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type tlsCheckResult struct {
+	Node    int
+	TlsName string
+	Check   string
+	Level   string // OK, WARN or ERROR
+	Detail  string
+}
+
+func (c *tlsCheckCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	err := chDir(c.ChDir)
+	if err != nil {
+		return err
+	}
+	if c.IsClient {
+		b.WorkOnClients()
+		defer b.WorkOnServers()
+	}
+	localRoots, localIntermediates, err := tlsLoadLocalCAs("CA")
+	if err != nil {
+		return err
+	}
+	if localRoots == nil {
+		log.Print("WARN: no CA directory found in the working directory, not verifying certificates against it")
+	}
+	confs, err := tlsCollect(c.ClusterName.String(), c.Nodes.String(), c.ParallelThreads, true)
+	if err != nil {
+		return err
+	}
+	results := []tlsCheckResult{}
+	for _, conf := range confs {
+		if len(conf.certs) == 0 {
+			results = append(results, tlsCheckResult{conf.node, "", "config", "WARN", "no tls stanzas or certificates found"})
+		}
+		tlsNames := []string{}
+		for _, cert := range conf.certs {
+			tlsNames = append(tlsNames, cert.tlsName)
+			results = append(results, c.checkCert(cert, localRoots, localIntermediates)...)
+		}
+		for context, ref := range conf.refs {
+			found := false
+			for _, name := range tlsNames {
+				if name == ref {
+					found = true
+					break
+				}
+			}
+			if !found {
+				results = append(results, tlsCheckResult{conf.node, ref, "config", "ERROR", fmt.Sprintf("%s uses tls-name %s, but there is no 'tls %s' stanza", context, ref, ref)})
+			}
+		}
+	}
+	isError := false
+	for _, r := range results {
+		if r.Level == "ERROR" {
+			isError = true
+		}
+	}
+	if c.Json {
+		err = json.NewEncoder(os.Stdout).Encode(results)
+		if err != nil {
+			return err
+		}
+	} else {
+		tb := table.NewWriter()
+		tb.SetStyle(table.StyleLight)
+		tb.AppendHeader(table.Row{"Node", "TlsName", "Check", "Level", "Detail"})
+		for _, r := range results {
+			level := r.Level
+			switch r.Level {
+			case "WARN":
+				level = text.FgYellow.Sprint(level)
+			case "ERROR":
+				level = text.FgRed.Sprint(level)
+			}
+			tb.AppendRow(table.Row{r.Node, r.TlsName, r.Check, level, r.Detail})
+		}
+		fmt.Println(tb.Render())
+	}
+	if isError {
+		return errors.New("some certificates failed the checks")
+	}
+	return nil
+}
+
+// checkCert checks expiry, tls-name, key match and chain of a node certificate
+func (c *tlsCheckCmd) checkCert(cert *tlsNodeCert, localRoots *x509.CertPool, localIntermediates []*x509.Certificate) []tlsCheckResult {
+	result := func(check string, level string, detail string) tlsCheckResult {
+		return tlsCheckResult{cert.node, cert.tlsName, check, level, detail}
+	}
+	if cert.err != nil {
+		return []tlsCheckResult{result("read", "ERROR", cert.err.Error())}
+	}
+	results := []tlsCheckResult{}
+	leaf := cert.certs[0]
+
+	// expiry
+	daysLeft := tlsDaysLeft(leaf)
+	switch {
+	case leaf.NotAfter.Before(time.Now()):
+		results = append(results, result("expiry", "ERROR", fmt.Sprintf("expired on %s", leaf.NotAfter.Format("2006-01-02"))))
+	case leaf.NotBefore.After(time.Now()):
+		results = append(results, result("expiry", "ERROR", fmt.Sprintf("not valid before %s", leaf.NotBefore.Format("2006-01-02"))))
+	case daysLeft < c.WarnDays:
+		results = append(results, result("expiry", "WARN", fmt.Sprintf("expires in %d days, on %s", daysLeft, leaf.NotAfter.Format("2006-01-02"))))
+	default:
+		results = append(results, result("expiry", "OK", fmt.Sprintf("expires in %d days", daysLeft)))
+	}
+
+	// tls-name must match the CN or a DNS SAN
+	if strings.EqualFold(leaf.Subject.CommonName, cert.tlsName) || leaf.VerifyHostname(cert.tlsName) == nil {
+		results = append(results, result("tls-name", "OK", "matches certificate CN/SAN"))
+	} else {
+		results = append(results, result("tls-name", "ERROR", fmt.Sprintf("tls-name does not match certificate CN (%s) or SANs (%s)", leaf.Subject.CommonName, strings.Join(tlsSans(leaf), ", "))))
+	}
+
+	// key-file must match the certificate
+	if len(cert.keyPem) > 0 {
+		if err := tlsCheckKeyMatch(cert.keyPem, leaf); err != nil {
+			results = append(results, result("key", "ERROR", err.Error()))
+		} else {
+			results = append(results, result("key", "OK", "key-file matches certificate"))
+		}
+	}
+
+	// chain against the node's ca-file
+	intermediates := x509.NewCertPool()
+	for _, chainCert := range cert.certs[1:] {
+		intermediates.AddCert(chainCert)
+	}
+	if len(cert.caCerts) > 0 {
+		roots := x509.NewCertPool()
+		for _, caCert := range cert.caCerts {
+			roots.AddCert(caCert)
+		}
+		results = append(results, tlsCheckChain(leaf, roots, intermediates, "chain (ca-file)", result))
+	}
+
+	// chain against the work-dir CA
+	if localRoots != nil {
+		for _, local := range localIntermediates {
+			intermediates.AddCert(local)
+		}
+		results = append(results, tlsCheckChain(leaf, localRoots, intermediates, "chain (work-dir CA)", result))
+	}
+	return results
+}
+
+func tlsCheckChain(leaf *x509.Certificate, roots *x509.CertPool, intermediates *x509.CertPool, check string, result func(string, string, string) tlsCheckResult) tlsCheckResult {
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return result(check, "ERROR", err.Error())
+	}
+	names := []string{}
+	for _, chainCert := range chains[0] {
+		names = append(names, chainCert.Subject.CommonName)
+	}
+	return result(check, "OK", strings.Join(names, " -> "))
+}
+
+// tlsCheckKeyMatch checks that the PEM private key belongs to the certificate
+func tlsCheckKeyMatch(keyPem []byte, cert *x509.Certificate) error {
+	key, err := tlsParseKey(keyPem)
+	if err != nil {
+		return fmt.Errorf("could not parse key-file: %s", err)
+	}
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return errors.New("key-file does not match the certificate")
+	}
+	return nil
+}
+
+// tlsLoadLocalCAs loads the CA certificates from the top level of the CA directory; self-signed ones are roots, others are intermediates. Returns a nil pool if the directory does not exist
+func tlsLoadLocalCAs(caDir string) (*x509.CertPool, []*x509.Certificate, error) {
+	files, err := filepath.Glob(filepath.Join(caDir, "*.pem"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, nil
+	}
+	roots := x509.NewCertPool()
+	intermediates := []*x509.Certificate{}
+	for _, file := range files {
+		certs, err := tlsReadCerts(file)
+		if err != nil {
+			continue
+		}
+		for _, cert := range certs {
+			if !cert.IsCA {
+				continue
+			}
+			if bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil {
+				roots.AddCert(cert)
+			} else {
+				intermediates = append(intermediates, cert)
+			}
+		}
+	}
+	return roots, intermediates, nil
+}
@@ -2,16 +2,31 @@ package main
 
 import (
 	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
+	"net"
 	"os"
-	"os/exec"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/aerospike/aerolab/parallelize"
 	"github.com/bestmethod/inslice"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 type tlsGenerateCmd struct {
@@ -20,7 +35,14 @@ type tlsGenerateCmd struct {
 	IsClient       bool            `short:"C" long:"client" description:"set to indicate the certficates should end up on client groups"`
 	TlsName        string          `short:"t" long:"tls-name" description:"Common Name (tlsname)" default:"tls1"`
 	CaName         string          `short:"c" long:"ca-name" description:"Name of the CA certificate(file)" default:"cacert"`
-	Bits           int             `short:"b" long:"cert-bits" description:"Bits size for the CA and certs" default:"2048"`
+	Bits           int             `short:"b" long:"cert-bits" description:"Bits size for the CA and certs, for rsa keys" default:"2048"`
+	KeyType        string          `short:"k" long:"key-type" description:"Key type for new CA and certificate keys (rsa|ecdsa|ed25519)" default:"rsa" webchoice:"rsa,ecdsa,ed25519"`
+	EcdsaCurve     string          `long:"ecdsa-curve" description:"Curve for ecdsa keys (P256|P384|P521)" default:"P256" webchoice:"P256,P384,P521"`
+	Intermediate   string          `short:"i" long:"intermediate" description:"If set, create (or reuse) an intermediate CA with this name, signed by the CA, and issue certificates from it"`
+	Sans           string          `short:"s" long:"san" description:"Comma-separated list of additional DNS names or IPs to add to certificates; node IPs and hostnames are added automatically"`
+	ClientCerts    string          `short:"U" long:"client-certs" description:"Comma-separated list of user names to generate mTLS client certificates for; the user name is the certificate Common Name"`
+	Pkcs12         bool            `short:"p" long:"pkcs12" description:"Also export a PKCS#12 truststore with the CA, and PKCS#12 keystores for the client certificates, for Java clients"`
+	Pkcs12Password string          `long:"pkcs12-password" description:"Password for the PKCS#12 truststore and keystores" default:"changeit"`
 	CaExpiryDays   int             `short:"e" long:"ca-expiry-days" description:"Number of days the CA certificate should be valid for" default:"3650"`
 	CertExpiryDays int             `short:"E" long:"cert-expiry-days" description:"Number of days the certificate should be valid for" default:"365"`
 	NoUpload       bool            `short:"u" long:"no-upload" description:"If set, will generate certificates on the local machine but not ship them to the cluster nodes"`
@@ -30,6 +52,21 @@ type tlsGenerateCmd struct {
 	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
+// tlsKeyOpts describes the type of newly generated keys
+type tlsKeyOpts struct {
+	keyType string
+	bits    int
+	curve   string
+}
+
+// tlsCA is a certificate authority loaded from the CA directory; when an intermediate is used, cert and key are the intermediate's
+type tlsCA struct {
+	root  *x509.Certificate
+	cert  *x509.Certificate
+	key   crypto.Signer
+	chain []*x509.Certificate // certificates to append to issued certificates, i.e. the intermediate
+}
+
 func (c *tlsGenerateCmd) Execute(args []string) error {
 	if earlyProcess(args) {
 		return nil
@@ -42,6 +79,25 @@ func (c *tlsGenerateCmd) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
+	keyOpts := tlsKeyOpts{keyType: c.KeyType, bits: c.Bits, curve: c.EcdsaCurve}
+	if err := keyOpts.validate(); err != nil {
+		return err
+	}
+	if c.Pkcs12 && c.Pkcs12Password == "" {
+		return errors.New("pkcs12-password cannot be empty")
+	}
+	extraDns, extraIps := tlsParseSans(c.Sans)
+	users := []string{}
+	for _, user := range strings.Split(c.ClientCerts, ",") {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		if strings.ContainsAny(user, "/\\") {
+			return fmt.Errorf("invalid client certificate user name: %s", user)
+		}
+		users = append(users, user)
+	}
 
 	if _, err := os.Stat("CA"); err == nil {
 		log.Printf("CA directory exists, reusing existing CAs (%s/CA)", wd)
@@ -92,118 +148,92 @@ func (c *tlsGenerateCmd) Execute(args []string) error {
 	}
 	// we have 'nodes' var with list of nodes to install the cert on
 
-	var commands [][]string
-	comm := "openssl"
-	_, errA := os.Stat(path.Join("CA", "private", c.CaName+".key"))
-	_, errB := os.Stat(path.Join("CA", c.CaName+".pem"))
-	if errA != nil || errB != nil {
-		commands = append(commands, []string{"req", "-new", "-nodes", "-x509", "-extensions", "v3_ca", "-keyout", path.Join("private", c.CaName+".key"), "-out", c.CaName + ".pem", "-days", strconv.Itoa(c.CaExpiryDays), "-config", "openssl.cnf", "-subj", fmt.Sprintf("/C=US/ST=CA/L=Cyberspace/O=Aerolab/CN=%s", c.CaName)})
-	}
-	commands = append(commands, []string{"req", "-new", "-nodes", "-extensions", "v3_req", "-out", "req.pem", "-config", "openssl.cnf", "-subj", fmt.Sprintf("/C=US/ST=CA/L=Cyberspace/O=Aerolab/CN=%s", c.TlsName)})
-	commands = append(commands, []string{"ca", "-batch", "-extensions", "v3_req", "-days", strconv.Itoa(c.CertExpiryDays), "-out", "cert.pem", "-config", "openssl.cnf", "-infiles", "req.pem"})
-	//os.RemoveAll("CA")
-	if _, err := os.Stat("CA"); err != nil {
-		os.Mkdir("CA", 0755)
-	}
-	err = os.Chdir("CA")
+	ca, err := tlsLoadOrCreateCA("CA", c.CaName, c.Intermediate, keyOpts, c.CaExpiryDays)
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile("openssl.cnf", []byte(tls_create_openssl_config(c.TlsName, c.CaName, c.Bits)), 0644)
+
+	// generic certificate, for use outside of the cluster nodes
+	localDns := append([]string{c.TlsName, "localhost"}, extraDns...)
+	localIps := append([]net.IP{net.ParseIP("127.0.0.1")}, extraIps...)
+	err = tlsIssueToDir(ca, keyOpts, "CA", "cert.pem", "key.pem", c.TlsName, localDns, localIps, c.CertExpiryDays, true)
 	if err != nil {
 		return err
 	}
-	for _, i := range []string{"private", "newcerts"} {
-		//os.RemoveAll(i)
-		if _, err := os.Stat(i); err != nil {
-			err = os.Mkdir(i, 0755)
+
+	// client certificates for mTLS users
+	for _, user := range users {
+		userDir := filepath.Join("CA", "users", user)
+		err = tlsIssueToDir(ca, keyOpts, userDir, "cert.pem", "key.pem", user, nil, nil, c.CertExpiryDays, false)
+		if err != nil {
+			return err
+		}
+		if c.Pkcs12 {
+			err = tlsWritePkcs12Keystore(filepath.Join(userDir, "cert.pem"), filepath.Join(userDir, "key.pem"), filepath.Join(userDir, "keystore.p12"), c.Pkcs12Password)
 			if err != nil {
 				return err
 			}
 		}
 	}
-	if _, err := os.Stat("index.txt"); err != nil {
-		err = os.WriteFile("index.txt", []byte{}, 0644)
+	if c.Pkcs12 {
+		err = tlsWritePkcs12Truststore(ca, filepath.Join("CA", "truststore.p12"), c.Pkcs12Password)
 		if err != nil {
 			return err
 		}
 	}
-	if _, err := os.Stat("serial"); err != nil {
-		err = os.WriteFile("serial", []byte("01"), 0644)
+
+	if !c.NoUpload {
+		ipMaps, err := tlsNodeIpMaps(string(c.ClusterName))
 		if err != nil {
 			return err
 		}
-	}
-	for _, command := range commands {
-		out, err := exec.Command(comm, command...).CombinedOutput()
-		if checkExecRetcode(err) != 0 {
-			return fmt.Errorf("error executing command: %s\n%s\nopenssl %s", err, out, strings.Join(command, " "))
+		caPem, err := os.ReadFile(filepath.Join("CA", c.CaName+".pem"))
+		if err != nil {
+			return err
 		}
-	}
-
-	if !c.NoUpload {
-		if c.ParallelThreads == 1 || len(nodes) == 1 {
-			_, err = b.RunCommands(string(c.ClusterName), [][]string{{"mkdir", "-p", fmt.Sprintf("/etc/aerospike/ssl/%s", c.TlsName)}}, nodes)
+		sslDir := fmt.Sprintf("/etc/aerospike/ssl/%s", c.TlsName)
+		returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+			dir, err := tlsIssueNodeCert(ca, keyOpts, string(c.ClusterName), c.IsClient, node, ipMaps, c.TlsName, c.Sans, c.CertExpiryDays)
 			if err != nil {
-				return fmt.Errorf("could not mkdir ssl location: %s", err)
+				return err
 			}
-
-			files := []string{"cert.pem", "key.pem", c.CaName + ".pem"}
-			fl := []fileList{}
-			for _, file := range files {
-				ct, err := os.ReadFile(file)
-				if err != nil {
-					return err
+			files := map[string]string{
+				filepath.Join(dir, c.TlsName+".pem"): sslDir + "/cert.pem",
+				filepath.Join(dir, c.TlsName+".key"): sslDir + "/key.pem",
+			}
+			for _, user := range users {
+				for _, file := range []string{"cert.pem", "key.pem", "keystore.p12"} {
+					files[filepath.Join("CA", "users", user, file)] = fmt.Sprintf("%s/users/%s/%s", sslDir, user, file)
 				}
-				fl = append(fl, fileList{fmt.Sprintf("/etc/aerospike/ssl/%s/%s", c.TlsName, file), string(ct), len(ct)})
 			}
-			err = b.CopyFilesToCluster(string(c.ClusterName), fl, nodes)
-			if err != nil {
-				return err
+			if c.Pkcs12 {
+				files[filepath.Join("CA", "truststore.p12")] = sslDir + "/truststore.p12"
 			}
-		} else {
-			parallel := make(chan int, c.ParallelThreads)
-			hasError := make(chan bool, len(nodes))
-			wait := new(sync.WaitGroup)
-			for _, node := range nodes {
-				parallel <- 1
-				wait.Add(1)
-				go func(node int, parallel chan int, wait *sync.WaitGroup, hasError chan bool) {
-					defer func() {
-						<-parallel
-						wait.Done()
-					}()
-					_, err := b.RunCommands(string(c.ClusterName), [][]string{{"mkdir", "-p", fmt.Sprintf("/etc/aerospike/ssl/%s", c.TlsName)}}, []int{node})
-					if err != nil {
-						log.Printf("could not mkdir ssl location: %s", err)
-						hasError <- true
-					}
-					files := []string{"cert.pem", "key.pem", c.CaName + ".pem"}
-					fl := []fileList{}
-					for _, file := range files {
-						ct, err := os.ReadFile(file)
-						if err != nil {
-							log.Println(err)
-							hasError <- true
-						}
-						fl = append(fl, fileList{fmt.Sprintf("/etc/aerospike/ssl/%s/%s", c.TlsName, file), string(ct), len(ct)})
-					}
-					err = b.CopyFilesToCluster(string(c.ClusterName), fl, []int{node})
-					if err != nil {
-						log.Println(err)
-						hasError <- true
+			upload := map[string][]byte{sslDir + "/" + c.CaName + ".pem": caPem}
+			for src, dst := range files {
+				ct, err := os.ReadFile(src)
+				if err != nil {
+					if os.IsNotExist(err) && strings.HasSuffix(src, ".p12") {
+						continue
 					}
-				}(node, parallel, wait, hasError)
+					return err
+				}
+				upload[dst] = ct
 			}
-			wait.Wait()
-			if len(hasError) > 0 {
-				return fmt.Errorf("failed to get logs from %d nodes", len(hasError))
+			return tlsUploadFiles(string(c.ClusterName), node, upload)
+		})
+		isError := false
+		for i, ret := range returns {
+			if ret != nil {
+				log.Printf("Node %d returned %s", nodes[i], ret)
+				isError = true
 			}
 		}
+		if isError {
+			return errors.New("some nodes returned errors")
+		}
 	}
 
-	os.Chdir("..")
-
 	if !c.NoUpload && !c.NoMesh {
 		//for each node, read config
 		var nodeIps []string
@@ -229,20 +259,26 @@ func (c *tlsGenerateCmd) Execute(args []string) error {
 			}
 			wait.Wait()
 			if len(hasError) > 0 {
-				return fmt.Errorf("failed to get logs from %d nodes", len(hasError))
+				return fmt.Errorf("failed to configure tls on the mesh heartbeat of %d nodes", len(hasError))
 			}
 		}
 	}
 	fmt.Println("--- aerospike.conf snippet ---")
 	fmt.Printf(`network {
-    tls tls1 {
+    tls %s {
 		cert-file /etc/aerospike/ssl/%s/cert.pem
 		key-file /etc/aerospike/ssl/%s/key.pem
 		ca-file /etc/aerospike/ssl/%s/%s.pem
 	}
 	...
-`, c.TlsName, c.TlsName, c.TlsName, c.CaName)
+`, c.TlsName, c.TlsName, c.TlsName, c.TlsName, c.CaName)
 	fmt.Println("--- aerospike.conf end ---")
+	if len(users) > 0 {
+		fmt.Printf("Client certificates for mTLS users are in %s/CA/users and in /etc/aerospike/ssl/%s/users on the nodes\n", wd, c.TlsName)
+	}
+	if c.Pkcs12 {
+		fmt.Printf("PKCS#12 truststore for Java clients: %s/CA/truststore.p12\n", wd)
+	}
 	log.Print("Done")
 	return nil
 }
@@ -254,7 +290,7 @@ func (c *tlsGenerateCmd) fixMeshParallel(node int, nodeIps []string, parallel ch
 	}()
 	err := c.fixMesh(node, nodeIps)
 	if err != nil {
-		log.Printf("ERROR getting logs from node %d: %s", node, err)
+		log.Printf("ERROR configuring tls on the mesh heartbeat of node %d: %s", node, err)
 		hasError <- true
 	}
 }
@@ -294,89 +330,410 @@ func (c *tlsGenerateCmd) fixMesh(node int, nodeIps []string) error {
 	return nil
 }
 
-func tls_create_openssl_config(tlsName string, caName string, bits int) string {
-	conf := `#
-# OpenSSL configuration file.
-#
-
-# Establish working directory.
-
-dir			= .
-
-[ req ]
-default_bits  	    = %d		# Size of keys
-default_keyfile     = key.pem		# name of generated keys
-default_md          = sha256		# message digest algorithm
-string_mask         = nombstr		# permitted characters
-distinguished_name  = req_distinguished_name
-req_extensions      = v3_req
-
-[ req_distinguished_name ]
-# Variable name		        Prompt string
-#----------------------   ----------------------------------
-0.organizationName        = Organization Name (company)
-organizationalUnitName    = Organizational Unit Name (department, division)
-emailAddress              = Email Address
-emailAddress_max          = 40
-localityName              = Locality Name (city, district)
-stateOrProvinceName       = State or Province Name (full name)
-countryName               = Country Name (2 letter code)
-countryName_min           = 2
-countryName_max           = 2
-commonName                = Common Name (hostname, IP, or your name)
-commonName_max            = 64
-
-# Default values for the above, for consistency and less typing.
-# Variable name			  Value
-#------------------------------	  ------------------------------
-0.organizationName_default         = Aerospike Inc
-organizationalUnitName_default     = operations
-emailAddress_default               = operations@aerospike.com
-localityName_default               = Bangalore
-stateOrProvinceName_default	   = Karnataka
-countryName_default		   = IN
-commonName_default                 = harvey 
-
-[ v3_ca ]
-basicConstraints	= CA:TRUE
-subjectKeyIdentifier	= hash
-authorityKeyIdentifier	= keyid:always,issuer:always
-subjectAltName = IP:127.0.0.1
-
-[ v3_req ]
-basicConstraints	= CA:FALSE
-subjectKeyIdentifier	= hash
-subjectAltName = @alt_names
-
-[alt_names]
-DNS.1   = %s
-IP.1 = 127.0.0.1
-
-[ ca ]
-default_ca		= CA_default
-
-[ CA_default ]
-serial			= $dir/serial
-database		= $dir/index.txt
-new_certs_dir		= $dir/newcerts
-certificate		= $dir/%s.pem
-private_key		= $dir/private/%s.key
-default_days		= 365
-default_md		= sha256
-preserve		= no
-email_in_dn		= no
-nameopt			= default_ca
-certopt			= default_ca
-policy			= policy_match
-
-[ policy_match ]
-countryName		= match
-stateOrProvinceName	= match
-organizationName	= match
-organizationalUnitName	= optional
-commonName		= supplied
-emailAddress		= optional
-
-`
-	return fmt.Sprintf(conf, bits, tlsName, caName, caName)
+// tlsParseSans splits a comma-separated list of SANs into DNS names and IPs
+func tlsParseSans(sans string) (dnsNames []string, ips []net.IP) {
+	for _, san := range strings.Split(sans, ",") {
+		san = strings.TrimSpace(san)
+		if san == "" {
+			continue
+		}
+		if ip := net.ParseIP(san); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			dnsNames = append(dnsNames, san)
+		}
+	}
+	return dnsNames, ips
+}
+
+// tlsNodeIpMaps returns the private and public IPs of the nodes of a cluster, or client group if the backend is working on clients
+func tlsNodeIpMaps(clusterName string) ([]map[int]string, error) {
+	ipMaps := []map[int]string{}
+	for _, internal := range []bool{true, false} {
+		ipMap, err := b.GetNodeIpMap(clusterName, internal)
+		if err != nil {
+			return nil, err
+		}
+		ipMaps = append(ipMaps, ipMap)
+	}
+	return ipMaps, nil
+}
+
+// tlsNodeCertDir returns the local directory holding the certificates of a node
+func tlsNodeCertDir(clusterName string, isClient bool, node int) string {
+	nodeDir := "clusters"
+	if isClient {
+		nodeDir = "clients"
+	}
+	return filepath.Join("CA", nodeDir, clusterName, strconv.Itoa(node))
+}
+
+// tlsIssueNodeCert issues TLS_NAME.pem and TLS_NAME.key for a node, with the node's hostname and IPs as SANs, and returns the directory they were written to
+func tlsIssueNodeCert(ca *tlsCA, keyOpts tlsKeyOpts, clusterName string, isClient bool, node int, ipMaps []map[int]string, tlsName string, sans string, expiryDays int) (string, error) {
+	out, err := b.RunCommands(clusterName, [][]string{{"hostname"}}, []int{node})
+	if err != nil {
+		return "", fmt.Errorf("could not get hostname: %s", err)
+	}
+	extraDns, extraIps := tlsParseSans(sans)
+	dnsNames := append([]string{tlsName, "localhost"}, extraDns...)
+	if hostname := strings.TrimSpace(string(out[0])); hostname != "" && !inslice.HasString(dnsNames, hostname) {
+		dnsNames = append(dnsNames, hostname)
+	}
+	ips := append([]net.IP{net.ParseIP("127.0.0.1")}, extraIps...)
+	for _, ipMap := range ipMaps {
+		if ip := net.ParseIP(ipMap[node]); ip != nil && !tlsHasIp(ips, ip) {
+			ips = append(ips, ip)
+		}
+	}
+	dir := tlsNodeCertDir(clusterName, isClient, node)
+	return dir, tlsIssueToDir(ca, keyOpts, dir, tlsName+".pem", tlsName+".key", tlsName, dnsNames, ips, expiryDays, true)
+}
+
+// tlsUploadFiles creates the destination directories on a node and uploads the files, keyed by destination path
+func tlsUploadFiles(clusterName string, node int, files map[string][]byte) error {
+	mkdirs := []string{"mkdir", "-p"}
+	fl := []fileList{}
+	for dst, ct := range files {
+		fl = append(fl, fileList{dst, string(ct), len(ct)})
+		if !inslice.HasString(mkdirs, path.Dir(dst)) {
+			mkdirs = append(mkdirs, path.Dir(dst))
+		}
+	}
+	_, err := b.RunCommands(clusterName, [][]string{mkdirs}, []int{node})
+	if err != nil {
+		return fmt.Errorf("could not mkdir ssl location: %s", err)
+	}
+	return b.CopyFilesToCluster(clusterName, fl, []int{node})
+}
+
+func tlsHasIp(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts tlsKeyOpts) validate() error {
+	switch opts.keyType {
+	case "rsa":
+		if opts.bits < 2048 {
+			return errors.New("cert-bits must be at least 2048 for rsa keys")
+		}
+	case "ecdsa":
+		if !inslice.HasString([]string{"P256", "P384", "P521"}, opts.curve) {
+			return fmt.Errorf("unsupported ecdsa curve %s, expected one of: P256, P384, P521", opts.curve)
+		}
+	case "ed25519":
+	default:
+		return fmt.Errorf("unsupported key type %s, expected one of: rsa, ecdsa, ed25519", opts.keyType)
+	}
+	return nil
+}
+
+// tlsGenerateKey generates a new private key of the given type
+func tlsGenerateKey(opts tlsKeyOpts) (crypto.Signer, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	switch opts.keyType {
+	case "ecdsa":
+		curve := map[string]elliptic.Curve{"P256": elliptic.P256(), "P384": elliptic.P384(), "P521": elliptic.P521()}[opts.curve]
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return rsa.GenerateKey(rand.Reader, opts.bits)
+	}
+}
+
+// tlsKeyType returns a printable description of a public key
+func tlsKeyType(pub interface{}) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return "unknown"
+	}
+}
+
+func tlsSubject(cn string) pkix.Name {
+	return pkix.Name{
+		Country:      []string{"US"},
+		Province:     []string{"CA"},
+		Locality:     []string{"Cyberspace"},
+		Organization: []string{"Aerolab"},
+		CommonName:   cn,
+	}
+}
+
+func tlsSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+}
+
+// tlsReadCerts reads all certificates from a PEM file
+func tlsReadCerts(fileName string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return tlsParseCerts(data)
+}
+
+// tlsParseCerts parses all certificates from PEM data
+func tlsParseCerts(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// tlsReadKey reads a PEM private key file
+func tlsReadKey(fileName string) (crypto.Signer, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := tlsParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	return key, nil
+}
+
+// tlsParseKey parses a PEM private key in PKCS#8, PKCS#1 or SEC1 format
+func tlsParseKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
+
+func tlsWriteKey(fileName string, key crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+}
+
+func tlsWriteCerts(fileName string, certs ...*x509.Certificate) error {
+	out := []byte{}
+	for _, cert := range certs {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return os.WriteFile(fileName, out, 0644)
+}
+
+// tlsLoadOrCreateCA loads the CA, and the intermediate if a name is given, from caDir, creating any that do not exist yet
+func tlsLoadOrCreateCA(caDir string, caName string, intermediate string, keyOpts tlsKeyOpts, expiryDays int) (*tlsCA, error) {
+	err := os.MkdirAll(filepath.Join(caDir, "private"), 0755)
+	if err != nil {
+		return nil, err
+	}
+	root, rootKey, err := tlsLoadOrCreateCACert(caDir, caName, nil, nil, keyOpts, expiryDays)
+	if err != nil {
+		return nil, err
+	}
+	ca := &tlsCA{root: root, cert: root, key: rootKey}
+	if intermediate == "" {
+		return ca, nil
+	}
+	if intermediate == caName {
+		return nil, errors.New("intermediate name must be different from the CA name")
+	}
+	ca.cert, ca.key, err = tlsLoadOrCreateCACert(caDir, intermediate, root, rootKey, keyOpts, expiryDays)
+	if err != nil {
+		return nil, err
+	}
+	if err = ca.cert.CheckSignatureFrom(root); err != nil {
+		return nil, fmt.Errorf("intermediate %s was not signed by CA %s: %s", intermediate, caName, err)
+	}
+	ca.chain = []*x509.Certificate{ca.cert}
+	return ca, nil
+}
+
+// tlsLoadOrCreateCACert loads CA certificate NAME.pem and key private/NAME.key; if they do not exist, a new CA is created, self-signed if parent is nil
+func tlsLoadOrCreateCACert(caDir string, name string, parent *x509.Certificate, parentKey crypto.Signer, keyOpts tlsKeyOpts, expiryDays int) (*x509.Certificate, crypto.Signer, error) {
+	certFile := filepath.Join(caDir, name+".pem")
+	keyFile := filepath.Join(caDir, "private", name+".key")
+	_, errA := os.Stat(keyFile)
+	_, errB := os.Stat(certFile)
+	if errA == nil && errB == nil {
+		certs, err := tlsReadCerts(certFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", certFile, err)
+		}
+		key, err := tlsReadKey(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !certs[0].IsCA {
+			return nil, nil, fmt.Errorf("%s is not a CA certificate", certFile)
+		}
+		return certs[0], key, nil
+	}
+	key, err := tlsGenerateKey(keyOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := tlsSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               tlsSubject(name),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(0, 0, expiryDays),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if parent == nil {
+		parent = template
+		parentKey = key
+		log.Printf("Creating CA %s (%s)", name, tlsKeyType(key.Public()))
+	} else {
+		template.MaxPathLenZero = true
+		log.Printf("Creating intermediate CA %s (%s), signed by %s", name, tlsKeyType(key.Public()), parent.Subject.CommonName)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create CA %s: %s", name, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = tlsWriteKey(keyFile, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = tlsWriteCerts(certFile, cert)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// issue issues a certificate signed by the CA; server certificates can also be used as client certificates, as nodes authenticate each other with them
+func (ca *tlsCA) issue(key crypto.Signer, cn string, dnsNames []string, ips []net.IP, expiryDays int, server bool) (*x509.Certificate, error) {
+	serial, err := tlsSerial()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               tlsSubject(cn),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(0, 0, expiryDays),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
+	}
+	if _, ok := key.Public().(*rsa.PublicKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	if server {
+		template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+	}
+	if template.NotAfter.After(ca.cert.NotAfter) {
+		template.NotAfter = ca.cert.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create certificate %s: %s", cn, err)
+	}
+	return x509.ParseCertificate(der)
+}
+
+// tlsIssueToDir generates a new key and certificate, writing the certificate followed by the intermediate chain to certName, and the key to keyName
+func tlsIssueToDir(ca *tlsCA, keyOpts tlsKeyOpts, dir string, certName string, keyName string, cn string, dnsNames []string, ips []net.IP, expiryDays int, server bool) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	key, err := tlsGenerateKey(keyOpts)
+	if err != nil {
+		return err
+	}
+	cert, err := ca.issue(key, cn, dnsNames, ips, expiryDays, server)
+	if err != nil {
+		return err
+	}
+	err = tlsWriteKey(filepath.Join(dir, keyName), key)
+	if err != nil {
+		return err
+	}
+	return tlsWriteCerts(filepath.Join(dir, certName), append([]*x509.Certificate{cert}, ca.chain...)...)
+}
+
+// tlsWritePkcs12Truststore writes the CA, and intermediate if any, to a PKCS#12 truststore
+func tlsWritePkcs12Truststore(ca *tlsCA, fileName string, password string) error {
+	data, err := pkcs12.Modern.EncodeTrustStore(append([]*x509.Certificate{ca.root}, ca.chain...), password)
+	if err != nil {
+		return fmt.Errorf("could not create PKCS#12 truststore: %s", err)
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+// tlsWritePkcs12Keystore writes a PEM certificate chain and key to a PKCS#12 keystore
+func tlsWritePkcs12Keystore(certFile string, keyFile string, fileName string, password string) error {
+	certs, err := tlsReadCerts(certFile)
+	if err != nil {
+		return fmt.Errorf("%s: %s", certFile, err)
+	}
+	key, err := tlsReadKey(keyFile)
+	if err != nil {
+		return err
+	}
+	data, err := pkcs12.Modern.Encode(key, certs[0], certs[1:], password)
+	if err != nil {
+		return fmt.Errorf("could not create PKCS#12 keystore: %s", err)
+	}
+	return os.WriteFile(fileName, data, 0600)
 }
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+)
+
+type tlsRotateCmd struct {
+	ClusterName    TypeClusterName `short:"n" long:"name" description:"Cluster name/Client group" default:"mydc"`
+	Nodes          TypeNodes       `short:"l" long:"nodes" description:"Nodes list, comma separated. Empty=ALL" default:""`
+	IsClient       bool            `short:"C" long:"client" description:"set to indicate the certficates are on a client group; implies --mode none"`
+	TlsName        string          `short:"t" long:"tls-name" description:"Common Name (tlsname)" default:"tls1"`
+	CaName         string          `short:"c" long:"ca-name" description:"Name of the CA certificate(file) the nodes are configured with" default:"cacert"`
+	NewCa          string          `long:"new-ca" description:"CA rollover: create (or reuse) a CA with this name, distribute a trust bundle with both CAs, then issue node certificates from the new CA"`
+	Issuer         string          `long:"issuer" description:"Name of the CA to issue certificates from, if different from ca-name, for example after a CA rollover; default: ca-name"`
+	RemoveOldCa    bool            `long:"remove-old-ca" description:"CA rollover: as the last step, remove the old CA from the trust bundle, leaving only the new CA"`
+	Intermediate   string          `short:"i" long:"intermediate" description:"If set, issue certificates from this intermediate CA, created under the issuing CA if it does not exist"`
+	Bits           int             `short:"b" long:"cert-bits" description:"Bits size for new certs and CAs, for rsa keys" default:"2048"`
+	KeyType        string          `short:"k" long:"key-type" description:"Key type for new certificate and CA keys (rsa|ecdsa|ed25519)" default:"rsa" webchoice:"rsa,ecdsa,ed25519"`
+	EcdsaCurve     string          `long:"ecdsa-curve" description:"Curve for ecdsa keys (P256|P384|P521)" default:"P256" webchoice:"P256,P384,P521"`
+	Sans           string          `short:"s" long:"san" description:"Comma-separated list of additional DNS names or IPs to add to certificates; node IPs and hostnames are added automatically"`
+	CaExpiryDays   int             `short:"e" long:"ca-expiry-days" description:"Number of days a new CA certificate should be valid for" default:"3650"`
+	CertExpiryDays int             `short:"E" long:"cert-expiry-days" description:"Number of days the certificate should be valid for" default:"365"`
+	Mode           string          `short:"M" long:"mode" description:"How nodes pick up the new files: rolling=restart one node at a time, waiting for the cluster to be stable; reload=dynamic reload without restart; none=only copy the files" default:"rolling" webchoice:"rolling,reload,none"`
+	ReloadInfo     string          `long:"reload-info" description:"With --mode reload, the info command to send to each node to make it reload the TLS files; empty=rely on the server picking up the changed files"`
+	Namespace      string          `long:"namespace" description:"With --mode rolling, namespace to check stability against" default:"test"`
+	Timeout        int             `long:"timeout" description:"With --mode rolling, seconds to wait for each node to rejoin the cluster and migrations to finish" default:"600"`
+	ChDir          string          `short:"W" long:"work-dir" description:"Specify working directory, containing the CA directory created by tls generate"`
+	parallelThreadsLongCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *tlsRotateCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	err := chDir(c.ChDir)
+	if err != nil {
+		return err
+	}
+	log.Print("Running tls.rotate")
+	if c.IsClient {
+		b.WorkOnClients()
+		defer b.WorkOnServers()
+	}
+	err = c.run()
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *tlsRotateCmd) run() error {
+	keyOpts := tlsKeyOpts{keyType: c.KeyType, bits: c.Bits, curve: c.EcdsaCurve}
+	if err := keyOpts.validate(); err != nil {
+		return err
+	}
+	if !inslice.HasString([]string{"rolling", "reload", "none"}, c.Mode) {
+		return errors.New("mode must be one of: rolling, reload, none")
+	}
+	if c.IsClient && c.Mode != "none" {
+		log.Print("Client groups do not run aerospike, only copying the files (--mode none)")
+		c.Mode = "none"
+	}
+	if c.Mode == "rolling" && c.Timeout <= 0 {
+		return errors.New("timeout must be greater than 0")
+	}
+	if c.RemoveOldCa && c.NewCa == "" {
+		return errors.New("remove-old-ca requires --new-ca")
+	}
+	if c.Issuer != "" && c.NewCa != "" {
+		return errors.New("issuer cannot be used with --new-ca, as certificates are issued from the new CA")
+	}
+	if c.NewCa == c.CaName {
+		return errors.New("new-ca must be different from ca-name")
+	}
+	oldCaFile := filepath.Join("CA", c.CaName+".pem")
+	oldCaPem, err := os.ReadFile(oldCaFile)
+	if err != nil {
+		return fmt.Errorf("could not read CA %s, run tls generate first: %s", oldCaFile, err)
+	}
+	if _, err = tlsParseCerts(oldCaPem); err != nil {
+		return fmt.Errorf("%s: %s", oldCaFile, err)
+	}
+	nodes, err := c.nodeList()
+	if err != nil {
+		return err
+	}
+	ipMaps, err := tlsNodeIpMaps(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	caFileOnNode := fmt.Sprintf("/etc/aerospike/ssl/%s/%s.pem", c.TlsName, c.CaName)
+
+	if c.NewCa == "" {
+		issuer := c.CaName
+		if c.Issuer != "" {
+			issuer = c.Issuer
+		}
+		if _, err := os.Stat(filepath.Join("CA", issuer+".pem")); err != nil {
+			return fmt.Errorf("issuing CA %s not found in the CA directory", issuer)
+		}
+		ca, err := tlsLoadOrCreateCA("CA", issuer, c.Intermediate, keyOpts, c.CaExpiryDays)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rotate: issuing new certificates from %s", ca.cert.Subject.CommonName)
+		err = c.rotateCerts(nodes, ca, keyOpts, ipMaps)
+		if err != nil {
+			return err
+		}
+		return c.apply(nodes, "new certificates")
+	}
+
+	// CA rollover: trust both CAs everywhere, then move all nodes to certificates from the new CA, then optionally stop trusting the old CA
+	newCa, err := tlsLoadOrCreateCA("CA", c.NewCa, c.Intermediate, keyOpts, c.CaExpiryDays)
+	if err != nil {
+		return err
+	}
+	newCaPem, err := os.ReadFile(filepath.Join("CA", c.NewCa+".pem"))
+	if err != nil {
+		return err
+	}
+	bundle := append(append([]byte{}, oldCaPem...), newCaPem...)
+	err = os.WriteFile(filepath.Join("CA", c.CaName+"-"+c.NewCa+"-bundle.pem"), bundle, 0644)
+	if err != nil {
+		return err
+	}
+	log.Printf("Rotate: step 1: distributing trust bundle with CAs %s and %s", c.CaName, c.NewCa)
+	err = c.upload(nodes, func(node int) (map[string][]byte, error) {
+		return map[string][]byte{caFileOnNode: bundle}, nil
+	})
+	if err != nil {
+		return err
+	}
+	err = c.apply(nodes, "trust bundle")
+	if err != nil {
+		return err
+	}
+	log.Printf("Rotate: step 2: issuing new certificates from %s", newCa.cert.Subject.CommonName)
+	err = c.rotateCerts(nodes, newCa, keyOpts, ipMaps)
+	if err != nil {
+		return err
+	}
+	err = c.apply(nodes, "new certificates")
+	if err != nil {
+		return err
+	}
+	if !c.RemoveOldCa {
+		log.Printf("Rotate: nodes trust both %s and %s; once all clients trust the new CA, run again with --remove-old-ca to remove %s from the trust bundle", c.CaName, c.NewCa, c.CaName)
+		return nil
+	}
+	log.Printf("Rotate: step 3: removing CA %s from the trust bundle", c.CaName)
+	err = c.upload(nodes, func(node int) (map[string][]byte, error) {
+		return map[string][]byte{caFileOnNode: newCaPem}, nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.apply(nodes, "trust bundle without the old CA")
+}
+
+func (c *tlsRotateCmd) nodeList() ([]int, error) {
+	nodeList, err := b.NodeListInCluster(c.ClusterName.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeList) == 0 {
+		return nil, fmt.Errorf("cluster %s not found", c.ClusterName)
+	}
+	if c.Nodes == "" {
+		return nodeList, nil
+	}
+	nodes, err := expandNodeList(c.Nodes.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		if !inslice.HasInt(nodeList, node) {
+			return nil, fmt.Errorf("node %d does not exist", node)
+		}
+	}
+	return nodes, nil
+}
+
+// rotateCerts issues new node certificates from the CA and uploads them to the nodes
+func (c *tlsRotateCmd) rotateCerts(nodes []int, ca *tlsCA, keyOpts tlsKeyOpts, ipMaps []map[int]string) error {
+	sslDir := fmt.Sprintf("/etc/aerospike/ssl/%s", c.TlsName)
+	return c.upload(nodes, func(node int) (map[string][]byte, error) {
+		dir, err := tlsIssueNodeCert(ca, keyOpts, c.ClusterName.String(), c.IsClient, node, ipMaps, c.TlsName, c.Sans, c.CertExpiryDays)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := os.ReadFile(filepath.Join(dir, c.TlsName+".pem"))
+		if err != nil {
+			return nil, err
+		}
+		key, err := os.ReadFile(filepath.Join(dir, c.TlsName+".key"))
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{sslDir + "/cert.pem": cert, sslDir + "/key.pem": key}, nil
+	})
+}
+
+// upload copies the files returned by getFiles to each node, in parallel
+func (c *tlsRotateCmd) upload(nodes []int, getFiles func(node int) (map[string][]byte, error)) error {
+	returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+		files, err := getFiles(node)
+		if err != nil {
+			return err
+		}
+		return tlsUploadFiles(c.ClusterName.String(), node, files)
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("some nodes returned errors")
+	}
+	return nil
+}
+
+// apply makes the nodes pick up the new files, according to the selected mode
+func (c *tlsRotateCmd) apply(nodes []int, what string) error {
+	switch c.Mode {
+	case "none":
+		log.Printf("Rotate: %s copied; nodes will use them after the next restart", what)
+		return nil
+	case "reload":
+		if c.ReloadInfo == "" {
+			log.Printf("Rotate: %s copied, relying on the server to reload them", what)
+			return nil
+		}
+		log.Printf("Rotate: reloading %s with info command %s", what, c.ReloadInfo)
+		returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+			out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"asinfo", "-v", c.ReloadInfo}}, []int{node})
+			if err != nil {
+				if len(out) == 0 {
+					out = [][]byte{{'-'}}
+				}
+				return fmt.Errorf("%s: %s", err, string(out[0]))
+			}
+			if resp := strings.TrimSpace(string(out[0])); resp != "ok" {
+				return fmt.Errorf("info command %s returned: %s", c.ReloadInfo, resp)
+			}
+			return nil
+		})
+		isError := false
+		for i, ret := range returns {
+			if ret != nil {
+				log.Printf("Node %d returned %s", nodes[i], ret)
+				isError = true
+			}
+		}
+		if isError {
+			return errors.New("some nodes returned errors, use --mode rolling if this server version cannot reload TLS files dynamically")
+		}
+		return nil
+	}
+	// rolling restart, one node at a time, waiting for the cluster to be stable in between
+	log.Printf("Rotate: rolling restart to load %s", what)
+	err := aerospikeRollingRestart(c.ClusterName, nodes, c.Namespace, c.Timeout, c.ParallelThreads)
+	if err != nil {
+		return fmt.Errorf("rotate aborted: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+	"github.com/jedib0t/go-pretty/v6/table"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+)
+
+type tlsShowCmd struct {
+	ClusterName TypeClusterName `short:"n" long:"name" description:"Cluster name/Client group" default:"mydc"`
+	Nodes       TypeNodes       `short:"l" long:"nodes" description:"Nodes list, comma separated. Empty=ALL" default:""`
+	IsClient    bool            `short:"C" long:"client" description:"set to indicate this is a client group"`
+	Json        bool            `short:"j" long:"json" description:"Provide output in json format"`
+	parallelThreadsLongCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// tlsNodeCert is a certificate configured on a node, either in an aerospike.conf tls stanza or found in /etc/aerospike/ssl
+type tlsNodeCert struct {
+	node     int
+	tlsName  string
+	certFile string
+	keyFile  string
+	caFile   string
+	certs    []*x509.Certificate // certificate followed by the chain from cert-file
+	caCerts  []*x509.Certificate
+	keyPem   []byte
+	err      error
+}
+
+// tlsNodeConf holds the tls certificates of a node, and the tls-name references of the network contexts
+type tlsNodeConf struct {
+	node  int
+	certs []*tlsNodeCert
+	refs  map[string]string // network context => tls-name
+}
+
+func (c *tlsShowCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	if c.IsClient {
+		b.WorkOnClients()
+		defer b.WorkOnServers()
+	}
+	confs, err := tlsCollect(c.ClusterName.String(), c.Nodes.String(), c.ParallelThreads, false)
+	if err != nil {
+		return err
+	}
+	type certJson struct {
+		Node      int
+		TlsName   string
+		CertFile  string
+		Subject   string
+		SANs      []string
+		Issuer    string
+		NotBefore time.Time
+		NotAfter  time.Time
+		DaysLeft  int
+		KeyType   string
+		Serial    string
+		Chain     []string
+		Error     string `json:",omitempty"`
+	}
+	list := []certJson{}
+	for _, conf := range confs {
+		for _, cert := range conf.certs {
+			item := certJson{
+				Node:     cert.node,
+				TlsName:  cert.tlsName,
+				CertFile: cert.certFile,
+			}
+			if cert.err != nil {
+				item.Error = cert.err.Error()
+				list = append(list, item)
+				continue
+			}
+			leaf := cert.certs[0]
+			item.Subject = leaf.Subject.CommonName
+			item.SANs = tlsSans(leaf)
+			item.Issuer = leaf.Issuer.CommonName
+			item.NotBefore = leaf.NotBefore
+			item.NotAfter = leaf.NotAfter
+			item.DaysLeft = tlsDaysLeft(leaf)
+			item.KeyType = tlsKeyType(leaf.PublicKey)
+			item.Serial = fmt.Sprintf("%X", leaf.SerialNumber)
+			for _, chain := range cert.certs[1:] {
+				item.Chain = append(item.Chain, chain.Subject.CommonName)
+			}
+			list = append(list, item)
+		}
+	}
+	if c.Json {
+		return json.NewEncoder(os.Stdout).Encode(list)
+	}
+	tb := table.NewWriter()
+	tb.SetStyle(table.StyleLight)
+	tb.AppendHeader(table.Row{"Node", "TlsName", "Subject", "SANs", "Issuer", "Expires", "DaysLeft", "Key"})
+	for _, item := range list {
+		if item.Error != "" {
+			tb.AppendRow(table.Row{item.Node, item.TlsName, "ERROR: " + item.Error, "", "", "", "", ""})
+			continue
+		}
+		issuer := item.Issuer
+		if len(item.Chain) > 0 {
+			issuer = issuer + " (chain: " + strings.Join(item.Chain, ",") + ")"
+		}
+		tb.AppendRow(table.Row{item.Node, item.TlsName, item.Subject, strings.Join(item.SANs, ", "), issuer, item.NotAfter.Local().Format(time.RFC3339), item.DaysLeft, item.KeyType})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
+
+// tlsSans returns the DNS and IP Subject Alternative Names of a certificate
+func tlsSans(cert *x509.Certificate) []string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+func tlsDaysLeft(cert *x509.Certificate) int {
+	return int(time.Until(cert.NotAfter).Hours() / 24)
+}
+
+// tlsConfValue returns the first value of a key in a stanza, or empty string
+func tlsConfValue(s aeroconf.Stanza, key string) string {
+	if s == nil || s.Type(key) != aeroconf.ValueString {
+		return ""
+	}
+	vals, err := s.GetValues(key)
+	if err != nil || len(vals) == 0 || vals[0] == nil {
+		return ""
+	}
+	return *vals[0]
+}
+
+// tlsCollect reads the tls configuration and certificates of each node; tls stanzas are read from aerospike.conf, and if there are none, from /etc/aerospike/ssl/*/cert.pem
+func tlsCollect(clusterName string, nodeList string, threads int, readKeys bool) ([]*tlsNodeConf, error) {
+	allNodes, err := b.NodeListInCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if len(allNodes) == 0 {
+		return nil, fmt.Errorf("cluster %s not found", clusterName)
+	}
+	nodes, err := expandNodeList(nodeList)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		nodes = allNodes
+	}
+	for _, node := range nodes {
+		if !inslice.HasInt(allNodes, node) {
+			return nil, fmt.Errorf("node %d does not exist", node)
+		}
+	}
+	confs := []*tlsNodeConf{}
+	confsLock := new(sync.Mutex)
+	returns := parallelize.MapLimit(nodes, threads, func(node int) error {
+		conf := &tlsNodeConf{node: node, refs: make(map[string]string)}
+		readFile := func(fileName string) ([]byte, error) {
+			out, err := b.RunCommands(clusterName, [][]string{{"cat", fileName}}, []int{node})
+			if err != nil {
+				if len(out) == 0 {
+					out = [][]byte{{'-'}}
+				}
+				return nil, fmt.Errorf("could not read %s: %s", fileName, strings.TrimSpace(string(out[0])))
+			}
+			return out[0], nil
+		}
+		if asdConf, err := readFile("/etc/aerospike/aerospike.conf"); err == nil {
+			if s, err := aeroconf.Parse(bytes.NewReader(asdConf)); err == nil && s.Type("network") == aeroconf.ValueStanza {
+				network := s.Stanza("network")
+				for _, key := range network.ListKeys() {
+					if network.Type(key) != aeroconf.ValueStanza {
+						continue
+					}
+					if name, ok := strings.CutPrefix(key, "tls "); ok {
+						tlsStanza := network.Stanza(key)
+						conf.certs = append(conf.certs, &tlsNodeCert{
+							node:     node,
+							tlsName:  strings.TrimSpace(name),
+							certFile: tlsConfValue(tlsStanza, "cert-file"),
+							keyFile:  tlsConfValue(tlsStanza, "key-file"),
+							caFile:   tlsConfValue(tlsStanza, "ca-file"),
+						})
+						continue
+					}
+					if ref := tlsConfValue(network.Stanza(key), "tls-name"); ref != "" {
+						conf.refs["network."+key] = ref
+					}
+				}
+			}
+		}
+		if len(conf.certs) == 0 {
+			out, err := b.RunCommands(clusterName, [][]string{{"ls", "/etc/aerospike/ssl"}}, []int{node})
+			if err == nil {
+				for _, dir := range strings.Split(string(out[0]), "\n") {
+					dir = strings.TrimSpace(dir)
+					if dir == "" {
+						continue
+					}
+					conf.certs = append(conf.certs, &tlsNodeCert{
+						node:     node,
+						tlsName:  dir,
+						certFile: "/etc/aerospike/ssl/" + dir + "/cert.pem",
+						keyFile:  "/etc/aerospike/ssl/" + dir + "/key.pem",
+					})
+				}
+			}
+		}
+		for _, cert := range conf.certs {
+			if cert.certFile == "" {
+				cert.err = errors.New("cert-file is not configured")
+				continue
+			}
+			data, err := readFile(cert.certFile)
+			if err != nil {
+				cert.err = err
+				continue
+			}
+			cert.certs, err = tlsParseCerts(data)
+			if err != nil {
+				cert.err = fmt.Errorf("%s: %s", cert.certFile, err)
+				continue
+			}
+			if cert.caFile != "" {
+				data, err = readFile(cert.caFile)
+				if err == nil {
+					cert.caCerts, err = tlsParseCerts(data)
+				}
+				if err != nil {
+					cert.err = fmt.Errorf("ca-file %s: %s", cert.caFile, err)
+					continue
+				}
+			}
+			if readKeys && cert.keyFile != "" {
+				cert.keyPem, err = readFile(cert.keyFile)
+				if err != nil {
+					cert.err = err
+					continue
+				}
+			}
+		}
+		sort.Slice(conf.certs, func(i, j int) bool {
+			return conf.certs[i].tlsName < conf.certs[j].tlsName
+		})
+		confsLock.Lock()
+		confs = append(confs, conf)
+		confsLock.Unlock()
+		return nil
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return nil, errors.New("some nodes returned errors")
+	}
+	sort.Slice(confs, func(i, j int) bool {
+		return confs[i].node < confs[j].node
+	})
+	return confs, nil
+}
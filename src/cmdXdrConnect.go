@@ -63,9 +63,11 @@ type xdrConnectRealCmd struct {
 	Restart                 TypeYesNo      `short:"T" long:"restart-source" description:"restart source nodes after connecting (y/n)" default:"y" webchoice:"y,n"`
 	Namespaces              string         `short:"M" long:"namespaces" description:"Comma-separated list of namespaces to connect." default:"test"`
 	CustomDestinationPort   int            `short:"P" long:"destination-port" description:"Optionally specify a custom destination port for the xdr connection"`
+	WanProfile              string         `long:"wan-profile" description:"Optionally apply a named WAN profile between the source and each destination, see: net wan profiles"`
 	xDestinations           []string
 	xNamespaces             []string
 	xDestIpList             map[string][]string
+	xDestCreds              map[string]*securityCreds
 }
 
 type xdrConnectAws struct {
@@ -98,6 +100,19 @@ func (c *xdrConnectRealCmd) runXdrConnect(args []string) error {
 	if c.Restart != "n" && c.Restart != "y" {
 		return errors.New("restart-source option only accepts 'y' or 'n'")
 	}
+	if c.WanProfile != "" && a.opts.Config.Backend.Type == "aws" {
+		srcRegion := string(c.aws.SourceRegion)
+		if srcRegion == "" {
+			srcRegion = c.prevAwsRegion
+		}
+		dstRegion := string(c.aws.DestinationRegion)
+		if dstRegion == "" {
+			dstRegion = c.prevAwsRegion
+		}
+		if srcRegion != dstRegion {
+			return errors.New("wan-profile cannot be used when source and destination are in different regions")
+		}
+	}
 
 	_, err := c.aws.SourceRegion.Set(c.prevAwsRegion)
 	if err != nil {
@@ -217,6 +232,20 @@ func (c *xdrConnectRealCmd) runXdrConnect(args []string) error {
 	if isError {
 		return errors.New("some nodes returned errors")
 	}
+	// credentials stored by 'security enable' for destination clusters
+	c.xDestCreds = make(map[string]*securityCreds)
+	if !c.isConnector {
+		for _, destination := range destinations {
+			creds, err := securityLoadCreds(destination)
+			if err != nil {
+				return err
+			}
+			if creds != nil {
+				log.Printf("Using stored credentials of user %s to authenticate to %s", creds.User, destination)
+				c.xDestCreds[destination] = creds
+			}
+		}
+	}
 	//for each source node
 	c.xDestIpList = destIpList
 	c.xDestinations = destinations
@@ -233,6 +262,20 @@ func (c *xdrConnectRealCmd) runXdrConnect(args []string) error {
 		return errors.New("some nodes returned errors")
 	}
 
+	if c.WanProfile != "" {
+		for _, destination := range destinations {
+			wan := &netWanSetCmd{Profile: c.WanProfile}
+			wan.SourceClusterName = c.sourceClusterName
+			wan.DestinationClusterName = TypeClusterName(destination)
+			wan.IsDestinationClient = c.isConnector
+			wan.ParallelThreads = c.parallelLimit
+			err = wan.run()
+			if err != nil {
+				return fmt.Errorf("failed to apply WAN profile to %s: %s", destination, err)
+			}
+		}
+	}
+
 	if c.Restart == "n" {
 		log.Print("Done, Aerospike on source has NOT been restarted, changes not yet in effect")
 		return nil
@@ -337,6 +380,7 @@ func (c *xdrConnectRealCmd) doItXdrConnect(snode int) error {
 
 	//filter to find which DCs we need to add only, build DC "add string"
 	dc_to_add := ""
+	passwordFiles := []fileList{}
 	dc2namespace := make(map[string][]string)
 	for i := 0; i < len(c.xDestinations); i++ {
 		found := c.xDestinations[i]
@@ -377,6 +421,18 @@ func (c *xdrConnectRealCmd) doItXdrConnect(snode int) error {
 					}
 				}
 			}
+			if creds, ok := c.xDestCreds[found]; ok {
+				if xdrVersion == "5" {
+					authMode := "internal"
+					if creds.AuthExternal {
+						authMode = "external-insecure"
+					}
+					dc_to_add = dc_to_add + fmt.Sprintf("\t\tauth-mode %s\n\t\tauth-user %s\n\t\tauth-password-file /etc/aerospike/xdr-%s.password\n", authMode, creds.User, found)
+					passwordFiles = append(passwordFiles, fileList{"/etc/aerospike/xdr-" + found + ".password", creds.Password, len(creds.Password)})
+				} else {
+					log.Printf("WARN: destination %s has security enabled, but stored credentials can only be configured for xdr version 5", found)
+				}
+			}
 			dc_to_add = dc_to_add + "\t}\n"
 		}
 	}
@@ -447,7 +503,7 @@ func (c *xdrConnectRealCmd) doItXdrConnect(snode int) error {
 	}
 
 	finalConf := strings.Join(confsx, "\n")
-	err = b.CopyFilesToCluster(string(c.sourceClusterName), []fileList{{"/etc/aerospike/aerospike.conf", finalConf, len(finalConf)}}, []int{snode})
+	err = b.CopyFilesToCluster(string(c.sourceClusterName), append(passwordFiles, fileList{"/etc/aerospike/aerospike.conf", finalConf, len(finalConf)}), []int{snode})
 	if err != nil {
 		return fmt.Errorf("error trying to modify config file while configuring xdr: %s", err)
 	}
@@ -35,6 +35,7 @@ type helpCmd struct{}
 type commandsDefaults struct {
 	MakeConfig bool    `hidden:"true" long:"make-config" description:"Make configuration file with current parameters"`
 	DryRun     bool    `hidden:"true" long:"dry-run" description:"Do not run the command (useful with --make-config parameter)"`
+	Refresh    bool    `long:"refresh" description:"Ignore the local state cache and refresh it from the backend (see: config backend --cache-ttl)" no-ini:"true"`
 	Help       helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
@@ -352,6 +353,12 @@ func earlyProcessV2(tail []string, initBackend bool) (early bool) {
 		if err != nil {
 			logExit("Could not init backend: %s", err)
 		}
+		if a.opts.Config.Backend.CacheTTL > 0 {
+			b, err = newBackendCache(b)
+			if err != nil {
+				logExit("Could not init state cache: %s", err)
+			}
+		}
 	}
 	if b != nil {
 		b.WorkOnServers()
@@ -0,0 +1,77 @@
+#!/bin/bash
+# aerolab LDAP server: installs OpenLDAP (slapd), optionally enables ldaps using the certificates in /etc/ldap/certs, and loads the seed users and groups from /tmp/ldap-seed.ldif
+LDAP_DOMAIN="%s"
+LDAP_BASE_DN="%s"
+LDAP_ADMIN_PASSWORD='%s'
+LDAP_TLS=%s
+set -e
+which apt-get >/dev/null 2>&1 || { echo "the LDAP server can only be installed on ubuntu or debian" >&2; exit 1; }
+export DEBIAN_FRONTEND=noninteractive
+
+cat <<EOF | debconf-set-selections
+slapd slapd/internal/adminpw password ${LDAP_ADMIN_PASSWORD}
+slapd slapd/internal/generated_adminpw password ${LDAP_ADMIN_PASSWORD}
+slapd slapd/password1 password ${LDAP_ADMIN_PASSWORD}
+slapd slapd/password2 password ${LDAP_ADMIN_PASSWORD}
+slapd slapd/domain string ${LDAP_DOMAIN}
+slapd shared/organization string aerolab
+slapd slapd/no_configuration boolean false
+slapd slapd/purge_database boolean true
+slapd slapd/move_old_database boolean true
+EOF
+apt-get update
+apt-get -y install slapd ldap-utils
+dpkg-reconfigure -f noninteractive slapd
+
+SERVICES="ldap:/// ldapi:///"
+[ "${LDAP_TLS}" = "true" ] && SERVICES="ldap:/// ldapi:/// ldaps:///"
+sed -i "s#^SLAPD_SERVICES=.*#SLAPD_SERVICES=\"${SERVICES}\"#" /etc/default/slapd
+
+# docker containers do not run systemd, so slapd is started from /opt/autoload there
+mkdir -p /opt/autoload
+cat <<EOF > /opt/autoload/01-slapd
+service slapd status >/dev/null 2>&1 || service slapd start || slapd -h "${SERVICES}" -u openldap -g openldap
+EOF
+chmod 755 /opt/autoload/01-slapd
+service slapd restart || (pkill slapd; sleep 1; slapd -h "${SERVICES}" -u openldap -g openldap)
+SLAPD_UP=0
+for i in $(seq 1 30); do
+    ldapsearch -x -H ldapi:/// -s base -b "" namingContexts >/dev/null 2>&1 && SLAPD_UP=1 && break
+    sleep 1
+done
+if [ ${SLAPD_UP} -ne 1 ]; then
+    echo "slapd did not start, ldapsearch on ldapi:/// failed after 30 attempts" >&2
+    exit 1
+fi
+
+if [ "${LDAP_TLS}" = "true" ]; then
+    chown -R openldap:openldap /etc/ldap/certs
+    chmod 640 /etc/ldap/certs/ldap.key
+    cat <<EOF | ldapmodify -Y EXTERNAL -H ldapi:///
+dn: cn=config
+changetype: modify
+replace: olcTLSCACertificateFile
+olcTLSCACertificateFile: /etc/ldap/certs/ca.pem
+-
+replace: olcTLSCertificateFile
+olcTLSCertificateFile: /etc/ldap/certs/ldap.pem
+-
+replace: olcTLSCertificateKeyFile
+olcTLSCertificateKeyFile: /etc/ldap/certs/ldap.key
+EOF
+fi
+
+# entries which already exist are skipped, so that the seed can be reloaded
+ldapadd -x -c -H ldapi:/// -D "cn=admin,${LDAP_BASE_DN}" -w "${LDAP_ADMIN_PASSWORD}" -f /tmp/ldap-seed.ldif || [ $? -eq 68 ]
+rm -f /tmp/ldap-seed.ldif
+
+# used by 'aerolab conf ldap'
+cat <<EOF > /etc/ldap/aerolab.conf
+LDAP_BASE_DN="${LDAP_BASE_DN}"
+LDAP_ADMIN_PASSWORD='${LDAP_ADMIN_PASSWORD}'
+LDAP_TLS=${LDAP_TLS}
+EOF
+chmod 600 /etc/ldap/aerolab.conf
+
+echo "Seeded users:"
+ldapsearch -x -LLL -H ldapi:/// -b "ou=People,${LDAP_BASE_DN}" "(objectClass=posixAccount)" uid | sed -n 's/^uid: /  /p'
@@ -0,0 +1,71 @@
+#!/bin/bash
+# aerolab WAN emulation: an htb root qdisc (handle 1a1a:) per interface, with one class, netem qdisc and u32 filter per destination IP
+# state is kept in /etc/aerolab-wan.state, one line per destination: IP INTERFACE CLASSID PROFILE
+set -e
+STATE=/etc/aerolab-wan.state
+touch ${STATE}
+which tc >/dev/null 2>&1 || { echo "tc command not found, install iproute2" >&2; exit 1; }
+
+wan_if() {
+    ip -o route get "$1" | sed -n 's/.* dev \([^ ]*\).*/\1/p'
+}
+
+wan_root() {
+    local IF=$1
+    local ROOT=$(tc qdisc show dev ${IF} root)
+    if echo "${ROOT}" | grep -q "htb 1a1a:"; then
+        return 0
+    fi
+    if echo "${ROOT}" | grep -qE "qdisc (htb|prio|netem|tbf|hfsc|cbq) "; then
+        echo "interface ${IF} already has traffic shaping rules not created by WAN profiles, remove them first (net loss-delay -a delall)" >&2
+        return 1
+    fi
+    tc qdisc replace dev ${IF} root handle 1a1a: htb default 1
+    tc class add dev ${IF} parent 1a1a: classid 1a1a:1 htb rate 10gbit quantum 60000
+}
+
+wan_del() {
+    local LINE=$(grep "^$1 " ${STATE} || true)
+    [ -z "${LINE}" ] && return 0
+    local IP IF ID PROFILE
+    read IP IF ID PROFILE <<< "${LINE}"
+    local HEX=$(printf '%x' ${ID})
+    tc filter del dev ${IF} parent 1a1a: protocol ip prio ${ID} 2>/dev/null || true
+    tc qdisc del dev ${IF} parent 1a1a:${HEX} handle ${HEX}: 2>/dev/null || true
+    tc class del dev ${IF} classid 1a1a:${HEX} 2>/dev/null || true
+    grep -v "^${IP} " ${STATE} > ${STATE}.tmp || true
+    mv ${STATE}.tmp ${STATE}
+    if ! grep -q " ${IF} " ${STATE}; then
+        tc qdisc del dev ${IF} root 2>/dev/null || true
+    fi
+}
+
+wan_set() {
+    local IP=$1
+    local PROFILE=$2
+    shift 2
+    wan_del ${IP}
+    local IF=$(wan_if ${IP})
+    [ -z "${IF}" ] && { echo "no route to ${IP}" >&2; return 1; }
+    wan_root ${IF}
+    local ID=$(awk 'BEGIN{m=1} {if ($3>m) m=$3} END{print m+1}' ${STATE})
+    local HEX=$(printf '%x' ${ID})
+    tc class add dev ${IF} parent 1a1a: classid 1a1a:${HEX} htb rate 10gbit quantum 60000
+    tc qdisc add dev ${IF} parent 1a1a:${HEX} handle ${HEX}: netem "$@"
+    tc filter add dev ${IF} parent 1a1a: protocol ip prio ${ID} u32 match ip dst ${IP}/32 flowid 1a1a:${HEX}
+    echo "${IP} ${IF} ${ID} ${PROFILE}" >> ${STATE}
+}
+
+wan_show() {
+    while read IP IF ID PROFILE; do
+        [ -z "${IP}" ] && continue
+        local HEX=$(printf '%x' ${ID})
+        echo "${IP} ${PROFILE} $(tc qdisc show dev ${IF} parent 1a1a:${HEX} | sed 's/.* limit /limit /')"
+    done < ${STATE}
+}
+
+wan_delall() {
+    for IP in $(awk '{print $1}' ${STATE}); do
+        wan_del ${IP}
+    done
+}
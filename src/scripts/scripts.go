@@ -3,6 +3,7 @@ package scripts
 import (
 	_ "embed"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -24,6 +25,12 @@ var eksctlBootstrap string
 //go:embed netLossDelay.sh
 var netLossDelay string
 
+//go:embed netWan.sh
+var netWan string
+
+//go:embed ldap-install.sh
+var ldapInstall string
+
 // docker login details
 type DockerLogin struct {
 	URL  string
@@ -83,3 +90,12 @@ func GetEksctlBootstrapScript() []byte {
 func GetNetLossDelay() string {
 	return netLossDelay
 }
+
+func GetNetWan() string {
+	return netWan
+}
+
+// domain: ex aerospike.com; baseDN: ex dc=aerospike,dc=com
+func GetLdapInstallScript(domain string, baseDN string, adminPassword string, tls bool) []byte {
+	return []byte(fmt.Sprintf(ldapInstall, domain, baseDN, adminPassword, strconv.FormatBool(tls)))
+}